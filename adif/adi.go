@@ -36,30 +36,83 @@ func NewADIIO() *ADIIO {
 
 func (_ *ADIIO) String() string { return "adi" }
 
-func (o *ADIIO) Read(in io.Reader) (*Logfile, error) {
-	var comments []string
-	l := NewLogfile()
-	r := bufio.NewReader(in)
-	s, err := r.ReadString('<')
+func (o *ADIIO) Read(in io.Reader) (*Logfile, error) { return ReadAll(o, in) }
+
+func (o *ADIIO) ReadRecords(in io.Reader, h StreamHandler) (RecordIterator, error) {
+	it := &adiRecords{o: o, r: bufio.NewReader(in), h: h, cur: NewRecord(), line: 1}
+	s, err := it.r.ReadString('<')
+	it.consumed(s)
 	if errors.Is(err, io.EOF) {
-		if s != "" {
-			l.Comment = s
+		if s != "" && h.Comment != nil {
+			h.Comment(s)
 		}
-		return l, nil
+		it.done = true
+		return it, nil
 	}
 	if err != nil {
 		return nil, fmt.Errorf("error reading to first tag: %w", err)
 	}
 	// final byte is '<'
-	comments = append(comments, s[0:len(s)-1])
+	it.comments = append(it.comments, s[0:len(s)-1])
+	// read ahead to the first record so the header has been processed
+	it.next, err = it.read()
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	return it, nil
+}
+
+type adiRecords struct {
+	o                          *ADIIO
+	r                          *bufio.Reader
+	h                          StreamHandler
+	cur, next                  *Record
+	comments                   []string
+	sawHeader, sawRecord, done bool
+	// line and offset count input consumed so far, curPos is where cur starts,
+	// and pos is where the most recently read record starts
+	line       int
+	offset     int64
+	curPos     Position
+	curStarted bool
+	pos        Position
+}
+
+// Position returns the position of the record most recently returned by Next.
+// Since the first record is read ahead, this is also the position after
+// ReadRecords returns.
+func (it *adiRecords) Position() Position { return it.pos }
+
+func (it *adiRecords) consumed(s string) {
+	it.offset += int64(len(s))
+	it.line += strings.Count(s, "\n")
+}
+
+func (it *adiRecords) Next() (*Record, error) {
+	if it.next != nil {
+		r := it.next
+		it.next = nil
+		return r, nil
+	}
+	return it.read()
+}
+
+func (it *adiRecords) read() (*Record, error) {
+	if it.done {
+		return nil, io.EOF
+	}
 	// ADIF specification seems to imply that without a comment at the start
 	// of a file, and thus the first character is '<', then there is no header
 	// and the < starts the first record.  This invariant may not hold for all
 	// software though, so allow an <EOH> even if we didn't get a comment.
-	cur := NewRecord()
-	var sawHeader, sawRecord bool
 	for { // invariant: last byte read was '<'
-		s, err = r.ReadString('>')
+		var rec *Record
+		if !it.curStarted {
+			it.curPos = Position{Line: it.line, Offset: it.offset - 1}
+			it.curStarted = true
+		}
+		s, err := it.r.ReadString('>')
+		it.consumed(s)
 		if errors.Is(err, io.EOF) {
 			return nil, fmt.Errorf("unfinished ADI tag at end: %q", s)
 		}
@@ -74,23 +127,30 @@ func (o *ADIIO) Read(in io.Reader) (*Logfile, error) {
 		case 1:
 			switch strings.ToUpper(tag[0]) {
 			case "EOH":
-				if sawHeader {
+				if it.sawHeader {
 					return nil, fmt.Errorf("invalid ADI file with two <EOH> headers")
 				}
-				if sawRecord {
+				if it.sawRecord {
 					return nil, fmt.Errorf("invalid ADI file with <EOH> header after first <EOR> record")
 				}
-				sawHeader = true
-				cur.SetComment(strings.Join(comments, o.RecordSep.Val()))
-				l.Header = cur
-				cur = NewRecord()
-				comments = nil
+				it.sawHeader = true
+				it.cur.SetComment(strings.Join(it.comments, it.o.RecordSep.Val()))
+				if it.h.Header != nil {
+					if err := it.h.Header(it.cur); err != nil {
+						return nil, err
+					}
+				}
+				it.cur = NewRecord()
+				it.curStarted = false
+				it.comments = nil
 			case "EOR":
-				sawRecord = true
-				cur.SetComment(strings.Join(comments, o.RecordSep.Val()))
-				l.AddRecord(cur)
-				cur = NewRecord()
-				comments = nil
+				it.sawRecord = true
+				it.cur.SetComment(strings.Join(it.comments, it.o.RecordSep.Val()))
+				rec = it.cur
+				it.pos = it.curPos
+				it.cur = NewRecord()
+				it.curStarted = false
+				it.comments = nil
 			default:
 				return nil, fmt.Errorf("invalid ADI field without length <%s", s)
 			}
@@ -100,9 +160,10 @@ func (o *ADIIO) Read(in io.Reader) (*Logfile, error) {
 				return nil, fmt.Errorf("invalid ADI field length <%s", s)
 			}
 			v := make([]byte, length)
-			if _, err = io.ReadFull(r, v); err != nil {
+			if _, err = io.ReadFull(it.r, v); err != nil {
 				return nil, fmt.Errorf("error reading ADI field value <%s got %q: %w", s, v, err)
 			}
+			it.consumed(string(v))
 			if strings.HasPrefix(strings.ToUpper(tag[0]), "USERDEF") {
 				if len(tag) != 3 {
 					return nil, fmt.Errorf("missing type for %s field %q", tag[0], v)
@@ -125,7 +186,10 @@ func (o *ADIIO) Read(in io.Reader) (*Logfile, error) {
 						u.EnumValues = strings.Split(extra[1:len(extra)-1], ",")
 					}
 				}
-				l.AddUserdef(u)
+				if it.h.Userdef != nil {
+					// a conflicting definition of the same field is ignored
+					it.h.Userdef(u)
+				}
 			} else {
 				// spec says everything is ASCII, but this accepts UTF-8
 				// as long as the tag length is accurate in bytes
@@ -136,29 +200,37 @@ func (o *ADIIO) Read(in io.Reader) (*Logfile, error) {
 						return nil, fmt.Errorf("%v from <%s", err, s)
 					}
 				}
-				cur.Set(f)
+				it.cur.Set(f)
 			}
 		default:
 			return nil, fmt.Errorf("invalid ADI tag format <%s", s)
 		}
 		// arbitrary text between one field or record and the next
-		c, err := r.ReadString('<')
+		c, err := it.r.ReadString('<')
+		it.consumed(c)
 		c = strings.TrimFunc(strings.TrimSuffix(c, "<"), unicode.IsSpace)
 		if c != "" {
-			comments = append(comments, c)
+			it.comments = append(it.comments, c)
 		}
 		if errors.Is(err, io.EOF) {
-			if len(cur.fields) != 0 {
-				return nil, fmt.Errorf("final record missing <EOR>: %s", cur)
+			if len(it.cur.fields) != 0 {
+				return nil, fmt.Errorf("final record missing <EOR>: %s", it.cur)
+			}
+			if len(it.comments) > 0 && it.h.Comment != nil {
+				it.h.Comment(strings.Join(it.comments, it.o.RecordSep.Val()))
 			}
-			if len(comments) > 0 {
-				l.Comment = strings.Join(comments, o.RecordSep.Val())
+			it.done = true
+			if rec != nil {
+				return rec, nil
 			}
-			return l, nil
+			return nil, io.EOF
 		}
 		if err != nil {
 			return nil, fmt.Errorf("error reading ADI intra-field text: %w", err)
 		}
+		if rec != nil {
+			return rec, nil
+		}
 	}
 }
 
@@ -168,6 +240,22 @@ func (o *ADIIO) Write(l *Logfile, out io.Writer) error {
 	if err := o.validate(l); err != nil {
 		return err
 	}
+	w, err := o.WriteHeader(l, out)
+	if err != nil {
+		return err
+	}
+	for _, r := range l.Records {
+		if err := w.WriteRecord(r); err != nil {
+			return err
+		}
+	}
+	return w.Close()
+}
+
+func (o *ADIIO) WriteHeader(l *Logfile, out io.Writer) (RecordWriter, error) {
+	if err := o.validateRecord(l.Header); err != nil {
+		return nil, err
+	}
 	b := bufio.NewWriter(out)
 	if !l.Header.Empty() {
 		c := l.Header.GetComment()
@@ -175,64 +263,80 @@ func (o *ADIIO) Write(l *Logfile, out io.Writer) error {
 			c = defaultAdiComment
 		}
 		if err := o.writeComment(b, c, o.RecordSep.Val()); err != nil {
-			return fmt.Errorf("writing ADI header: %w", err)
+			return nil, fmt.Errorf("writing ADI header: %w", err)
 		}
 		for _, f := range l.Header.Fields() {
 			if err := o.writeField(f, b); err != nil {
-				return fmt.Errorf("writing ADI header: %w", err)
+				return nil, fmt.Errorf("writing ADI header: %w", err)
 			}
 		}
 		if err := o.writeUserdef(l.Userdef, b); err != nil {
-			return fmt.Errorf("writing ADI header: %w", err)
+			return nil, fmt.Errorf("writing ADI header: %w", err)
 		}
 		if _, err := b.WriteString(fmt.Sprintf("<%s>%s", o.fixCase("EOH"), o.RecordSep.Val())); err != nil {
-			return fmt.Errorf("writing ADI header: %w", err)
+			return nil, fmt.Errorf("writing ADI header: %w", err)
 		}
 	} else if len(l.Userdef) > 0 { // add a header for the userdef fields
 		if err := o.writeComment(b, defaultAdiComment, o.RecordSep.Val()); err != nil {
-			return fmt.Errorf("writing ADI header: %w", err)
+			return nil, fmt.Errorf("writing ADI header: %w", err)
 		}
 		if err := o.writeUserdef(l.Userdef, b); err != nil {
-			return fmt.Errorf("writing ADI header: %w", err)
+			return nil, fmt.Errorf("writing ADI header: %w", err)
 		}
 		if _, err := b.WriteString(fmt.Sprintf("<%s>%s", o.fixCase("EOH"), o.RecordSep.Val())); err != nil {
-			return fmt.Errorf("writing ADI header: %w", err)
+			return nil, fmt.Errorf("writing ADI header: %w", err)
 		}
 	}
+	return &adiRecordWriter{o: o, l: l, b: b}, nil
+}
 
-	for i, r := range l.Records {
-		if c := r.GetComment(); c != "" {
-			if err := o.writeComment(b, c, o.FieldSep.Val()); err != nil {
-				return fmt.Errorf("writing ADI record comment: %w", err)
-			}
+type adiRecordWriter struct {
+	o *ADIIO
+	l *Logfile
+	b *bufio.Writer
+	i int
+}
+
+func (w *adiRecordWriter) WriteRecord(r *Record) error {
+	o, b, i := w.o, w.b, w.i
+	w.i++
+	if err := o.validateRecord(r); err != nil {
+		return err
+	}
+	if c := r.GetComment(); c != "" {
+		if err := o.writeComment(b, c, o.FieldSep.Val()); err != nil {
+			return fmt.Errorf("writing ADI record comment: %w", err)
 		}
-		seen := make(map[string]bool)
-		for _, n := range l.FieldOrder {
-			if f, ok := r.Get(n); ok {
-				if err := o.writeField(f, b); err != nil {
-					return fmt.Errorf("writing ADI record #%d: %w", i, err)
-				}
-				seen[f.Name] = true
+	}
+	seen := make(map[string]bool)
+	for _, n := range w.l.FieldOrder {
+		if f, ok := r.Get(n); ok {
+			if err := o.writeField(f, b); err != nil {
+				return fmt.Errorf("writing ADI record #%d: %w", i, err)
 			}
+			seen[f.Name] = true
 		}
-		for _, f := range r.Fields() {
-			if !seen[f.Name] {
-				if err := o.writeField(f, b); err != nil {
-					return fmt.Errorf("writing ADI record #%d: %w", i, err)
-				}
+	}
+	for _, f := range r.Fields() {
+		if !seen[f.Name] {
+			if err := o.writeField(f, b); err != nil {
+				return fmt.Errorf("writing ADI record #%d: %w", i, err)
 			}
 		}
-		if _, err := b.WriteString(fmt.Sprintf("<%s>%s", o.fixCase("EOR"), o.RecordSep.Val())); err != nil {
-			return fmt.Errorf("writing ADI record #%d: %w", i, err)
-		}
 	}
+	if _, err := b.WriteString(fmt.Sprintf("<%s>%s", o.fixCase("EOR"), o.RecordSep.Val())); err != nil {
+		return fmt.Errorf("writing ADI record #%d: %w", i, err)
+	}
+	return nil
+}
 
-	if l.Comment != "" {
-		if err := o.writeComment(b, l.Comment, "\n"); err != nil {
+func (w *adiRecordWriter) Close() error {
+	if w.l.Comment != "" {
+		if err := w.o.writeComment(w.b, w.l.Comment, "\n"); err != nil {
 			return fmt.Errorf("writing ADI comment: %w", err)
 		}
 	}
-	return b.Flush()
+	return w.b.Flush()
 }
 
 func (o *ADIIO) writeField(f Field, b *bufio.Writer) error {
@@ -290,29 +394,30 @@ func (o *ADIIO) fixCase(s string) string {
 }
 
 func (o *ADIIO) validate(l *Logfile) error {
-	check := func(r *Record) error {
-		if r == nil { // in case l.Header is nil
-			return nil
-		}
-		for _, f := range r.Fields() {
-			if o.ASCIIOnly {
-				for _, r := range f.Value {
-					// spec limits Character to ASCII 32 to 126; MultilineString also allows CR/LF
-					if (r < 32 || r > 126) && (r != '\r' && r != '\n') {
-						return fmt.Errorf("non-ASCII character in %v", f)
-					}
-				}
-			}
-		}
-		return nil
-	}
-	if err := check(l.Header); err != nil {
+	if err := o.validateRecord(l.Header); err != nil {
 		return err
 	}
 	for _, r := range l.Records {
-		if err := check(r); err != nil {
+		if err := o.validateRecord(r); err != nil {
 			return err
 		}
 	}
 	return nil
 }
+
+func (o *ADIIO) validateRecord(r *Record) error {
+	if r == nil { // in case l.Header is nil
+		return nil
+	}
+	for _, f := range r.Fields() {
+		if o.ASCIIOnly {
+			for _, r := range f.Value {
+				// spec limits Character to ASCII 32 to 126; MultilineString also allows CR/LF
+				if (r < 32 || r > 126) && (r != '\r' && r != '\n') {
+					return fmt.Errorf("non-ASCII character in %v", f)
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -15,6 +15,7 @@
 package adif
 
 import (
+	"io"
 	"strings"
 	"testing"
 
@@ -213,3 +214,110 @@ func TestADIASCIIOnly(t *testing.T) {
 		}
 	}
 }
+
+func TestADIReadRecords(t *testing.T) {
+	input := `Header comment <PROGRAMID:8>adi_test <USERDEF1:5:S>MY_ID <EOH>
+<CALL:4>W1AW <EOR>
+<CALL:3>N0P <MY_ID:2>xy <EOR>
+Final comment`
+	var header *Record
+	var userdefs []UserdefField
+	var comment string
+	h := StreamHandler{
+		Header:  func(r *Record) error { header = r; return nil },
+		Userdef: func(u UserdefField) error { userdefs = append(userdefs, u); return nil },
+		Comment: func(c string) { comment = c },
+	}
+	it, err := NewADIIO().ReadRecords(strings.NewReader(input), h)
+	if err != nil {
+		t.Fatalf("ReadRecords(%q) got error %v", input, err)
+	}
+	wantHeader := NewRecord(Field{Name: "PROGRAMID", Value: "adi_test"})
+	wantHeader.SetComment("Header comment")
+	if diff := cmp.Diff(wantHeader, header); diff != "" {
+		t.Errorf("ReadRecords(%q) header diff before first record:\n%s", input, diff)
+	}
+	if diff := cmp.Diff([]UserdefField{{Name: "MY_ID", Type: TypeString}}, userdefs); diff != "" {
+		t.Errorf("ReadRecords(%q) userdef diff before first record:\n%s", input, diff)
+	}
+	want := []*Record{
+		NewRecord(Field{Name: "CALL", Value: "W1AW"}),
+		NewRecord(Field{Name: "CALL", Value: "N0P"}, Field{Name: "MY_ID", Value: "xy"}),
+	}
+	for i, w := range want {
+		r, err := it.Next()
+		if err != nil {
+			t.Fatalf("Next() record %d got error %v", i+1, err)
+		}
+		if diff := cmp.Diff(w, r); diff != "" {
+			t.Errorf("Next() record %d diff:\n%s", i+1, diff)
+		}
+		if i < len(want)-1 && comment != "" {
+			t.Errorf("Comment callback called before end of input with %q", comment)
+		}
+	}
+	if r, err := it.Next(); err != io.EOF {
+		t.Errorf("Next() after last record got %v, %v; want io.EOF", r, err)
+	}
+	if comment != "Final comment" {
+		t.Errorf("got comment %q, want %q", comment, "Final comment")
+	}
+}
+
+func TestADIConflictingUserdef(t *testing.T) {
+	input := "<USERDEF1:5:N>MYFLD <USERDEF2:5:S>MYFLD <EOH>\n<CALL:4>W1AW <MYFLD:2>42 <EOR>\n"
+	l, err := NewADIIO().Read(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Read(%q) got error %v", input, err)
+	}
+	if diff := cmp.Diff([]UserdefField{{Name: "MYFLD", Type: TypeNumber}}, l.Userdef); diff != "" {
+		t.Errorf("Read(%q) userdef diff:\n%s", input, diff)
+	}
+	if len(l.Records) != 1 {
+		t.Errorf("Read(%q) got %d records, want 1", input, len(l.Records))
+	}
+}
+
+func TestADIWriteHeader(t *testing.T) {
+	l := NewLogfile()
+	l.Header.Set(Field{Name: "PROGRAMID", Value: "adi_test"})
+	out := &strings.Builder{}
+	w, err := NewADIIO().WriteHeader(l, out)
+	if err != nil {
+		t.Fatalf("WriteHeader got error %v", err)
+	}
+	// field order and comment can change while writing
+	l.FieldOrder = []string{"BAND", "CALL"}
+	if err := w.WriteRecord(NewRecord(Field{Name: "CALL", Value: "W1AW"}, Field{Name: "BAND", Value: "40m"})); err != nil {
+		t.Fatalf("WriteRecord got error %v", err)
+	}
+	l.Comment = "The end"
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close got error %v", err)
+	}
+	want := `ADI format, see https://adif.org.uk/
+<PROGRAMID:8>adi_test <EOH>
+<BAND:3>40m <CALL:4>W1AW <EOR>
+The end
+`
+	if diff := cmp.Diff(want, out.String()); diff != "" {
+		t.Errorf("streaming write had diff with expected:\n%s", diff)
+	}
+}
+
+func TestADIPosition(t *testing.T) {
+	input := "Header <EOH>\n<CALL:4>W1AW <EOR>\nComment\n<CALL:4>\nN0P\n<EOR>\n<CALL:3>K0A<EOR>"
+	it, err := NewADIIO().ReadRecords(strings.NewReader(input), StreamHandler{})
+	if err != nil {
+		t.Fatalf("ReadRecords(%q) got error %v", input, err)
+	}
+	want := []Position{{Line: 2, Offset: 13}, {Line: 4, Offset: 40}, {Line: 7, Offset: 59}}
+	for i, w := range want {
+		if _, err := it.Next(); err != nil {
+			t.Fatalf("Next() record %d got error %v", i+1, err)
+		}
+		if got := it.(PositionedIterator).Position(); got != w {
+			t.Errorf("Position() record %d got %v, want %v", i+1, got, w)
+		}
+	}
+}
@@ -0,0 +1,524 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adif
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CabrilloIO reads and writes contest logs in the Cabrillo 3.0 format,
+// specified at https://wwrof.org/cabrillo/
+//
+// Cabrillo header tags are stored in the Logfile header as application-defined
+// fields, e.g. CATEGORY-OPERATOR becomes APP_CABRILLO_CATEGORY_OPERATOR, so
+// they survive a round trip through ADI or ADX.  Tags which appear more than
+// once, like ADDRESS and SOAPBOX, are joined with newlines.  QSO lines are
+// converted to records with FREQ (or BAND for VHF and higher designations),
+// MODE, QSO_DATE, TIME_ON, STATION_CALLSIGN, and CALL fields.  The DG
+// (digital) mode does not say which ADIF mode was used, so it is stored in
+// APP_CABRILLO_MODE and MODE is left unset.  Exchange
+// fields are read as RST_SENT/RST_RCVD if they look like a signal report
+// followed by STX/SRX for numeric exchanges or STX_STRING/SRX_STRING otherwise,
+// unless Templates has an entry for the log's contest.
+type CabrilloIO struct {
+	CRLF bool
+	// Templates maps upper-case Cabrillo contest names and ADIF CONTEST_ID
+	// values to the exchange layout of QSO lines for that contest.
+	Templates map[string]CabrilloTemplate
+}
+
+// CabrilloTemplate describes the exchange columns of QSO lines for a contest.
+// MyExchange and TheirExchange list the ADIF field for each column following
+// the sending and receiving station's callsign, respectively.  Consecutive
+// columns with the same field name are joined with spaces when reading and
+// split on whitespace when writing, so ARRL Sweepstakes precedence and check
+// can be sent as a single STX_STRING field.
+type CabrilloTemplate struct {
+	// CabrilloName is the CONTEST header value, e.g. ARRL-FD.
+	CabrilloName string
+	// ContestID is the ADIF CONTEST_ID value, e.g. ARRL-FIELD-DAY.
+	ContestID                 string
+	MyExchange, TheirExchange []string
+}
+
+func (t CabrilloTemplate) String() string {
+	return fmt.Sprintf("%s %s %s %s", t.CabrilloName,
+		strings.Join(t.MyExchange, ","), strings.Join(t.TheirExchange, ","), t.ContestID)
+}
+
+func (o *CabrilloIO) template(contest string) (CabrilloTemplate, bool) {
+	if contest == "" {
+		return CabrilloTemplate{}, false
+	}
+	t, ok := o.Templates[strings.ToUpper(contest)]
+	return t, ok
+}
+
+func NewCabrilloIO() *CabrilloIO { return &CabrilloIO{} }
+
+func (_ *CabrilloIO) String() string { return "cabrillo" }
+
+// CabrilloAppPrefix is added to Cabrillo header tags to make ADIF header
+// field names.
+const CabrilloAppPrefix = "APP_CABRILLO_"
+
+var cabrilloBands = []struct {
+	band, designation string
+	lower, upper      float64 // MHz
+}{
+	{band: "160m", designation: "1800", lower: 1.8, upper: 2.0},
+	{band: "80m", designation: "3500", lower: 3.5, upper: 4.0},
+	{band: "40m", designation: "7000", lower: 7.0, upper: 7.3},
+	{band: "30m", designation: "10100", lower: 10.1, upper: 10.15},
+	{band: "20m", designation: "14000", lower: 14.0, upper: 14.35},
+	{band: "17m", designation: "18068", lower: 18.068, upper: 18.168},
+	{band: "15m", designation: "21000", lower: 21.0, upper: 21.45},
+	{band: "12m", designation: "24890", lower: 24.89, upper: 24.99},
+	{band: "10m", designation: "28000", lower: 28.0, upper: 29.7},
+	{band: "6m", designation: "50", lower: 50, upper: 54},
+	{band: "4m", designation: "70", lower: 70, upper: 71},
+	{band: "2m", designation: "144", lower: 144, upper: 148},
+	{band: "1.25m", designation: "222", lower: 222, upper: 225},
+	{band: "70cm", designation: "432", lower: 420, upper: 450},
+	{band: "33cm", designation: "902", lower: 902, upper: 928},
+	{band: "23cm", designation: "1.2G", lower: 1240, upper: 1300},
+	{band: "13cm", designation: "2.3G", lower: 2300, upper: 2450},
+	{band: "9cm", designation: "3.4G", lower: 3300, upper: 3500},
+	{band: "6cm", designation: "5.7G", lower: 5650, upper: 5925},
+	{band: "3cm", designation: "10G", lower: 10000, upper: 10500},
+	{band: "1.25cm", designation: "24G", lower: 24000, upper: 24250},
+	{band: "6mm", designation: "47G", lower: 47000, upper: 47200},
+	{band: "4mm", designation: "75G", lower: 75500, upper: 81000},
+	{band: "2.5mm", designation: "122G", lower: 119980, upper: 123000},
+	{band: "2mm", designation: "134G", lower: 134000, upper: 149000},
+	{band: "1mm", designation: "241G", lower: 241000, upper: 250000},
+	{band: "submm", designation: "LIGHT", lower: 300000, upper: 7500000},
+}
+
+// Cabrillo modes are CW, PH (phone), FM, RY (RTTY), and DG (digital).  DG and
+// unknown modes are not read as an ADIF MODE.
+var cabrilloModesRead = map[string]string{"CW": "CW", "PH": "SSB", "FM": "FM", "RY": "RTTY"}
+
+func cabrilloMode(mode string) string {
+	switch strings.ToUpper(mode) {
+	case "CW":
+		return "CW"
+	case "SSB", "AM", "DIGITALVOICE", "PH":
+		return "PH"
+	case "FM":
+		return "FM"
+	case "RTTY", "RY":
+		return "RY"
+	default:
+		return "DG"
+	}
+}
+
+func (o *CabrilloIO) Read(in io.Reader) (*Logfile, error) {
+	l := NewLogfile()
+	scan := bufio.NewScanner(in)
+	var started, ended bool
+	line := 0
+	for scan.Scan() {
+		line++
+		text := strings.TrimSpace(scan.Text())
+		if text == "" || ended {
+			continue
+		}
+		tag, val, ok := strings.Cut(text, ":")
+		if !ok {
+			return nil, fmt.Errorf("Cabrillo line %d missing tag: %q", line, text)
+		}
+		tag = strings.ToUpper(strings.TrimSpace(tag))
+		val = strings.TrimSpace(val)
+		if !started && tag != "START-OF-LOG" {
+			return nil, fmt.Errorf("Cabrillo line %d: expected START-OF-LOG, got %s", line, tag)
+		}
+		switch tag {
+		case "START-OF-LOG":
+			if started {
+				return nil, fmt.Errorf("Cabrillo line %d: duplicate START-OF-LOG", line)
+			}
+			started = true
+		case "END-OF-LOG":
+			ended = true
+		case "QSO":
+			contest, _ := l.Header.Get(CabrilloAppPrefix + "CONTEST")
+			r, err := o.readQSO(val, contest.Value)
+			if err != nil {
+				return nil, fmt.Errorf("Cabrillo line %d: %w", line, err)
+			}
+			l.AddRecord(r)
+		case "X-QSO":
+			// QSOs which should not be scored are not included
+		default:
+			name := CabrilloAppPrefix + strings.ReplaceAll(tag, "-", "_")
+			if f, ok := l.Header.Get(name); ok {
+				val = f.Value + "\n" + val
+			}
+			if err := l.Header.Set(Field{Name: name, Value: val}); err != nil {
+				return nil, fmt.Errorf("Cabrillo line %d: %w", line, err)
+			}
+		}
+	}
+	if err := scan.Err(); err != nil {
+		return nil, fmt.Errorf("reading Cabrillo line %d: %w", line, err)
+	}
+	if !started {
+		return nil, fmt.Errorf("missing Cabrillo START-OF-LOG")
+	}
+	if !ended {
+		return nil, fmt.Errorf("missing Cabrillo END-OF-LOG")
+	}
+	if c, ok := l.Header.Get(CabrilloAppPrefix + "CONTEST"); ok && c.Value != "" {
+		id := c.Value
+		if t, ok := o.template(c.Value); ok && t.ContestID != "" {
+			id = t.ContestID
+		}
+		for _, r := range l.Records {
+			if f, ok := r.Get("CONTEST_ID"); !ok || f.Value == "" {
+				r.Set(Field{Name: "CONTEST_ID", Value: id})
+			}
+		}
+	}
+	return l, nil
+}
+
+func (o *CabrilloIO) readQSO(val, contest string) (*Record, error) {
+	tok := strings.Fields(val)
+	if len(tok) < 6 {
+		return nil, fmt.Errorf("too few QSO fields: %q", val)
+	}
+	r := NewRecord()
+	freq := strings.ToUpper(tok[0])
+	var band string
+	for _, b := range cabrilloBands {
+		if b.lower >= 50 && b.designation == freq {
+			band = b.band
+			break
+		}
+	}
+	if band != "" {
+		r.Set(Field{Name: "BAND", Value: band})
+	} else {
+		khz, err := strconv.ParseFloat(freq, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid frequency %q", tok[0])
+		}
+		r.Set(Field{Name: "FREQ", Value: strconv.FormatFloat(khz/1000.0, 'f', -1, 64)})
+	}
+	mode := strings.ToUpper(tok[1])
+	if m, ok := cabrilloModesRead[mode]; ok {
+		r.Set(Field{Name: "MODE", Value: m})
+	} else {
+		r.Set(Field{Name: CabrilloAppPrefix + "MODE", Value: mode})
+	}
+	d, err := time.Parse("2006-01-02", tok[2])
+	if err != nil {
+		return nil, fmt.Errorf("invalid date %q", tok[2])
+	}
+	r.Set(Field{Name: "QSO_DATE", Value: d.Format("20060102")})
+	if _, err := time.Parse("1504", tok[3]); err != nil {
+		return nil, fmt.Errorf("invalid time %q", tok[3])
+	}
+	r.Set(Field{Name: "TIME_ON", Value: tok[3]})
+	rest := tok[4:]
+	var transmitter string
+	if t, ok := o.template(contest); ok {
+		want := 2 + len(t.MyExchange) + len(t.TheirExchange)
+		if len(rest) == want+1 {
+			transmitter = rest[want]
+		} else if len(rest) != want {
+			return nil, fmt.Errorf("%s QSO needs %d callsign and exchange values, got %d in %q", contest, want, len(rest), val)
+		}
+		sent, rcvd := rest[:len(t.MyExchange)+1], rest[len(t.MyExchange)+1:want]
+		r.Set(Field{Name: "STATION_CALLSIGN", Value: sent[0]})
+		setTemplateExchange(r, sent[1:], t.MyExchange)
+		r.Set(Field{Name: "CALL", Value: rcvd[0]})
+		setTemplateExchange(r, rcvd[1:], t.TheirExchange)
+	} else {
+		if len(rest)%2 == 1 {
+			transmitter = rest[len(rest)-1]
+			rest = rest[:len(rest)-1]
+		}
+		sent, rcvd := rest[:len(rest)/2], rest[len(rest)/2:]
+		r.Set(Field{Name: "STATION_CALLSIGN", Value: sent[0]})
+		setCabrilloExchange(r, sent[1:], "RST_SENT", "STX", "STX_STRING")
+		r.Set(Field{Name: "CALL", Value: rcvd[0]})
+		setCabrilloExchange(r, rcvd[1:], "RST_RCVD", "SRX", "SRX_STRING")
+	}
+	if transmitter != "" && transmitter != "0" && transmitter != "1" {
+		return nil, fmt.Errorf("cannot split sent and received exchange in %q", val)
+	}
+	if transmitter != "" {
+		r.Set(Field{Name: CabrilloAppPrefix + "TRANSMITTER_ID", Value: transmitter})
+	}
+	return r, nil
+}
+
+func setTemplateExchange(r *Record, vals []string, fields []string) {
+	for i := 0; i < len(fields); {
+		j := i + 1
+		for j < len(fields) && strings.EqualFold(fields[j], fields[i]) {
+			j++
+		}
+		r.Set(Field{Name: fields[i], Value: strings.Join(vals[i:j], " ")})
+		i = j
+	}
+}
+
+func setCabrilloExchange(r *Record, vals []string, rst, serial, str string) {
+	if len(vals) >= 2 && looksLikeRST(vals[0]) {
+		r.Set(Field{Name: rst, Value: vals[0]})
+		vals = vals[1:]
+	}
+	if len(vals) == 1 && isAllDigits(vals[0]) {
+		r.Set(Field{Name: serial, Value: vals[0]})
+	} else if len(vals) > 0 {
+		r.Set(Field{Name: str, Value: strings.Join(vals, " ")})
+	}
+}
+
+func looksLikeRST(s string) bool {
+	return (len(s) == 2 || len(s) == 3) && isAllDigits(s) && s[0] >= '1' && s[0] <= '5'
+}
+
+func isAllDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func (o *CabrilloIO) Write(l *Logfile, out io.Writer) error {
+	b := bufio.NewWriter(out)
+	eol := "\n"
+	if o.CRLF {
+		eol = "\r\n"
+	}
+	writeTag := func(tag, val string) error {
+		for _, v := range strings.Split(strings.ReplaceAll(val, "\r\n", "\n"), "\n") {
+			line := tag + ":"
+			if v != "" {
+				line += " " + v
+			}
+			if _, err := b.WriteString(line + eol); err != nil {
+				return fmt.Errorf("writing Cabrillo %s: %w", tag, err)
+			}
+		}
+		return nil
+	}
+	if err := writeTag("START-OF-LOG", "3.0"); err != nil {
+		return err
+	}
+	seen := make(map[string]bool)
+	for _, f := range l.Header.Fields() {
+		if !strings.HasPrefix(f.Name, CabrilloAppPrefix) {
+			continue
+		}
+		tag := strings.ReplaceAll(strings.TrimPrefix(f.Name, CabrilloAppPrefix), "_", "-")
+		if tag == "START-OF-LOG" || tag == "END-OF-LOG" || tag == "TRANSMITTER-ID" {
+			continue
+		}
+		seen[tag] = true
+		if err := writeTag(tag, f.Value); err != nil {
+			return err
+		}
+	}
+	firstValue := func(names ...string) string {
+		for _, r := range l.Records {
+			for _, n := range names {
+				if f, ok := r.Get(n); ok && f.Value != "" {
+					return f.Value
+				}
+			}
+		}
+		return ""
+	}
+	contest := firstValue("CONTEST_ID")
+	if c, ok := l.Header.Get(CabrilloAppPrefix + "CONTEST"); ok && c.Value != "" {
+		contest = c.Value
+	}
+	tmpl, hasTmpl := o.template(contest)
+	if !seen["CONTEST"] && contest != "" {
+		if hasTmpl && tmpl.CabrilloName != "" {
+			contest = tmpl.CabrilloName
+		}
+		if err := writeTag("CONTEST", contest); err != nil {
+			return err
+		}
+	}
+	if !seen["CALLSIGN"] {
+		if c := firstValue("STATION_CALLSIGN", "OPERATOR"); c != "" {
+			if err := writeTag("CALLSIGN", c); err != nil {
+				return err
+			}
+		}
+	}
+	if !seen["CREATED-BY"] {
+		if p, ok := l.Header.Get("PROGRAMID"); ok && p.Value != "" {
+			c := p.Value
+			if v, ok := l.Header.Get("PROGRAMVERSION"); ok && v.Value != "" {
+				c += " " + v.Value
+			}
+			if err := writeTag("CREATED-BY", c); err != nil {
+				return err
+			}
+		}
+	}
+	for i, r := range l.Records {
+		var t *CabrilloTemplate
+		if hasTmpl {
+			t = &tmpl
+		}
+		q, err := o.formatQSO(r, t)
+		if err != nil {
+			return fmt.Errorf("writing Cabrillo record #%d: %w", i+1, err)
+		}
+		if err := writeTag("QSO", q); err != nil {
+			return err
+		}
+	}
+	if err := writeTag("END-OF-LOG", ""); err != nil {
+		return err
+	}
+	return b.Flush()
+}
+
+func (o *CabrilloIO) formatQSO(r *Record, t *CabrilloTemplate) (string, error) {
+	get := func(name string) string {
+		f, _ := r.Get(name)
+		return strings.TrimSpace(f.Value)
+	}
+	freq, err := cabrilloFrequency(get("FREQ"), get("BAND"))
+	if err != nil {
+		return "", err
+	}
+	mode := get("MODE")
+	if mode == "" {
+		mode = get(CabrilloAppPrefix + "MODE")
+	}
+	if mode == "" {
+		return "", fmt.Errorf("missing MODE")
+	}
+	d, err := time.Parse("20060102", get("QSO_DATE"))
+	if err != nil {
+		return "", fmt.Errorf("invalid QSO_DATE %q", get("QSO_DATE"))
+	}
+	tm := get("TIME_ON")
+	if len(tm) == 6 {
+		tm = tm[0:4]
+	}
+	if _, err := time.Parse("1504", tm); err != nil {
+		return "", fmt.Errorf("invalid TIME_ON %q", get("TIME_ON"))
+	}
+	mycall := get("STATION_CALLSIGN")
+	if mycall == "" {
+		mycall = get("OPERATOR")
+	}
+	if mycall == "" {
+		return "", fmt.Errorf("missing STATION_CALLSIGN")
+	}
+	call := get("CALL")
+	if call == "" {
+		return "", fmt.Errorf("missing CALL")
+	}
+	exchange := func(rst, serial, str string) string {
+		var vals []string
+		if v := get(rst); v != "" {
+			vals = append(vals, v)
+		}
+		if v := get(serial); v != "" {
+			vals = append(vals, v)
+		} else if v := get(str); v != "" {
+			vals = append(vals, strings.Fields(v)...)
+		}
+		return strings.Join(vals, " ")
+	}
+	sent, rcvd := exchange("RST_SENT", "STX", "STX_STRING"), exchange("RST_RCVD", "SRX", "SRX_STRING")
+	if t != nil {
+		if sent, err = templateExchange(r, t.MyExchange); err != nil {
+			return "", err
+		}
+		if rcvd, err = templateExchange(r, t.TheirExchange); err != nil {
+			return "", err
+		}
+	}
+	var q strings.Builder
+	fmt.Fprintf(&q, "%5s %s %s %s %-13s %s %-13s %s",
+		freq, cabrilloMode(mode), d.Format("2006-01-02"), tm, mycall, sent, call, rcvd)
+	if tx := get(CabrilloAppPrefix + "TRANSMITTER_ID"); tx != "" {
+		q.WriteString(" " + tx)
+	}
+	return strings.TrimRight(q.String(), " "), nil
+}
+
+func templateExchange(r *Record, fields []string) (string, error) {
+	var vals []string
+	for i := 0; i < len(fields); {
+		j := i + 1
+		for j < len(fields) && strings.EqualFold(fields[j], fields[i]) {
+			j++
+		}
+		f, _ := r.Get(fields[i])
+		v := strings.Fields(f.Value)
+		if len(v) == 0 {
+			return "", fmt.Errorf("missing %s", strings.ToUpper(fields[i]))
+		}
+		if len(v) != j-i {
+			return "", fmt.Errorf("%s value %q should have %d words for Cabrillo exchange", strings.ToUpper(fields[i]), f.Value, j-i)
+		}
+		vals = append(vals, v...)
+		i = j
+	}
+	return strings.Join(vals, " "), nil
+}
+
+func cabrilloFrequency(freq, band string) (string, error) {
+	if freq != "" {
+		mhz, err := strconv.ParseFloat(freq, 64)
+		if err != nil {
+			return "", fmt.Errorf("invalid FREQ %q", freq)
+		}
+		if mhz >= 50 {
+			for _, b := range cabrilloBands {
+				if b.lower <= mhz && mhz <= b.upper {
+					return b.designation, nil
+				}
+			}
+		}
+		return strconv.FormatInt(int64(math.Round(mhz*1000)), 10), nil
+	}
+	if band != "" {
+		for _, b := range cabrilloBands {
+			if strings.EqualFold(b.band, band) {
+				return b.designation, nil
+			}
+		}
+		return "", fmt.Errorf("no Cabrillo frequency for BAND %q", band)
+	}
+	return "", fmt.Errorf("missing FREQ and BAND")
+}
@@ -0,0 +1,317 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adif
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestReadCabrillo(t *testing.T) {
+	input := `START-OF-LOG: 3.0
+CONTEST: CQ-WW-CW
+CALLSIGN: W1AW
+CATEGORY-OPERATOR: SINGLE-OP
+CATEGORY-BAND: ALL
+CLAIMED-SCORE: 12345
+OPERATORS: W1AW K1MU
+SOAPBOX: Great conditions
+SOAPBOX: Thanks for the QSOs
+QSO:  7005 CW 2023-11-25 0001 W1AW          599 5      DL1ABC        599 14
+QSO: 14025 CW 2023-11-25 0102 W1AW          599 5      JA1XYZ        599 25     1
+X-QSO: 14025 CW 2023-11-25 0103 W1AW          599 5      JA1XYZ        599 25
+QSO:   144 PH 2023-11-25 0204 W1AW          59  FN31   K1MU          59  FN42
+QSO: 14074 DG 2023-11-25 0305 W1AW          599 6      K2B           599 5
+END-OF-LOG:
+`
+	wantHeader := []Field{
+		{Name: "APP_CABRILLO_CONTEST", Value: "CQ-WW-CW"},
+		{Name: "APP_CABRILLO_CALLSIGN", Value: "W1AW"},
+		{Name: "APP_CABRILLO_CATEGORY_OPERATOR", Value: "SINGLE-OP"},
+		{Name: "APP_CABRILLO_CATEGORY_BAND", Value: "ALL"},
+		{Name: "APP_CABRILLO_CLAIMED_SCORE", Value: "12345"},
+		{Name: "APP_CABRILLO_OPERATORS", Value: "W1AW K1MU"},
+		{Name: "APP_CABRILLO_SOAPBOX", Value: "Great conditions\nThanks for the QSOs"},
+	}
+	wantFields := [][]Field{
+		{
+			{Name: "FREQ", Value: "7.005"},
+			{Name: "MODE", Value: "CW"},
+			{Name: "QSO_DATE", Value: "20231125"},
+			{Name: "TIME_ON", Value: "0001"},
+			{Name: "STATION_CALLSIGN", Value: "W1AW"},
+			{Name: "RST_SENT", Value: "599"},
+			{Name: "STX", Value: "5"},
+			{Name: "CALL", Value: "DL1ABC"},
+			{Name: "RST_RCVD", Value: "599"},
+			{Name: "SRX", Value: "14"},
+			{Name: "CONTEST_ID", Value: "CQ-WW-CW"},
+		}, {
+			{Name: "FREQ", Value: "14.025"},
+			{Name: "MODE", Value: "CW"},
+			{Name: "QSO_DATE", Value: "20231125"},
+			{Name: "TIME_ON", Value: "0102"},
+			{Name: "STATION_CALLSIGN", Value: "W1AW"},
+			{Name: "RST_SENT", Value: "599"},
+			{Name: "STX", Value: "5"},
+			{Name: "CALL", Value: "JA1XYZ"},
+			{Name: "RST_RCVD", Value: "599"},
+			{Name: "SRX", Value: "25"},
+			{Name: "APP_CABRILLO_TRANSMITTER_ID", Value: "1"},
+			{Name: "CONTEST_ID", Value: "CQ-WW-CW"},
+		}, {
+			{Name: "BAND", Value: "2m"},
+			{Name: "MODE", Value: "SSB"},
+			{Name: "QSO_DATE", Value: "20231125"},
+			{Name: "TIME_ON", Value: "0204"},
+			{Name: "STATION_CALLSIGN", Value: "W1AW"},
+			{Name: "RST_SENT", Value: "59"},
+			{Name: "STX_STRING", Value: "FN31"},
+			{Name: "CALL", Value: "K1MU"},
+			{Name: "RST_RCVD", Value: "59"},
+			{Name: "SRX_STRING", Value: "FN42"},
+			{Name: "CONTEST_ID", Value: "CQ-WW-CW"},
+		}, {
+			{Name: "FREQ", Value: "14.074"},
+			{Name: "APP_CABRILLO_MODE", Value: "DG"},
+			{Name: "QSO_DATE", Value: "20231125"},
+			{Name: "TIME_ON", Value: "0305"},
+			{Name: "STATION_CALLSIGN", Value: "W1AW"},
+			{Name: "RST_SENT", Value: "599"},
+			{Name: "STX", Value: "6"},
+			{Name: "CALL", Value: "K2B"},
+			{Name: "RST_RCVD", Value: "599"},
+			{Name: "SRX", Value: "5"},
+			{Name: "CONTEST_ID", Value: "CQ-WW-CW"},
+		},
+	}
+	cab := NewCabrilloIO()
+	parsed, err := cab.Read(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Read(%q) got error %v", input, err)
+	}
+	if diff := cmp.Diff(wantHeader, parsed.Header.Fields()); diff != "" {
+		t.Errorf("Read(%q) header did not match expected, diff:\n%s", input, diff)
+	}
+	if gotlen := len(parsed.Records); gotlen != len(wantFields) {
+		t.Fatalf("Read(%q) got %d records, want %d", input, gotlen, len(wantFields))
+	}
+	for i, r := range parsed.Records {
+		if diff := cmp.Diff(wantFields[i], r.Fields()); diff != "" {
+			t.Errorf("Read(%q) record %d did not match expected, diff:\n%s", input, i+1, diff)
+		}
+	}
+}
+
+func TestReadCabrilloErrors(t *testing.T) {
+	tests := []struct{ name, input string }{
+		{name: "empty", input: ""},
+		{name: "no start", input: "CONTEST: TEST\nEND-OF-LOG:\n"},
+		{name: "no end", input: "START-OF-LOG: 3.0\nCONTEST: TEST\n"},
+		{name: "no tag", input: "START-OF-LOG: 3.0\nhello\nEND-OF-LOG:\n"},
+		{name: "short QSO", input: "START-OF-LOG: 3.0\nQSO: 7000 CW 2023-01-02 0304 W1AW\nEND-OF-LOG:\n"},
+		{name: "bad date", input: "START-OF-LOG: 3.0\nQSO: 7000 CW 01/02/2023 0304 W1AW 1 K1MU 2\nEND-OF-LOG:\n"},
+		{name: "bad frequency", input: "START-OF-LOG: 3.0\nQSO: 40m CW 2023-01-02 0304 W1AW 1 K1MU 2\nEND-OF-LOG:\n"},
+		{name: "uneven exchange", input: "START-OF-LOG: 3.0\nQSO: 7000 CW 2023-01-02 0304 W1AW 1 K1MU 2 3\nEND-OF-LOG:\n"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if l, err := NewCabrilloIO().Read(strings.NewReader(tc.input)); err == nil {
+				t.Errorf("Read(%q) got %v, want error", tc.input, l)
+			}
+		})
+	}
+}
+
+func TestWriteCabrillo(t *testing.T) {
+	l := NewLogfile()
+	l.Comment = "Cabrillo ignores comments"
+	l.Header.Set(Field{Name: "PROGRAMID", Value: "adifmt"})
+	l.Header.Set(Field{Name: "PROGRAMVERSION", Value: "1.2.3"})
+	l.Header.Set(Field{Name: "APP_CABRILLO_CATEGORY_OPERATOR", Value: "SINGLE-OP"})
+	l.Header.Set(Field{Name: "APP_CABRILLO_SOAPBOX", Value: "Great conditions\nThanks for the QSOs"})
+	l.AddRecord(NewRecord(
+		Field{Name: "CALL", Value: "DL1ABC"},
+		Field{Name: "QSO_DATE", Value: "20231125"},
+		Field{Name: "TIME_ON", Value: "000123"},
+		Field{Name: "FREQ", Value: "7.005"},
+		Field{Name: "MODE", Value: "CW"},
+		Field{Name: "STATION_CALLSIGN", Value: "W1AW"},
+		Field{Name: "CONTEST_ID", Value: "CQ-WW-CW"},
+		Field{Name: "RST_SENT", Value: "599"},
+		Field{Name: "RST_RCVD", Value: "599"},
+		Field{Name: "STX", Value: "5"},
+		Field{Name: "SRX", Value: "14"},
+	)).AddRecord(NewRecord(
+		Field{Name: "CALL", Value: "K1MU"},
+		Field{Name: "QSO_DATE", Value: "20231125"},
+		Field{Name: "TIME_ON", Value: "0204"},
+		Field{Name: "BAND", Value: "2m"},
+		Field{Name: "MODE", Value: "SSB"},
+		Field{Name: "STATION_CALLSIGN", Value: "W1AW"},
+		Field{Name: "RST_SENT", Value: "59"},
+		Field{Name: "RST_RCVD", Value: "59"},
+		Field{Name: "STX_STRING", Value: "FN31"},
+		Field{Name: "SRX_STRING", Value: "FN42"},
+		Field{Name: "APP_CABRILLO_TRANSMITTER_ID", Value: "1"},
+	)).AddRecord(NewRecord(
+		Field{Name: "CALL", Value: "JA1XYZ"},
+		Field{Name: "QSO_DATE", Value: "20231125"},
+		Field{Name: "TIME_ON", Value: "0305"},
+		Field{Name: "FREQ", Value: "1296.1"},
+		Field{Name: "MODE", Value: "FT8"},
+		Field{Name: "STATION_CALLSIGN", Value: "W1AW"},
+	))
+	want := `START-OF-LOG: 3.0
+CATEGORY-OPERATOR: SINGLE-OP
+SOAPBOX: Great conditions
+SOAPBOX: Thanks for the QSOs
+CONTEST: CQ-WW-CW
+CALLSIGN: W1AW
+CREATED-BY: adifmt 1.2.3
+QSO:  7005 CW 2023-11-25 0001 W1AW          599 5 DL1ABC        599 14
+QSO:   144 PH 2023-11-25 0204 W1AW          59 FN31 K1MU          59 FN42 1
+QSO:  1.2G DG 2023-11-25 0305 W1AW           JA1XYZ
+END-OF-LOG:
+`
+	cab := NewCabrilloIO()
+	out := &strings.Builder{}
+	if err := cab.Write(l, out); err != nil {
+		t.Fatalf("Write(%v) got error %v", l, err)
+	}
+	if diff := cmp.Diff(want, out.String()); diff != "" {
+		t.Errorf("Write(%v) had diff with expected:\n%s", l, diff)
+	}
+	roundTrip, err := cab.Read(strings.NewReader(out.String()))
+	if err != nil {
+		t.Fatalf("Read(Write(%v)) got error %v", l, err)
+	}
+	if got, want := len(roundTrip.Records), len(l.Records); got != want {
+		t.Errorf("Read(Write(%v)) got %d records, want %d", l, got, want)
+	}
+	again := &strings.Builder{}
+	if err := cab.Write(roundTrip, again); err != nil {
+		t.Fatalf("Write(Read(Write(%v))) got error %v", l, err)
+	}
+	if diff := cmp.Diff(want, again.String()); diff != "" {
+		t.Errorf("Write(Read(Write(%v))) had diff with expected:\n%s", l, diff)
+	}
+	for _, name := range []string{"APP_CABRILLO_CATEGORY_OPERATOR", "APP_CABRILLO_SOAPBOX"} {
+		got, _ := roundTrip.Header.Get(name)
+		want, _ := l.Header.Get(name)
+		if got.Value != want.Value {
+			t.Errorf("Read(Write(%v)) header %s got %q, want %q", l, name, got.Value, want.Value)
+		}
+	}
+}
+
+func TestWriteCabrilloErrors(t *testing.T) {
+	base := []Field{
+		{Name: "CALL", Value: "K1MU"},
+		{Name: "QSO_DATE", Value: "20231125"},
+		{Name: "TIME_ON", Value: "0204"},
+		{Name: "FREQ", Value: "14.025"},
+		{Name: "MODE", Value: "CW"},
+		{Name: "STATION_CALLSIGN", Value: "W1AW"},
+	}
+	for _, missing := range []string{"CALL", "QSO_DATE", "TIME_ON", "FREQ", "MODE", "STATION_CALLSIGN"} {
+		r := NewRecord()
+		for _, f := range base {
+			if f.Name != missing {
+				r.Set(f)
+			}
+		}
+		l := NewLogfile().AddRecord(r)
+		if err := NewCabrilloIO().Write(l, &strings.Builder{}); err == nil {
+			t.Errorf("Write(%v) without %s got no error", l, missing)
+		}
+	}
+}
+
+func TestCabrilloTemplate(t *testing.T) {
+	cab := &CabrilloIO{Templates: map[string]CabrilloTemplate{
+		"ARRL-SS-CW": {
+			CabrilloName:  "ARRL-SS-CW",
+			ContestID:     "ARRL-SS-CW",
+			MyExchange:    []string{"STX", "STX_STRING", "STX_STRING", "MY_ARRL_SECT"},
+			TheirExchange: []string{"SRX", "PRECEDENCE", "CHECK", "ARRL_SECT"},
+		},
+	}}
+	input := `START-OF-LOG: 3.0
+CONTEST: ARRL-SS-CW
+QSO: 21000 CW 1997-11-01 2102 N5KO            3 B 74 STX K9ZO            2 A 69 IL
+END-OF-LOG:
+`
+	want := []Field{
+		{Name: "FREQ", Value: "21"},
+		{Name: "MODE", Value: "CW"},
+		{Name: "QSO_DATE", Value: "19971101"},
+		{Name: "TIME_ON", Value: "2102"},
+		{Name: "STATION_CALLSIGN", Value: "N5KO"},
+		{Name: "STX", Value: "3"},
+		{Name: "STX_STRING", Value: "B 74"},
+		{Name: "MY_ARRL_SECT", Value: "STX"},
+		{Name: "CALL", Value: "K9ZO"},
+		{Name: "SRX", Value: "2"},
+		{Name: "PRECEDENCE", Value: "A"},
+		{Name: "CHECK", Value: "69"},
+		{Name: "ARRL_SECT", Value: "IL"},
+		{Name: "CONTEST_ID", Value: "ARRL-SS-CW"},
+	}
+	parsed, err := cab.Read(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Read(%q) got error %v", input, err)
+	}
+	if len(parsed.Records) != 1 {
+		t.Fatalf("Read(%q) got %d records, want 1", input, len(parsed.Records))
+	}
+	if diff := cmp.Diff(want, parsed.Records[0].Fields()); diff != "" {
+		t.Errorf("Read(%q) record did not match expected, diff:\n%s", input, diff)
+	}
+	out := &strings.Builder{}
+	if err := cab.Write(parsed, out); err != nil {
+		t.Fatalf("Write(%v) got error %v", parsed, err)
+	}
+	wantOut := `START-OF-LOG: 3.0
+CONTEST: ARRL-SS-CW
+CALLSIGN: N5KO
+QSO: 21000 CW 1997-11-01 2102 N5KO          3 B 74 STX K9ZO          2 A 69 IL
+END-OF-LOG:
+`
+	if diff := cmp.Diff(wantOut, out.String()); diff != "" {
+		t.Errorf("Write(%v) had diff with expected:\n%s", parsed, diff)
+	}
+
+	bad := []string{
+		"QSO: 21000 CW 1997-11-01 2102 N5KO 3 B 74 STX K9ZO 2 A 69\n",
+		"QSO: 21000 CW 1997-11-01 2102 N5KO 3 B 74 STX K9ZO 2 A 69 IL 1 2\n",
+	}
+	for _, q := range bad {
+		in := "START-OF-LOG: 3.0\nCONTEST: ARRL-SS-CW\n" + q + "END-OF-LOG:\n"
+		if l, err := cab.Read(strings.NewReader(in)); err == nil {
+			t.Errorf("Read(%q) got %v, want error", in, l)
+		}
+	}
+	parsed.Records[0].Set(Field{Name: "STX_STRING", Value: "B"})
+	if err := cab.Write(parsed, &strings.Builder{}); err == nil {
+		t.Errorf("Write(%v) with short STX_STRING got no error", parsed)
+	}
+	parsed.Records[0].Set(Field{Name: "STX_STRING", Value: ""})
+	if err := cab.Write(parsed, &strings.Builder{}); err == nil {
+		t.Errorf("Write(%v) with empty STX_STRING got no error", parsed)
+	}
+}
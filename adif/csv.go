@@ -19,7 +19,6 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"strings"
 )
 
 type CSVIO struct {
@@ -29,6 +28,8 @@ type CSVIO struct {
 	LazyQuotes        bool
 	RequireFullRecord bool
 	TrimLeadingSpace  bool
+	// Mapping, if not nil, converts columns to fields when reading
+	Mapping *ColumnMapping
 }
 
 func NewCSVIO() *CSVIO {
@@ -37,8 +38,9 @@ func NewCSVIO() *CSVIO {
 
 func (o *CSVIO) String() string { return "csv" }
 
-func (o *CSVIO) Read(in io.Reader) (*Logfile, error) {
-	l := NewLogfile()
+func (o *CSVIO) Read(in io.Reader) (*Logfile, error) { return ReadAll(o, in) }
+
+func (o *CSVIO) ReadRecords(in io.Reader, h StreamHandler) (RecordIterator, error) {
 	c := csv.NewReader(in)
 	c.ReuseRecord = true
 	c.Comma = o.Comma
@@ -50,86 +52,175 @@ func (o *CSVIO) Read(in io.Reader) (*Logfile, error) {
 	} else {
 		c.FieldsPerRecord = -1
 	}
-	header, err := c.Read()
+	header := o.Mapping.header()
+	if header == nil {
+		h, err := c.Read()
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("got EOF reading CSV header row")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading CSV header row: %w", err)
+		}
+		// copy header, since array will be reused
+		header = make([]string, len(h))
+		copy(header, h)
+	}
+	it := &csvRecords{c: c, head: header, mapping: o.Mapping}
+	var err error
+	var order []string
+	if it.fields, order, err = o.Mapping.columnFields(header); err != nil {
+		return nil, fmt.Errorf("CSV header: %w", err)
+	}
+	if h.FieldOrder != nil {
+		h.FieldOrder(order)
+	}
+	// TODO if there are any USERDEF fields, call h.Userdef
+	return it, nil
+}
+
+type csvRecords struct {
+	c       *csv.Reader
+	head    []string
+	fields  []ColumnField
+	mapping *ColumnMapping
+	line    int
+}
+
+func (it *csvRecords) Position() Position { return Position{Line: it.line, Offset: -1} }
+
+func (it *csvRecords) Next() (*Record, error) {
+	line, err := it.c.Read()
 	if errors.Is(err, io.EOF) {
-		return nil, fmt.Errorf("got EOF reading CSV header row")
+		return nil, io.EOF
 	}
 	if err != nil {
-		return nil, fmt.Errorf("error reading CSV header row: %w", err)
-	}
-	// copy header, since array will be reused
-	h := make([]string, len(header))
-	copy(h, header)
-	l.FieldOrder = make([]string, len(h))
-	for i, n := range h {
-		l.FieldOrder[i] = strings.ToUpper(n)
-	}
-	// TODO if there are any USERDEF fields, add them to l.Header
-	for line, err := c.Read(); err != io.EOF; line, err = c.Read() {
-		lnum, _ := c.FieldPos(0)
-		if err != nil {
-			return nil, fmt.Errorf("error reading CSV: %w", err)
+		return nil, fmt.Errorf("error reading CSV: %w", err)
+	}
+	lnum, _ := it.c.FieldPos(0)
+	it.line = lnum
+	h := it.head
+	r := NewRecord()
+	for i, v := range line {
+		// if RequreFullRecord, c.Read already returned an error for under/overflow, but catch overflow even if it's false
+		if i >= len(h) {
+			return nil, fmt.Errorf("extra field value %q at line %d field %d", v, lnum, i)
+		}
+		f := it.fields[i]
+		if f.Name == "" {
+			continue
 		}
-		r := NewRecord()
-		for i, v := range line {
-			// if RequreFullRecord, c.Read already returned an error for under/overflow, but catch overflow even if it's false
-			if i >= len(h) {
-				return nil, fmt.Errorf("extra field value %q at line %d field %d", v, lnum, i)
-			}
-			if err := r.Set(Field{Name: h[i], Value: v}); err != nil {
-				return nil, fmt.Errorf("could not set field %s to %q: %w", h[i], v, err)
-			}
+		if v, err = f.convert(h[i], v); err != nil {
+			return nil, fmt.Errorf("line %d: %w", lnum, err)
+		}
+		if err := r.Set(Field{Name: f.Name, Value: v}); err != nil {
+			return nil, fmt.Errorf("could not set field %s to %q: %w", f.Name, v, err)
+		}
+	}
+	for i := len(line); i < len(h); i++ {
+		f := it.fields[i]
+		if f.Name == "" {
+			continue
 		}
-		for i := len(line); i < len(h); i++ {
-			if err := r.Set(Field{Name: h[i], Value: ""}); err != nil {
-				return nil, fmt.Errorf("could not set field %s to empty: %w", h[i], err)
-			}
+		if err := r.Set(Field{Name: f.Name, Value: ""}); err != nil {
+			return nil, fmt.Errorf("could not set field %s to empty: %w", f.Name, err)
 		}
-		l.AddRecord(r)
 	}
-	return l, nil
+	if err := it.mapping.adjust(r); err != nil {
+		return nil, fmt.Errorf("line %d: %w", lnum, err)
+	}
+	return r, nil
 }
 
 func (o *CSVIO) Write(l *Logfile, out io.Writer) error {
-	order := make([]string, len(l.FieldOrder))
-	seen := make(map[string]bool)
-	for i, n := range l.FieldOrder {
-		n = strings.ToUpper(n)
-		order[i] = n
-		seen[n] = true
-	}
+	w := o.newRecordWriter(l, out)
+	w.columns = columnNames(l.FieldOrder, l.Records)
 	for _, r := range l.Records {
-		for _, f := range r.Fields() {
-			n := strings.ToUpper(f.Name)
-			if !seen[n] {
-				order = append(order, n)
-				seen[n] = true
-			}
+		if err := w.WriteRecord(r); err != nil {
+			return err
 		}
 	}
-	if len(order) == 0 {
-		return nil
-	}
+	return w.Close()
+}
+
+// WriteHeader returns a RecordWriter for CSV rows.  The header row is written
+// with the first record, or by Close if there are no records.  Columns are
+// l.FieldOrder followed by any other fields in the first record, so if records
+// have different fields, l.FieldOrder needs to list all of them; WriteRecord
+// returns an error for a non-empty field which is not a column.
+func (o *CSVIO) WriteHeader(l *Logfile, out io.Writer) (RecordWriter, error) {
+	return o.newRecordWriter(l, out), nil
+}
+
+func (o *CSVIO) newRecordWriter(l *Logfile, out io.Writer) *csvRecordWriter {
 	c := csv.NewWriter(out)
 	c.Comma = o.Comma
 	c.UseCRLF = o.CRLF
-	// CSV header row
-	if err := c.Write(order); err != nil {
-		return fmt.Errorf("writing CSV header to %s: %w", l, err)
-	}
-	row := make([]string, len(order))
-	for i, r := range l.Records {
-		for i, n := range order {
-			if f, ok := r.Get(n); !ok {
-				row[i] = ""
-			} else {
-				row[i] = f.Value
-			}
+	return &csvRecordWriter{l: l, c: c}
+}
+
+type csvRecordWriter struct {
+	l       *Logfile
+	c       *csv.Writer
+	columns []string
+	index   map[string]bool
+	row     []string
+	started bool
+	count   int
+}
+
+// start writes the header row, adding fields from r if columns were not set.
+func (w *csvRecordWriter) start(r *Record) error {
+	w.started = true
+	if w.columns == nil {
+		var recs []*Record
+		if r != nil {
+			recs = append(recs, r)
 		}
-		if err := c.Write(row); err != nil {
-			return fmt.Errorf("writing CSV record %d to %s: %w", i+1, l, err)
+		w.columns = columnNames(w.l.FieldOrder, recs)
+	}
+	w.index = make(map[string]bool)
+	for _, n := range w.columns {
+		w.index[n] = true
+	}
+	w.row = make([]string, len(w.columns))
+	if len(w.columns) == 0 {
+		return nil
+	}
+	if err := w.c.Write(w.columns); err != nil {
+		return fmt.Errorf("writing CSV header to %s: %w", w.l, err)
+	}
+	return nil
+}
+
+func (w *csvRecordWriter) WriteRecord(r *Record) error {
+	if !w.started {
+		if err := w.start(r); err != nil {
+			return err
+		}
+	}
+	w.count++
+	if err := checkColumns(r, w.index); err != nil {
+		return fmt.Errorf("writing CSV record %d to %s: %w", w.count, w.l, err)
+	}
+	if len(w.columns) == 0 {
+		return nil
+	}
+	for i, n := range w.columns {
+		f, _ := r.Get(n)
+		w.row[i] = f.Value
+	}
+	if err := w.c.Write(w.row); err != nil {
+		return fmt.Errorf("writing CSV record %d to %s: %w", w.count, w.l, err)
+	}
+	return nil
+}
+
+func (w *csvRecordWriter) Close() error {
+	if !w.started {
+		if err := w.start(nil); err != nil {
+			return err
 		}
 	}
-	c.Flush()
-	return c.Error()
+	w.c.Flush()
+	return w.c.Error()
 }
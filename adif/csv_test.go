@@ -15,6 +15,7 @@
 package adif
 
 import (
+	"fmt"
 	"strings"
 	"testing"
 
@@ -110,6 +111,47 @@ func TestWriteCSV(t *testing.T) {
 	}
 }
 
+func TestCSVWriteHeader(t *testing.T) {
+	l := NewLogfile()
+	l.FieldOrder = []string{"call"}
+	out := &strings.Builder{}
+	w, err := NewCSVIO().WriteHeader(l, out)
+	if err != nil {
+		t.Fatalf("WriteHeader got error %v", err)
+	}
+	// header row has field order and fields of the first record
+	for _, r := range []*Record{
+		NewRecord(Field{Name: "BAND", Value: "40m"}, Field{Name: "CALL", Value: "W1AW"}),
+		NewRecord(Field{Name: "CALL", Value: "K2B"}, Field{Name: "MODE", Value: ""}),
+	} {
+		if err := w.WriteRecord(r); err != nil {
+			t.Fatalf("WriteRecord(%v) got error %v", r, err)
+		}
+	}
+	r := NewRecord(Field{Name: "CALL", Value: "N3C"}, Field{Name: "MODE", Value: "CW"})
+	if err := w.WriteRecord(r); err == nil {
+		t.Errorf("WriteRecord(%v) with a new field got no error", r)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close got error %v", err)
+	}
+	want := "CALL,BAND\nW1AW,40m\nK2B,\n"
+	if diff := cmp.Diff(want, out.String()); diff != "" {
+		t.Errorf("streaming write had diff with expected:\n%s", diff)
+	}
+
+	out.Reset()
+	if w, err = NewCSVIO().WriteHeader(l, out); err != nil {
+		t.Fatalf("WriteHeader got error %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close got error %v", err)
+	}
+	if diff := cmp.Diff("CALL\n", out.String()); diff != "" {
+		t.Errorf("streaming write with no records had diff with expected:\n%s", diff)
+	}
+}
+
 func TestCSVQuotes(t *testing.T) {
 	input := `QSO_DATE,NAME,VUCC_GRIDS,ADDRESS_INTL,NOTES
 19990101,"""C.G."" Tuska","FN31,FN21","225 Main St.
@@ -165,3 +207,125 @@ Switzerland",",comma notes,,,"
 		}
 	}
 }
+
+func TestCSVPosition(t *testing.T) {
+	input := "CALL,NAME\nW1AW,\"Hiram\nPercy\"\nN0P,Santa\n"
+	it, err := NewCSVIO().ReadRecords(strings.NewReader(input), StreamHandler{})
+	if err != nil {
+		t.Fatalf("ReadRecords(%q) got error %v", input, err)
+	}
+	for i, w := range []Position{{Line: 2, Offset: -1}, {Line: 4, Offset: -1}} {
+		if _, err := it.Next(); err != nil {
+			t.Fatalf("Next() record %d got error %v", i+1, err)
+		}
+		if got := it.(PositionedIterator).Position(); got != w {
+			t.Errorf("Position() record %d got %v, want %v", i+1, got, w)
+		}
+	}
+}
+
+func TestCSVColumnMapping(t *testing.T) {
+	input := `Date,Time (UTC),Freq  kHz,Their Call,Notes
+2023-10-17,14:05,14074,w1aw,ignored
+2023-10-18,,7074.5,k0a,
+`
+	m := &ColumnMapping{}
+	if err := m.Add("date", ColumnField{Name: "qso_date", Convert: func(s string) (string, error) { return strings.ReplaceAll(s, "-", ""), nil }}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Add("TIME (UTC)", ColumnField{Name: "TIME_ON", Convert: func(s string) (string, error) { return strings.ReplaceAll(s, ":", ""), nil }}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Add("Freq kHz", ColumnField{Name: "FREQ"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Add("Their Call", ColumnField{Name: "CALL", Convert: func(s string) (string, error) { return strings.ToUpper(s), nil }}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Add("notes", ColumnField{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Add("Notes", ColumnField{Name: "COMMENT"}); err == nil {
+		t.Errorf("Add duplicate column Notes got no error")
+	}
+	m.Adjust = func(r *Record) error { return r.Set(Field{Name: "MODE", Value: "FT8"}) }
+	csv := NewCSVIO()
+	csv.Mapping = m
+	parsed, err := csv.Read(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Read(%q) got error %v", input, err)
+	}
+	want := [][]Field{
+		{{Name: "QSO_DATE", Value: "20231017"}, {Name: "TIME_ON", Value: "1405"}, {Name: "FREQ", Value: "14074"}, {Name: "CALL", Value: "W1AW"}, {Name: "MODE", Value: "FT8"}},
+		{{Name: "QSO_DATE", Value: "20231018"}, {Name: "TIME_ON", Value: ""}, {Name: "FREQ", Value: "7074.5"}, {Name: "CALL", Value: "K0A"}, {Name: "MODE", Value: "FT8"}},
+	}
+	if len(parsed.Records) != len(want) {
+		t.Fatalf("Read(%q) got %d records, want %d", input, len(parsed.Records), len(want))
+	}
+	for i, r := range parsed.Records {
+		if diff := cmp.Diff(want[i], r.Fields()); diff != "" {
+			t.Errorf("Read(%q) record %d diff:\n%s", input, i+1, diff)
+		}
+	}
+	if diff := cmp.Diff([]string{"QSO_DATE", "TIME_ON", "FREQ", "CALL"}, parsed.FieldOrder); diff != "" {
+		t.Errorf("Read(%q) field order diff:\n%s", input, diff)
+	}
+}
+
+func TestCSVColumnMappingErrors(t *testing.T) {
+	fail := func(string) (string, error) { return "", fmt.Errorf("bad value") }
+	tests := []struct {
+		name, input string
+		mapping     func(m *ColumnMapping) error
+	}{
+		{
+			name:  "duplicate field",
+			input: "Call,Their Call\nW1AW,W1AW\n",
+			mapping: func(m *ColumnMapping) error {
+				return m.Add("Their Call", ColumnField{Name: "CALL"})
+			},
+		},
+		{
+			name:  "conversion error",
+			input: "Freq\n14074\n",
+			mapping: func(m *ColumnMapping) error {
+				return m.Add("Freq", ColumnField{Name: "FREQ", Convert: fail})
+			},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			m := &ColumnMapping{}
+			if err := tc.mapping(m); err != nil {
+				t.Fatal(err)
+			}
+			csv := NewCSVIO()
+			csv.Mapping = m
+			if got, err := csv.Read(strings.NewReader(tc.input)); err == nil {
+				t.Errorf("Read(%q) got %v, want error", tc.input, got)
+			}
+		})
+	}
+}
+
+func TestCSVColumns(t *testing.T) {
+	input := "W1AW,20m,SSB\nK0A,40m,CW\n"
+	csv := NewCSVIO()
+	csv.Mapping = &ColumnMapping{Columns: []string{"CALL", "BAND", "MODE"}}
+	parsed, err := csv.Read(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Read(%q) got error %v", input, err)
+	}
+	want := [][]Field{
+		{{Name: "CALL", Value: "W1AW"}, {Name: "BAND", Value: "20m"}, {Name: "MODE", Value: "SSB"}},
+		{{Name: "CALL", Value: "K0A"}, {Name: "BAND", Value: "40m"}, {Name: "MODE", Value: "CW"}},
+	}
+	if len(parsed.Records) != len(want) {
+		t.Fatalf("Read(%q) got %d records, want %d", input, len(parsed.Records), len(want))
+	}
+	for i, r := range parsed.Records {
+		if diff := cmp.Diff(want[i], r.Fields()); diff != "" {
+			t.Errorf("Read(%q) record %d diff:\n%s", input, i+1, diff)
+		}
+	}
+}
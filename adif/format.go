@@ -18,6 +18,7 @@ package adif
 import (
 	"bufio"
 	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -27,7 +28,7 @@ import (
 	"unicode"
 )
 
-// ENUM(ADI, ADX, CSV, JSON, TSV)
+// ENUM(ADI, ADX, CABRILLO, CSV, JSON, JSONL, TSV)
 type Format string
 
 // GuessFormatFromName guesses a file's Format based on its extension.
@@ -38,15 +39,23 @@ func GuessFormatFromName(filename string) (Format, error) {
 	if ext == "" {
 		return Format(""), fmt.Errorf("no file extension in %q", filename)
 	}
+	switch strings.ToLower(ext) {
+	case "log", "cbr":
+		return FormatCABRILLO, nil
+	case "ndjson":
+		return FormatJSONL, nil
+	}
 	return ParseFormat(ext)
 }
 
 var (
 	// ADI files can start with an arbitrary-length comment
 	firstADITagPat = regexp.MustCompile(`^[^<]*<\w+:\d+(:\w)?>`)
-	// Require CSV and TSV files to have a header with purely alphanumeric columns
-	csvHeaderPat = regexp.MustCompile(`^\w+(,\w+)+[\r\n]`)
-	tsvHeaderPat = regexp.MustCompile(`^\w+(\t\w+)+[\r\n]`)
+	// Require CSV and TSV files to have a header with at least two non-empty
+	// columns; spreadsheet headers like "Time (UTC)" may have spaces and
+	// punctuation, and CSV headers may be quoted
+	csvHeaderPat = regexp.MustCompile(`^(?:"[^"\r\n]*"|[^,\t"\r\n]+)(?:,(?:"[^"\r\n]*"|[^,\t"\r\n]+))+[\r\n]`)
+	tsvHeaderPat = regexp.MustCompile(`^[^\t\r\n]+(\t[^\t\r\n]+)+[\r\n]`)
 )
 
 const contentPeekSize = 4096
@@ -66,10 +75,16 @@ func GuessFormatFromContent(r *bufio.Reader) (Format, error) {
 	if bytes.HasPrefix(start, []byte("<?xml")) || bytes.HasPrefix(start, []byte("<ADX>")) {
 		return FormatADX, nil
 	}
+	if bytes.HasPrefix(bytes.ToUpper(start), []byte("START-OF-LOG:")) {
+		return FormatCABRILLO, nil
+	}
 	if firstADITagPat.Find(start) != nil {
 		return FormatADI, nil
 	}
 	if start[0] == '{' {
+		if isJSONLines(start) {
+			return FormatJSONL, nil
+		}
 		return FormatJSON, nil
 	}
 	if csvHeaderPat.Find(start) != nil {
@@ -80,3 +95,21 @@ func GuessFormatFromContent(r *bufio.Reader) (Format, error) {
 	}
 	return Format(""), fmt.Errorf("could not determine data format, use the -input option")
 }
+
+// isJSONLines returns true if the first line of buf is a complete JSON object
+// which looks like a record, rather than the start of a JSON log with HEADER
+// and RECORDS keys.
+func isJSONLines(buf []byte) bool {
+	line, _, _ := bytes.Cut(buf, []byte("\n"))
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(line, &obj); err != nil || len(obj) == 0 {
+		return false
+	}
+	for k := range obj {
+		switch strings.ToUpper(k) {
+		case "HEADER", "RECORDS":
+			return false
+		}
+	}
+	return true
+}
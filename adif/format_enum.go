@@ -16,10 +16,14 @@ const (
 	FormatADI Format = "ADI"
 	// FormatADX is a Format of type ADX.
 	FormatADX Format = "ADX"
+	// FormatCABRILLO is a Format of type CABRILLO.
+	FormatCABRILLO Format = "CABRILLO"
 	// FormatCSV is a Format of type CSV.
 	FormatCSV Format = "CSV"
 	// FormatJSON is a Format of type JSON.
 	FormatJSON Format = "JSON"
+	// FormatJSONL is a Format of type JSONL.
+	FormatJSONL Format = "JSONL"
 	// FormatTSV is a Format of type TSV.
 	FormatTSV Format = "TSV"
 )
@@ -29,8 +33,10 @@ var ErrInvalidFormat = fmt.Errorf("not a valid Format, try [%s]", strings.Join(_
 var _FormatNames = []string{
 	string(FormatADI),
 	string(FormatADX),
+	string(FormatCABRILLO),
 	string(FormatCSV),
 	string(FormatJSON),
+	string(FormatJSONL),
 	string(FormatTSV),
 }
 
@@ -53,16 +59,20 @@ func (x Format) IsValid() bool {
 }
 
 var _FormatValue = map[string]Format{
-	"ADI":  FormatADI,
-	"adi":  FormatADI,
-	"ADX":  FormatADX,
-	"adx":  FormatADX,
-	"CSV":  FormatCSV,
-	"csv":  FormatCSV,
-	"JSON": FormatJSON,
-	"json": FormatJSON,
-	"TSV":  FormatTSV,
-	"tsv":  FormatTSV,
+	"ADI":      FormatADI,
+	"adi":      FormatADI,
+	"ADX":      FormatADX,
+	"adx":      FormatADX,
+	"CABRILLO": FormatCABRILLO,
+	"cabrillo": FormatCABRILLO,
+	"CSV":      FormatCSV,
+	"csv":      FormatCSV,
+	"JSON":     FormatJSON,
+	"json":     FormatJSON,
+	"JSONL":    FormatJSONL,
+	"jsonl":    FormatJSONL,
+	"TSV":      FormatTSV,
+	"tsv":      FormatTSV,
 }
 
 // ParseFormat attempts to convert a string to a Format.
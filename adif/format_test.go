@@ -29,13 +29,20 @@ func TestGuessFormatFromName(t *testing.T) {
 	}{
 		{name: "foo.adi", want: FormatADI},
 		{name: "foo.adx", want: FormatADX},
+		{name: "foo.cbr", want: FormatCABRILLO},
+		{name: "foo.log", want: FormatCABRILLO},
 		{name: "foo.csv", want: FormatCSV},
 		{name: "foo.json", want: FormatJSON},
+		{name: "foo.jsonl", want: FormatJSONL},
+		{name: "foo.ndjson", want: FormatJSONL},
 		{name: "foo.tsv", want: FormatTSV},
 		{name: "bar.ADI", want: FormatADI},
 		{name: "bar.ADX", want: FormatADX},
+		{name: "bar.CBR", want: FormatCABRILLO},
+		{name: "bar.LOG", want: FormatCABRILLO},
 		{name: "bar.CSV", want: FormatCSV},
 		{name: "bar.JSON", want: FormatJSON},
+		{name: "bar.JSONL", want: FormatJSONL},
 		{name: "bar.TSV", want: FormatTSV},
 		{name: "BAZ.tmp.adx", want: FormatADX},
 		{name: "/path/to/file.csv", want: FormatCSV},
@@ -132,6 +139,17 @@ func TestGuessFormatFromContent(t *testing.T) {
 			want: FormatADX,
 			text: xml.Header + "\n<!-- This is my log file -->\n<ADX><HEADER></HEADER><RECORDS></RECORDS></ADX>\n",
 		},
+		{
+			name:    "Cabrillo basic",
+			want:    FormatCABRILLO,
+			records: 1,
+			text:    "START-OF-LOG: 3.0\nCONTEST: TEST\nQSO: 7000 CW 2023-01-02 0304 W1AW 599 1 K1MU 599 2\nEND-OF-LOG:\n",
+		},
+		{
+			name: "Cabrillo lower case no records",
+			want: FormatCABRILLO,
+			text: shortSpace + "start-of-log: 3.0\r\nend-of-log:\r\n",
+		},
 		{
 			name:    "CSV basic",
 			want:    FormatCSV,
@@ -155,6 +173,18 @@ func TestGuessFormatFromContent(t *testing.T) {
 			records: 1,
 			text:    shortSpace + "CALL,MODE\nW1AW,CW\n",
 		},
+		{
+			name:    "CSV spreadsheet header",
+			want:    FormatCSV,
+			records: 1,
+			text:    "Date,Time (UTC),Freq kHz,Their Call\n2023-10-17,14:05,14074,W1AW\n",
+		},
+		{
+			name:    "CSV quoted header",
+			want:    FormatCSV,
+			records: 1,
+			text:    "\"Date\",\"Freq, kHz\",Call\n2023-10-17,14074,W1AW\n",
+		},
 		{
 			name:    "JSON basic",
 			want:    FormatJSON,
@@ -182,6 +212,24 @@ func TestGuessFormatFromContent(t *testing.T) {
 			want: FormatJSON,
 			text: `{}`,
 		},
+		{
+			name:    "JSON one line",
+			want:    FormatJSON,
+			records: 1,
+			text:    `{"HEADER":{"PROGRAMID":"format test"},"RECORDS":[{"CALL":"W1AW","MODE":"CW"}]}` + "\n",
+		},
+		{
+			name:    "JSON Lines basic",
+			want:    FormatJSONL,
+			records: 2,
+			text:    `{"CALL":"W1AW","MODE":"CW"}` + "\n" + `{"CALL":"N0P","MODE":"SSB"}` + "\n",
+		},
+		{
+			name:    "JSON Lines one record",
+			want:    FormatJSONL,
+			records: 1,
+			text:    shortSpace + `{"CALL": "W1AW", "MODE": "CW"}`,
+		},
 		{
 			name:    "TSV basic",
 			want:    FormatTSV,
@@ -205,6 +253,12 @@ func TestGuessFormatFromContent(t *testing.T) {
 			records: 1,
 			text:    "  CALL\tMODE\nW1AW\tCW\n",
 		},
+		{
+			name:    "TSV spreadsheet header",
+			want:    FormatTSV,
+			records: 1,
+			text:    "Date\tFreq, kHz\tTheir Call\n2023-10-17\t14074\tW1AW\n",
+		},
 		{
 			name:    "ADI looks like CSV",
 			want:    FormatADI,
@@ -281,10 +335,14 @@ func TestGuessFormatFromContent(t *testing.T) {
 					fr = NewADIIO()
 				case FormatADX:
 					fr = NewADXIO()
+				case FormatCABRILLO:
+					fr = NewCabrilloIO()
 				case FormatCSV:
 					fr = NewCSVIO()
 				case FormatJSON:
 					fr = NewJSONIO()
+				case FormatJSONL:
+					fr = NewJSONLIO()
 				case FormatTSV:
 					fr = NewTSVIO()
 				}
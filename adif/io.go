@@ -15,6 +15,7 @@
 package adif
 
 import (
+	"errors"
 	"fmt"
 	"io"
 )
@@ -32,3 +33,91 @@ type ReadWriter interface {
 	Writer
 	fmt.Stringer
 }
+
+// RecordIterator provides access to log records one at a time.
+type RecordIterator interface {
+	// Next returns the next record, or io.EOF if there are no more records.
+	Next() (*Record, error)
+}
+
+// Position is the location in an input where a record starts.  Line starts
+// at 1.  Offset is the number of bytes before the record, or -1 if unknown.
+type Position struct {
+	Line   int
+	Offset int64
+}
+
+// PositionedIterator is a RecordIterator which knows the position of the
+// record most recently returned by Next.
+type PositionedIterator interface {
+	RecordIterator
+	Position() Position
+}
+
+// StreamHandler receives non-record data from a StreamReader.  Any callback
+// may be nil.
+type StreamHandler struct {
+	// Header is called with header fields, if the input has a header.
+	Header func(*Record) error
+	// Userdef is called with each user-defined field declared in the input.
+	Userdef func(UserdefField) error
+	// FieldOrder is called with field names in the order the input lists them,
+	// e.g. the CSV header row.
+	FieldOrder func([]string)
+	// Comment is called with any comment text at the end of the input.
+	Comment func(string)
+}
+
+// StreamReader reads a log one record at a time so the whole log does not need
+// to be held in memory.  Header, userdef, and field order callbacks are called
+// before ReadRecords returns if that data comes before the first record in the
+// input.  The Comment callback is called when Next reaches the end of input.
+type StreamReader interface {
+	ReadRecords(in io.Reader, h StreamHandler) (RecordIterator, error)
+}
+
+// LogfileHandler returns a StreamHandler which sets data from a stream on l.
+func LogfileHandler(l *Logfile) StreamHandler {
+	return StreamHandler{
+		Header:     func(r *Record) error { l.Header = r; return nil },
+		Userdef:    l.AddUserdef,
+		FieldOrder: func(o []string) { l.FieldOrder = o },
+		Comment:    func(c string) { l.Comment = c },
+	}
+}
+
+// ReadAll reads every record from a StreamReader into a Logfile.
+func ReadAll(s StreamReader, in io.Reader) (*Logfile, error) {
+	l := NewLogfile()
+	it, err := s.ReadRecords(in, LogfileHandler(l))
+	if err != nil {
+		return nil, err
+	}
+	for {
+		r, err := it.Next()
+		if errors.Is(err, io.EOF) {
+			return l, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		l.AddRecord(r)
+	}
+}
+
+// RecordWriter writes a log one record at a time.
+type RecordWriter interface {
+	WriteRecord(*Record) error
+	// Close writes any data which follows records, e.g. a trailing comment, and
+	// flushes output.  The underlying io.Writer is not closed.
+	Close() error
+}
+
+// StreamWriter writes a log without needing all records in memory.
+type StreamWriter interface {
+	// WriteHeader writes the header and userdef fields of l to out and returns
+	// a RecordWriter for the log's records.  l.Records is ignored;
+	// l.FieldOrder is consulted as each record is written and l.Comment is
+	// consulted by Close, so they may change after WriteHeader returns.
+	WriteHeader(l *Logfile, out io.Writer) (RecordWriter, error)
+}
@@ -15,6 +15,8 @@
 package adif
 
 import (
+	"bufio"
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -78,11 +80,6 @@ func (j jsonRecord) toRecord() (*Record, error) {
 	return r, nil
 }
 
-type jsonFile struct {
-	Header  jsonRecord   `json:"HEADER"`
-	Records []jsonRecord `json:"RECORDS"`
-}
-
 type JSONIO struct {
 	HTMLSafe    bool
 	Indent      int
@@ -93,44 +90,193 @@ func NewJSONIO() *JSONIO { return &JSONIO{} }
 
 func (_ *JSONIO) String() string { return "json" }
 
-func (o *JSONIO) Read(in io.Reader) (*Logfile, error) {
+func (o *JSONIO) Read(in io.Reader) (*Logfile, error) { return ReadAll(o, in) }
+
+// ReadRecords decodes JSON records one at a time.  The header is passed to
+// h.Header before ReadRecords returns if the HEADER key comes before RECORDS,
+// as it does in files written by JSONIO.
+func (o *JSONIO) ReadRecords(in io.Reader, h StreamHandler) (RecordIterator, error) {
 	d := json.NewDecoder(in)
 	d.UseNumber()
-	var f jsonFile
-	if err := d.Decode(&f); err != nil {
-		return nil, fmt.Errorf("JSON decoding error: %w", err)
+	if err := expectJSONDelim(d, '{'); err != nil {
+		return nil, err
 	}
-	l := NewLogfile()
-	if f.Header != nil {
-		h, err := f.Header.toRecord()
-		if err != nil {
+	it := &jsonRecords{d: d, h: h}
+	if err := it.readKeys(); err != nil {
+		return nil, err
+	}
+	return it, nil
+}
+
+type jsonRecords struct {
+	d                   *json.Decoder
+	h                   StreamHandler
+	inRecords, finished bool
+}
+
+func (it *jsonRecords) Next() (*Record, error) {
+	for !it.finished {
+		if it.inRecords {
+			if it.d.More() {
+				var j jsonRecord
+				if err := it.d.Decode(&j); err != nil {
+					return nil, fmt.Errorf("JSON decoding error: %w", err)
+				}
+				return j.toRecord()
+			}
+			if err := expectJSONDelim(it.d, ']'); err != nil {
+				return nil, err
+			}
+			it.inRecords = false
+		}
+		if err := it.readKeys(); err != nil {
 			return nil, err
 		}
-		l.Header = h
 	}
-	l.Records = make([]*Record, len(f.Records))
-	for i, r := range f.Records {
-		rec, err := r.toRecord()
+	return nil, io.EOF
+}
+
+// readKeys processes object keys until the start of the RECORDS array or the
+// end of the top-level object.
+func (it *jsonRecords) readKeys() error {
+	for it.d.More() {
+		t, err := it.d.Token()
 		if err != nil {
-			return nil, err
+			return fmt.Errorf("JSON decoding error: %w", err)
+		}
+		key, ok := t.(string)
+		if !ok {
+			return fmt.Errorf("JSON decoding error: unexpected %v", t)
+		}
+		switch strings.ToUpper(key) {
+		case "HEADER":
+			var j jsonRecord
+			if err := it.d.Decode(&j); err != nil {
+				return fmt.Errorf("JSON decoding error: %w", err)
+			}
+			if j != nil {
+				h, err := j.toRecord()
+				if err != nil {
+					return err
+				}
+				if it.h.Header != nil {
+					if err := it.h.Header(h); err != nil {
+						return err
+					}
+				}
+			}
+		case "RECORDS":
+			t, err := it.d.Token()
+			if err != nil {
+				return fmt.Errorf("JSON decoding error: %w", err)
+			}
+			if t == nil { // null
+				continue
+			}
+			if t != json.Delim('[') {
+				return fmt.Errorf("JSON decoding error: expected RECORDS array, got %v", t)
+			}
+			it.inRecords = true
+			return nil
+		default:
+			var ignored json.RawMessage
+			if err := it.d.Decode(&ignored); err != nil {
+				return fmt.Errorf("JSON decoding error: %w", err)
+			}
 		}
-		l.Records[i] = rec
 	}
-	return l, nil
+	if err := expectJSONDelim(it.d, '}'); err != nil {
+		return err
+	}
+	it.finished = true
+	return nil
 }
 
-func (o *JSONIO) Write(l *Logfile, out io.Writer) error {
-	var f jsonFile
-	f.Header = newJsonRecord(l.Header, o.TypedOutput)
-	f.Records = make([]jsonRecord, len(l.Records))
-	for i, r := range l.Records {
-		f.Records[i] = newJsonRecord(r, o.TypedOutput)
-	}
-	e := json.NewEncoder(out)
-	e.SetIndent("", strings.Repeat(" ", o.Indent))
-	e.SetEscapeHTML(o.HTMLSafe)
-	if err := e.Encode(f); err != nil {
-		return fmt.Errorf("JSON encoding error: %w", err)
+func expectJSONDelim(d *json.Decoder, delim json.Delim) error {
+	t, err := d.Token()
+	if err != nil {
+		return fmt.Errorf("JSON decoding error: %w", err)
+	}
+	if t != delim {
+		return fmt.Errorf("JSON decoding error: expected %v, got %v", delim, t)
 	}
 	return nil
 }
+
+func (o *JSONIO) Write(l *Logfile, out io.Writer) error {
+	w, err := o.WriteHeader(l, out)
+	if err != nil {
+		return err
+	}
+	for _, r := range l.Records {
+		if err := w.WriteRecord(r); err != nil {
+			return err
+		}
+	}
+	return w.Close()
+}
+
+// WriteHeader starts a JSON object with HEADER and RECORDS keys.  Output is
+// formatted the same as encoding/json would format a whole log.
+func (o *JSONIO) WriteHeader(l *Logfile, out io.Writer) (RecordWriter, error) {
+	w := &jsonRecordWriter{o: o, b: bufio.NewWriter(out)}
+	if o.Indent > 0 {
+		w.indent = strings.Repeat(" ", o.Indent)
+	}
+	h, err := w.encode(newJsonRecord(l.Header, o.TypedOutput), w.indent)
+	if err != nil {
+		return nil, err
+	}
+	if w.indent == "" {
+		fmt.Fprintf(w.b, `{"HEADER":%s,"RECORDS":[`, h)
+	} else {
+		fmt.Fprintf(w.b, "{\n%s\"HEADER\": %s,\n%s\"RECORDS\": [", w.indent, h, w.indent)
+	}
+	return w, nil
+}
+
+type jsonRecordWriter struct {
+	o      *JSONIO
+	b      *bufio.Writer
+	indent string
+	count  int
+}
+
+func (w *jsonRecordWriter) encode(j jsonRecord, prefix string) ([]byte, error) {
+	var buf bytes.Buffer
+	e := json.NewEncoder(&buf)
+	e.SetIndent(prefix, w.indent)
+	e.SetEscapeHTML(w.o.HTMLSafe)
+	if err := e.Encode(j); err != nil {
+		return nil, fmt.Errorf("JSON encoding error: %w", err)
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+func (w *jsonRecordWriter) WriteRecord(r *Record) error {
+	j, err := w.encode(newJsonRecord(r, w.o.TypedOutput), w.indent+w.indent)
+	if err != nil {
+		return err
+	}
+	if w.count > 0 {
+		w.b.WriteByte(',')
+	}
+	if w.indent != "" {
+		w.b.WriteString("\n" + w.indent + w.indent)
+	}
+	w.count++
+	_, err = w.b.Write(j)
+	return err
+}
+
+func (w *jsonRecordWriter) Close() error {
+	if w.indent != "" && w.count > 0 {
+		w.b.WriteString("\n" + w.indent)
+	}
+	if w.indent != "" {
+		w.b.WriteString("]\n}\n")
+	} else {
+		w.b.WriteString("]}\n")
+	}
+	return w.b.Flush()
+}
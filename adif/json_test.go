@@ -15,6 +15,7 @@
 package adif
 
 import (
+	"io"
 	"strings"
 	"testing"
 
@@ -185,3 +186,33 @@ Inverted L antenna, < 70' above ground
 		}
 	}
 }
+
+func TestJSONReadRecords(t *testing.T) {
+	input := `{"RECORDS": [{"CALL": "W1AW"}, {"CALL": "N0P", "BAND": "40m"}], "OTHER": [1, {"x": 2}], "HEADER": {"PROGRAMID": "json_test"}}`
+	var header *Record
+	it, err := NewJSONIO().ReadRecords(strings.NewReader(input), StreamHandler{Header: func(r *Record) error { header = r; return nil }})
+	if err != nil {
+		t.Fatalf("ReadRecords(%q) got error %v", input, err)
+	}
+	want := []*Record{
+		NewRecord(Field{Name: "CALL", Value: "W1AW"}),
+		NewRecord(Field{Name: "BAND", Value: "40m"}, Field{Name: "CALL", Value: "N0P"}),
+	}
+	var got []*Record
+	for {
+		r, err := it.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Next() got error %v", err)
+		}
+		got = append(got, r)
+	}
+	if diff := cmp.Diff(want, got, cmp.Comparer(func(a, b *Record) bool { return a.Equal(b) })); diff != "" {
+		t.Errorf("ReadRecords(%q) got diff:\n%s", input, diff)
+	}
+	if diff := cmp.Diff(NewRecord(Field{Name: "PROGRAMID", Value: "json_test"}), header); diff != "" {
+		t.Errorf("ReadRecords(%q) header diff:\n%s", input, diff)
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adif
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// JSONLIO reads and writes JSON Lines (also known as newline-delimited JSON),
+// one JSON object per record with no header, see https://jsonlines.org/
+type JSONLIO struct {
+	HTMLSafe    bool
+	TypedOutput bool
+}
+
+func NewJSONLIO() *JSONLIO { return &JSONLIO{} }
+
+func (_ *JSONLIO) String() string { return "jsonl" }
+
+func (o *JSONLIO) Read(in io.Reader) (*Logfile, error) { return ReadAll(o, in) }
+
+// ReadRecords decodes one JSON object at a time.  Blank lines are ignored.
+func (o *JSONLIO) ReadRecords(in io.Reader, h StreamHandler) (RecordIterator, error) {
+	d := json.NewDecoder(in)
+	d.UseNumber()
+	return &jsonlRecords{d: d}, nil
+}
+
+type jsonlRecords struct {
+	d     *json.Decoder
+	count int
+}
+
+func (it *jsonlRecords) Next() (*Record, error) {
+	if !it.d.More() {
+		// distinguish trailing garbage like ] from the end of input
+		if _, err := it.d.Token(); err != io.EOF {
+			return nil, fmt.Errorf("JSON Lines decoding error after record %d: expected object", it.count)
+		}
+		return nil, io.EOF
+	}
+	var j jsonRecord
+	if err := it.d.Decode(&j); err != nil {
+		return nil, fmt.Errorf("JSON Lines decoding error in record %d: %w", it.count+1, err)
+	}
+	it.count++
+	if j == nil {
+		return nil, fmt.Errorf("JSON Lines decoding error in record %d: expected object, got null", it.count)
+	}
+	return j.toRecord()
+}
+
+func (o *JSONLIO) Write(l *Logfile, out io.Writer) error {
+	w, err := o.WriteHeader(l, out)
+	if err != nil {
+		return err
+	}
+	for _, r := range l.Records {
+		if err := w.WriteRecord(r); err != nil {
+			return err
+		}
+	}
+	return w.Close()
+}
+
+// WriteHeader returns a RecordWriter which writes each record as a compact
+// JSON object on its own line.  JSON Lines has no header, so l's header
+// fields are not written.
+func (o *JSONLIO) WriteHeader(l *Logfile, out io.Writer) (RecordWriter, error) {
+	b := bufio.NewWriter(out)
+	e := json.NewEncoder(b)
+	e.SetEscapeHTML(o.HTMLSafe)
+	return &jsonlRecordWriter{o: o, b: b, e: e}, nil
+}
+
+type jsonlRecordWriter struct {
+	o *JSONLIO
+	b *bufio.Writer
+	e *json.Encoder
+}
+
+func (w *jsonlRecordWriter) WriteRecord(r *Record) error {
+	if err := w.e.Encode(newJsonRecord(r, w.o.TypedOutput)); err != nil {
+		return fmt.Errorf("JSON encoding error: %w", err)
+	}
+	return nil
+}
+
+func (w *jsonlRecordWriter) Close() error { return w.b.Flush() }
@@ -0,0 +1,103 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adif
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestReadJSONL(t *testing.T) {
+	input := `{"QSO_DATE": "19901031", "TIME_ON": "1234", "CALL": "W1AW"}
+
+{"QSO_DATE": 20221224, "CALL": "N0P", "QSO_RANDOM": false}
+{"call": "1AY", "FREQ": 7.654, "RIG": "100 watt C.W.\nArmstrong regenerative circuit"}
+`
+	want := []*Record{
+		NewRecord(Field{Name: "CALL", Value: "W1AW"},
+			Field{Name: "QSO_DATE", Value: "19901031"},
+			Field{Name: "TIME_ON", Value: "1234"},
+		),
+		NewRecord(Field{Name: "CALL", Value: "N0P"},
+			Field{Name: "QSO_DATE", Value: "20221224", Type: TypeNumber},
+			Field{Name: "QSO_RANDOM", Value: "N", Type: TypeBoolean},
+		),
+		NewRecord(Field{Name: "FREQ", Value: "7.654", Type: TypeNumber},
+			Field{Name: "RIG", Value: "100 watt C.W.\nArmstrong regenerative circuit"},
+			Field{Name: "call", Value: "1AY"},
+		),
+	}
+	l, err := NewJSONLIO().Read(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Read(%q) got error %v", input, err)
+	}
+	if diff := cmp.Diff(want, l.Records, cmp.Comparer(func(a, b *Record) bool { return a.Equal(b) })); diff != "" {
+		t.Errorf("Read(%q) got diff:\n%s", input, diff)
+	}
+}
+
+func TestReadJSONLErrors(t *testing.T) {
+	tests := []string{
+		`{"CALL": "W1AW"`,
+		`{"CALL": "W1AW"}` + "\n" + `["N0P"]`,
+		`{"CALL": "W1AW"}` + "\n" + `null`,
+		`{"CALL": "W1AW"}` + "\n]",
+		`{"CALL": ["W1AW", "N0P"]}`,
+	}
+	for _, tc := range tests {
+		if l, err := NewJSONLIO().Read(strings.NewReader(tc)); err == nil {
+			t.Errorf("Read(%q) got %v, want error", tc, l)
+		}
+	}
+}
+
+func TestWriteJSONL(t *testing.T) {
+	l := NewLogfile()
+	l.Header.Set(Field{Name: "PROGRAMID", Value: "jsonl_test"})
+	l.AddRecord(NewRecord(
+		Field{Name: "QSO_DATE", Value: "19901031", Type: TypeDate},
+		Field{Name: "CALL", Value: "W1AW"},
+		Field{Name: "NAME", Value: "Hiram Percy Maxim", Type: TypeString},
+	)).AddRecord(NewRecord(
+		Field{Name: "call", Value: "1AY"},
+		Field{Name: "FREQ", Value: "7.654", Type: TypeNumber},
+		Field{Name: "SILENT_KEY", Value: "Y", Type: TypeBoolean},
+		Field{Name: "RIG", Value: "100 watt C.W.\n< 70' above ground"},
+	))
+	tests := []struct {
+		typed, htmlSafe bool
+		want            string
+	}{
+		{want: `{"CALL":"W1AW","NAME":"Hiram Percy Maxim","QSO_DATE":"19901031"}
+{"CALL":"1AY","FREQ":"7.654","RIG":"100 watt C.W.\n< 70' above ground","SILENT_KEY":"Y"}
+`},
+		{typed: true, htmlSafe: true, want: `{"CALL":"W1AW","NAME":"Hiram Percy Maxim","QSO_DATE":"19901031"}
+{"CALL":"1AY","FREQ":7.654,"RIG":"100 watt C.W.\n\u003c 70' above ground","SILENT_KEY":true}
+`},
+	}
+	for _, tc := range tests {
+		jsonl := NewJSONLIO()
+		jsonl.TypedOutput = tc.typed
+		jsonl.HTMLSafe = tc.htmlSafe
+		out := &strings.Builder{}
+		if err := jsonl.Write(l, out); err != nil {
+			t.Errorf("Write(%v) typed=%v got error %v", l, tc.typed, err)
+		} else if diff := cmp.Diff(tc.want, out.String()); diff != "" {
+			t.Errorf("Write(%v) typed=%v had diff with expected:\n%s", l, tc.typed, diff)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adif
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ColumnMapping changes the column names of CSV and TSV input into field names
+// and optionally converts values, e.g. to read spreadsheets with headers like
+// "Their Call" and "Freq kHz".  A nil or zero ColumnMapping uses column names
+// as field names.  ColumnMapping does not affect output.
+type ColumnMapping struct {
+	// Columns, if not empty, names the columns of input which does not have a
+	// header row.
+	Columns []string
+	// Adjust, if not nil, is called with each record after values have been
+	// converted, e.g. to change time zones.
+	Adjust func(*Record) error
+
+	fields map[string]ColumnField
+}
+
+// ColumnField is the field for a column in a ColumnMapping.
+type ColumnField struct {
+	// Name is the field name, or empty to ignore the column.
+	Name string
+	// Convert, if not nil, changes each non-empty value, e.g. to different units.
+	Convert func(string) (string, error)
+}
+
+// NormalizeColumnName returns a column name in upper case with runs of spaces
+// replaced by a single space, so "Time  (utc)" and "TIME (UTC)" are the same.
+func NormalizeColumnName(s string) string {
+	return strings.ToUpper(strings.Join(strings.Fields(s), " "))
+}
+
+// Add maps column to field f, returning an error if column is already mapped.
+func (m *ColumnMapping) Add(column string, f ColumnField) error {
+	c := NormalizeColumnName(column)
+	if c == "" {
+		return fmt.Errorf("empty column name for field %s", f.Name)
+	}
+	if _, ok := m.fields[c]; ok {
+		return fmt.Errorf("duplicate mapping for column %q", column)
+	}
+	if m.fields == nil {
+		m.fields = make(map[string]ColumnField)
+	}
+	f.Name = strings.ToUpper(strings.TrimSpace(f.Name))
+	m.fields[c] = f
+	return nil
+}
+
+// Field returns the field for column, which is the column name if it is not
+// in the mapping.
+func (m *ColumnMapping) Field(column string) ColumnField {
+	if m != nil {
+		if f, ok := m.fields[NormalizeColumnName(column)]; ok {
+			return f
+		}
+	}
+	return ColumnField{Name: column}
+}
+
+// header returns Columns if set, or nil if the input has a header row.
+func (m *ColumnMapping) header() []string {
+	if m == nil || len(m.Columns) == 0 {
+		return nil
+	}
+	res := make([]string, len(m.Columns))
+	copy(res, m.Columns)
+	return res
+}
+
+// columnFields returns the field for each column in header and the field
+// names in order, skipping ignored columns.  It is an error for two columns to
+// map to the same field.
+func (m *ColumnMapping) columnFields(header []string) ([]ColumnField, []string, error) {
+	res := make([]ColumnField, len(header))
+	order := make([]string, 0, len(header))
+	seen := make(map[string]string)
+	for i, h := range header {
+		f := m.Field(h)
+		res[i] = f
+		if f.Name == "" {
+			continue
+		}
+		n := strings.ToUpper(f.Name)
+		if prev, ok := seen[n]; ok && m != nil && len(m.fields) > 0 {
+			return nil, nil, fmt.Errorf("columns %q and %q are both mapped to %s", prev, h, n)
+		}
+		seen[n] = h
+		order = append(order, n)
+	}
+	return res, order, nil
+}
+
+// convert applies f's conversion to a non-empty value v.
+func (f ColumnField) convert(column, v string) (string, error) {
+	if f.Convert == nil || strings.TrimSpace(v) == "" {
+		return v, nil
+	}
+	c, err := f.Convert(v)
+	if err != nil {
+		return v, fmt.Errorf("column %q %s value %q: %w", column, f.Name, v, err)
+	}
+	return c, nil
+}
+
+func (m *ColumnMapping) adjust(r *Record) error {
+	if m == nil || m.Adjust == nil {
+		return nil
+	}
+	return m.Adjust(r)
+}
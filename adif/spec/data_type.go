@@ -24,3 +24,17 @@ type DataType struct {
 
 // DataTypes allows lookups by DataType.Name and DataType.Indicator.
 var DataTypes = make(map[string]DataType)
+
+// ListSeparator returns the string which separates values of list data types,
+// e.g. "," for POTARefList and ":" for SecondarySubdivisionList, or an empty
+// string if t is not a list type.
+func (t DataType) ListSeparator() string {
+	switch t.Name {
+	case AwardListDataType.Name, CreditListDataType.Name, GridSquareListDataType.Name, POTARefListDataType.Name, SponsoredAwardListDataType.Name:
+		return ","
+	case SecondarySubdivisionListDataType.Name:
+		return ":"
+	default:
+		return ""
+	}
+}
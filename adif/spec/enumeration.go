@@ -17,6 +17,7 @@ package spec
 import (
 	"fmt"
 	"strings"
+	"sync"
 )
 
 type EnumValue interface {
@@ -28,11 +29,28 @@ type Enumeration struct {
 	Name       string
 	Properties []string
 	Values     []EnumValue
+	// index speeds up lookups; if nil, Values are scanned
+	index *enumIndex
+}
+
+// enumIndex maps case-folded values to EnumValues, built on first use since
+// most programs only look at a few enumerations.
+type enumIndex struct {
+	once   sync.Once
+	values map[string][]EnumValue
+	// scoped is keyed by value and ScopeProperty, e.g. subdivision and DXCC
+	scoped map[[2]string][]EnumValue
 }
 
 func (e Enumeration) String() string { return e.Name }
 
+// Value returns all values matching val, ignoring case.  Most enumerations
+// have at most one match, but some, like Primary_Administrative_Subdivision,
+// have the same code in several scopes.
 func (e Enumeration) Value(val string) []EnumValue {
+	if e.index != nil {
+		return clip(e.lookup().values[enumKey(val)])
+	}
 	res := make([]EnumValue, 0, 2)
 	for _, v := range e.Values {
 		// Band is lower case, most others upper
@@ -43,6 +61,52 @@ func (e Enumeration) Value(val string) []EnumValue {
 	return res
 }
 
+// ScopedValue returns the values matching val whose ScopeProperty matches
+// scope, ignoring case, e.g. a subdivision code within a DXCC entity code.
+// Enumerations without a ScopeProperty return nil.
+func (e Enumeration) ScopedValue(val, scope string) []EnumValue {
+	prop := e.ScopeProperty()
+	if prop == "" {
+		return nil
+	}
+	if e.index != nil {
+		return clip(e.lookup().scoped[[2]string{enumKey(val), enumKey(scope)}])
+	}
+	var res []EnumValue
+	for _, v := range e.Value(val) {
+		if strings.EqualFold(scope, v.Property(prop)) {
+			res = append(res, v)
+		}
+	}
+	return res
+}
+
+func (e Enumeration) lookup() *enumIndex {
+	e.index.once.Do(func() {
+		e.index.values = make(map[string][]EnumValue, len(e.Values))
+		prop := e.ScopeProperty()
+		if prop != "" {
+			e.index.scoped = make(map[[2]string][]EnumValue, len(e.Values))
+		}
+		for _, v := range e.Values {
+			k := enumKey(v.String())
+			e.index.values[k] = append(e.index.values[k], v)
+			if prop != "" {
+				sk := [2]string{k, enumKey(v.Property(prop))}
+				e.index.scoped[sk] = append(e.index.scoped[sk], v)
+			}
+		}
+	})
+	return e.index
+}
+
+// enumKey folds case for index keys, matching strings.EqualFold for the
+// characters used in the ADIF specification.
+func enumKey(s string) string { return strings.ToUpper(s) }
+
+// clip prevents callers from appending to a slice in the index.
+func clip(v []EnumValue) []EnumValue { return v[:len(v):len(v)] }
+
 func (e Enumeration) ScopeProperty() string {
 	switch e.Name {
 	case "Primary_Administrative_Subdivision":
@@ -14,7 +14,12 @@
 
 package spec
 
-import "testing"
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
 
 func TestEnumerationDeclared(t *testing.T) {
 	if len(Enumerations) == 0 {
@@ -47,3 +52,76 @@ func TestEnumerationValues(t *testing.T) {
 		}
 	}
 }
+
+func TestEnumerationIndex(t *testing.T) {
+	for name, e := range Enumerations {
+		if e.index == nil {
+			t.Errorf("%s has no index", name)
+			continue
+		}
+		linear := e
+		linear.index = nil
+		prop := e.ScopeProperty()
+		for _, v := range e.Values {
+			for _, s := range []string{v.String(), strings.ToLower(v.String()), strings.ToUpper(v.String())} {
+				if diff := cmp.Diff(linear.Value(s), e.Value(s)); diff != "" {
+					t.Errorf("%s.Value(%q) index differs from scan:\n%s", name, s, diff)
+				}
+				if prop == "" {
+					continue
+				}
+				scope := v.Property(prop)
+				if diff := cmp.Diff(linear.ScopedValue(s, scope), e.ScopedValue(s, scope)); diff != "" {
+					t.Errorf("%s.ScopedValue(%q, %q) index differs from scan:\n%s", name, s, scope, diff)
+				}
+				if got := e.ScopedValue(s, scope); len(got) == 0 {
+					t.Errorf("%s.ScopedValue(%q, %q) got no values", name, s, scope)
+				}
+			}
+		}
+		if got := e.Value("no such value"); len(got) != 0 {
+			t.Errorf("%s.Value(%q) got %v, want empty", name, "no such value", got)
+		}
+	}
+}
+
+func TestEnumerationScopedValue(t *testing.T) {
+	tests := []struct {
+		enum       Enumeration
+		val, scope string
+		want       int
+	}{
+		{enum: PrimaryAdministrativeSubdivisionEnumeration, val: "ON", scope: "1", want: 1},
+		{enum: PrimaryAdministrativeSubdivisionEnumeration, val: "on", scope: "291", want: 0},
+		{enum: PrimaryAdministrativeSubdivisionEnumeration, val: "MA", scope: "291", want: 1},
+		{enum: SecondaryAdministrativeSubdivisionEnumeration, val: "ak,bethel", scope: "6", want: 1},
+		{enum: SubmodeEnumeration, val: "ft4", scope: "mfsk", want: 1},
+		{enum: SubmodeEnumeration, val: "FT4", scope: "SSB", want: 0},
+		{enum: BandEnumeration, val: "20m", scope: "", want: 0},
+	}
+	for _, tc := range tests {
+		if got := tc.enum.ScopedValue(tc.val, tc.scope); len(got) != tc.want {
+			t.Errorf("%s.ScopedValue(%q, %q) got %v, want %d values", tc.enum, tc.val, tc.scope, got, tc.want)
+		}
+	}
+}
+
+func BenchmarkEnumerationValue(b *testing.B) {
+	e := PrimaryAdministrativeSubdivisionEnumeration
+	vals := make([]string, len(e.Values))
+	for i, v := range e.Values {
+		vals[i] = strings.ToLower(v.String())
+	}
+	b.Run("index", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			e.Value(vals[i%len(vals)])
+		}
+	})
+	linear := e
+	linear.index = nil
+	b.Run("scan", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			linear.Value(vals[i%len(vals)])
+		}
+	})
+}
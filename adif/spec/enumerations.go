@@ -86,6 +86,7 @@ var AntPathEnumeration = Enumeration{
 		AntPathS,
 		AntPathL,
 	},
+	index: &enumIndex{},
 }
 
 type ArrlSectionEnum struct {
@@ -756,6 +757,7 @@ var ArrlSectionEnumeration = Enumeration{
 		ArrlSectionWI,
 		ArrlSectionWY,
 	},
+	index: &enumIndex{},
 }
 
 type AwardEnum struct {
@@ -972,6 +974,7 @@ var AwardEnumeration = Enumeration{
 		AwardUSACA,
 		AwardVUCC,
 	},
+	index: &enumIndex{},
 }
 
 type AwardSponsorEnum struct {
@@ -1084,6 +1087,7 @@ var AwardSponsorEnumeration = Enumeration{
 		AwardSponsorTAG,
 		AwardSponsorWABAG,
 	},
+	index: &enumIndex{},
 }
 
 type BandEnum struct {
@@ -1365,6 +1369,7 @@ var BandEnumeration = Enumeration{
 		Band1mm,
 		Bandsubmm,
 	},
+	index: &enumIndex{},
 }
 
 type ContestIdEnum struct {
@@ -2813,6 +2818,7 @@ var ContestIdEnumeration = Enumeration{
 		ContestIdYOHFDX,
 		ContestIdYUDXC,
 	},
+	index: &enumIndex{},
 }
 
 type ContinentEnum struct {
@@ -2901,6 +2907,7 @@ var ContinentEnumeration = Enumeration{
 		ContinentAS,
 		ContinentAN,
 	},
+	index: &enumIndex{},
 }
 
 type CreditEnum struct {
@@ -3523,6 +3530,7 @@ var CreditEnumeration = Enumeration{
 		CreditWITUZ,
 		CreditWITUZ_BAND,
 	},
+	index: &enumIndex{},
 }
 
 type DxccEntityCodeEnum struct {
@@ -6053,6 +6061,7 @@ var DxccEntityCodeEnumeration = Enumeration{
 		DxccEntityCode521,
 		DxccEntityCode522,
 	},
+	index: &enumIndex{},
 }
 
 type ModeEnum struct {
@@ -6613,6 +6622,7 @@ var ModeEnumeration = Enumeration{
 		ModeQPSK125,
 		ModeTHRBX,
 	},
+	index: &enumIndex{},
 }
 
 type PrimaryAdministrativeSubdivisionEnum struct {
@@ -21196,6 +21206,7 @@ var PrimaryAdministrativeSubdivisionEnumeration = Enumeration{
 		PrimaryAdministrativeSubdivisionSVI_504,
 		PrimaryAdministrativeSubdivisionVRT_504,
 	},
+	index: &enumIndex{},
 }
 
 type PropagationModeEnum struct {
@@ -21362,6 +21373,7 @@ var PropagationModeEnumeration = Enumeration{
 		PropagationModeTEP,
 		PropagationModeTR,
 	},
+	index: &enumIndex{},
 }
 
 type QslMediumEnum struct {
@@ -21426,6 +21438,7 @@ var QslMediumEnumeration = Enumeration{
 		QslMediumEQSL,
 		QslMediumLOTW,
 	},
+	index: &enumIndex{},
 }
 
 type QslRcvdEnum struct {
@@ -21511,6 +21524,7 @@ var QslRcvdEnumeration = Enumeration{
 		QslRcvdI,
 		QslRcvdV,
 	},
+	index: &enumIndex{},
 }
 
 type QslSentEnum struct {
@@ -21595,6 +21609,7 @@ var QslSentEnumeration = Enumeration{
 		QslSentQ,
 		QslSentI,
 	},
+	index: &enumIndex{},
 }
 
 type QslViaEnum struct {
@@ -21666,6 +21681,7 @@ var QslViaEnumeration = Enumeration{
 		QslViaE,
 		QslViaM,
 	},
+	index: &enumIndex{},
 }
 
 type QsoCompleteEnum struct {
@@ -21736,6 +21752,7 @@ var QsoCompleteEnumeration = Enumeration{
 		QsoCompleteNotHeard,
 		QsoCompleteUncertain,
 	},
+	index: &enumIndex{},
 }
 
 type QsoUploadStatusEnum struct {
@@ -21800,6 +21817,7 @@ var QsoUploadStatusEnumeration = Enumeration{
 		QsoUploadStatusN,
 		QsoUploadStatusM,
 	},
+	index: &enumIndex{},
 }
 
 type RegionEnum struct {
@@ -21927,6 +21945,7 @@ var RegionEnumeration = Enumeration{
 		RegionKO,
 		RegionET,
 	},
+	index: &enumIndex{},
 }
 
 type SecondaryAdministrativeSubdivisionEnum struct {
@@ -22474,6 +22493,7 @@ var SecondaryAdministrativeSubdivisionEnumeration = Enumeration{
 		SecondaryAdministrativeSubdivisionAK_Yakutat,
 		SecondaryAdministrativeSubdivisionAK_Yukon_Koyukuk,
 	},
+	index: &enumIndex{},
 }
 
 type SubmodeEnum struct {
@@ -23597,6 +23617,7 @@ var SubmodeEnumeration = Enumeration{
 		SubmodeVARA_FM_1200,
 		SubmodeVARA_FM_9600,
 	},
+	index: &enumIndex{},
 }
 
 type CountryEnum struct {
@@ -26460,6 +26481,7 @@ var CountryEnumeration = Enumeration{
 		CountrySouthSudanRepublicOf,
 		CountryRepublicOfKosovo,
 	},
+	index: &enumIndex{},
 }
 
 func init() {
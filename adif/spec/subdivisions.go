@@ -0,0 +1,91 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spec
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Subdivisions is a list of secondary administrative subdivisions like US
+// counties and Japanese cities, guns, and ku.  The ADIF specification only
+// enumerates Alaska's subdivisions and refers to external sources for the
+// rest, so a Subdivisions list can be loaded from such a source and set in a
+// ValidationContext.
+type Subdivisions struct {
+	// entities maps DXCC entity code to upper-case secondary subdivision to
+	// primary subdivision
+	entities map[int]map[string]string
+}
+
+func NewSubdivisions() *Subdivisions {
+	return &Subdivisions{entities: make(map[int]map[string]string)}
+}
+
+// Add adds secondary subdivision code, which is part of primary subdivision
+// (which may be empty if unknown) in DXCC entity dxcc.
+func (s *Subdivisions) Add(dxcc int, primary, secondary string) {
+	e := s.entities[dxcc]
+	if e == nil {
+		e = make(map[string]string)
+		s.entities[dxcc] = e
+	}
+	e[strings.ToUpper(strings.TrimSpace(secondary))] = strings.ToUpper(strings.TrimSpace(primary))
+}
+
+// HasEntity returns true if any subdivisions were added for dxcc.
+func (s *Subdivisions) HasEntity(dxcc int) bool {
+	return s != nil && len(s.entities[dxcc]) > 0
+}
+
+// Primary returns the primary subdivision of secondary in DXCC entity dxcc
+// and false if secondary is not known in dxcc.
+func (s *Subdivisions) Primary(dxcc int, secondary string) (string, bool) {
+	if s == nil {
+		return "", false
+	}
+	p, ok := s.entities[dxcc][strings.ToUpper(strings.TrimSpace(secondary))]
+	return p, ok
+}
+
+// ValidateSubdivision checks a secondary subdivision value which is not in the
+// ADIF enumeration against ctx.Subdivisions.  The DXCC entity comes from
+// f.EnumScope and the primary subdivision from STATE or MY_STATE.  Values are
+// accepted if the entity is unknown or there is no subdivision list for it.
+func ValidateSubdivision(val string, f Field, ctx ValidationContext) Validation {
+	if ctx.Subdivisions == nil || ctx.FieldValue == nil || f.EnumScope == "" {
+		return valid()
+	}
+	dxcc, err := strconv.Atoi(strings.TrimSpace(ctx.FieldValue(f.EnumScope)))
+	if err != nil || !ctx.Subdivisions.HasEntity(dxcc) {
+		return valid()
+	}
+	primary, ok := ctx.Subdivisions.Primary(dxcc, val)
+	if !ok {
+		fn := errorf
+		if ctx.UnknownEnumValueWarning {
+			fn = warningf
+		}
+		return fn("%s unknown value %q for %s %d", f.Name, val, f.EnumScope, dxcc)
+	}
+	state := StateField.Name
+	if strings.HasPrefix(f.Name, "MY_") {
+		state = MyStateField.Name
+	}
+	if s := strings.TrimSpace(ctx.FieldValue(state)); primary != "" && s != "" && !strings.EqualFold(s, primary) {
+		return errorf("%s %q is in %s %s, not %s", f.Name, val, state, primary, s)
+	}
+	return valid()
+}
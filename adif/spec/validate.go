@@ -79,6 +79,9 @@ var (
 type ValidationContext struct {
 	UnknownEnumValueWarning bool // if true, values not in an enumeration are a warning, otherwise an error
 	FieldValue              func(name string) string
+	// Subdivisions, if set, has secondary administrative subdivisions which
+	// are not in the ADIF enumeration, e.g. US counties.
+	Subdivisions *Subdivisions
 }
 
 type FieldValidator func(value string, f Field, ctx ValidationContext) Validation
@@ -107,10 +110,10 @@ var TypeValidators = map[string]FieldValidator{
 	"String":                   ValidateString,
 	"Time":                     ValidateTime,
 	"WWFFRef":                  formatValidator("WWFF reference", wwffPat),
-	"AwardList":                ValidateNoop, // TODO
-	"CreditList":               ValidateNoop, // TODO
-	"SecondarySubdivisionList": ValidateNoop, // TODO
-	"SponsoredAwardList":       ValidateNoop, // TODO
+	"AwardList":                ValidateAwardList,
+	"CreditList":               ValidateCreditList,
+	"SecondarySubdivisionList": ValidateSecondarySubdivisionList,
+	"SponsoredAwardList":       ValidateSponsoredAwardList,
 }
 
 func ValidateNoop(value string, f Field, ctx ValidationContext) Validation { return valid() }
@@ -325,9 +328,9 @@ func ValidateEnumeration(val string, f Field, ctx ValidationContext) Validation
 	vals := e.Value(val)
 	if len(vals) == 0 {
 		if e.Name == "Secondary_Administrative_Subdivision" {
-			// TODO add ValidateCounties; ADIF spec only lists Alaska values but has
-			// references to formats and other sources in III.B.12
-			return valid()
+			// ADIF spec only lists Alaska values but has references to formats and
+			// other sources in III.B.12
+			return ValidateSubdivision(val, f, ctx)
 		}
 		fn := errorf
 		if ctx.UnknownEnumValueWarning {
@@ -359,24 +362,142 @@ func ValidateEnumScope(val string, f Field, ctx ValidationContext) Validation {
 		sval = ctx.FieldValue(f.EnumScope)
 	}
 	if sval != "" {
-		var match bool
-		prop := e.ScopeProperty()
-		if prop == "" {
+		if e.ScopeProperty() == "" {
 			return warningf("%s config error! %s doesn't have a ScopeProperty", f.Name, e.Name)
 		}
-		for _, v := range vals {
-			if strings.EqualFold(v.Property(prop), sval) {
-				match = true
+		if len(e.ScopedValue(val, sval)) == 0 {
+			return warningf("%s value %q is not valid for %s=%q", f.Name, val, f.EnumScope, sval)
+		}
+	}
+	return valid()
+}
+
+// ValidateAwardList checks a comma-separated list of Award enumeration values.
+func ValidateAwardList(val string, f Field, ctx ValidationContext) Validation {
+	if val == "" {
+		return valid()
+	}
+	for _, v := range strings.Split(val, ",") {
+		if v == "" {
+			return errorf("%s empty item in list %q", f.Name, val)
+		}
+		if len(AwardEnumeration.Value(v)) == 0 {
+			return unknownEnumValue(f, v, AwardEnumeration, ctx)
+		}
+	}
+	return valid()
+}
+
+// ValidateCreditList checks a comma-separated list of Credit enumeration
+// values, each optionally followed by a colon and an &-separated list of
+// QSL_Medium values, e.g. IOTA,WAS:LOTW&CARD,DXCC:CARD.  Since fields with
+// this type may also have import-only Award values, those are warnings.
+func ValidateCreditList(val string, f Field, ctx ValidationContext) Validation {
+	if val == "" {
+		return valid()
+	}
+	var award []string
+	for _, v := range strings.Split(val, ",") {
+		credit, media, hasMedia := strings.Cut(v, ":")
+		if credit == "" {
+			return errorf("%s empty credit in list %q", f.Name, val)
+		}
+		if len(CreditEnumeration.Value(credit)) == 0 {
+			if hasMedia || len(AwardEnumeration.Value(credit)) == 0 {
+				return unknownEnumValue(f, credit, CreditEnumeration, ctx)
+			}
+			award = append(award, credit)
+		}
+		if hasMedia {
+			for _, m := range strings.Split(media, "&") {
+				if m == "" {
+					return errorf("%s empty QSL medium for %s in %q", f.Name, credit, val)
+				}
+				if len(QslMediumEnumeration.Value(m)) == 0 {
+					return unknownEnumValue(f, m, QslMediumEnumeration, ctx)
+				}
+			}
+		}
+	}
+	if len(award) > 0 {
+		return warningf("%s import-only award values %s", f.Name, strings.Join(award, ","))
+	}
+	return valid()
+}
+
+// ValidateSponsoredAwardList checks a comma-separated list of awards, each
+// starting with an Award_Sponsor prefix like ADIF_ or ARRL_.
+func ValidateSponsoredAwardList(val string, f Field, ctx ValidationContext) Validation {
+	if val == "" {
+		return valid()
+	}
+	for _, v := range strings.Split(val, ",") {
+		if v == "" {
+			return errorf("%s empty item in list %q", f.Name, val)
+		}
+		var ok bool
+		for _, s := range AwardSponsorEnumeration.Values {
+			p := s.(AwardSponsorEnum).Sponsor
+			if len(v) > len(p) && strings.EqualFold(v[:len(p)], p) {
+				ok = true
 				break
 			}
 		}
-		if !match {
-			return warningf("%s value %q is not valid for %s=%q", f.Name, val, f.EnumScope, sval)
+		if !ok {
+			fn := errorf
+			if ctx.UnknownEnumValueWarning {
+				fn = warningf
+			}
+			return fn("%s award %q does not start with a sponsor from %s", f.Name, v, AwardSponsorEnumeration.Name)
 		}
 	}
 	return valid()
 }
 
+// ValidateSecondarySubdivisionList checks a colon-separated list of
+// Secondary_Administrative_Subdivision codes like MA,Franklin:MA,Hampshire.
+// The enumeration only lists Alaska boroughs, so codes for other places are
+// only checked for format unless ctx.Subdivisions has US counties.
+func ValidateSecondarySubdivisionList(val string, f Field, ctx ValidationContext) Validation {
+	if val == "" {
+		return valid()
+	}
+	list := strings.Split(val, ":")
+	if len(list) < 2 {
+		return errorf("%s needs two or more subdivisions separated by colons, got %q", f.Name, val)
+	}
+	for _, v := range list {
+		primary, secondary, ok := strings.Cut(v, ",")
+		if !ok || strings.TrimSpace(primary) == "" || strings.TrimSpace(secondary) == "" {
+			return errorf("%s subdivision %q is not in the form STATE,County in %q", f.Name, v, val)
+		}
+		if len(SecondaryAdministrativeSubdivisionEnumeration.Value(v)) > 0 {
+			continue
+		}
+		if strings.EqualFold(primary, "AK") {
+			return unknownEnumValue(f, v, SecondaryAdministrativeSubdivisionEnumeration, ctx)
+		}
+		if ctx.Subdivisions.HasEntity(usaDxcc) {
+			if p, ok := ctx.Subdivisions.Primary(usaDxcc, v); !ok || (p != "" && !strings.EqualFold(p, strings.TrimSpace(primary))) {
+				return unknownEnumValue(f, v, SecondaryAdministrativeSubdivisionEnumeration, ctx)
+			}
+		}
+	}
+	return valid()
+}
+
+// usaDxcc is the DXCC entity code for the United States, whose counties are
+// used in SecondarySubdivisionList fields for the USA-CA award.
+const usaDxcc = 291
+
+func unknownEnumValue(f Field, val string, e Enumeration, ctx ValidationContext) Validation {
+	fn := errorf
+	if ctx.UnknownEnumValueWarning {
+		fn = warningf
+	}
+	return fn("%s unknown value %q for enumeration %s", f.Name, val, e.Name)
+}
+
 func gridsquarerValidator(maxLen int) FieldValidator {
 	return func(val string, f Field, ctx ValidationContext) Validation {
 		if val == "" {
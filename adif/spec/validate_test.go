@@ -747,3 +747,140 @@ func TestValidateWWFFRef(t *testing.T) {
 		testValidator(t, tc, emptyCtx, "ValidateWWFFRef")
 	}
 }
+
+func TestValidateAwardList(t *testing.T) {
+	awards := Field{Name: "TEST_AWARDS", Type: AwardListDataType}
+	tests := []validateTest{
+		{field: awards, value: "", want: Valid},
+		{field: awards, value: "AJA", want: Valid},
+		{field: awards, value: "dxcc,CQWAZ_MIXED,IOTA", want: Valid},
+		{field: awards, value: "DXCC,", want: InvalidError},
+		{field: awards, value: "POTA", want: InvalidError},
+		{field: awards, value: "DXCC WAS", want: InvalidError},
+	}
+	for _, tc := range tests {
+		testValidator(t, tc, emptyCtx, "ValidateAwardList")
+	}
+}
+
+func TestValidateCreditList(t *testing.T) {
+	tests := []validateTest{
+		{field: CreditSubmittedField, value: "", want: Valid},
+		{field: CreditGrantedField, value: "IOTA", want: Valid},
+		{field: CreditSubmittedField, value: "IOTA,WAS:LOTW&CARD,DXCC:CARD", want: Valid},
+		{field: CreditGrantedField, value: "was_band:eqsl", want: Valid},
+		{field: CreditSubmittedField, value: "DXCC_CW", want: InvalidWarning},
+		{field: CreditGrantedField, value: "DXCC,AJA", want: InvalidWarning},
+		{field: CreditSubmittedField, value: "AJA:CARD", want: InvalidError},
+		{field: CreditGrantedField, value: "DXCC:", want: InvalidError},
+		{field: CreditSubmittedField, value: "DXCC:LOTW&", want: InvalidError},
+		{field: CreditGrantedField, value: "DXCC:QRZ", want: InvalidError},
+		{field: CreditSubmittedField, value: ":CARD", want: InvalidError},
+		{field: CreditGrantedField, value: "DXCC,,WAS", want: InvalidError},
+		{field: CreditSubmittedField, value: "DXCC;WAS", want: InvalidError},
+		{field: CreditGrantedField, value: "SOTA", want: InvalidError},
+	}
+	for _, tc := range tests {
+		testValidator(t, tc, emptyCtx, "ValidateCreditList")
+	}
+}
+
+func TestValidateSponsoredAwardList(t *testing.T) {
+	tests := []validateTest{
+		{field: AwardSubmittedField, value: "", want: Valid},
+		{field: AwardGrantedField, value: "ADIF_CENTURY_BASIC", want: Valid},
+		{field: AwardSubmittedField, value: "ADIF_CENTURY_BASIC,ADIF_CENTURY_SILVER,ADIF_SPECTRUM_100-160m", want: Valid},
+		{field: AwardGrantedField, value: "arrl_dxcc,cq_waz", want: Valid},
+		{field: AwardSubmittedField, value: "ADIF_", want: InvalidError},
+		{field: AwardGrantedField, value: "ADIF_CENTURY_BASIC,", want: InvalidError},
+		{field: AwardSubmittedField, value: "DXCC", want: InvalidError},
+		{field: AwardGrantedField, value: "XYZ_AWARD", want: InvalidError},
+	}
+	for _, tc := range tests {
+		testValidator(t, tc, emptyCtx, "ValidateSponsoredAwardList")
+	}
+}
+
+func TestValidateSecondarySubdivisionList(t *testing.T) {
+	tests := []validateTest{
+		{field: UsacaCountiesField, value: "", want: Valid},
+		{field: MyUsacaCountiesField, value: "MA,Franklin:MA,Hampshire", want: Valid},
+		{field: UsacaCountiesField, value: "AK,Aleutians East:AK,Bethel", want: Valid},
+		{field: MyUsacaCountiesField, value: "NY,Bronx:NY,New York:NY,Kings", want: Valid},
+		{field: UsacaCountiesField, value: "MA,Franklin", want: InvalidError},
+		{field: MyUsacaCountiesField, value: "MA,Franklin:", want: InvalidError},
+		{field: UsacaCountiesField, value: "MA,Franklin:Hampshire", want: InvalidError},
+		{field: MyUsacaCountiesField, value: "MA,Franklin,MA,Hampshire", want: InvalidError},
+		{field: UsacaCountiesField, value: "AK,Aleutians East:AK,Anchorage Borough", want: InvalidError},
+		{field: MyUsacaCountiesField, value: ",Franklin:MA,", want: InvalidError},
+	}
+	for _, tc := range tests {
+		testValidator(t, tc, emptyCtx, "ValidateSecondarySubdivisionList")
+	}
+}
+
+func TestValidateSubdivisions(t *testing.T) {
+	subs := NewSubdivisions()
+	subs.Add(291, "MA", "MA,Franklin")
+	subs.Add(291, "MA", "MA,Hampshire")
+	subs.Add(291, "NY", "NY,New York")
+	subs.Add(339, "10", "1001")
+	tests := []struct {
+		validateTest
+		values map[string]string
+	}{
+		{
+			validateTest: validateTest{field: CntyField, value: "MA,Franklin", want: Valid},
+			values:       map[string]string{"DXCC": "291", "STATE": "MA"},
+		},
+		{
+			validateTest: validateTest{field: MyCntyField, value: "ma,hampshire", want: Valid},
+			values:       map[string]string{"MY_DXCC": "291"},
+		},
+		{
+			validateTest: validateTest{field: CntyField, value: "AK,Bethel", want: Valid},
+			values:       map[string]string{"DXCC": "6", "STATE": "AK"},
+		},
+		{
+			validateTest: validateTest{field: CntyField, value: "1001", want: Valid},
+			values:       map[string]string{"DXCC": "339", "STATE": "10"},
+		},
+		{
+			validateTest: validateTest{field: CntyField, value: "Anything", want: Valid},
+			values:       map[string]string{"DXCC": "1"},
+		},
+		{
+			validateTest: validateTest{field: CntyField, value: "MA,Franklin", want: Valid},
+			values:       map[string]string{},
+		},
+		{
+			validateTest: validateTest{field: CntyField, value: "MA,Frankln", want: InvalidError},
+			values:       map[string]string{"DXCC": "291", "STATE": "MA"},
+		},
+		{
+			validateTest: validateTest{field: MyCntyField, value: "MA,Franklin", want: InvalidError},
+			values:       map[string]string{"MY_DXCC": "291", "MY_STATE": "NY"},
+		},
+		{
+			validateTest: validateTest{field: CntyField, value: "1002", want: InvalidError},
+			values:       map[string]string{"DXCC": "339", "STATE": "10"},
+		},
+		{
+			validateTest: validateTest{field: UsacaCountiesField, value: "MA,Franklin:NY,New York", want: Valid},
+		},
+		{
+			validateTest: validateTest{field: UsacaCountiesField, value: "MA,Franklin:AK,Bethel", want: Valid},
+		},
+		{
+			validateTest: validateTest{field: UsacaCountiesField, value: "MA,Franklin:NY,Franklin", want: InvalidError},
+		},
+	}
+	for _, tc := range tests {
+		ctx := ValidationContext{Subdivisions: subs, FieldValue: func(name string) string { return tc.values[name] }}
+		testValidator(t, tc.validateTest, ctx, "ValidateSubdivisions")
+	}
+	for _, v := range []string{"MA,Frankln", "1002"} {
+		ctx := ValidationContext{FieldValue: func(string) string { return "291" }}
+		testValidator(t, validateTest{field: CntyField, value: v, want: Valid}, ctx, "ValidateEnumeration")
+	}
+}
@@ -26,20 +26,29 @@ type TSVIO struct {
 	CRLF               bool
 	EscapeSpecial      bool
 	IgnoreEmptyHeaders bool
+	// Mapping, if not nil, converts columns to fields when reading
+	Mapping *ColumnMapping
 }
 
 func NewTSVIO() *TSVIO { return &TSVIO{} }
 
-func (o *TSVIO) Read(r io.Reader) (*Logfile, error) {
+func (o *TSVIO) Read(r io.Reader) (*Logfile, error) { return ReadAll(o, r) }
+
+func (o *TSVIO) ReadRecords(r io.Reader, h StreamHandler) (RecordIterator, error) {
 	scan := bufio.NewScanner(r)
-	if !scan.Scan() {
-		return nil, errors.New("no TSV header row")
-	}
-	headRow := scan.Text()
-	if strings.TrimSpace(headRow) == "" {
-		return nil, errors.New("empty TSV header row")
+	head := o.Mapping.header()
+	line := 0
+	if head == nil {
+		if !scan.Scan() {
+			return nil, errors.New("no TSV header row")
+		}
+		line++
+		headRow := scan.Text()
+		if strings.TrimSpace(headRow) == "" {
+			return nil, errors.New("empty TSV header row")
+		}
+		head = strings.Split(headRow, "\t")
 	}
-	head := strings.Split(headRow, "\t")
 	seen := make(map[string]bool)
 	for i, h := range head {
 		head[i] = strings.ToUpper(o.unescape(strings.TrimSpace(h)))
@@ -54,97 +63,201 @@ func (o *TSVIO) Read(r io.Reader) (*Logfile, error) {
 			seen[head[i]] = true
 		}
 	}
-	line := 1 // after reading header
-	l := NewLogfile()
-	l.FieldOrder = head
-	for scan.Scan() {
-		line++
-		fs := strings.Split(scan.Text(), "\t")
-		if len(fs) > len(head) {
-			return nil, fmt.Errorf("line %d has %d fields, more than %d in TSV header", len(l.Records)+2, len(fs), len(head))
+	fields, order, err := o.Mapping.columnFields(head)
+	if err != nil {
+		return nil, fmt.Errorf("TSV header: %w", err)
+	}
+	if h.FieldOrder != nil {
+		h.FieldOrder(order)
+	}
+	return &tsvRecords{o: o, scan: scan, head: head, fields: fields, line: line}, nil
+}
+
+type tsvRecords struct {
+	o      *TSVIO
+	scan   *bufio.Scanner
+	head   []string
+	fields []ColumnField
+	line   int
+}
+
+func (it *tsvRecords) Position() Position { return Position{Line: it.line, Offset: -1} }
+
+func (it *tsvRecords) Next() (*Record, error) {
+	for it.scan.Scan() {
+		it.line++
+		fs := strings.Split(it.scan.Text(), "\t")
+		if len(fs) > len(it.head) {
+			return nil, fmt.Errorf("line %d has %d fields, more than %d in TSV header", it.line, len(fs), len(it.head))
 		}
 		if len(fs) == 1 && fs[0] == "" {
 			continue // skip blank lines
 		}
-		fields := make([]Field, len(fs))
-		for i, f := range fs {
-			fields[i] = Field{Name: head[i], Value: o.unescape(f)}
+		fields := make([]Field, 0, len(fs))
+		for i, v := range fs {
+			f := it.fields[i]
+			if f.Name == "" {
+				continue
+			}
+			v, err := f.convert(it.head[i], it.o.unescape(v))
+			if err != nil {
+				return nil, fmt.Errorf("TSV line %d: %w", it.line, err)
+			}
+			fields = append(fields, Field{Name: f.Name, Value: v})
+		}
+		r := NewRecord(fields...)
+		if err := it.o.Mapping.adjust(r); err != nil {
+			return nil, fmt.Errorf("TSV line %d: %w", it.line, err)
 		}
-		l.AddRecord(NewRecord(fields...))
+		return r, nil
 	}
-	if err := scan.Err(); err != nil {
-		return nil, fmt.Errorf("reading TSV line %d: %w", line, err)
+	if err := it.scan.Err(); err != nil {
+		return nil, fmt.Errorf("reading TSV line %d: %w", it.line, err)
 	}
-	return l, nil
+	return nil, io.EOF
 }
 
-func (o *TSVIO) Write(l *Logfile, w io.Writer) error {
-	order := make([]string, len(l.FieldOrder))
-	seen := make(map[string]bool)
-	for i, n := range l.FieldOrder {
-		n = strings.ToUpper(n)
-		if !o.EscapeSpecial && strings.ContainsAny(n, "\t\r\n") {
-			return fmt.Errorf("invalid TSV field name %q", n)
+func (o *TSVIO) Write(l *Logfile, out io.Writer) error {
+	w := o.newRecordWriter(l, out)
+	w.columns = columnNames(l.FieldOrder, l.Records)
+	// check everything before writing anything
+	for _, n := range w.columns {
+		if err := o.checkName(n); err != nil {
+			return err
 		}
-		order[i] = n
-		seen[n] = true
 	}
 	for _, r := range l.Records {
-		for _, f := range r.Fields() {
-			n := strings.ToUpper(f.Name)
-			if !o.EscapeSpecial && strings.ContainsAny(n, "\t\r\n") {
-				return fmt.Errorf("invalid TSV field name %q", n)
-			}
-			if !o.EscapeSpecial && strings.ContainsAny(f.Value, "\t\r\n") {
-				return fmt.Errorf("invalid TSV field value %q = %q", n, f.Value)
-			}
-			if !seen[n] {
-				order = append(order, n)
-				seen[n] = true
-			}
+		if err := o.checkValues(r); err != nil {
+			return err
+		}
+	}
+	for _, r := range l.Records {
+		if err := w.WriteRecord(r); err != nil {
+			return err
+		}
+	}
+	return w.Close()
+}
+
+// WriteHeader returns a RecordWriter for TSV rows.  The header row is written
+// with the first record, or by Close if there are no records.  Columns are
+// l.FieldOrder followed by any other fields in the first record, so if records
+// have different fields, l.FieldOrder needs to list all of them; WriteRecord
+// returns an error for a non-empty field which is not a column.
+func (o *TSVIO) WriteHeader(l *Logfile, out io.Writer) (RecordWriter, error) {
+	return o.newRecordWriter(l, out), nil
+}
+
+func (o *TSVIO) newRecordWriter(l *Logfile, out io.Writer) *tsvRecordWriter {
+	return &tsvRecordWriter{o: o, l: l, b: bufio.NewWriter(out)}
+}
+
+func (o *TSVIO) checkName(n string) error {
+	if !o.EscapeSpecial && strings.ContainsAny(n, "\t\r\n") {
+		return fmt.Errorf("invalid TSV field name %q", n)
+	}
+	return nil
+}
+
+func (o *TSVIO) checkValues(r *Record) error {
+	for _, f := range r.Fields() {
+		if err := o.checkName(f.Name); err != nil {
+			return err
+		}
+		if !o.EscapeSpecial && strings.ContainsAny(f.Value, "\t\r\n") {
+			return fmt.Errorf("invalid TSV field value %q = %q", strings.ToUpper(f.Name), f.Value)
+		}
+	}
+	return nil
+}
+
+type tsvRecordWriter struct {
+	o       *TSVIO
+	l       *Logfile
+	b       *bufio.Writer
+	columns []string
+	index   map[string]bool
+	row     []string
+	started bool
+	count   int
+}
+
+// start writes the header row, adding fields from r if columns were not set.
+func (w *tsvRecordWriter) start(r *Record) error {
+	w.started = true
+	if w.columns == nil {
+		var recs []*Record
+		if r != nil {
+			recs = append(recs, r)
+		}
+		w.columns = columnNames(w.l.FieldOrder, recs)
+	}
+	w.index = make(map[string]bool)
+	for _, n := range w.columns {
+		if err := w.o.checkName(n); err != nil {
+			return err
 		}
+		w.index[n] = true
+	}
+	w.row = make([]string, len(w.columns))
+	if err := w.writeRow(w.columns); err != nil {
+		return fmt.Errorf("writing TSV header: %w", err)
 	}
-	if len(order) == 0 {
+	return nil
+}
+
+func (w *tsvRecordWriter) writeRow(vals []string) error {
+	if len(vals) == 0 {
 		return nil
 	}
-	out := bufio.NewWriter(w)
-	writeDelim := func(i int) error {
-		if i < len(order)-1 {
-			if err := out.WriteByte('\t'); err != nil {
-				return err
-			}
-		} else {
-			if o.CRLF {
-				if err := out.WriteByte('\r'); err != nil {
-					return err
-				}
-			}
-			if err := out.WriteByte('\n'); err != nil {
+	for i, v := range vals {
+		if i > 0 {
+			if err := w.b.WriteByte('\t'); err != nil {
 				return err
 			}
 		}
-		return nil
+		if _, err := w.b.WriteString(w.o.escape(v)); err != nil {
+			return err
+		}
 	}
-	for i, h := range order {
-		if _, err := out.WriteString(o.escape(h)); err != nil {
-			return fmt.Errorf("writing TSV header: %w", err)
+	if w.o.CRLF {
+		if err := w.b.WriteByte('\r'); err != nil {
+			return err
 		}
-		if err := writeDelim(i); err != nil {
-			return fmt.Errorf("writing TSV header: %w", err)
+	}
+	return w.b.WriteByte('\n')
+}
+
+func (w *tsvRecordWriter) WriteRecord(r *Record) error {
+	if !w.started {
+		if err := w.start(r); err != nil {
+			return err
 		}
 	}
-	for _, r := range l.Records {
-		for i, h := range order {
-			f, _ := r.Get(h)
-			if _, err := out.WriteString(o.escape(f.Value)); err != nil {
-				return fmt.Errorf("writing TSV record: %w", err)
-			}
-			if err := writeDelim(i); err != nil {
-				return fmt.Errorf("writing TSV header: %w", err)
-			}
+	w.count++
+	if err := w.o.checkValues(r); err != nil {
+		return err
+	}
+	if err := checkColumns(r, w.index); err != nil {
+		return fmt.Errorf("writing TSV record %d: %w", w.count, err)
+	}
+	for i, n := range w.columns {
+		f, _ := r.Get(n)
+		w.row[i] = f.Value
+	}
+	if err := w.writeRow(w.row); err != nil {
+		return fmt.Errorf("writing TSV record %d: %w", w.count, err)
+	}
+	return nil
+}
+
+func (w *tsvRecordWriter) Close() error {
+	if !w.started {
+		if err := w.start(nil); err != nil {
+			return err
 		}
 	}
-	return out.Flush()
+	return w.b.Flush()
 }
 
 func (o *TSVIO) escape(s string) string {
@@ -107,6 +107,40 @@ func TestWriteTSV(t *testing.T) {
 	}
 }
 
+func TestTSVWriteHeader(t *testing.T) {
+	l := NewLogfile()
+	out := &strings.Builder{}
+	w, err := NewTSVIO().WriteHeader(l, out)
+	if err != nil {
+		t.Fatalf("WriteHeader got error %v", err)
+	}
+	// field order can change before the first record
+	l.FieldOrder = []string{"BAND"}
+	for _, r := range []*Record{
+		NewRecord(Field{Name: "CALL", Value: "W1AW"}, Field{Name: "BAND", Value: "40m"}),
+		NewRecord(Field{Name: "CALL", Value: "K2B"}),
+	} {
+		if err := w.WriteRecord(r); err != nil {
+			t.Fatalf("WriteRecord(%v) got error %v", r, err)
+		}
+	}
+	for _, r := range []*Record{
+		NewRecord(Field{Name: "CALL", Value: "N3C"}, Field{Name: "MODE", Value: "CW"}),
+		NewRecord(Field{Name: "CALL", Value: "N3C"}, Field{Name: "BAND", Value: "20\tm"}),
+	} {
+		if err := w.WriteRecord(r); err == nil {
+			t.Errorf("WriteRecord(%v) got no error", r)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close got error %v", err)
+	}
+	want := "BAND\tCALL\n40m\tW1AW\n\tK2B\n"
+	if diff := cmp.Diff(want, out.String()); diff != "" {
+		t.Errorf("streaming write had diff with expected:\n%s", diff)
+	}
+}
+
 func TestTSVRejectSpecialCharacters(t *testing.T) {
 	tests := []*Logfile{
 		NewLogfile().AddRecord(NewRecord(Field{Name: "APP_TEST_TrailingNewline", Value: "Foo\n"})),
@@ -183,3 +217,47 @@ func TestTSVEscapeSpecialCharacters(t *testing.T) {
 		}
 	}
 }
+
+func TestTSVColumnMapping(t *testing.T) {
+	input := "Their Call\tFreq, kHz\tRemarks\n" +
+		"w1aw\t14074\tignored\n"
+	m := &ColumnMapping{}
+	if err := m.Add("Their Call", ColumnField{Name: "CALL", Convert: func(s string) (string, error) { return strings.ToUpper(s), nil }}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Add("Freq, kHz", ColumnField{Name: "FREQ"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Add("Remarks", ColumnField{}); err != nil {
+		t.Fatal(err)
+	}
+	tsv := NewTSVIO()
+	tsv.Mapping = m
+	parsed, err := tsv.Read(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Read(%q) got error %v", input, err)
+	}
+	want := [][]Field{{{Name: "CALL", Value: "W1AW"}, {Name: "FREQ", Value: "14074"}}}
+	if len(parsed.Records) != len(want) {
+		t.Fatalf("Read(%q) got %d records, want %d", input, len(parsed.Records), len(want))
+	}
+	for i, r := range parsed.Records {
+		if diff := cmp.Diff(want[i], r.Fields()); diff != "" {
+			t.Errorf("Read(%q) record %d diff:\n%s", input, i+1, diff)
+		}
+	}
+
+	input = "K0A\t7030\n"
+	tsv.Mapping = &ColumnMapping{Columns: []string{"CALL", "FREQ"}}
+	parsed, err = tsv.Read(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Read(%q) with columns got error %v", input, err)
+	}
+	want = [][]Field{{{Name: "CALL", Value: "K0A"}, {Name: "FREQ", Value: "7030"}}}
+	if len(parsed.Records) != len(want) {
+		t.Fatalf("Read(%q) with columns got %d records, want %d", input, len(parsed.Records), len(want))
+	}
+	if diff := cmp.Diff(want[0], parsed.Records[0].Fields()); diff != "" {
+		t.Errorf("Read(%q) with columns diff:\n%s", input, diff)
+	}
+}
@@ -15,9 +15,45 @@
 package adif
 
 import (
+	"fmt"
 	"strings"
 )
 
+// columnNames returns upper case field names for formats with a header row,
+// like CSV: order followed by any other fields in recs, in the order they
+// first appear.
+func columnNames(order []string, recs []*Record) []string {
+	res := make([]string, 0, len(order))
+	seen := make(map[string]bool)
+	add := func(n string) {
+		n = strings.ToUpper(n)
+		if !seen[n] {
+			res = append(res, n)
+			seen[n] = true
+		}
+	}
+	for _, n := range order {
+		add(n)
+	}
+	for _, r := range recs {
+		for _, f := range r.Fields() {
+			add(f.Name)
+		}
+	}
+	return res
+}
+
+// checkColumns returns an error if r has a non-empty field which is not in
+// columns, which has upper case names.
+func checkColumns(r *Record, columns map[string]bool) error {
+	for _, f := range r.Fields() {
+		if f.Value != "" && !columns[strings.ToUpper(f.Name)] {
+			return fmt.Errorf("field %s is not in the header row", f.Name)
+		}
+	}
+	return nil
+}
+
 func ensureCRLF(s string) string {
 	first := -1
 	var wasCR bool
@@ -17,6 +17,7 @@ package main
 import (
 	"flag"
 	"fmt"
+	"strings"
 
 	"github.com/flwyd/adif-multitool/adif/spec"
 	"github.com/flwyd/adif-multitool/cmd"
@@ -30,21 +31,56 @@ type cmdConfig struct {
 var (
 	catConf = cmdConfig{Command: cmd.Cat}
 
+	awardsConf = cmdConfig{Command: cmd.Awards,
+		Configure: func(ctx *cmd.Context, fs *flag.FlagSet) {
+			cctx := cmd.AwardsContext{}
+			fs.Var(&cctx.Awards, "award", "Comma-separated or multiple instance award `names`: "+strings.Join([]string{cmd.AwardDXCC, cmd.AwardWAS, cmd.AwardWAZ, cmd.AwardVUCC, cmd.AwardIOTA}, ", ")+" (default all)")
+			fs.Var(&cctx.Confirmation, "confirmation", "Comma-separated or multiple instance confirmation `sources`: "+strings.Join([]string{cmd.ConfirmQSL, cmd.ConfirmLOTW, cmd.ConfirmEQSL}, ", ")+" (default all)")
+			fs.BoolVar(&cctx.ByBand, "by-band", false, "Count awards separately for each band")
+			fs.BoolVar(&cctx.ByMode, "by-mode", false, "Count awards separately for each mode")
+			fs.BoolVar(&cctx.Needed, "needed", false, "Output a record for each entity which has not been confirmed")
+			ctx.CommandCtx = &cctx
+		}}
+
+	countConf = cmdConfig{Command: cmd.Count,
+		Configure: func(ctx *cmd.Context, fs *flag.FlagSet) {
+			cctx := cmd.CountContext{Fields: make(cmd.FieldList, 0, 4)}
+			fs.Var(&cctx.Fields, "fields", "Comma-separated or multiple instance field `names` to count distinct values of")
+			fs.BoolVar(&cctx.CrossTab, "cross-tab", false, "With two --fields, output a matrix of counts with a column for each value of the second field")
+			fs.StringVar(&cctx.Style, "style", cmd.CountStyleRecords, "Output `style`: "+cmd.CountStyleRecords+" for a record per distinct value, "+cmd.CountStyleReport+" for input records with a \"Report\" comment")
+			ctx.CommandCtx = &cctx
+		}}
+
+	dedupeConf = cmdConfig{Command: cmd.Dedupe,
+		Configure: func(ctx *cmd.Context, fs *flag.FlagSet) {
+			cctx := cmd.DedupeContext{Keys: make(cmd.FieldList, 0, 8)}
+			fs.Var(&cctx.Keys, "key", "Comma-separated or multiple instance field `names` which must match for records to be duplicates")
+			fs.DurationVar(&cctx.Window, "window", 0, "Only consider records duplicates if TIME_ON is within `duration` (e.g. 24h, 30m) of the original")
+			fs.BoolVar(&cctx.SameUTCDay, "same-utc-day", false, "Only consider records duplicates if QSO_DATE is the same as the original")
+			fs.StringVar(&cctx.Action, "action", cmd.DedupeDrop, "What to do with duplicates: "+cmd.DedupeDrop+", "+cmd.DedupeFlag+", or "+cmd.DedupeReport)
+			fs.StringVar(&cctx.FlagField, "flag-field", "", "With -action flag or report, set `field` (e.g. APP_MYLOG_DUPE) to Y on duplicates rather than adding a comment")
+			ctx.CommandCtx = &cctx
+		}}
+
 	editConf = cmdConfig{Command: cmd.Edit,
 		Configure: func(ctx *cmd.Context, fs *flag.FlagSet) {
 			cctx := cmd.EditContext{
-				Add:    cmd.NewFieldAssignments(cmd.ValidateAlphanumName),
-				Set:    cmd.NewFieldAssignments(cmd.ValidateAlphanumName),
+				Add:    cmd.NewFieldAssignments(cmd.ValidateAssignment),
+				Set:    cmd.NewFieldAssignments(cmd.ValidateAssignment),
 				Remove: make(cmd.FieldList, 0)}
 			// fs.Var(&cctx.If, "if", "Only edit records where `field=value` is already set (repeatable)")
 			fs.Var(cctx.Cond.IfFlag(), "if", "Only edit records where `condition` is true (repeatable)")
 			fs.Var(cctx.Cond.IfNotFlag(), "if-not", "Only edit records where `condition` is false (repeatable)")
 			fs.Var(cctx.Cond.OrIfFlag(), "or-if", "Only edit records where `condition` is true or any previous --if group is true (repeatable)")
 			fs.Var(cctx.Cond.OrIfNotFlag(), "or-if-not", "Only edit records where `condition` is false or any previous --if group is true (repeatable)")
-			fs.Var(&cctx.Add, "add", "Add `field=value` if field is not already in a record (repeatable)")
-			fs.Var(&cctx.Set, "set", "Set `field=value` for all records (repeatable)")
+			fs.Var(cctx.Cond.WhereFlag(), "where", "Only edit records where boolean `expression` is true, e.g. \"mode=CW AND (band=20m OR band=40m)\" (repeatable)")
+			fs.Var(&cctx.Add, "add", "Add `field=value` if field is not already in a record, value may use {field} or expressions like upper({call}) (repeatable)")
+			fs.Var(&cctx.Set, "set", "Set `field=value` for all records, value may use {field} or expressions like upper({call}) (repeatable)")
 			fs.Var(&cctx.Remove, "remove", "Remove `fields` from all records (comma-separated, repeatable)")
 			fs.BoolVar(&cctx.RemoveBlank, "remove-blank", false, "Remove all blank fields")
+			fs.Var(&cctx.Rename, "rename", "Rename `old=new` fields, keeping their position (comma-separated, repeatable)")
+			fs.Var(&cctx.Copy, "copy", "Copy `source=destination` field values (comma-separated, repeatable)")
+			fs.BoolVar(&cctx.Overwrite, "overwrite", false, "Allow -rename and -copy to replace fields which already have a different value")
 			fs.Var(&cctx.FromZone, "time-zone-from", "Adjust times and dates from this time `zone` into -time-zone-to (default UTC)")
 			fs.Var(&cctx.ToZone, "time-zone-to", "Adjust times and dates into this time `zone` from -time-zone-from (default UTC)")
 			ctx.CommandCtx = &cctx
@@ -57,10 +93,21 @@ var (
 			fs.Var(cctx.Cond.IfNotFlag(), "if-not", "Include records where `condition` is false (repeatable)")
 			fs.Var(cctx.Cond.OrIfFlag(), "or-if", "Include records where `condition` is true or any previous --if group is true (repeatable)")
 			fs.Var(cctx.Cond.OrIfNotFlag(), "or-if-not", "Include records where `condition` is false or any previous --if group is true (repeatable)")
+			fs.Var(cctx.Cond.WhereFlag(), "where", "Include records where boolean `expression` is true, e.g. \"mode=CW AND (band=20m OR band=40m)\" (repeatable)")
 			ctx.CommandCtx = &cctx
 		}}
 
-	fixConf = cmdConfig{Command: cmd.Fix}
+	fixConf = cmdConfig{Command: cmd.Fix,
+		Configure: func(ctx *cmd.Context, fs *flag.FlagSet) {
+			cctx := cmd.FixContext{}
+			fixes := strings.Join([]string{cmd.FixDate, cmd.FixTime, cmd.FixLocation, cmd.FixCountry, cmd.FixBoolean, cmd.FixCase, cmd.FixFrequency, cmd.FixBand, cmd.FixMode, cmd.FixContestID}, ", ")
+			fs.Var(&cctx.Fixes, "fixes", "Comma-separated or multiple instance fix category `names` to apply, default all: "+fixes)
+			fs.BoolVar(&cctx.CommentLog, "comment-log", false, "Add record comments with a list of fixed fields")
+			fs.StringVar(&cctx.DateFormat, "date-format", "", "Field order DMY, MDY, or YMD for ambiguous dates, or date `format` like DD/MM/YYYY, %d.%m.%y, or a Go layout")
+			fs.StringVar(&cctx.TimeFormat, "time-format", "", "Time `format` like HH.MM, hh:mm AM, %H.%M, or a Go layout")
+			fs.IntVar(&cctx.YearPivot, "year-pivot", 50, "Two-digit years less than `year` are in the 2000s, others in the 1900s")
+			ctx.CommandCtx = &cctx
+		}}
 
 	helpConf = cmdConfig{Command: cmd.Command{
 		Name: "help", Description: "Print program or command usage information",
@@ -74,6 +121,26 @@ var (
 			cctx := cmd.InferContext{}
 			fs.Var(&cctx.Fields, "fields", "Comma-separated or multiple instance field `names` to infer if absent")
 			fs.BoolVar(&cctx.CommentLog, "comment-log", false, "Add record comments with a list of successfully inferred fields")
+			fs.BoolVar(&cctx.LongPath, "long-path", false, "Infer DISTANCE and ANT_AZ along the long path if ANT_PATH is not set")
+			fs.StringVar(&cctx.CtyFile, "cty-file", "", "Country prefix `file` in cty.dat or cty.csv format for inferring fields from callsigns")
+			fs.StringVar(&cctx.BoundaryFile, "boundary-file", "", "GeoJSON `file` with subdivision boundaries for inferring CNTY from LAT/LON")
+			ctx.CommandCtx = &cctx
+		}}
+
+	pipeConf = cmdConfig{Command: cmd.Command{
+		Name: "pipe", Description: "Run several commands in one process, separated by " + pipeSeparator,
+		Help: helpPipe,
+		Run: func(*cmd.Context, []string) error {
+			// handled specially by main
+			return nil
+		}}}
+
+	potaConf = cmdConfig{Command: cmd.Pota,
+		Configure: func(ctx *cmd.Context, fs *flag.FlagSet) {
+			cctx := cmd.PotaContext{}
+			fs.StringVar(&cctx.UploadDir, "upload-dir", "", "Write an upload file for each activation to `directory`")
+			fs.BoolVar(&cctx.CreateDirectory, "create-dirs", false, "Create the upload directory if it does not exist")
+			fs.BoolVar(&cctx.Quiet, "quiet", false, "Don't print upload file names to standard error")
 			ctx.CommandCtx = &cctx
 		}}
 
@@ -83,6 +150,8 @@ var (
 			fs.BoolVar(&cctx.CreateDirectory, "create-dirs", false, "Create any needed parent directories of the output file(s)")
 			fs.BoolVar(&cctx.Quiet, "quiet", false, "Do not print record counts and file names to stderr")
 			fs.BoolVar(&cctx.OverwriteExisting, "overwrite-existing", false, "Overwrite output file if it already exists")
+			fs.BoolVar(&cctx.Append, "append", false, "Add records to the end of output file if it already exists")
+			fs.BoolVar(&cctx.SkipDuplicates, "skip-duplicates", false, "With -append, do not add records which exactly match a record in the existing file")
 			fs.BoolVar(&cctx.WriteIfEmpty, "write-if-empty", false, "Write output file even if standard input has no records")
 			ctx.CommandCtx = &cctx
 		}}
@@ -94,6 +163,15 @@ var (
 			ctx.CommandCtx = &cctx
 		}}
 
+	sotaConf = cmdConfig{Command: cmd.Sota,
+		Configure: func(ctx *cmd.Context, fs *flag.FlagSet) {
+			cctx := cmd.SotaContext{}
+			fs.StringVar(&cctx.SummitsFile, "summits", "", "SOTA summits list CSV `file` for computing points")
+			fs.StringVar(&cctx.ExportFile, "export", "", "Write SOTA database V2 CSV upload `file`")
+			fs.BoolVar(&cctx.Quiet, "quiet", false, "Don't print the export file name to standard error")
+			ctx.CommandCtx = &cctx
+		}}
+
 	sortConf = cmdConfig{Command: cmd.Sort,
 		Configure: func(ctx *cmd.Context, fs *flag.FlagSet) {
 			cctx := cmd.SortContext{Fields: make(cmd.FieldList, 0, 16)}
@@ -101,7 +179,23 @@ var (
 			ctx.CommandCtx = &cctx
 		}}
 
-	validateConf = cmdConfig{Command: cmd.Validate}
+	validateConf = cmdConfig{Command: cmd.Validate,
+		Configure: func(ctx *cmd.Context, fs *flag.FlagSet) {
+			cctx := cmd.ValidateContext{}
+			rules := strings.Join([]string{cmd.RuleBandFreq, cmd.RuleBandRxFreqRx, cmd.RuleSubmodeMode, cmd.RuleTimeOff, cmd.RuleGridLocation, cmd.RuleDxccCountry, cmd.RuleStateDxcc, cmd.RuleSigInfoRef, cmd.RuleCntyState}, ", ")
+			fs.Var(&cctx.DisableRules, "disable-rules", "Comma-separated or multiple instance record consistency rule `names` to skip: "+rules)
+			fs.Var(&cctx.ErrorRules, "error-rules", "Comma-separated or multiple instance record consistency rule `names` to report as errors")
+			fs.Var(&cctx.WarningRules, "warning-rules", "Comma-separated or multiple instance record consistency rule `names` to report as warnings")
+			fs.Var(&cctx.Profiles, "profile", "Comma-separated or multiple instance upload `profiles` to check records against, e.g. lotw, pota (see help validate)")
+			fs.StringVar(&cctx.ProfileFile, "profile-file", "", "Read additional validation profiles from `file`")
+			fs.StringVar(&cctx.SubdivisionsFile, "subdivisions-file", "", "CSV `file` of secondary subdivisions with DXCC, STATE, and CNTY columns for validating CNTY")
+			fs.StringVar(&cctx.ReportFormat, "report-format", cmd.ValidateReportText, "Validation message `format`: "+cmd.ValidateReportText+" or "+cmd.ValidateReportJSON)
+			fs.StringVar(&cctx.ReportFile, "report-file", "", "Write validation messages to `file` rather than standard error")
+			fs.IntVar(&cctx.MaxErrors, "max-errors", 0, "Stop validating after `count` errors (0 for no limit)")
+			fs.IntVar(&cctx.ErrorExitCode, "error-exit-code", 1, "Exit `status` if any record has an error")
+			fs.IntVar(&cctx.WarningExitCode, "warning-exit-code", 0, "Exit `status` if there are warnings but no errors; output is still written")
+			ctx.CommandCtx = &cctx
+		}}
 
 	versionConf = cmdConfig{Command: cmd.Command{
 		Name: "version", Description: "Print program version information",
@@ -114,14 +208,20 @@ var (
 
 	cmds = []cmdConfig{
 		catConf,
+		awardsConf,
+		countConf,
+		dedupeConf,
 		editConf,
 		findConf,
 		fixConf,
 		helpConf,
 		inferConf,
+		pipeConf,
+		potaConf,
 		saveConf,
 		selectConf,
 		sortConf,
+		sotaConf,
 		validateConf,
 		versionConf,
 	}
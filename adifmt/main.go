@@ -18,6 +18,7 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"os"
@@ -92,10 +93,21 @@ func main() {
 		c.Configure(ctx, fs)
 	}
 	fs.Parse(os.Args[2:])
+	if c.Name == pipeConf.Name {
+		c.Run = runPipe
+	}
 	err := c.Run(ctx, fs.Args())
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error running %s: %v\n", name, err)
-		os.Exit(1)
+		code := 1
+		var ec cmd.ExitCodeError
+		isEC := errors.As(err, &ec)
+		if isEC && ec.Code > 0 {
+			code = ec.Code
+		}
+		if !isEC || !ec.Reported {
+			fmt.Fprintf(os.Stderr, "Error running %s: %v\n", name, err)
+		}
+		os.Exit(code)
 	}
 }
 
@@ -131,19 +143,30 @@ func (v runeValue) Get() rune { return *v.r }
 func configureContext(ctx *cmd.Context, fs *flag.FlagSet) {
 	adiio := adif.NewADIIO()
 	adxio := adif.NewADXIO()
+	cabrilloio := adif.NewCabrilloIO()
+	cabrillotmpl := cmd.DefaultCabrilloTemplates()
+	cabrilloio.Templates = cabrillotmpl
 	csvio := adif.NewCSVIO()
 	jsonio := adif.NewJSONIO()
+	jsonlio := adif.NewJSONLIO()
 	tsvio := adif.NewTSVIO()
+	mapping := &adif.ColumnMapping{}
+	csvio.Mapping = mapping
+	tsvio.Mapping = mapping
 	ctx.Readers = map[adif.Format]adif.Reader{
-		adif.FormatADI: adiio, adif.FormatADX: adxio, adif.FormatCSV: csvio, adif.FormatJSON: jsonio, adif.FormatTSV: tsvio,
+		adif.FormatADI: adiio, adif.FormatADX: adxio, adif.FormatCABRILLO: cabrilloio, adif.FormatCSV: csvio, adif.FormatJSON: jsonio, adif.FormatJSONL: jsonlio, adif.FormatTSV: tsvio,
 	}
 	ctx.Writers = map[adif.Format]adif.Writer{
-		adif.FormatADI: adiio, adif.FormatADX: adxio, adif.FormatCSV: csvio, adif.FormatJSON: jsonio, adif.FormatTSV: tsvio,
+		adif.FormatADI: adiio, adif.FormatADX: adxio, adif.FormatCABRILLO: cabrilloio, adif.FormatCSV: csvio, adif.FormatJSON: jsonio, adif.FormatJSONL: jsonlio, adif.FormatTSV: tsvio,
 	}
 	ctx.Out = os.Stdout
 	ctx.Prepare = func(l *adif.Logfile) {
 		t := time.Now()
-		l.Header.SetComment(fmt.Sprintf("Generated at %s with %d records by %s", t.Format(time.RFC1123Z), len(l.Records), helpUrl))
+		if len(l.Records) > 0 {
+			l.Header.SetComment(fmt.Sprintf("Generated at %s with %d records by %s", t.Format(time.RFC1123Z), len(l.Records), helpUrl))
+		} else { // no records, or records are streamed after the header is written
+			l.Header.SetComment(fmt.Sprintf("Generated at %s by %s", t.Format(time.RFC1123Z), helpUrl))
+		}
 		l.Header.Set(adif.Field{Name: spec.AdifVerField.Name, Value: spec.ADIFVersion})
 		l.Header.Set(adif.Field{Name: spec.CreatedTimestampField.Name, Value: t.Format("20060102 150405")})
 		l.Header.Set(adif.Field{Name: spec.ProgramidField.Name, Value: programName})
@@ -175,6 +198,10 @@ func configureContext(ctx *cmd.Context, fs *flag.FlagSet) {
 	// ADX flags
 	fs.IntVar(&adxio.Indent, "adx-indent", 1, "ADX files: indent nested XML structures `n` spaces, 0 for no whitespace")
 
+	// Cabrillo flags
+	fs.BoolVar(&cabrilloio.CRLF, "cabrillo-crlf", false, "Cabrillo files: output MS Windows line endings")
+	fs.Var(cabrillotmpl, "cabrillo-templates", "Cabrillo files: read contest exchange templates from `file`, one per line:\nCONTEST SENT_FIELD,... RECEIVED_FIELD,... [CONTEST_ID]")
+
 	// CSV flags
 	// TODO csv-lower-case
 	// TODO separate comma values for input and output?
@@ -184,6 +211,8 @@ func configureContext(ctx *cmd.Context, fs *flag.FlagSet) {
 	fs.BoolVar(&csvio.RequireFullRecord, "csv-require-all-fields", false, "CSV files: error if fewer fields in a record than in header")
 	fs.BoolVar(&csvio.TrimLeadingSpace, "csv-trim-space", false, "CSV files: ignore leading space in fields")
 	fs.BoolVar(&csvio.CRLF, "csv-crlf", false, "CSV files: output MS Windows line endings")
+	fs.Var(cmd.CSVMapping{Mapping: mapping}, "csv-mapping", "CSV and TSV files: read column name to field mappings from `file`, one per line:\nColumn Name = FIELD [khz|hz|upper|lower|date:DD/MM/YYYY|time:HH:MM|zone:Area/City]...")
+	fs.Var(cmd.CSVColumns{Mapping: mapping}, "columns", "CSV and TSV files: comma-separated column `names` for command input without a header row (multi)")
 
 	// JSON flags
 	// TODO json-lower-case
@@ -191,6 +220,10 @@ func configureContext(ctx *cmd.Context, fs *flag.FlagSet) {
 	fs.IntVar(&jsonio.Indent, "json-indent", 1, "JSON files: indent nested JSON structures `n` spaces, 0 for no whitespace")
 	fs.BoolVar(&jsonio.TypedOutput, "json-typed-output", false, "JSON files: output numbers and booleans instead of strings")
 
+	// JSON Lines flags
+	fs.BoolVar(&jsonlio.HTMLSafe, "jsonl-html-safe", false, "JSON Lines files: escape characters including < > & for use in HTML")
+	fs.BoolVar(&jsonlio.TypedOutput, "jsonl-typed-output", false, "JSON Lines files: output numbers and booleans instead of strings")
+
 	// TSV flags
 	fs.BoolVar(&tsvio.CRLF, "tsv-crlf", false, "TSV files: output MS Windows line endings")
 	fs.BoolVar(&tsvio.EscapeSpecial, "tsv-escape-special", false, "TSV files: accept and produce \\t \\r \\n and \\\\ escapes in fields")
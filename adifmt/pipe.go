@@ -0,0 +1,74 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/flwyd/adif-multitool/cmd"
+)
+
+const pipeSeparator = "::"
+
+func helpPipe() string {
+	return fmt.Sprintf(`Commands are separated by %[1]s and each command takes its own options.
+Records are passed from one command to the next without converting to ADI
+or another format, so field types are kept.  Global options like --output
+go before the first command and apply to every command.  Commands after the
+first read the previous command's output as standard input; the last command's
+output is written to standard output.  Example:
+  %[2]s pipe infer --fields band %[1]s fix %[1]s validate %[1]s save out.adx
+`, pipeSeparator, programName)
+}
+
+func runPipe(ctx *cmd.Context, args []string) error {
+	var stages []cmd.PipelineStage
+	start := 0
+	for i := 0; i <= len(args); i++ {
+		if i < len(args) && args[i] != pipeSeparator {
+			continue
+		}
+		stage, err := pipeStage(ctx, args[start:i])
+		if err != nil {
+			return err
+		}
+		stages = append(stages, stage)
+		start = i + 1
+	}
+	return cmd.RunPipeline(stages)
+}
+
+func pipeStage(ctx *cmd.Context, args []string) (cmd.PipelineStage, error) {
+	if len(args) == 0 {
+		return cmd.PipelineStage{}, fmt.Errorf("missing command before or after %s", pipeSeparator)
+	}
+	c, ok := commandNamed(args[0])
+	if !ok || c.Name == helpConf.Name || c.Name == pipeConf.Name || c.Name == versionConf.Name {
+		return cmd.PipelineStage{}, fmt.Errorf("cannot run %q in a pipeline", args[0])
+	}
+	sctx := *ctx
+	sctx.CommandCtx = nil
+	fs := flag.NewFlagSet(c.Name, flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	if c.Configure != nil {
+		c.Configure(&sctx, fs)
+	}
+	if err := fs.Parse(args[1:]); err != nil {
+		return cmd.PipelineStage{}, fmt.Errorf("%s: %w", c.Name, err)
+	}
+	return cmd.PipelineStage{Command: c.Command, Ctx: &sctx, Args: fs.Args()}, nil
+}
@@ -0,0 +1,396 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/flwyd/adif-multitool/adif"
+	"github.com/flwyd/adif-multitool/adif/spec"
+	"golang.org/x/text/language"
+)
+
+var Awards = Command{Name: "awards", Run: runAwards, Help: helpAwards,
+	Description: "Count worked and confirmed DXCC, WAS, WAZ, VUCC, and IOTA credits"}
+
+const (
+	AwardDXCC = "DXCC"
+	AwardWAS  = "WAS"
+	AwardWAZ  = "WAZ"
+	AwardVUCC = "VUCC"
+	AwardIOTA = "IOTA"
+
+	ConfirmQSL  = "QSL"
+	ConfirmLOTW = "LOTW"
+	ConfirmEQSL = "EQSL"
+
+	awardField     = "AWARD"
+	workedField    = "WORKED"
+	confirmedField = "CONFIRMED"
+	idField        = "ID"
+	nameField      = "NAME"
+)
+
+type AwardsContext struct {
+	Awards       FieldList
+	Confirmation FieldList
+	ByBand       bool
+	ByMode       bool
+	Needed       bool
+}
+
+// award identifies the entities a record counts for.  entities lists every
+// entity in order if the award has a fixed set, and names maps entity IDs to
+// display names.
+type award struct {
+	ids      func(r *adif.Record) []string
+	entities []string
+	names    map[string]string
+}
+
+var awards = map[string]award{
+	AwardDXCC: dxccAward(),
+	AwardWAS:  wasAward(),
+	AwardWAZ:  wazAward(),
+	AwardVUCC: {ids: vuccIDs},
+	AwardIOTA: {ids: iotaIDs},
+}
+
+var (
+	defaultAwards       = []string{AwardDXCC, AwardWAS, AwardWAZ, AwardVUCC, AwardIOTA}
+	defaultConfirmation = []string{ConfirmQSL, ConfirmLOTW, ConfirmEQSL}
+	confirmationFields  = map[string]string{
+		ConfirmQSL:  spec.QslRcvdField.Name,
+		ConfirmLOTW: spec.LotwQslRcvdField.Name,
+		ConfirmEQSL: spec.EqslQslRcvdField.Name,
+	}
+	wasDxcc       = map[string]bool{"291": true, "6": true, "110": true}
+	vuccGridPat   = regexp.MustCompile(`^[A-R]{2}[0-9]{2}`)
+	awardsHelpFmt = "  %-5s %s\n"
+)
+
+func helpAwards() string {
+	res := &strings.Builder{}
+	res.WriteString(`Outputs a record for each award with the number of WORKED and CONFIRMED
+entities and, for awards with a fixed set of entities, the TOTAL available.
+--by-band and --by-mode output a record for each band and/or mode in the log.
+--needed instead outputs a record for each entity which has not been confirmed,
+with ID, NAME, and whether it has been WORKED.
+
+Awards:
+`)
+	fmt.Fprintf(res, awardsHelpFmt, AwardDXCC, "DXCC Entity from DXCC field, excluding deleted entities")
+	fmt.Fprintf(res, awardsHelpFmt, AwardWAS, "Worked All States from STATE if DXCC is empty or a US entity, DC counts as MD")
+	fmt.Fprintf(res, awardsHelpFmt, AwardWAZ, "Worked All Zones from CQZ")
+	fmt.Fprintf(res, awardsHelpFmt, AwardVUCC, "VHF/UHF Century Club from 4-character GRIDSQUARE and VUCC_GRIDS, 6m and up")
+	fmt.Fprintf(res, awardsHelpFmt, AwardIOTA, "Islands on the Air from IOTA")
+	res.WriteString("\nConfirmation sources:\n")
+	for _, c := range defaultConfirmation {
+		fmt.Fprintf(res, awardsHelpFmt, c, confirmationFields[c]+" is Y or V")
+	}
+	return res.String()
+}
+
+// awardKey identifies a row of awards output; band and mode are empty unless
+// the breakdown was requested.
+type awardKey struct{ award, band, mode string }
+
+type awardTally struct {
+	worked, confirmed map[string]bool
+}
+
+func runAwards(ctx *Context, args []string) error {
+	cctx := ctx.CommandCtx.(*AwardsContext)
+	names := cctx.Awards
+	if len(names) == 0 {
+		names = defaultAwards
+	}
+	for _, a := range names {
+		if _, ok := awards[a]; !ok {
+			return fmt.Errorf("unknown award %q, options: %s", a, strings.Join(defaultAwards, ", "))
+		}
+	}
+	confs := cctx.Confirmation
+	if len(confs) == 0 {
+		confs = defaultConfirmation
+	}
+	for _, c := range confs {
+		if _, ok := confirmationFields[c]; !ok {
+			return fmt.Errorf("unknown confirmation source %q, options: %s", c, strings.Join(defaultConfirmation, ", "))
+		}
+	}
+	tallies := make(map[awardKey]*awardTally)
+	err := forEachRecord(ctx, args, func(r *adif.Record, _ *adif.Logfile) error {
+		k := awardKey{}
+		if cctx.ByBand {
+			b, _ := r.Get(spec.BandField.Name)
+			k.band = strings.ToLower(b.Value)
+		}
+		if cctx.ByMode {
+			m, _ := r.Get(spec.ModeField.Name)
+			k.mode = strings.ToUpper(m.Value)
+		}
+		conf := isConfirmed(r, confs)
+		for _, a := range names {
+			ids := awards[a].ids(r)
+			if len(ids) == 0 {
+				continue
+			}
+			k.award = a
+			t := tallies[k]
+			if t == nil {
+				t = &awardTally{worked: make(map[string]bool), confirmed: make(map[string]bool)}
+				tallies[k] = t
+			}
+			for _, id := range ids {
+				t.worked[id] = true
+				if conf {
+					t.confirmed[id] = true
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if !cctx.ByBand && !cctx.ByMode {
+		for _, a := range names {
+			if k := (awardKey{award: a}); tallies[k] == nil {
+				tallies[k] = &awardTally{worked: make(map[string]bool), confirmed: make(map[string]bool)}
+			}
+		}
+	}
+	out := adif.NewLogfile()
+	out.FieldOrder = []string{awardField}
+	if cctx.ByBand {
+		out.FieldOrder = append(out.FieldOrder, spec.BandField.Name)
+	}
+	if cctx.ByMode {
+		out.FieldOrder = append(out.FieldOrder, spec.ModeField.Name)
+	}
+	if cctx.Needed {
+		out.FieldOrder = append(out.FieldOrder, idField, nameField, workedField)
+	} else {
+		out.FieldOrder = append(out.FieldOrder, workedField, confirmedField, totalValue)
+	}
+	for _, k := range sortedAwardKeys(tallies, names, ctx.Locale) {
+		a, t := awards[k.award], tallies[k]
+		key := func() *adif.Record {
+			r := adif.NewRecord(adif.Field{Name: awardField, Value: k.award})
+			if cctx.ByBand {
+				r.Set(adif.Field{Name: spec.BandField.Name, Value: k.band})
+			}
+			if cctx.ByMode {
+				r.Set(adif.Field{Name: spec.ModeField.Name, Value: k.mode})
+			}
+			return r
+		}
+		if !cctx.Needed {
+			r := key()
+			r.Set(adif.Field{Name: workedField, Value: strconv.Itoa(len(t.worked))})
+			r.Set(adif.Field{Name: confirmedField, Value: strconv.Itoa(len(t.confirmed))})
+			total := ""
+			if a.entities != nil {
+				total = strconv.Itoa(len(a.entities))
+			}
+			r.Set(adif.Field{Name: totalValue, Value: total})
+			out.AddRecord(r)
+			continue
+		}
+		for _, id := range a.entities {
+			if t.confirmed[id] {
+				continue
+			}
+			n := key()
+			n.Set(adif.Field{Name: idField, Value: id})
+			n.Set(adif.Field{Name: nameField, Value: a.names[id]})
+			w := "N"
+			if t.worked[id] {
+				w = "Y"
+			}
+			n.Set(adif.Field{Name: workedField, Value: w})
+			out.AddRecord(n)
+		}
+	}
+	return write(ctx, out)
+}
+
+// sortedAwardKeys orders keys by award in the requested order, then by band
+// frequency and mode name.
+func sortedAwardKeys(tallies map[awardKey]*awardTally, names []string, locale language.Tag) []awardKey {
+	order := make(map[string]int)
+	for i, n := range names {
+		order[n] = i
+	}
+	bandComp := spec.ComparatorForField(spec.BandField, locale)
+	keys := make([]awardKey, 0, len(tallies))
+	for k := range tallies {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if a.award != b.award {
+			return order[a.award] < order[b.award]
+		}
+		if a.band != b.band {
+			if c, err := bandComp(a.band, b.band); err == nil && c != 0 {
+				return c < 0
+			}
+			return a.band < b.band
+		}
+		return a.mode < b.mode
+	})
+	return keys
+}
+
+func isConfirmed(r *adif.Record, sources []string) bool {
+	for _, s := range sources {
+		if f, ok := r.Get(confirmationFields[s]); ok {
+			switch strings.ToUpper(f.Value) {
+			case "Y", "V":
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func dxccAward() award {
+	a := award{names: make(map[string]string)}
+	for _, e := range spec.DxccEntityCodeEnumeration.Values {
+		ee := e.(spec.DxccEntityCodeEnum)
+		if ee.Deleted == "true" || ee.EntityCode == "0" {
+			continue
+		}
+		a.entities = append(a.entities, ee.EntityCode)
+		a.names[ee.EntityCode] = ee.EntityName
+	}
+	sort.Slice(a.entities, func(i, j int) bool {
+		x, _ := strconv.Atoi(a.entities[i])
+		y, _ := strconv.Atoi(a.entities[j])
+		return x < y
+	})
+	a.ids = func(r *adif.Record) []string {
+		f, ok := r.Get(spec.DxccField.Name)
+		if !ok {
+			return nil
+		}
+		code := strings.TrimLeft(strings.TrimSpace(f.Value), "0")
+		if _, ok := a.names[code]; !ok {
+			return nil
+		}
+		return []string{code}
+	}
+	return a
+}
+
+func wasAward() award {
+	a := award{names: make(map[string]string)}
+	for _, e := range spec.PrimaryAdministrativeSubdivisionEnumeration.Values {
+		s := e.(spec.PrimaryAdministrativeSubdivisionEnum)
+		if !wasDxcc[s.DxccEntityCode] || s.Deleted == "true" || s.Code == "DC" {
+			continue
+		}
+		a.entities = append(a.entities, s.Code)
+		a.names[s.Code] = s.PrimaryAdministrativeSubdivision
+	}
+	sort.Strings(a.entities)
+	a.ids = func(r *adif.Record) []string {
+		if d, ok := r.Get(spec.DxccField.Name); ok && d.Value != "" && !wasDxcc[strings.TrimSpace(d.Value)] {
+			return nil
+		}
+		s, _ := r.Get(spec.StateField.Name)
+		st := strings.ToUpper(strings.TrimSpace(s.Value))
+		if st == "DC" {
+			st = "MD"
+		}
+		if _, ok := a.names[st]; !ok {
+			return nil
+		}
+		return []string{st}
+	}
+	return a
+}
+
+func wazAward() award {
+	a := award{names: make(map[string]string)}
+	for i := 1; i <= 40; i++ {
+		z := strconv.Itoa(i)
+		a.entities = append(a.entities, z)
+		a.names[z] = "CQ Zone " + z
+	}
+	a.ids = func(r *adif.Record) []string {
+		f, _ := r.Get(spec.CqzField.Name)
+		z, err := strconv.Atoi(strings.TrimSpace(f.Value))
+		if err != nil || z < 1 || z > 40 {
+			return nil
+		}
+		return []string{strconv.Itoa(z)}
+	}
+	return a
+}
+
+// vuccMinMhz is the lower edge of the 6 meter band; VUCC does not count HF
+// contacts.
+const vuccMinMhz = 50
+
+func vuccIDs(r *adif.Record) []string {
+	if mhz, ok := recordMhz(r); ok && mhz < vuccMinMhz {
+		return nil
+	}
+	var grids []string
+	if f, ok := r.Get(spec.VuccGridsField.Name); ok && f.Value != "" {
+		grids = strings.Split(f.Value, ",")
+	} else if f, ok := r.Get(spec.GridsquareField.Name); ok {
+		grids = []string{f.Value}
+	}
+	var res []string
+	for _, g := range grids {
+		g = strings.ToUpper(strings.TrimSpace(g))
+		if vuccGridPat.MatchString(g) {
+			res = append(res, g[0:4])
+		}
+	}
+	return res
+}
+
+// recordMhz returns the lower edge of r's BAND or, if BAND is not a valid band,
+// FREQ.  ok is false if neither is set.
+func recordMhz(r *adif.Record) (mhz float64, ok bool) {
+	f, _ := r.Get(spec.BandField.Name)
+	for _, b := range spec.BandEnumeration.Value(strings.TrimSpace(f.Value)) {
+		if mhz, err := strconv.ParseFloat(b.(spec.BandEnum).LowerFreqMhz, 64); err == nil {
+			return mhz, true
+		}
+	}
+	f, _ = r.Get(spec.FreqField.Name)
+	if mhz, err := strconv.ParseFloat(strings.TrimSpace(f.Value), 64); err == nil {
+		return mhz, true
+	}
+	return 0, false
+}
+
+func iotaIDs(r *adif.Record) []string {
+	f, _ := r.Get(spec.IotaField.Name)
+	if v := strings.ToUpper(strings.TrimSpace(f.Value)); v != "" {
+		return []string{v}
+	}
+	return nil
+}
@@ -0,0 +1,189 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/flwyd/adif-multitool/adif"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestAwards(t *testing.T) {
+	adi := adif.NewADIIO()
+	csv := adif.NewCSVIO()
+	file1 := `CALL,BAND,MODE,DXCC,STATE,CQZ,GRIDSQUARE,VUCC_GRIDS,IOTA,QSL_RCVD,LOTW_QSL_RCVD,EQSL_QSL_RCVD
+W1AW,20m,CW,291,CT,5,FN31pr,,,N,Y,
+K6XYZ,20M,SSB,291,CA,3,CM87,,,,,Y
+KH6A,40m,CW,110,HI,31,BL11,,oc-019,Y,,
+DL1A,20m,CW,230,,14,JO62,,,,,
+W3DC,6m,SSB,291,DC,5,FM18,,,,V,
+VE3A,6m,SSB,1,ON,4,,"FN03,FN04",,,,
+XX1X,20m,CW,246,,0,,,,R,,
+OLD1,20m,CW,81,,,,,,Y,,
+`
+	tests := []struct {
+		name, want string
+		ctx        AwardsContext
+	}{
+		{
+			name: "all awards",
+			want: `AWARD,WORKED,CONFIRMED,TOTAL
+DXCC,5,2,340
+WAS,4,4,50
+WAZ,5,3,40
+VUCC,3,1,
+IOTA,1,1,
+`,
+		},
+		{
+			name: "lotw only",
+			ctx:  AwardsContext{Awards: FieldList{"DXCC", "WAS"}, Confirmation: FieldList{"LOTW"}},
+			want: `AWARD,WORKED,CONFIRMED,TOTAL
+DXCC,5,1,340
+WAS,4,2,50
+`,
+		},
+		{
+			name: "by band",
+			ctx:  AwardsContext{Awards: FieldList{"WAZ", "DXCC"}, ByBand: true},
+			want: `AWARD,BAND,WORKED,CONFIRMED,TOTAL
+WAZ,40m,1,1,40
+WAZ,20m,3,2,40
+WAZ,6m,2,1,40
+DXCC,40m,1,1,340
+DXCC,20m,3,1,340
+DXCC,6m,2,1,340
+`,
+		},
+		{
+			name: "by band and mode",
+			ctx:  AwardsContext{Awards: FieldList{"WAS"}, ByBand: true, ByMode: true},
+			want: `AWARD,BAND,MODE,WORKED,CONFIRMED,TOTAL
+WAS,40m,CW,1,1,50
+WAS,20m,CW,1,1,50
+WAS,20m,SSB,1,1,50
+WAS,6m,SSB,1,1,50
+`,
+		},
+		{
+			name: "no matching records",
+			ctx:  AwardsContext{Awards: FieldList{"IOTA"}, ByMode: true},
+			want: `AWARD,MODE,WORKED,CONFIRMED,TOTAL
+IOTA,CW,1,1,
+`,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			cctx := tc.ctx
+			ctx := &Context{
+				OutputFormat: adif.FormatCSV,
+				Readers:      readers(adi, csv),
+				Writers:      writers(adi, csv),
+				Out:          out,
+				fs:           fakeFilesystem{map[string]string{"foo.csv": file1}},
+				CommandCtx:   &cctx}
+			if err := Awards.Run(ctx, []string{"foo.csv"}); err != nil {
+				t.Fatalf("Awards.Run(%+v, foo.csv) got error %v", tc.ctx, err)
+			}
+			if diff := cmp.Diff(tc.want, out.String()); diff != "" {
+				t.Errorf("Awards.Run(%+v, foo.csv) unexpected output, diff:\n%s", tc.ctx, diff)
+			}
+		})
+	}
+}
+
+func TestAwardsVUCCBands(t *testing.T) {
+	csv := adif.NewCSVIO()
+	file := `CALL,BAND,FREQ,GRIDSQUARE,QSL_RCVD
+W1AW,20m,14.074,FN31,Y
+K1A,,7.074,FN32,Y
+K2A,,50.313,FN20,Y
+K3A,2m,,FM29,N
+K4A,,,EM95,N
+`
+	out := &bytes.Buffer{}
+	ctx := &Context{
+		OutputFormat: adif.FormatCSV,
+		Readers:      readers(csv),
+		Writers:      writers(csv),
+		Out:          out,
+		fs:           fakeFilesystem{map[string]string{"foo.csv": file}},
+		CommandCtx:   &AwardsContext{Awards: FieldList{"VUCC"}}}
+	if err := Awards.Run(ctx, []string{"foo.csv"}); err != nil {
+		t.Fatalf("Awards.Run(foo.csv) got error %v", err)
+	}
+	want := "AWARD,WORKED,CONFIRMED,TOTAL\nVUCC,3,1,\n"
+	if diff := cmp.Diff(want, out.String()); diff != "" {
+		t.Errorf("Awards.Run(foo.csv) unexpected output, diff:\n%s", diff)
+	}
+}
+
+func TestAwardsNeeded(t *testing.T) {
+	csv := adif.NewCSVIO()
+	file := `CALL,STATE,CQZ,QSL_RCVD
+W1AW,CT,5,Y
+K6XYZ,CA,3,N
+`
+	out := &bytes.Buffer{}
+	ctx := &Context{
+		OutputFormat: adif.FormatCSV,
+		Readers:      readers(csv),
+		Writers:      writers(csv),
+		Out:          out,
+		fs:           fakeFilesystem{map[string]string{"foo.csv": file}},
+		CommandCtx:   &AwardsContext{Awards: FieldList{"WAS", "WAZ", "VUCC"}, Needed: true}}
+	if err := Awards.Run(ctx, []string{"foo.csv"}); err != nil {
+		t.Fatalf("Awards.Run(foo.csv) got error %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if want := 1 + 49 + 39; len(lines) != want {
+		t.Errorf("Awards.Run(foo.csv) got %d lines, want %d:\n%s", len(lines), want, out.String())
+	}
+	for _, want := range []string{"AWARD,ID,NAME,WORKED", "WAS,AK,Alaska,N", "WAS,CA,California,Y", "WAZ,3,CQ Zone 3,Y", "WAZ,40,CQ Zone 40,N"} {
+		if !strings.Contains(out.String(), want+"\n") {
+			t.Errorf("Awards.Run(foo.csv) output missing %q:\n%s", want, out.String())
+		}
+	}
+	for _, notWant := range []string{"WAS,CT", "WAS,DC", "WAZ,5,", "VUCC"} {
+		if strings.Contains(out.String(), notWant) {
+			t.Errorf("Awards.Run(foo.csv) output contains %q:\n%s", notWant, out.String())
+		}
+	}
+}
+
+func TestAwardsErrors(t *testing.T) {
+	csv := adif.NewCSVIO()
+	for _, cctx := range []AwardsContext{
+		{Awards: FieldList{"WAC"}},
+		{Confirmation: FieldList{"CARD"}},
+	} {
+		cctx := cctx
+		ctx := &Context{
+			OutputFormat: adif.FormatCSV,
+			Readers:      readers(csv),
+			Writers:      writers(csv),
+			Out:          &bytes.Buffer{},
+			fs:           fakeFilesystem{map[string]string{"foo.csv": "CALL,DXCC\nK1A,291\n"}},
+			CommandCtx:   &cctx}
+		if err := Awards.Run(ctx, []string{"foo.csv"}); err == nil {
+			t.Errorf("Awards.Run(%+v, foo.csv) got no error", cctx)
+		}
+	}
+}
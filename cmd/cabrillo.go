@@ -0,0 +1,175 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/flwyd/adif-multitool/adif"
+	"github.com/flwyd/adif-multitool/adif/spec"
+)
+
+func cabrilloTemplate(c spec.ContestIdEnum, cabrilloName string, mine, theirs []spec.Field) adif.CabrilloTemplate {
+	if cabrilloName == "" {
+		cabrilloName = c.ContestId
+	}
+	t := adif.CabrilloTemplate{CabrilloName: cabrilloName, ContestID: c.ContestId}
+	for _, f := range mine {
+		t.MyExchange = append(t.MyExchange, f.Name)
+	}
+	for _, f := range theirs {
+		t.TheirExchange = append(t.TheirExchange, f.Name)
+	}
+	return t
+}
+
+var (
+	rstSerialSent   = []spec.Field{spec.RstSentField, spec.StxField}
+	rstSerialRcvd   = []spec.Field{spec.RstRcvdField, spec.SrxField}
+	rstStringSent   = []spec.Field{spec.RstSentField, spec.StxStringField}
+	rstStringRcvd   = []spec.Field{spec.RstRcvdField, spec.SrxStringField}
+	fieldDaySent    = []spec.Field{spec.StxStringField, spec.MyArrlSectField}
+	fieldDayRcvd    = []spec.Field{spec.ClassField, spec.ArrlSectField}
+	gridSent        = []spec.Field{spec.MyGridsquareField}
+	gridRcvd        = []spec.Field{spec.GridsquareField}
+	sweepstakesSent = []spec.Field{spec.StxField, spec.StxStringField, spec.StxStringField, spec.MyArrlSectField}
+	sweepstakesRcvd = []spec.Field{spec.SrxField, spec.PrecedenceField, spec.CheckField, spec.ArrlSectField}
+	nameStateSent   = []spec.Field{spec.StxStringField, spec.StxStringField}
+	nameStateRcvd   = []spec.Field{spec.NameField, spec.StateField}
+)
+
+var defaultCabrilloTemplates = []adif.CabrilloTemplate{
+	cabrilloTemplate(spec.ContestIdARRL_10, "", rstStringSent, rstStringRcvd),
+	cabrilloTemplate(spec.ContestIdARRL_160, "", []spec.Field{spec.RstSentField, spec.MyArrlSectField}, []spec.Field{spec.RstRcvdField, spec.ArrlSectField}),
+	cabrilloTemplate(spec.ContestIdARRL_DX_CW, "", rstStringSent, rstStringRcvd),
+	cabrilloTemplate(spec.ContestIdARRL_DX_SSB, "", rstStringSent, rstStringRcvd),
+	cabrilloTemplate(spec.ContestIdARRL_FIELD_DAY, "ARRL-FD", fieldDaySent, fieldDayRcvd),
+	cabrilloTemplate(spec.ContestIdARRL_SS_CW, "", sweepstakesSent, sweepstakesRcvd),
+	cabrilloTemplate(spec.ContestIdARRL_SS_SSB, "", sweepstakesSent, sweepstakesRcvd),
+	cabrilloTemplate(spec.ContestIdARRL_VHF_JAN, "", gridSent, gridRcvd),
+	cabrilloTemplate(spec.ContestIdARRL_VHF_JUN, "", gridSent, gridRcvd),
+	cabrilloTemplate(spec.ContestIdARRL_VHF_SEP, "", gridSent, gridRcvd),
+	cabrilloTemplate(spec.ContestIdCQ_160_CW, "", rstStringSent, rstStringRcvd),
+	cabrilloTemplate(spec.ContestIdCQ_160_SSB, "", rstStringSent, rstStringRcvd),
+	cabrilloTemplate(spec.ContestIdCQ_VHF, "", gridSent, gridRcvd),
+	cabrilloTemplate(spec.ContestIdCQ_WPX_CW, "", rstSerialSent, rstSerialRcvd),
+	cabrilloTemplate(spec.ContestIdCQ_WPX_RTTY, "", rstSerialSent, rstSerialRcvd),
+	cabrilloTemplate(spec.ContestIdCQ_WPX_SSB, "", rstSerialSent, rstSerialRcvd),
+	cabrilloTemplate(spec.ContestIdCQ_WW_CW, "", []spec.Field{spec.RstSentField, spec.MyCqZoneField}, []spec.Field{spec.RstRcvdField, spec.CqzField}),
+	cabrilloTemplate(spec.ContestIdCQ_WW_SSB, "", []spec.Field{spec.RstSentField, spec.MyCqZoneField}, []spec.Field{spec.RstRcvdField, spec.CqzField}),
+	cabrilloTemplate(spec.ContestIdIARU_HF, "", rstStringSent, rstStringRcvd),
+	cabrilloTemplate(spec.ContestIdNAQP_CW, "", nameStateSent, nameStateRcvd),
+	cabrilloTemplate(spec.ContestIdNAQP_RTTY, "", nameStateSent, nameStateRcvd),
+	cabrilloTemplate(spec.ContestIdNAQP_SSB, "", nameStateSent, nameStateRcvd),
+	cabrilloTemplate(spec.ContestIdSTEW_PERRY, "", gridSent, gridRcvd),
+	cabrilloTemplate(spec.ContestIdWFD, "", fieldDaySent, fieldDayRcvd),
+}
+
+// CabrilloTemplates maps Cabrillo contest names and ADIF CONTEST_ID values to
+// the layout of QSO line exchanges for that contest.  Keys are upper case.
+// Use Set to add templates from a file, e.g. as a flag.Value.
+type CabrilloTemplates map[string]adif.CabrilloTemplate
+
+// DefaultCabrilloTemplates returns a new map with exchange templates for
+// popular contests.
+func DefaultCabrilloTemplates() CabrilloTemplates {
+	res := make(CabrilloTemplates)
+	for _, t := range defaultCabrilloTemplates {
+		res.add(t)
+	}
+	return res
+}
+
+func (c CabrilloTemplates) add(t adif.CabrilloTemplate) {
+	c[strings.ToUpper(t.ContestID)] = t
+	c[strings.ToUpper(t.CabrilloName)] = t
+}
+
+func (c CabrilloTemplates) String() string {
+	seen := make(map[string]bool)
+	var res []string
+	for _, t := range c {
+		if !seen[t.CabrilloName] {
+			res = append(res, t.CabrilloName)
+			seen[t.CabrilloName] = true
+		}
+	}
+	sort.Strings(res)
+	return strings.Join(res, ",")
+}
+
+// Set reads the named template file, see Read for the file format.
+func (c CabrilloTemplates) Set(filename string) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := c.Read(f); err != nil {
+		return fmt.Errorf("%s: %w", filename, err)
+	}
+	return nil
+}
+
+// Read parses Cabrillo exchange templates, one per line, and adds them to c,
+// replacing any existing template with the same contest name.  Each line has
+// the Cabrillo contest name, comma-separated ADIF field names for the sent
+// exchange, comma-separated field names for the received exchange, and
+// optionally the ADIF CONTEST_ID if it differs from the Cabrillo name:
+//
+//	# comments and blank lines are ignored
+//	ARRL-FD STX_STRING,MY_ARRL_SECT CLASS,ARRL_SECT ARRL-FIELD-DAY
+func (c CabrilloTemplates) Read(r io.Reader) error {
+	scan := bufio.NewScanner(r)
+	line := 0
+	for scan.Scan() {
+		line++
+		text := strings.TrimSpace(scan.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		tok := strings.Fields(text)
+		if len(tok) != 3 && len(tok) != 4 {
+			return fmt.Errorf("line %d: expected CONTEST SENT_FIELDS RECEIVED_FIELDS [CONTEST_ID], got %q", line, text)
+		}
+		t := adif.CabrilloTemplate{CabrilloName: strings.ToUpper(tok[0]), ContestID: strings.ToUpper(tok[0])}
+		if len(tok) == 4 {
+			t.ContestID = strings.ToUpper(tok[3])
+		}
+		var mine, theirs FieldList
+		if err := mine.Set(tok[1]); err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+		if err := theirs.Set(tok[2]); err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+		for _, f := range append(mine.Get(), theirs.Get()...) {
+			if err := ValidateAlphanumName(f, ""); err != nil {
+				return fmt.Errorf("line %d: %w", line, err)
+			}
+		}
+		t.MyExchange, t.TheirExchange = mine, theirs
+		c.add(t)
+	}
+	if err := scan.Err(); err != nil {
+		return fmt.Errorf("line %d: %w", line, err)
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/flwyd/adif-multitool/adif"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDefaultCabrilloTemplates(t *testing.T) {
+	tmpl := DefaultCabrilloTemplates()
+	for _, name := range []string{"ARRL-FD", "ARRL-FIELD-DAY"} {
+		got, ok := tmpl[name]
+		if !ok {
+			t.Errorf("DefaultCabrilloTemplates() missing %s", name)
+			continue
+		}
+		want := adif.CabrilloTemplate{
+			CabrilloName:  "ARRL-FD",
+			ContestID:     "ARRL-FIELD-DAY",
+			MyExchange:    []string{"STX_STRING", "MY_ARRL_SECT"},
+			TheirExchange: []string{"CLASS", "ARRL_SECT"},
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("DefaultCabrilloTemplates()[%q] diff:\n%s", name, diff)
+		}
+	}
+	for _, name := range []string{"CQ-WW-CW", "ARRL-SS-SSB", "NAQP-CW", "ARRL-VHF-JUN"} {
+		if _, ok := tmpl[name]; !ok {
+			t.Errorf("DefaultCabrilloTemplates() missing %s", name)
+		}
+	}
+}
+
+func TestReadCabrilloTemplates(t *testing.T) {
+	input := `# My contests
+MY-QSO-PARTY   RST_SENT,MY_STATE   RST_RCVD,STATE   MY-QP
+
+arrl-fd stx_string,stx_string,my_arrl_sect class,arrl_sect
+`
+	tmpl := DefaultCabrilloTemplates()
+	if err := tmpl.Read(strings.NewReader(input)); err != nil {
+		t.Fatalf("Read(%q) got error %v", input, err)
+	}
+	want := adif.CabrilloTemplate{
+		CabrilloName:  "MY-QSO-PARTY",
+		ContestID:     "MY-QP",
+		MyExchange:    []string{"RST_SENT", "MY_STATE"},
+		TheirExchange: []string{"RST_RCVD", "STATE"},
+	}
+	for _, name := range []string{"MY-QSO-PARTY", "MY-QP"} {
+		if diff := cmp.Diff(want, tmpl[name]); diff != "" {
+			t.Errorf("Read(%q) template %s diff:\n%s", input, name, diff)
+		}
+	}
+	want = adif.CabrilloTemplate{
+		CabrilloName:  "ARRL-FD",
+		ContestID:     "ARRL-FD",
+		MyExchange:    []string{"STX_STRING", "STX_STRING", "MY_ARRL_SECT"},
+		TheirExchange: []string{"CLASS", "ARRL_SECT"},
+	}
+	if diff := cmp.Diff(want, tmpl["ARRL-FD"]); diff != "" {
+		t.Errorf("Read(%q) did not replace ARRL-FD, diff:\n%s", input, diff)
+	}
+
+	for _, bad := range []string{
+		"CONTEST-ONLY\n",
+		"TOO MANY FIELDS IN THIS LINE\n",
+		"EMPTY-FIELD RST_SENT,,STX RST_RCVD,SRX\n",
+		"BAD-FIELD RST-SENT RST_RCVD\n",
+	} {
+		if err := make(CabrilloTemplates).Read(strings.NewReader(bad)); err == nil {
+			t.Errorf("Read(%q) got no error", bad)
+		}
+	}
+}
+
+func TestCatCabrilloTemplate(t *testing.T) {
+	adi := adif.NewADIIO()
+	cab := adif.NewCabrilloIO()
+	cab.Templates = DefaultCabrilloTemplates()
+	out := &bytes.Buffer{}
+	file1 := `START-OF-LOG: 3.0
+CONTEST: ARRL-FD
+QSO: 14025 CW 2023-06-24 1801 W1AW 2A CT K1MU 1D EMA
+END-OF-LOG:
+`
+	ctx := &Context{
+		OutputFormat: adif.FormatADI,
+		Readers:      readers(adi, cab),
+		Writers:      writers(adi, cab),
+		Out:          out,
+		Prepare:      testPrepare("My Comment", "3.1.4", "cat test", "1.2.3"),
+		fs:           fakeFilesystem{map[string]string{"fd.log": file1}}}
+	if err := Cat.Run(ctx, []string{"fd.log"}); err != nil {
+		t.Fatalf("Cat.Run(ctx) got error %v", err)
+	}
+	want := `My Comment
+<APP_CABRILLO_CONTEST:7>ARRL-FD <ADIF_VER:5>3.1.4 <PROGRAMID:8>cat test <PROGRAMVERSION:5>1.2.3 <EOH>
+<FREQ:6>14.025 <MODE:2>CW <QSO_DATE:8>20230624 <TIME_ON:4>1801 <STATION_CALLSIGN:4>W1AW <STX_STRING:2>2A <MY_ARRL_SECT:2>CT <CALL:4>K1MU <CLASS:2>1D <ARRL_SECT:3>EMA <CONTEST_ID:14>ARRL-FIELD-DAY <EOR>
+`
+	if diff := cmp.Diff(want, out.String()); diff != "" {
+		t.Errorf("Cat.Run(ctx, fd.log) unexpected output, diff:\n%s", diff)
+	}
+}
@@ -18,23 +18,15 @@ import (
 	"github.com/flwyd/adif-multitool/adif"
 )
 
-var Cat = Command{Name: "cat", Run: runCat,
+var Cat = Command{Name: "cat", Run: runCat, Help: helpCat,
 	Description: "Concatenate all input files to standard output"}
 
+func helpCat() string { return helpStreamOutput("cat") }
+
 func runCat(ctx *Context, args []string) error {
 	// TODO add any needed flags
-	acc := accumulator{Out: adif.NewLogfile(), Ctx: ctx}
-	for _, f := range filesOrStdin(args) {
-		l, err := acc.read(f)
-		if err != nil {
-			return err
-		}
-		updateFieldOrder(acc.Out, l.FieldOrder)
-		// TODO merge headers and comments
-		acc.Out.Records = append(acc.Out.Records, l.Records...)
-	}
-	if err := acc.prepare(); err != nil {
-		return err
-	}
-	return write(ctx, acc.Out)
+	s := recordStream{Ctx: ctx, Out: adif.NewLogfile(), KnownFields: true}
+	return s.run(args, func(r *adif.Record, _ *adif.Logfile, _ int) (*adif.Record, error) {
+		return r, nil
+	})
 }
@@ -44,6 +44,28 @@ func TestCatEmpty(t *testing.T) {
 	}
 }
 
+func TestCatConflictingUserdef(t *testing.T) {
+	io := adif.NewADIIO()
+	out := &bytes.Buffer{}
+	file1 := `<USERDEF1:5:N>MYFLD <USERDEF2:5:S>MYFLD <EOH>
+<CALL:4>W1AW <MYFLD:2>42 <EOR>
+`
+	ctx := &Context{
+		OutputFormat: adif.FormatADI,
+		Readers:      readers(io),
+		Writers:      writers(io),
+		Out:          out,
+		Prepare:      testPrepare("My Comment", "3.1.4", "cat test", "1.2.3"),
+		fs:           fakeFilesystem{map[string]string{"foo.adi": file1}}}
+	if err := Cat.Run(ctx, []string{"foo.adi"}); err != nil {
+		t.Fatalf("Cat.Run(ctx, foo.adi) got error %v", err)
+	}
+	want := "My Comment\n<ADIF_VER:5>3.1.4 <PROGRAMID:8>cat test <PROGRAMVERSION:5>1.2.3 <USERDEF1:5:N>MYFLD <EOH>\n<CALL:4>W1AW <MYFLD:2>42 <EOR>\n"
+	if diff := cmp.Diff(want, out.String()); diff != "" {
+		t.Errorf("Cat.Run(ctx, foo.adi) unexpected output, diff:\n%s", diff)
+	}
+}
+
 func TestEditADIToCSV(t *testing.T) {
 	adi := adif.NewADIIO()
 	csv := adif.NewCSVIO()
@@ -120,3 +142,57 @@ Fox Trot,Golf,Hotel,,
 		}
 	}
 }
+
+func TestCatAppHeader(t *testing.T) {
+	adi := adif.NewADIIO()
+	out := &bytes.Buffer{}
+	file1 := "<APP_MONOLOG_LOCATION:4>home <APP_CABRILLO_CONTEST:7>ARRL-FD <EOH>\n<CALL:4>W1AW <EOR>\n"
+	ctx := &Context{
+		OutputFormat: adif.FormatADI,
+		Readers:      readers(adi),
+		Writers:      writers(adi),
+		Out:          out,
+		Prepare:      testPrepare("My Comment", "3.1.4", "cat test", "1.2.3"),
+		fs:           fakeFilesystem{map[string]string{"foo.adi": file1}}}
+	if err := Cat.Run(ctx, []string{"foo.adi"}); err != nil {
+		t.Fatalf("Cat.Run(ctx) got error %v", err)
+	}
+	want := `My Comment
+<APP_CABRILLO_CONTEST:7>ARRL-FD <ADIF_VER:5>3.1.4 <PROGRAMID:8>cat test <PROGRAMVERSION:5>1.2.3 <EOH>
+<CALL:4>W1AW <EOR>
+`
+	if diff := cmp.Diff(want, out.String()); diff != "" {
+		t.Errorf("Cat.Run(ctx, foo.adi) unexpected output, diff:\n%s", diff)
+	}
+}
+
+func TestCatCabrilloHeader(t *testing.T) {
+	adi := adif.NewADIIO()
+	cab := adif.NewCabrilloIO()
+	out := &bytes.Buffer{}
+	file1 := `START-OF-LOG: 3.0
+CONTEST: ARRL-FD
+CATEGORY-STATION: PORTABLE
+QSO: 14025 CW 2023-06-24 1801 W1AW 2A CT K1MU 1D EMA
+END-OF-LOG:
+`
+	ctx := &Context{
+		OutputFormat: adif.FormatADI,
+		Readers:      readers(adi, cab),
+		Writers:      writers(adi, cab),
+		Out:          out,
+		Prepare:      testPrepare("My Comment", "3.1.4", "cat test", "1.2.3"),
+		fs:           fakeFilesystem{map[string]string{"fd.log": file1}}}
+	if err := Cat.Run(ctx, []string{"fd.log"}); err != nil {
+		t.Errorf("Cat.Run(ctx) got error %v", err)
+	} else {
+		got := out.String()
+		want := `My Comment
+<APP_CABRILLO_CONTEST:7>ARRL-FD <APP_CABRILLO_CATEGORY_STATION:8>PORTABLE <ADIF_VER:5>3.1.4 <PROGRAMID:8>cat test <PROGRAMVERSION:5>1.2.3 <EOH>
+<FREQ:6>14.025 <MODE:2>CW <QSO_DATE:8>20230624 <TIME_ON:4>1801 <STATION_CALLSIGN:4>W1AW <STX_STRING:5>2A CT <CALL:4>K1MU <SRX_STRING:6>1D EMA <CONTEST_ID:7>ARRL-FD <EOR>
+`
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("Cat.Run(ctx, fd.log) unexpected output, diff:\n%s", diff)
+		}
+	}
+}
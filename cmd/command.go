@@ -20,3 +20,17 @@ type Command struct {
 	Run         func(ctx *Context, args []string) error
 	Help        func() string
 }
+
+// ExitCodeError is returned by a command which should exit with a specific
+// status code.
+type ExitCodeError struct {
+	Code int
+	Err  error
+	// Reported is true if the command has already described the problem on
+	// standard error, e.g. as a JSON report, so Err should not be printed.
+	Reported bool
+}
+
+func (e ExitCodeError) Error() string { return e.Err.Error() }
+
+func (e ExitCodeError) Unwrap() error { return e.Err }
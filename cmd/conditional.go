@@ -76,7 +76,11 @@ const (
 	OpLessThanEqual             = "<="
 	OpGreaterThan               = ">"
 	OpGreaterThanEqual          = ">="
-	// TODO OpGlob
+	OpNotEqual                  = "!="
+	OpMatches                   = "~"
+	OpContains                  = "*="
+	OpStartsWith                = "^="
+	OpEndsWith                  = "$="
 )
 
 type comparison struct {
@@ -84,6 +88,9 @@ type comparison struct {
 	FieldName string
 	Operands  []string
 	Negate    bool
+	// patterns has a compiled regular expression for each operand which is a
+	// glob or, with OpMatches, a regular expression; nil for other operands.
+	patterns []*regexp.Regexp
 }
 
 func (c comparison) String() string {
@@ -95,50 +102,125 @@ func (c comparison) String() string {
 }
 
 func (c comparison) Evaluate(e EvaluationContext) bool {
-	result := func(b bool) bool {
-		if c.Negate {
-			return !b
-		}
-		return b
-	}
 	f := e.Get(c.FieldName)
-	for _, o := range c.Operands {
-		var v adif.Field
-		if strings.HasPrefix(o, "{") && strings.HasSuffix(o, "}") {
-			v = e.Get(o[1 : len(o)-1])
-		} else {
-			v = e.Cast(c.FieldName, o)
+	var match bool
+	for i, o := range c.Operands {
+		if c.matches(e, f, i, o) {
+			match = true
+			break
 		}
-		comp, err := e.Compare(f, v)
-		if err != nil {
-			return false
+	}
+	if c.Op == OpNotEqual {
+		match = !match
+	}
+	if c.Negate {
+		return !match
+	}
+	return match
+}
+
+// matches returns true if field f matches operand o, the ith operand.
+func (c comparison) matches(e EvaluationContext, f adif.Field, i int, o string) bool {
+	if re := c.pattern(i); re != nil {
+		return anyListValue(f, re.MatchString)
+	}
+	var v adif.Field
+	if isFieldReference(o) {
+		v = e.Get(o[1 : len(o)-1])
+	} else {
+		v = e.Cast(c.FieldName, o)
+	}
+	return compareFields(e, c.Op, f, v)
+}
+
+// compareFields returns true if f and v satisfy op, which must not be
+// OpMatches or a glob.
+func compareFields(e EvaluationContext, op operator, f, v adif.Field) bool {
+	var strfn func(s, substr string) bool
+	switch op {
+	case OpMatches:
+		return false // invalid pattern
+	case OpContains:
+		strfn = strings.Contains
+	case OpStartsWith:
+		strfn = strings.HasPrefix
+	case OpEndsWith:
+		strfn = strings.HasSuffix
+	}
+	if strfn != nil {
+		want := strings.ToUpper(v.Value)
+		return want != "" && anyListValue(f, func(s string) bool { return strfn(strings.ToUpper(s), want) })
+	}
+	comp, err := e.Compare(f, v)
+	if err != nil {
+		return false
+	}
+	switch op {
+	case OpEqual, OpNotEqual:
+		return comp == 0
+	case OpLessThan:
+		return comp < 0
+	case OpLessThanEqual:
+		return comp <= 0
+	case OpGreaterThan:
+		return comp > 0
+	case OpGreaterThanEqual:
+		return comp >= 0
+	default:
+		panic("Unknown operator " + op)
+	}
+}
+
+// pattern returns the regular expression for the ith operand, or nil if it
+// is not a pattern or is invalid.  Comparisons created by ifValue.Set have
+// patterns compiled in advance.
+func (c comparison) pattern(i int) *regexp.Regexp {
+	if i < len(c.patterns) {
+		return c.patterns[i]
+	}
+	re, _ := compileOperand(c.Op, c.Operands[i])
+	return re
+}
+
+// compileOperand returns a case-insensitive regular expression for o if op is
+// OpMatches or o is a glob with OpEqual or OpNotEqual, otherwise nil.
+func compileOperand(op operator, o string) (*regexp.Regexp, error) {
+	switch op {
+	case OpMatches:
+		return regexp.Compile("(?i)" + o)
+	case OpEqual, OpNotEqual:
+		if isFieldReference(o) || !strings.ContainsAny(o, "*?") {
+			return nil, nil
 		}
-		switch c.Op {
-		case OpEqual:
-			if comp == 0 {
-				return result(true)
-			}
-		case OpLessThan:
-			if comp < 0 {
-				return result(true)
-			}
-		case OpLessThanEqual:
-			if comp <= 0 {
-				return result(true)
-			}
-		case OpGreaterThan:
-			if comp > 0 {
-				return result(true)
-			}
-		case OpGreaterThanEqual:
-			if comp >= 0 {
-				return result(true)
-			}
-		default:
-			panic("Unknown operator " + c.Op)
+		glob := regexp.QuoteMeta(o)
+		glob = strings.ReplaceAll(glob, `\*`, ".*")
+		glob = strings.ReplaceAll(glob, `\?`, ".")
+		return regexp.Compile("(?is)^" + glob + "$")
+	default:
+		return nil, nil
+	}
+}
+
+func isFieldReference(o string) bool {
+	return strings.HasPrefix(o, "{") && strings.HasSuffix(o, "}")
+}
+
+// anyListValue returns true if fn is true for f's value or, if f has a list
+// type, any value in the list.
+func anyListValue(f adif.Field, fn func(string) bool) bool {
+	var sep string
+	if sf, ok := spec.Fields[strings.ToUpper(f.Name)]; ok {
+		sep = sf.Type.ListSeparator()
+	}
+	if sep == "" || !strings.Contains(f.Value, sep) {
+		return fn(f.Value)
+	}
+	for _, v := range strings.Split(f.Value, sep) {
+		if fn(strings.TrimSpace(v)) {
+			return true
 		}
 	}
-	return result(false)
+	return false
 }
 
 type junction struct {
@@ -175,8 +257,9 @@ func (j junction) Evaluate(e EvaluationContext) bool {
 }
 
 type ConditionValue struct {
-	cur  junction
-	done []junction
+	cur   junction
+	done  []junction
+	where []Condition
 }
 
 func (cv *ConditionValue) IfFlag() flag.Value {
@@ -203,10 +286,17 @@ func (cv *ConditionValue) Get() junction {
 	for _, t := range cv.done {
 		j.Terms = append(j.Terms, t)
 	}
-	return j
+	if len(cv.where) == 0 {
+		return j
+	}
+	all := junction{Terms: cv.where}
+	if len(j.Terms) > 0 {
+		all.Terms = append([]Condition{j}, cv.where...)
+	}
+	return all
 }
 
-var conditionalPat = regexp.MustCompile(`^(\w+)(=|[<>]=?)(.*)`)
+var conditionalPat = regexp.MustCompile(`^(\w+)(=|!=|~|[*^$]=|[<>]=?)(.*)`)
 
 type ifValue struct {
 	cv     *ConditionValue
@@ -220,14 +310,27 @@ func (i *ifValue) Set(s string) error {
 	if g == nil {
 		return fmt.Errorf("invalid if condition: %q", s)
 	}
+	op := operator(g[2])
 	opts := strings.Split(g[3], "|")
+	if op == OpMatches {
+		// | is regular expression alternation
+		opts = []string{g[3]}
+	}
 	if g[3] == "" {
-		if g[2] != string(OpEqual) && g[2] != string(OpGreaterThan) {
+		if op != OpEqual && op != OpNotEqual && op != OpGreaterThan {
 			return fmt.Errorf("cannot use %s with empty string: %q", g[2], s)
 		}
 		opts = []string{""}
 	}
-	c := comparison{Op: operator(g[2]), FieldName: g[1], Operands: opts, Negate: i.negate}
+	c := comparison{Op: op, FieldName: g[1], Operands: opts, Negate: i.negate}
+	c.patterns = make([]*regexp.Regexp, len(opts))
+	for j, o := range opts {
+		re, err := compileOperand(op, o)
+		if err != nil {
+			return fmt.Errorf("invalid pattern in condition %q: %w", s, err)
+		}
+		c.patterns[j] = re
+	}
 	i.cv.cur.Terms = append(i.cv.cur.Terms, c)
 	return nil
 }
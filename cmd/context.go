@@ -38,6 +38,7 @@ type Context struct {
 	UserdefFields UserdefFieldList
 	Prepare       func(*adif.Logfile)
 	fs            filesystem
+	pipe          *pipe
 }
 
 func testPrepare(comment, adifVer, progName, progVer string) func(l *adif.Logfile) {
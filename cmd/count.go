@@ -0,0 +1,245 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/flwyd/adif-multitool/adif"
+)
+
+var Count = Command{Name: "count", Run: runCount, Help: helpCount,
+	Description: "Count records and distinct field values"}
+
+const (
+	CountStyleRecords = "records"
+	CountStyleReport  = "report"
+
+	countField = "COUNT"
+	totalValue = "TOTAL"
+	emptyValue = "EMPTY"
+)
+
+type CountContext struct {
+	Fields   FieldList
+	CrossTab bool
+	Style    string
+}
+
+func helpCount() string {
+	return `With no --fields, output a single record with the total COUNT.  With --fields,
+output one record for each distinct combination of field values, with COUNT.
+Field values are compared according to their type, so BAND 20M and 20m are
+counted together.
+
+--cross-tab with two fields outputs one record per value of the first field,
+a column for each value of the second field, and TOTAL row and column.
+Columns are named with the second field and its value, with characters other
+than letters, digits, and underscore changed to underscore, e.g.
+  adifmt count --fields band,mode --cross-tab --output csv
+  BAND,MODE_CW,MODE_SSB,TOTAL
+  40m,3,1,4
+  20m,2,5,7
+  TOTAL,5,6,11
+
+--style report passes through all input records and adds a "Report" comment
+with the total and the number of records for each value of each field.
+`
+}
+
+type countGroup struct {
+	first *adif.Record
+	count int
+}
+
+func runCount(ctx *Context, args []string) error {
+	cctx := ctx.CommandCtx.(*CountContext)
+	style := strings.ToLower(cctx.Style)
+	if style == "" {
+		style = CountStyleRecords
+	}
+	if style != CountStyleRecords && style != CountStyleReport {
+		return fmt.Errorf("unknown count style %q, want %s or %s", cctx.Style, CountStyleRecords, CountStyleReport)
+	}
+	if cctx.CrossTab && len(cctx.Fields) != 2 {
+		return fmt.Errorf("cross-tab needs exactly two fields, got %q", cctx.Fields.String())
+	}
+	if cctx.CrossTab && style == CountStyleReport {
+		return fmt.Errorf("cross-tab is not supported with %s style", CountStyleReport)
+	}
+	in := adif.NewLogfile()
+	acc := accumulator{Out: in, Ctx: ctx}
+	for _, f := range filesOrStdin(args) {
+		l, err := acc.read(f)
+		if err != nil {
+			return err
+		}
+		updateFieldOrder(in, l.FieldOrder)
+		in.Records = append(in.Records, l.Records...)
+	}
+	var out *adif.Logfile
+	switch {
+	case style == CountStyleReport:
+		out = in
+		out.Comment = countReport(in, cctx.Fields, ctx)
+	case cctx.CrossTab:
+		out = crossTab(in, cctx.Fields[0], cctx.Fields[1], ctx)
+	default:
+		out = adif.NewLogfile()
+		out.FieldOrder = append(append(out.FieldOrder, cctx.Fields...), countField)
+		for _, g := range countGroups(in.Records, cctx.Fields, in, ctx) {
+			r := adif.NewRecord()
+			for _, f := range cctx.Fields {
+				v, _ := g.first.Get(f)
+				r.Set(adif.Field{Name: f, Value: v.Value})
+			}
+			r.Set(adif.Field{Name: countField, Value: strconv.Itoa(g.count)})
+			out.AddRecord(r)
+		}
+	}
+	acc.Out = out
+	if err := acc.prepare(); err != nil {
+		return err
+	}
+	return write(ctx, out)
+}
+
+// countGroups returns records grouped by distinct values of fields, in sorted
+// order.  With no fields, all records are in a single group.
+func countGroups(recs []*adif.Record, fields []string, l *adif.Logfile, ctx *Context) []countGroup {
+	if len(recs) == 0 {
+		if len(fields) == 0 {
+			return []countGroup{{first: adif.NewRecord()}}
+		}
+		return nil
+	}
+	comp := recordComparator(fields, l, ctx.Locale)
+	sorted := make([]*adif.Record, len(recs))
+	copy(sorted, recs)
+	sort.SliceStable(sorted, func(i, j int) bool { return comp(sorted[i], sorted[j]) < 0 })
+	var res []countGroup
+	for _, r := range sorted {
+		if len(res) > 0 && comp(res[len(res)-1].first, r) == 0 {
+			res[len(res)-1].count++
+		} else {
+			res = append(res, countGroup{first: r, count: 1})
+		}
+	}
+	return res
+}
+
+var crossTabNameChars = regexp.MustCompile(`[^A-Z0-9_]+`)
+
+// crossTabColumn returns a field name for a value of colField, e.g. MODE_SSB
+// or BAND_1_25M, with runs of characters which are not valid in field names
+// replaced by an underscore.
+func crossTabColumn(colField, value string) string {
+	v := crossTabNameChars.ReplaceAllString(strings.ToUpper(strings.TrimSpace(value)), "_")
+	if v = strings.Trim(v, "_"); v == "" {
+		v = emptyValue
+	}
+	return strings.ToUpper(colField) + "_" + v
+}
+
+func crossTab(in *adif.Logfile, rowField, colField string, ctx *Context) *adif.Logfile {
+	out := adif.NewLogfile()
+	rows := countGroups(in.Records, []string{rowField}, in, ctx)
+	cols := countGroups(in.Records, []string{colField}, in, ctx)
+	used := map[string]bool{strings.ToUpper(rowField): true, totalValue: true}
+	colNames := make([]string, len(cols))
+	for i, c := range cols {
+		f, _ := c.first.Get(colField)
+		name := crossTabColumn(colField, f.Value)
+		for n := 2; used[name]; n++ { // e.g. values "A B" and "A-B"
+			name = fmt.Sprintf("%s_%d", crossTabColumn(colField, f.Value), n)
+		}
+		used[name] = true
+		colNames[i] = name
+	}
+	out.FieldOrder = append(append([]string{rowField}, colNames...), totalValue)
+	rowIndex := groupIndex(rows, rowField, in, ctx)
+	colIndex := groupIndex(cols, colField, in, ctx)
+	counts := make([][]int, len(rows))
+	for i := range counts {
+		counts[i] = make([]int, len(cols))
+	}
+	for _, r := range in.Records {
+		counts[rowIndex(r)][colIndex(r)]++
+	}
+	for i, row := range rows {
+		f, _ := row.first.Get(rowField)
+		rec := adif.NewRecord(adif.Field{Name: rowField, Value: f.Value})
+		for j, n := range counts[i] {
+			rec.Set(adif.Field{Name: colNames[j], Value: strconv.Itoa(n)})
+		}
+		rec.Set(adif.Field{Name: totalValue, Value: strconv.Itoa(row.count)})
+		out.AddRecord(rec)
+	}
+	total := adif.NewRecord(adif.Field{Name: rowField, Value: totalValue})
+	for i, c := range cols {
+		total.Set(adif.Field{Name: colNames[i], Value: strconv.Itoa(c.count)})
+	}
+	total.Set(adif.Field{Name: totalValue, Value: strconv.Itoa(len(in.Records))})
+	out.AddRecord(total)
+	return out
+}
+
+// groupIndex returns a function which finds the position of a record in
+// groups, as returned by countGroups for field.  Positions are found by binary
+// search and saved for each distinct field value.
+func groupIndex(groups []countGroup, field string, l *adif.Logfile, ctx *Context) func(*adif.Record) int {
+	comp := recordComparator([]string{field}, l, ctx.Locale)
+	found := make(map[adif.Field]int)
+	return func(r *adif.Record) int {
+		f, _ := r.Get(field)
+		if i, ok := found[f]; ok {
+			return i
+		}
+		i := sort.Search(len(groups), func(i int) bool { return comp(groups[i].first, r) >= 0 })
+		if i == len(groups) || comp(groups[i].first, r) != 0 {
+			for i = range groups { // comparison was not transitive, e.g. mixed types
+				if comp(groups[i].first, r) == 0 {
+					break
+				}
+			}
+		}
+		found[f] = i
+		return i
+	}
+}
+
+// countReport formats counts as a plain text comment, with a "Report" heading,
+// the total number of records, and a section for each field listing the number
+// of records with each distinct value.
+func countReport(in *adif.Logfile, fields []string, ctx *Context) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Report\n\nRecords: %d\n", len(in.Records))
+	for _, f := range fields {
+		fmt.Fprintf(&b, "\n%s\n", strings.ToUpper(f))
+		for _, g := range countGroups(in.Records, []string{f}, in, ctx) {
+			v, _ := g.first.Get(f)
+			val := v.Value
+			if val == "" {
+				val = "(" + strings.ToLower(emptyValue) + ")"
+			}
+			fmt.Fprintf(&b, "  %s: %d\n", val, g.count)
+		}
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
@@ -0,0 +1,179 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/flwyd/adif-multitool/adif"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCount(t *testing.T) {
+	adi := adif.NewADIIO()
+	csv := adif.NewCSVIO()
+	file1 := `CALL,BAND,MODE
+K1A,20m,CW
+K2B,20M,SSB
+K3C,40m,CW
+K4D,20m,cw
+K5E,,FT8
+`
+	tests := []struct {
+		name, file, want string
+		ctx              CountContext
+	}{
+		{
+			name: "total",
+			file: file1,
+			want: "COUNT\n5\n",
+		},
+		{
+			name: "total empty",
+			file: "CALL,BAND\n",
+			want: "COUNT\n0\n",
+		},
+		{
+			name: "one field",
+			file: file1,
+			ctx:  CountContext{Fields: FieldList{"BAND"}},
+			want: `BAND,COUNT
+,1
+40m,1
+20m,3
+`,
+		},
+		{
+			name: "two fields",
+			file: file1,
+			ctx:  CountContext{Fields: FieldList{"MODE", "BAND"}, Style: CountStyleRecords},
+			want: `MODE,BAND,COUNT
+CW,40m,1
+CW,20m,2
+FT8,,1
+SSB,20M,1
+`,
+		},
+		{
+			name: "cross tab",
+			file: file1,
+			ctx:  CountContext{Fields: FieldList{"BAND", "MODE"}, CrossTab: true},
+			want: `BAND,MODE_CW,MODE_FT8,MODE_SSB,TOTAL
+,0,1,0,1
+40m,1,0,0,1
+20m,2,0,1,3
+TOTAL,3,1,1,5
+`,
+		},
+		{
+			name: "cross tab column names",
+			file: "BAND,APP_X_CLUB\n20m,Key Club\n20m,Key-Club\n40m,\n",
+			ctx:  CountContext{Fields: FieldList{"BAND", "APP_X_CLUB"}, CrossTab: true},
+			want: `BAND,APP_X_CLUB_EMPTY,APP_X_CLUB_KEY_CLUB,APP_X_CLUB_KEY_CLUB_2,TOTAL
+40m,1,0,0,1
+20m,0,1,1,2
+TOTAL,1,1,1,3
+`,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			cctx := tc.ctx
+			ctx := &Context{
+				OutputFormat: adif.FormatCSV,
+				Readers:      readers(adi, csv),
+				Writers:      writers(adi, csv),
+				Out:          out,
+				Prepare:      testPrepare("My Comment", "3.1.4", "count test", "1.2.3"),
+				fs:           fakeFilesystem{map[string]string{"foo.csv": tc.file}},
+				CommandCtx:   &cctx}
+			if err := Count.Run(ctx, []string{"foo.csv"}); err != nil {
+				t.Fatalf("Count.Run(%+v, foo.csv) got error %v", tc.ctx, err)
+			}
+			if diff := cmp.Diff(tc.want, out.String()); diff != "" {
+				t.Errorf("Count.Run(%+v, foo.csv) unexpected output, diff:\n%s", tc.ctx, diff)
+			}
+		})
+	}
+}
+
+func TestCountReport(t *testing.T) {
+	adi := adif.NewADIIO()
+	out := &bytes.Buffer{}
+	file1 := `<EOH>
+<CALL:4>W1AW <BAND:3>40m <EOR>
+<CALL:4>K1MU <BAND:3>20m <EOR>
+<CALL:4>W1AW <BAND:3>40M <EOR>
+<CALL:3>N0P <EOR>
+`
+	ctx := &Context{
+		OutputFormat: adif.FormatADI,
+		Readers:      readers(adi),
+		Writers:      writers(adi),
+		Out:          out,
+		Prepare:      testPrepare("My Comment", "3.1.4", "count test", "1.2.3"),
+		fs:           fakeFilesystem{map[string]string{"foo.adi": file1}},
+		CommandCtx:   &CountContext{Fields: FieldList{"BAND", "CALL"}, Style: CountStyleReport}}
+	if err := Count.Run(ctx, []string{"foo.adi"}); err != nil {
+		t.Fatalf("Count.Run(ctx, foo.adi) got error %v", err)
+	}
+	want := `My Comment
+<ADIF_VER:5>3.1.4 <PROGRAMID:10>count test <PROGRAMVERSION:5>1.2.3 <EOH>
+<CALL:4>W1AW <BAND:3>40m <EOR>
+<CALL:4>K1MU <BAND:3>20m <EOR>
+<CALL:4>W1AW <BAND:3>40M <EOR>
+<CALL:3>N0P <EOR>
+Report
+
+Records: 4
+
+BAND
+  (empty): 1
+  40m: 2
+  20m: 1
+
+CALL
+  K1MU: 1
+  N0P: 1
+  W1AW: 2
+`
+	if diff := cmp.Diff(want, out.String()); diff != "" {
+		t.Errorf("Count.Run(ctx, foo.adi) unexpected output, diff:\n%s", diff)
+	}
+}
+
+func TestCountErrors(t *testing.T) {
+	csv := adif.NewCSVIO()
+	for _, cctx := range []CountContext{
+		{Style: "table"},
+		{Fields: FieldList{"BAND"}, CrossTab: true},
+		{Fields: FieldList{"BAND", "MODE", "CALL"}, CrossTab: true},
+		{Fields: FieldList{"BAND", "MODE"}, CrossTab: true, Style: CountStyleReport},
+	} {
+		cctx := cctx
+		ctx := &Context{
+			OutputFormat: adif.FormatCSV,
+			Readers:      readers(csv),
+			Writers:      writers(csv),
+			Out:          &bytes.Buffer{},
+			fs:           fakeFilesystem{map[string]string{"foo.csv": "CALL,BAND,MODE\nK1A,20m,CW\n"}},
+			CommandCtx:   &cctx}
+		if err := Count.Run(ctx, []string{"foo.csv"}); err == nil {
+			t.Errorf("Count.Run(%+v, foo.csv) got no error", cctx)
+		}
+	}
+}
@@ -0,0 +1,258 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/flwyd/adif-multitool/adif"
+	"github.com/flwyd/adif-multitool/adif/spec"
+)
+
+// CSVMapping is a flag which reads a column mapping file for CSV and TSV
+// input into Mapping.
+type CSVMapping struct{ Mapping *adif.ColumnMapping }
+
+func (c CSVMapping) String() string { return "" }
+
+// Set reads the named mapping file, see Read for the file format.
+func (c CSVMapping) Set(filename string) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := c.Read(f); err != nil {
+		return fmt.Errorf("%s: %w", filename, err)
+	}
+	return nil
+}
+
+// Read parses column mappings, one per line, and adds them to c.Mapping.  Each
+// line has a column name (quoted if it contains an equals sign), an equals
+// sign, an ADIF field name or - to ignore the column, and optional
+// conversions:
+//
+//	# comments and blank lines are ignored
+//	Date = QSO_DATE date:DD/MM/YYYY
+//	Time (local) = TIME_ON time:HH:MM zone:America/Denver
+//	Freq kHz = FREQ khz
+//	Their Call = CALL upper
+//	Notes = -
+//
+// Conversions are khz and hz (to MHz), upper, lower, date:FORMAT and
+// time:FORMAT (see dateLayout and timeLayout, quote formats with spaces), and
+// zone:NAME to convert TIME_ON and TIME_OFF from a local time zone to UTC.
+func (c CSVMapping) Read(r io.Reader) error {
+	scan := bufio.NewScanner(r)
+	line := 0
+	for scan.Scan() {
+		line++
+		text := strings.TrimSpace(scan.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		if err := c.parseLine(text); err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+	}
+	return scan.Err()
+}
+
+func (c CSVMapping) parseLine(text string) error {
+	i := indexUnquoted(text, '=')
+	if i < 0 {
+		return fmt.Errorf(`expected "column = FIELD", got %q`, text)
+	}
+	col, err := splitQuoted(text[:i])
+	if err != nil {
+		return err
+	}
+	tok, err := splitQuoted(text[i+1:])
+	if err != nil {
+		return err
+	}
+	if len(col) == 0 || len(tok) == 0 {
+		return fmt.Errorf(`expected "column = FIELD", got %q`, text)
+	}
+	column := strings.Join(col, " ")
+	f := adif.ColumnField{Name: strings.ToUpper(tok[0])}
+	if f.Name == "-" {
+		if len(tok) > 1 {
+			return fmt.Errorf("conversions %s for ignored column %q", strings.Join(tok[1:], " "), column)
+		}
+		return c.Mapping.Add(column, adif.ColumnField{})
+	}
+	if !adifNamePat.MatchString(f.Name) {
+		return fmt.Errorf("invalid ADIF field name %q for column %q", tok[0], column)
+	}
+	var convs []func(string) (string, error)
+	for _, t := range tok[1:] {
+		name, arg, _ := strings.Cut(t, ":")
+		var conv func(string) (string, error)
+		switch strings.ToLower(name) {
+		case "khz":
+			conv = scaleFrequency(1000)
+		case "hz":
+			conv = scaleFrequency(1000000)
+		case "upper":
+			conv = func(s string) (string, error) { return strings.ToUpper(s), nil }
+		case "lower":
+			conv = func(s string) (string, error) { return strings.ToLower(s), nil }
+		case "date":
+			l, err := dateLayout(arg)
+			if err != nil {
+				return err
+			}
+			conv = func(s string) (string, error) { return formatADIFDate(l, s) }
+		case "time":
+			l, err := timeLayout(arg)
+			if err != nil {
+				return err
+			}
+			conv = func(s string) (string, error) { return formatADIFTime(l, s) }
+		case "zone":
+			if err := c.setZone(arg); err != nil {
+				return err
+			}
+			continue
+		default:
+			return fmt.Errorf("unknown conversion %q for column %q", t, column)
+		}
+		convs = append(convs, conv)
+	}
+	if len(convs) > 0 {
+		f.Convert = func(s string) (string, error) {
+			var err error
+			for _, conv := range convs {
+				if s, err = conv(s); err != nil {
+					return s, err
+				}
+			}
+			return s, nil
+		}
+	}
+	return c.Mapping.Add(column, f)
+}
+
+func (c CSVMapping) setZone(name string) error {
+	if name == "" {
+		return errors.New("missing time zone name in zone:")
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return fmt.Errorf("invalid time zone %q: %w", name, err)
+	}
+	if c.Mapping.Adjust != nil {
+		return fmt.Errorf("time zone %s: only one zone conversion is allowed", name)
+	}
+	c.Mapping.Adjust = func(r *adif.Record) error {
+		if fieldValue(r, spec.TimeOnField.Name) == "" && fieldValue(r, spec.TimeOffField.Name) == "" {
+			return nil
+		}
+		if err := adjustTimeZone(r, loc, time.UTC); err != nil {
+			return fmt.Errorf("could not convert from %s: %w", name, err)
+		}
+		return nil
+	}
+	return nil
+}
+
+func scaleFrequency(divisor float64) func(string) (string, error) {
+	return func(s string) (string, error) {
+		f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+		if err != nil {
+			return s, errors.New("not a number")
+		}
+		return formatNumber(f/divisor, 6), nil
+	}
+}
+
+// indexUnquoted returns the first index of b in s which is not inside double
+// quotes, or -1.
+func indexUnquoted(s string, b byte) int {
+	quoted := false
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '"':
+			quoted = !quoted
+		case b:
+			if !quoted {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// splitQuoted splits s on whitespace like strings.Fields, except whitespace
+// inside double quotes.  Quote characters are removed.
+func splitQuoted(s string) ([]string, error) {
+	var res []string
+	var cur strings.Builder
+	quoted, inWord := false, false
+	for _, r := range s {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			inWord = true
+		case !quoted && (r == ' ' || r == '\t'):
+			if inWord {
+				res = append(res, cur.String())
+				cur.Reset()
+				inWord = false
+			}
+		default:
+			cur.WriteRune(r)
+			inWord = true
+		}
+	}
+	if quoted {
+		return nil, fmt.Errorf("unterminated quote in %q", s)
+	}
+	if inWord {
+		res = append(res, cur.String())
+	}
+	return res, nil
+}
+
+// CSVColumns is a flag with comma-separated or multiple instance column names
+// for CSV and TSV input without a header row.
+type CSVColumns struct{ Mapping *adif.ColumnMapping }
+
+func (c CSVColumns) String() string {
+	if c.Mapping == nil {
+		return ""
+	}
+	return strings.Join(c.Mapping.Columns, ",")
+}
+
+func (c CSVColumns) Set(s string) error {
+	for _, n := range strings.Split(s, ",") {
+		n = strings.TrimSpace(n)
+		if n == "" {
+			return fmt.Errorf("empty column name in %q", s)
+		}
+		c.Mapping.Columns = append(c.Mapping.Columns, n)
+	}
+	return nil
+}
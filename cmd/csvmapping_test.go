@@ -0,0 +1,147 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/flwyd/adif-multitool/adif"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCSVMapping(t *testing.T) {
+	mapping := `# club spreadsheet export
+Date = QSO_DATE date:DD/MM/YYYY
+Time (local) = TIME_ON time:"hh:mm PM" zone:America/New_York
+"Freq = kHz" = FREQ khz
+Their   Call = call upper
+Notes = -
+`
+	input := `Date,Time (Local),Freq = kHz,Their Call,Notes,Mode
+31/12/2023,8:05 PM,14074,w1aw,ignored,FT8
+01/01/2024,,7030.5,k0a,,CW
+`
+	m := &adif.ColumnMapping{}
+	if err := (CSVMapping{Mapping: m}).Read(strings.NewReader(mapping)); err != nil {
+		t.Fatalf("Read(%q) got error %v", mapping, err)
+	}
+	csv := adif.NewCSVIO()
+	csv.Mapping = m
+	l, err := csv.Read(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Read(%q) got error %v", input, err)
+	}
+	want := [][]adif.Field{
+		{{Name: "QSO_DATE", Value: "20240101"}, {Name: "TIME_ON", Value: "0105"}, {Name: "FREQ", Value: "14.074"}, {Name: "CALL", Value: "W1AW"}, {Name: "MODE", Value: "FT8"}},
+		{{Name: "QSO_DATE", Value: "20240101"}, {Name: "TIME_ON", Value: ""}, {Name: "FREQ", Value: "7.0305"}, {Name: "CALL", Value: "K0A"}, {Name: "MODE", Value: "CW"}},
+	}
+	if len(l.Records) != len(want) {
+		t.Fatalf("Read(%q) got %d records, want %d", input, len(l.Records), len(want))
+	}
+	for i, r := range l.Records {
+		if diff := cmp.Diff(want[i], r.Fields()); diff != "" {
+			t.Errorf("Read(%q) record %d diff:\n%s", input, i+1, diff)
+		}
+	}
+}
+
+func TestCSVMappingErrors(t *testing.T) {
+	tests := []string{
+		"Date QSO_DATE",
+		"Date =",
+		"= QSO_DATE",
+		"Date = QSO-DATE",
+		"Date = QSO_DATE julian",
+		"Date = QSO_DATE date:QQ/MM",
+		"Time = TIME_ON time:%Q",
+		"Time = TIME_ON zone:Mars/Olympus_Mons",
+		"Time = TIME_ON zone:UTC\nTime Off = TIME_OFF zone:Asia/Tokyo",
+		"Notes = - upper",
+		`"Their Call = CALL`,
+		"Call = CALL\ncall = CALL",
+	}
+	for _, tc := range tests {
+		if err := (CSVMapping{Mapping: &adif.ColumnMapping{}}).Read(strings.NewReader(tc)); err == nil {
+			t.Errorf("Read(%q) got no error", tc)
+		}
+	}
+}
+
+func TestCSVColumns(t *testing.T) {
+	m := &adif.ColumnMapping{}
+	c := CSVColumns{Mapping: m}
+	for _, s := range []string{"call, band", "MODE"} {
+		if err := c.Set(s); err != nil {
+			t.Errorf("Set(%q) got error %v", s, err)
+		}
+	}
+	if diff := cmp.Diff([]string{"call", "band", "MODE"}, m.Columns); diff != "" {
+		t.Errorf("Columns diff:\n%s", diff)
+	}
+	if got, want := c.String(), "call,band,MODE"; got != want {
+		t.Errorf("String() got %q, want %q", got, want)
+	}
+	if err := c.Set("call,,band"); err == nil {
+		t.Errorf("Set(%q) got no error", "call,,band")
+	}
+}
+
+func TestDateTimeLayouts(t *testing.T) {
+	tests := []struct {
+		format, value, want string
+		time                bool
+	}{
+		{format: "DD/MM/YYYY", value: "17/10/2023", want: "20231017"},
+		{format: "m/d/yy", value: "10/7/23", want: "20231007"},
+		{format: "D Month YYYY", value: "7 October 2023", want: "20231007"},
+		{format: "DD-MON-YYYY", value: "07-Oct-2023", want: "20231007"},
+		{format: "%Y-%m-%d", value: "2023-10-17", want: "20231017"},
+		{format: "Jan 2, 2006", value: "Oct 17, 2023", want: "20231017"},
+		{format: "HH:MM", value: "14:05", want: "1405", time: true},
+		{format: "HH:mm:ss", value: "14:05:09", want: "140509", time: true},
+		{format: "h:mm am", value: "2:05 pm", want: "1405", time: true},
+		{format: "%H%M", value: "0905", want: "0905", time: true},
+		{format: "%T", value: "09:05:00", want: "090500", time: true},
+		{format: "15.04", value: "21.30", want: "2130", time: true},
+	}
+	for _, tc := range tests {
+		var got string
+		if tc.time {
+			l, err := timeLayout(tc.format)
+			if err != nil {
+				t.Errorf("timeLayout(%q) got error %v", tc.format, err)
+				continue
+			}
+			if got, err = formatADIFTime(l, tc.value); err != nil {
+				t.Errorf("formatADIFTime(%q, %q) got error %v", l, tc.value, err)
+				continue
+			}
+		} else {
+			l, err := dateLayout(tc.format)
+			if err != nil {
+				t.Errorf("dateLayout(%q) got error %v", tc.format, err)
+				continue
+			}
+			if got, err = formatADIFDate(l, tc.value); err != nil {
+				t.Errorf("formatADIFDate(%q, %q) got error %v", l, tc.value, err)
+				continue
+			}
+		}
+		if got != tc.want {
+			t.Errorf("format %q value %q got %q, want %q", tc.format, tc.value, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,364 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/flwyd/adif-multitool/adif/spec"
+)
+
+// ctyEntity is a DXCC entity (or WAE region) from a country file, with any
+// zone, continent, or location overrides for a particular prefix applied.
+type ctyEntity struct {
+	Name string
+	// DXCC is a spec.DxccEntityCodeEnumeration code, or empty if the entity
+	// name could not be matched to an ADIF entity.
+	DXCC      string
+	CQZone    int
+	ITUZone   int
+	Continent string
+	// Lat and Lon are in decimal degrees, positive north and east.
+	Lat, Lon float64
+}
+
+// ctyDatabase resolves callsigns to entities using the country file format
+// maintained by Jim Reisert AD1C at https://www.country-files.com/
+type ctyDatabase struct {
+	prefixes map[string]ctyEntity
+	calls    map[string]ctyEntity
+}
+
+// ctyIgnoredSuffixes are callsign suffixes which don't change the entity.
+var ctyIgnoredSuffixes = map[string]bool{
+	"P": true, "M": true, "A": true, "B": true, "QRP": true, "QRPP": true, "LH": true, "BCN": true,
+}
+
+// ctyOverrideEnds maps the opening character of a prefix override to the
+// closing character.
+var ctyOverrideEnds = map[byte]byte{'(': ')', '[': ']', '<': '>', '{': '}', '~': '~'}
+
+// ctyNameAliases maps cty.dat entity names which differ from the ADIF
+// specification's entity names to DXCC codes.  Entities marked as WAE-only in
+// cty.dat map to their parent DXCC entity.
+var ctyNameAliases = map[string]string{
+	"Sov Mil Order of Malta":           "246",
+	"Agalega & St. Brandon":            "4",
+	"Kingdom of Eswatini":              "468",
+	"Dem. Rep. of the Congo":           "414",
+	"Fed. Rep. of Germany":             "230",
+	"Austral Islands":                  "508",
+	"Vatican City":                     "295",
+	"United States":                    "291",
+	"US Virgin Islands":                "285",
+	"DPR of Korea":                     "344",
+	"Sint Maarten":                     "518",
+	"St. Peter & St. Paul":             "253",
+	"Trindade & Martim Vaz":            "273",
+	"Western Kiribati":                 "301",
+	"Central Kiribati":                 "31",
+	"Eastern Kiribati":                 "48",
+	"Banaba Island":                    "490",
+	"Asiatic Turkey":                   "390",
+	"Central African Republic":         "408",
+	"North Macedonia":                  "502",
+	"Republic of South Sudan":          "521",
+	"UK Base Areas on Cyprus":          "283",
+	"Tristan da Cunha & Gough Islands": "274",
+	"N.Z. Subantarctic Is.":            "16",
+	"Pr. Edward & Marion Is.":          "201",
+	"Kosovo":                           "522",
+	// WAE entities
+	"European Turkey":  "390",
+	"Vienna Intl Ctr":  "206",
+	"African Italy":    "248",
+	"Sicily":           "248",
+	"Shetland Islands": "279",
+	"Bear Island":      "259",
+}
+
+// readCtyFile parses a country file in cty.csv format if filename ends in
+// .csv and in cty.dat format otherwise.
+func readCtyFile(ctx *Context, filename string) (*ctyDatabase, error) {
+	fs := ctx.fs
+	if fs == nil {
+		fs = osFilesystem{}
+	}
+	f, err := fs.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var db *ctyDatabase
+	if strings.EqualFold(filepath.Ext(filename), ".csv") {
+		db, err = parseCtyCSV(f)
+	} else {
+		db, err = parseCtyDat(f)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading country file %s: %w", filename, err)
+	}
+	return db, nil
+}
+
+// parseCtyDat reads the cty.dat format, where each entity is a line with eight
+// colon-terminated fields (name, CQ zone, ITU zone, continent, latitude,
+// longitude with west positive, UTC offset, primary prefix) followed by a
+// comma-separated list of prefixes and exact callsigns terminated by a
+// semicolon.
+func parseCtyDat(r io.Reader) (*ctyDatabase, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	db := &ctyDatabase{prefixes: make(map[string]ctyEntity), calls: make(map[string]ctyEntity)}
+	names := dxccNameCodes()
+	for _, chunk := range strings.Split(string(b), ";") {
+		chunk = strings.TrimSpace(chunk)
+		if chunk == "" {
+			continue
+		}
+		fields := strings.SplitN(chunk, ":", 9)
+		if len(fields) != 9 {
+			return nil, fmt.Errorf("malformed entity %q", firstLine(chunk))
+		}
+		for i := range fields {
+			fields[i] = strings.TrimSpace(fields[i])
+		}
+		e, err := parseCtyEntity(fields[0], fields[1], fields[2], fields[3], fields[4], fields[5])
+		if err != nil {
+			return nil, err
+		}
+		if c, ok := ctyNameAliases[e.Name]; ok {
+			e.DXCC = c
+		} else {
+			e.DXCC = names[normalizeEntityName(e.Name)]
+		}
+		if err := db.addAliases(e, strings.Split(fields[8], ",")); err != nil {
+			return nil, err
+		}
+	}
+	return db, nil
+}
+
+// parseCtyCSV reads the cty.csv format, which has the columns primary prefix,
+// entity name, DXCC code, continent, CQ zone, ITU zone, latitude, longitude
+// (west positive), UTC offset, and a space-separated list of prefixes and
+// exact callsigns terminated by a semicolon.
+func parseCtyCSV(r io.Reader) (*ctyDatabase, error) {
+	c := csv.NewReader(r)
+	c.FieldsPerRecord = 10
+	db := &ctyDatabase{prefixes: make(map[string]ctyEntity), calls: make(map[string]ctyEntity)}
+	for {
+		row, err := c.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		e, err := parseCtyEntity(row[1], row[4], row[5], row[3], row[6], row[7])
+		if err != nil {
+			return nil, err
+		}
+		e.DXCC = strings.TrimSpace(row[2])
+		if err := db.addAliases(e, strings.Fields(strings.TrimSuffix(strings.TrimSpace(row[9]), ";"))); err != nil {
+			return nil, err
+		}
+	}
+	return db, nil
+}
+
+func parseCtyEntity(name, cq, itu, cont, lat, lon string) (ctyEntity, error) {
+	e := ctyEntity{Name: strings.TrimSpace(name), Continent: strings.TrimSpace(cont)}
+	var err error
+	if e.CQZone, err = strconv.Atoi(strings.TrimSpace(cq)); err != nil {
+		return e, fmt.Errorf("invalid CQ zone for %s: %w", e.Name, err)
+	}
+	if e.ITUZone, err = strconv.Atoi(strings.TrimSpace(itu)); err != nil {
+		return e, fmt.Errorf("invalid ITU zone for %s: %w", e.Name, err)
+	}
+	if e.Lat, err = strconv.ParseFloat(strings.TrimSpace(lat), 64); err != nil {
+		return e, fmt.Errorf("invalid latitude for %s: %w", e.Name, err)
+	}
+	if e.Lon, err = strconv.ParseFloat(strings.TrimSpace(lon), 64); err != nil {
+		return e, fmt.Errorf("invalid longitude for %s: %w", e.Name, err)
+	}
+	e.Lon = -e.Lon
+	return e, nil
+}
+
+// addAliases adds prefixes and exact callsigns (starting with =) for entity e.
+// Each alias may be followed by overrides: (CQ zone), [ITU zone],
+// <lat/lon>, {continent}, and ~UTC offset~.
+func (db *ctyDatabase) addAliases(e ctyEntity, aliases []string) error {
+	for _, a := range aliases {
+		a = strings.TrimSpace(a)
+		if a == "" {
+			continue
+		}
+		exact := strings.HasPrefix(a, "=")
+		a = strings.TrimPrefix(a, "=")
+		ae := e
+		end := strings.IndexAny(a, "([<{~")
+		if end < 0 {
+			end = len(a)
+		}
+		call := strings.ToUpper(a[:end])
+		for o := a[end:]; o != ""; {
+			c, ok := ctyOverrideEnds[o[0]]
+			j := strings.IndexByte(o[1:], c) + 1
+			if !ok || j == 0 {
+				return fmt.Errorf("malformed override in %q for %s", a, e.Name)
+			}
+			val := o[1:j]
+			var err error
+			switch o[0] {
+			case '(':
+				ae.CQZone, err = strconv.Atoi(val)
+			case '[':
+				ae.ITUZone, err = strconv.Atoi(val)
+			case '{':
+				ae.Continent = val
+			case '<':
+				ll := strings.SplitN(val, "/", 2)
+				if len(ll) != 2 {
+					err = fmt.Errorf("expected lat/lon, got %q", val)
+					break
+				}
+				if ae.Lat, err = strconv.ParseFloat(ll[0], 64); err == nil {
+					ae.Lon, err = strconv.ParseFloat(ll[1], 64)
+					ae.Lon = -ae.Lon
+				}
+			}
+			if err != nil {
+				return fmt.Errorf("malformed override in %q for %s: %w", a, e.Name, err)
+			}
+			o = o[j+1:]
+		}
+		if exact {
+			db.calls[call] = ae
+		} else {
+			db.prefixes[call] = ae
+		}
+	}
+	return nil
+}
+
+// lookup finds the entity for a callsign.  Exact callsign matches take
+// precedence, then the longest matching prefix.  Portable operations are
+// handled: KH6/W1AW and W1AW/KH6 both resolve to Hawaii, W1AW/4 is treated as
+// W4AW, suffixes like /P and /QRP are ignored, and maritime and aeronautical
+// mobile (/MM and /AM) stations are not in any entity.
+func (db *ctyDatabase) lookup(call string) (ctyEntity, bool) {
+	call = strings.ToUpper(strings.TrimSpace(call))
+	if call == "" {
+		return ctyEntity{}, false
+	}
+	if e, ok := db.calls[call]; ok {
+		return e, true
+	}
+	var parts []string
+	for _, p := range strings.Split(call, "/") {
+		if p == "MM" || p == "AM" {
+			return ctyEntity{}, false
+		}
+		if p != "" && !ctyIgnoredSuffixes[p] {
+			parts = append(parts, p)
+		}
+	}
+	switch len(parts) {
+	case 0:
+		return ctyEntity{}, false
+	case 1:
+		if e, ok := db.calls[parts[0]]; ok {
+			return e, true
+		}
+		return db.longestPrefix(parts[0])
+	}
+	base, pre := parts[0], parts[1]
+	if len(base) < len(pre) {
+		base, pre = pre, base
+	}
+	if len(pre) == 1 && unicode.IsDigit(rune(pre[0])) {
+		return db.longestPrefix(replaceCallDigit(base, pre[0]))
+	}
+	return db.longestPrefix(pre)
+}
+
+func (db *ctyDatabase) longestPrefix(s string) (ctyEntity, bool) {
+	for i := len(s); i > 0; i-- {
+		if e, ok := db.prefixes[s[:i]]; ok {
+			return e, true
+		}
+	}
+	return ctyEntity{}, false
+}
+
+// replaceCallDigit replaces the last digit before a callsign's suffix, e.g.
+// W1AW and 4 returns W4AW.
+func replaceCallDigit(call string, digit byte) string {
+	for i := len(call) - 1; i >= 0; i-- {
+		if unicode.IsDigit(rune(call[i])) {
+			return call[:i] + string(digit) + call[i+1:]
+		}
+	}
+	return call
+}
+
+// dxccNameCodes maps normalized names of current DXCC entities to codes.
+func dxccNameCodes() map[string]string {
+	res := make(map[string]string)
+	for _, e := range spec.DxccEntityCodeEnumeration.Values {
+		ee := e.(spec.DxccEntityCodeEnum)
+		if ee.Deleted == "true" {
+			continue
+		}
+		res[normalizeEntityName(ee.EntityName)] = ee.EntityCode
+	}
+	return res
+}
+
+// normalizeEntityName reduces differences in punctuation and abbreviation
+// between country file and ADIF entity names.
+func normalizeEntityName(s string) string {
+	s = strings.ReplaceAll(strings.ToUpper(s), "&", " AND ")
+	words := strings.FieldsFunc(s, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) })
+	for i, w := range words {
+		switch w {
+		case "ISLANDS":
+			words[i] = "IS"
+		case "ISLAND":
+			words[i] = "I"
+		case "SAINT":
+			words[i] = "ST"
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+func firstLine(s string) string {
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
@@ -0,0 +1,148 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+const testCtyDat = `United States:            05:  08:  NA:   37.53:    91.67:     5.0:  K:
+    AA,AB,K,N,W,
+    =K1ABC(4)[7]<40.0/105.0>;
+Hawaii:                   31:  61:  OC:   21.12:   157.48:    10.0:  KH6:
+    AH6,KH6,NH6,WH6;
+Fed. Rep. of Germany:     14:  28:  EU:   51.00:   -10.00:    -1.0:  DL:
+    DA,DB,DC,DD,DE,DF,DG,DH,DI,DJ,DK,DL,DM,DN,DO,DP,DQ,DR;
+Sicily:                   15:  28:  EU:   37.50:   -14.00:    -1.0:  *IT9:
+    IT9,IW9;
+Italy:                    15:  28:  EU:   42.82:   -12.58:    -1.0:  I:
+    I,=IT9ABC;
+Japan:                    25:  45:  AS:   36.40:  -138.38:    -9.0:  JA:
+    JA,JE,JR;
+Ogasawara:                27:  45:  AS:   27.05:  -142.20:    -9.0:  JD1:
+    JD1;
+St. Kitts & Nevis:        08:  11:  NA:   17.37:    62.78:     4.0:  V4:
+    V4;
+Atlantis:                 99:  99:  AF:    0.00:     0.00:     0.0:  ZZ:
+    ZZ;
+`
+
+const testCtyCSV = `K,United States,291,NA,5,8,37.53,91.67,5.0,AA AB K N W =K1ABC(4)[7]<40.0/105.0>;
+KH6,Hawaii,110,OC,31,61,21.12,157.48,10.0,AH6 KH6 NH6 WH6;
+*IT9,Sicily,248,EU,15,28,37.50,-14.00,-1.0,IT9 IW9;
+I,Italy,248,EU,15,28,42.82,-12.58,-1.0,I =IT9ABC;
+`
+
+func TestCtyLookup(t *testing.T) {
+	us := ctyEntity{Name: "United States", DXCC: "291", CQZone: 5, ITUZone: 8, Continent: "NA", Lat: 37.53, Lon: -91.67}
+	k1abc := ctyEntity{Name: "United States", DXCC: "291", CQZone: 4, ITUZone: 7, Continent: "NA", Lat: 40.0, Lon: -105.0}
+	hawaii := ctyEntity{Name: "Hawaii", DXCC: "110", CQZone: 31, ITUZone: 61, Continent: "OC", Lat: 21.12, Lon: -157.48}
+	sicily := ctyEntity{Name: "Sicily", DXCC: "248", CQZone: 15, ITUZone: 28, Continent: "EU", Lat: 37.50, Lon: 14.00}
+	italy := ctyEntity{Name: "Italy", DXCC: "248", CQZone: 15, ITUZone: 28, Continent: "EU", Lat: 42.82, Lon: 12.58}
+	germany := ctyEntity{Name: "Fed. Rep. of Germany", DXCC: "230", CQZone: 14, ITUZone: 28, Continent: "EU", Lat: 51.00, Lon: 10.00}
+	japan := ctyEntity{Name: "Japan", DXCC: "339", CQZone: 25, ITUZone: 45, Continent: "AS", Lat: 36.40, Lon: 138.38}
+	ogasawara := ctyEntity{Name: "Ogasawara", DXCC: "192", CQZone: 27, ITUZone: 45, Continent: "AS", Lat: 27.05, Lon: 142.20}
+	kitts := ctyEntity{Name: "St. Kitts & Nevis", DXCC: "249", CQZone: 8, ITUZone: 11, Continent: "NA", Lat: 17.37, Lon: -62.78}
+	atlantis := ctyEntity{Name: "Atlantis", CQZone: 99, ITUZone: 99, Continent: "AF"}
+	none := ctyEntity{}
+
+	tests := []struct {
+		call    string
+		want    ctyEntity
+		wantCSV ctyEntity
+	}{
+		{call: "W1AW", want: us, wantCSV: us},
+		{call: "w1aw", want: us, wantCSV: us},
+		{call: "K1ABC", want: k1abc, wantCSV: k1abc},
+		{call: "K1ABC/P", want: k1abc, wantCSV: k1abc},
+		{call: "K1ABCD", want: us, wantCSV: us},
+		{call: "KH6ABC", want: hawaii, wantCSV: hawaii},
+		{call: "KH6/W1AW", want: hawaii, wantCSV: hawaii},
+		{call: "W1AW/KH6", want: hawaii, wantCSV: hawaii},
+		{call: "KH6/W1AW/P", want: hawaii, wantCSV: hawaii},
+		{call: "W1AW/QRP", want: us, wantCSV: us},
+		{call: "W1AW/MM", want: none, wantCSV: none},
+		{call: "W1AW/AM", want: none, wantCSV: none},
+		{call: "IT9XYZ", want: sicily, wantCSV: sicily},
+		{call: "IT9ABC", want: italy, wantCSV: italy},
+		{call: "I1ABC/IT9", want: sicily, wantCSV: sicily},
+		{call: "DL1ABC/P", want: germany, wantCSV: none},
+		{call: "JA1ABC", want: japan, wantCSV: none},
+		{call: "JD1ABC", want: ogasawara, wantCSV: none},
+		{call: "V47XYZ", want: kitts, wantCSV: none},
+		{call: "ZZ1Z", want: atlantis, wantCSV: none},
+		{call: "QQ1Q", want: none, wantCSV: none},
+		{call: "", want: none, wantCSV: none},
+	}
+	dat, err := parseCtyDat(strings.NewReader(testCtyDat))
+	if err != nil {
+		t.Fatalf("parseCtyDat got error %v", err)
+	}
+	csv, err := parseCtyCSV(strings.NewReader(testCtyCSV))
+	if err != nil {
+		t.Fatalf("parseCtyCSV got error %v", err)
+	}
+	for _, tc := range tests {
+		got, ok := dat.lookup(tc.call)
+		if ok != (tc.want != none) {
+			t.Errorf("cty.dat lookup(%q) got ok %v", tc.call, ok)
+		}
+		if diff := cmp.Diff(tc.want, got); diff != "" {
+			t.Errorf("cty.dat lookup(%q) diff (-want +got):\n%s", tc.call, diff)
+		}
+		got, ok = csv.lookup(tc.call)
+		if ok != (tc.wantCSV != none) {
+			t.Errorf("cty.csv lookup(%q) got ok %v", tc.call, ok)
+		}
+		if diff := cmp.Diff(tc.wantCSV, got); diff != "" {
+			t.Errorf("cty.csv lookup(%q) diff (-want +got):\n%s", tc.call, diff)
+		}
+	}
+}
+
+func TestCtyPortableDigit(t *testing.T) {
+	db, err := parseCtyDat(strings.NewReader(`United States: 05: 08: NA: 37.53: 91.67: 5.0: K:
+    K,W,=W6XYZ;
+California: 03: 06: NA: 36.00: 120.00: 8.0: W6:
+    W6;
+`))
+	if err != nil {
+		t.Fatalf("parseCtyDat got error %v", err)
+	}
+	if e, ok := db.lookup("W1AW/6"); !ok || e.Name != "California" {
+		t.Errorf("lookup(W1AW/6) got %v, %v, want California", e, ok)
+	}
+	if e, ok := db.lookup("W6XYZ/1"); !ok || e.Name != "United States" {
+		t.Errorf("lookup(W6XYZ/1) got %v, %v, want United States", e, ok)
+	}
+}
+
+func TestCtyParseErrors(t *testing.T) {
+	tests := []struct{ name, dat string }{
+		{name: "too few fields", dat: "Nowhere: 1: 2: NA: 0: 0: K;\n"},
+		{name: "bad zone", dat: "Nowhere: X: 2: NA: 0: 0: 0: K:\n K;\n"},
+		{name: "bad latitude", dat: "Nowhere: 1: 2: NA: north: 0: 0: K:\n K;\n"},
+		{name: "unterminated override", dat: "Nowhere: 1: 2: NA: 0: 0: 0: K:\n K(3;\n"},
+		{name: "bad override", dat: "Nowhere: 1: 2: NA: 0: 0: 0: K:\n K[x];\n"},
+	}
+	for _, tc := range tests {
+		if db, err := parseCtyDat(strings.NewReader(tc.dat)); err == nil {
+			t.Errorf("%s: parseCtyDat(%q) got %v, want error", tc.name, tc.dat, db)
+		}
+	}
+}
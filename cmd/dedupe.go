@@ -0,0 +1,196 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/flwyd/adif-multitool/adif"
+	"github.com/flwyd/adif-multitool/adif/spec"
+)
+
+var Dedupe = Command{Name: "dedupe", Run: runDedupe, Help: helpDedupe,
+	Description: "Remove, flag, or report duplicate records"}
+
+const (
+	DedupeDrop   = "drop"
+	DedupeFlag   = "flag"
+	DedupeReport = "report"
+)
+
+type DedupeContext struct {
+	Keys       FieldList
+	Window     time.Duration
+	SameUTCDay bool
+	Action     string
+	FlagField  string
+}
+
+func helpDedupe() string {
+	return `Records are duplicates if all --key fields match.  Field values are compared
+according to their type, so BAND 20M matches 20m and CALL k1mu matches K1MU.
+Empty or absent fields match each other.  The first record in a set of
+duplicates (or the earliest, if --window or --same-utc-day is set) is the
+original; later records are duplicates.
+
+Time rules, based on QSO_DATE and TIME_ON:
+  --window 24h : duplicate if within 24 hours of the original
+  --same-utc-day : duplicate if QSO_DATE is the same as the original
+
+Actions:
+  drop : output original records only
+  flag : output all records, with a comment on duplicates, or --flag-field set to Y
+  report : output only original records which have duplicates and the duplicates, flagged
+
+Example: adifmt dedupe --key call,band,mode,my_sig_info --same-utc-day
+`
+}
+
+type dedupeEntry struct {
+	record    *adif.Record
+	index     int // position in input, starting at 0
+	timestamp time.Time
+	original  int // index of original record if a duplicate, otherwise -1
+}
+
+func runDedupe(ctx *Context, args []string) error {
+	cctx := ctx.CommandCtx.(*DedupeContext)
+	if len(cctx.Keys) == 0 {
+		return fmt.Errorf("no key fields provided, try %s dedupe --key CALL,BAND,MODE", filepath.Base(os.Args[0]))
+	}
+	action := strings.ToLower(cctx.Action)
+	if action == "" {
+		action = DedupeDrop
+	}
+	if action != DedupeDrop && action != DedupeFlag && action != DedupeReport {
+		return fmt.Errorf("unknown dedupe action %q, want %s, %s, or %s", cctx.Action, DedupeDrop, DedupeFlag, DedupeReport)
+	}
+	if cctx.Window < 0 {
+		return fmt.Errorf("negative dedupe window %s", cctx.Window)
+	}
+	if cctx.FlagField != "" {
+		if err := ValidateAlphanumName(cctx.FlagField, ""); err != nil {
+			return err
+		}
+	}
+	timed := cctx.Window > 0 || cctx.SameUTCDay
+	out := adif.NewLogfile()
+	acc := accumulator{Out: out, Ctx: ctx}
+	var entries []*dedupeEntry
+	for _, f := range filesOrStdin(args) {
+		l, err := acc.read(f)
+		if err != nil {
+			return err
+		}
+		updateFieldOrder(out, l.FieldOrder)
+		for _, r := range l.Records {
+			e := &dedupeEntry{record: r, index: len(entries), original: -1}
+			if timed {
+				if e.timestamp, err = qsoTimestamp(r); err != nil {
+					return fmt.Errorf("record %d from %s: %w", len(entries)+1, f, err)
+				}
+			}
+			entries = append(entries, e)
+		}
+	}
+	compareKeys := recordComparator(cctx.Keys, out, ctx.Locale)
+	sorted := make([]*dedupeEntry, len(entries))
+	copy(sorted, entries)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if c := compareKeys(sorted[i].record, sorted[j].record); c != 0 {
+			return c < 0
+		}
+		return timed && sorted[i].timestamp.Before(sorted[j].timestamp)
+	})
+	hasDupes := make(map[int]bool)
+	var orig *dedupeEntry
+	for i, e := range sorted {
+		if i == 0 || compareKeys(orig.record, e.record) != 0 {
+			orig = e
+			continue
+		}
+		if cctx.Window > 0 && e.timestamp.Sub(orig.timestamp) > cctx.Window {
+			orig = e
+			continue
+		}
+		if cctx.SameUTCDay && e.timestamp.Format("20060102") != orig.timestamp.Format("20060102") {
+			orig = e
+			continue
+		}
+		e.original = orig.index
+		hasDupes[orig.index] = true
+	}
+	for _, e := range entries {
+		r := e.record
+		if e.original >= 0 && action != DedupeDrop {
+			if cctx.FlagField != "" {
+				r.Set(adif.Field{Name: cctx.FlagField, Value: "Y"})
+			} else {
+				c := fmt.Sprintf("duplicate of record %d", e.original+1)
+				if old := r.GetComment(); old != "" {
+					c = old + "\n" + c
+				}
+				r.SetComment(c)
+			}
+		}
+		switch action {
+		case DedupeDrop:
+			if e.original < 0 {
+				out.AddRecord(r)
+			}
+		case DedupeFlag:
+			out.AddRecord(r)
+		case DedupeReport:
+			if e.original >= 0 || hasDupes[e.index] {
+				out.AddRecord(r)
+			}
+		}
+	}
+	if cctx.FlagField != "" && action != DedupeDrop {
+		updateFieldOrder(out, []string{cctx.FlagField})
+	}
+	if err := acc.prepare(); err != nil {
+		return err
+	}
+	return write(ctx, out)
+}
+
+func qsoTimestamp(r *adif.Record) (time.Time, error) {
+	d, _ := r.Get(spec.QsoDateField.Name)
+	t, _ := r.Get(spec.TimeOnField.Name)
+	if d.Value == "" {
+		return time.Time{}, fmt.Errorf("missing %s", spec.QsoDateField.Name)
+	}
+	layout, val := "20060102", d.Value
+	switch len(t.Value) {
+	case 0: // midnight
+	case 4:
+		layout, val = layout+"1504", val+t.Value
+	case 6:
+		layout, val = layout+"150405", val+t.Value
+	default:
+		return time.Time{}, fmt.Errorf("invalid %s %q", spec.TimeOnField.Name, t.Value)
+	}
+	ts, err := time.ParseInLocation(layout, val, time.UTC)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid %s %s %s: %w", spec.QsoDateField.Name, spec.TimeOnField.Name, val, err)
+	}
+	return ts, nil
+}
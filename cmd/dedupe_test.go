@@ -0,0 +1,187 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/flwyd/adif-multitool/adif"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDedupe(t *testing.T) {
+	adi := adif.NewADIIO()
+	csv := adif.NewCSVIO()
+	file1 := `QSO_DATE,TIME_ON,CALL,BAND,MODE
+20230101,0100,K1A,20m,CW
+20230101,0200,k1a,20M,CW
+20230101,0300,K1A,40m,CW
+20230101,2330,N2B,20m,SSB
+20230102,0030,N2B,20m,SSB
+20230102,0200,K1A,20m,cw
+20230103,0300,K1A,20m,CW
+`
+	tests := []struct {
+		name, want string
+		ctx        DedupeContext
+	}{
+		{
+			name: "no time rule",
+			ctx:  DedupeContext{Keys: FieldList{"CALL", "BAND", "MODE"}},
+			want: `QSO_DATE,TIME_ON,CALL,BAND,MODE
+20230101,0100,K1A,20m,CW
+20230101,0300,K1A,40m,CW
+20230101,2330,N2B,20m,SSB
+`,
+		},
+		{
+			name: "same UTC day",
+			ctx:  DedupeContext{Keys: FieldList{"CALL", "BAND", "MODE"}, SameUTCDay: true},
+			want: `QSO_DATE,TIME_ON,CALL,BAND,MODE
+20230101,0100,K1A,20m,CW
+20230101,0300,K1A,40m,CW
+20230101,2330,N2B,20m,SSB
+20230102,0030,N2B,20m,SSB
+20230102,0200,K1A,20m,cw
+20230103,0300,K1A,20m,CW
+`,
+		},
+		{
+			name: "24 hour window",
+			ctx:  DedupeContext{Keys: FieldList{"CALL", "BAND", "MODE"}, Window: 24 * time.Hour},
+			want: `QSO_DATE,TIME_ON,CALL,BAND,MODE
+20230101,0100,K1A,20m,CW
+20230101,0300,K1A,40m,CW
+20230101,2330,N2B,20m,SSB
+20230102,0200,K1A,20m,cw
+20230103,0300,K1A,20m,CW
+`,
+		},
+		{
+			name: "window and same day",
+			ctx:  DedupeContext{Keys: FieldList{"call", "band"}, Window: 3 * time.Hour, SameUTCDay: true, Action: DedupeDrop},
+			want: `QSO_DATE,TIME_ON,CALL,BAND,MODE
+20230101,0100,K1A,20m,CW
+20230101,0300,K1A,40m,CW
+20230101,2330,N2B,20m,SSB
+20230102,0030,N2B,20m,SSB
+20230102,0200,K1A,20m,cw
+20230103,0300,K1A,20m,CW
+`,
+		},
+		{
+			name: "flag field",
+			ctx:  DedupeContext{Keys: FieldList{"CALL", "BAND", "MODE"}, SameUTCDay: true, Action: DedupeFlag, FlagField: "APP_TEST_DUPE"},
+			want: `QSO_DATE,TIME_ON,CALL,BAND,MODE,APP_TEST_DUPE
+20230101,0100,K1A,20m,CW,
+20230101,0200,k1a,20M,CW,Y
+20230101,0300,K1A,40m,CW,
+20230101,2330,N2B,20m,SSB,
+20230102,0030,N2B,20m,SSB,
+20230102,0200,K1A,20m,cw,
+20230103,0300,K1A,20m,CW,
+`,
+		},
+		{
+			name: "report",
+			ctx:  DedupeContext{Keys: FieldList{"CALL", "MODE"}, Action: DedupeReport, FlagField: "APP_TEST_DUPE"},
+			want: `QSO_DATE,TIME_ON,CALL,BAND,MODE,APP_TEST_DUPE
+20230101,0100,K1A,20m,CW,
+20230101,0200,k1a,20M,CW,Y
+20230101,0300,K1A,40m,CW,Y
+20230101,2330,N2B,20m,SSB,
+20230102,0030,N2B,20m,SSB,Y
+20230102,0200,K1A,20m,cw,Y
+20230103,0300,K1A,20m,CW,Y
+`,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			cctx := tc.ctx
+			ctx := &Context{
+				OutputFormat: adif.FormatCSV,
+				Readers:      readers(adi, csv),
+				Writers:      writers(adi, csv),
+				Out:          out,
+				Prepare:      testPrepare("My Comment", "3.1.4", "dedupe test", "1.2.3"),
+				fs:           fakeFilesystem{map[string]string{"foo.csv": file1}},
+				CommandCtx:   &cctx}
+			if err := Dedupe.Run(ctx, []string{"foo.csv"}); err != nil {
+				t.Fatalf("Dedupe.Run(%+v, foo.csv) got error %v", tc.ctx, err)
+			}
+			if diff := cmp.Diff(tc.want, out.String()); diff != "" {
+				t.Errorf("Dedupe.Run(%+v, foo.csv) unexpected output, diff:\n%s", tc.ctx, diff)
+			}
+		})
+	}
+}
+
+func TestDedupeComment(t *testing.T) {
+	adi := adif.NewADIIO()
+	out := &bytes.Buffer{}
+	file1 := `<EOH>
+<CALL:4>W1AW <BAND:3>40m <EOR>
+<CALL:4>K1MU <BAND:3>40m <EOR>
+<CALL:4>w1aw <BAND:3>40M <EOR>
+`
+	ctx := &Context{
+		OutputFormat: adif.FormatADI,
+		Readers:      readers(adi),
+		Writers:      writers(adi),
+		Out:          out,
+		Prepare:      testPrepare("My Comment", "3.1.4", "dedupe test", "1.2.3"),
+		fs:           fakeFilesystem{map[string]string{"foo.adi": file1}},
+		CommandCtx:   &DedupeContext{Keys: FieldList{"CALL", "BAND"}, Action: DedupeFlag}}
+	if err := Dedupe.Run(ctx, []string{"foo.adi"}); err != nil {
+		t.Fatalf("Dedupe.Run(ctx, foo.adi) got error %v", err)
+	}
+	want := `My Comment
+<ADIF_VER:5>3.1.4 <PROGRAMID:11>dedupe test <PROGRAMVERSION:5>1.2.3 <EOH>
+<CALL:4>W1AW <BAND:3>40m <EOR>
+<CALL:4>K1MU <BAND:3>40m <EOR>
+duplicate of record 1 <CALL:4>w1aw <BAND:3>40M <EOR>
+`
+	if diff := cmp.Diff(want, out.String()); diff != "" {
+		t.Errorf("Dedupe.Run(ctx, foo.adi) unexpected output, diff:\n%s", diff)
+	}
+}
+
+func TestDedupeErrors(t *testing.T) {
+	csv := adif.NewCSVIO()
+	file1 := "QSO_DATE,TIME_ON,CALL\n20230101,0100,K1A\n,,K1A\n"
+	for _, cctx := range []DedupeContext{
+		{},
+		{Keys: FieldList{"CALL"}, Action: "delete"},
+		{Keys: FieldList{"CALL"}, Window: -time.Hour},
+		{Keys: FieldList{"CALL"}, Action: DedupeFlag, FlagField: "APP-DUPE"},
+		{Keys: FieldList{"CALL"}, SameUTCDay: true},
+	} {
+		cctx := cctx
+		ctx := &Context{
+			OutputFormat: adif.FormatCSV,
+			Readers:      readers(csv),
+			Writers:      writers(csv),
+			Out:          &bytes.Buffer{},
+			fs:           fakeFilesystem{map[string]string{"foo.csv": file1}},
+			CommandCtx:   &cctx}
+		if err := Dedupe.Run(ctx, []string{"foo.csv"}); err == nil {
+			t.Errorf("Dedupe.Run(%+v, foo.csv) got no error", cctx)
+		}
+	}
+}
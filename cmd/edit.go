@@ -16,6 +16,7 @@ package cmd
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/flwyd/adif-multitool/adif"
@@ -30,6 +31,9 @@ type EditContext struct {
 	Set         FieldAssignments
 	Remove      FieldList
 	RemoveBlank bool
+	Rename      FieldPairs
+	Copy        FieldPairs
+	Overwrite   bool
 	Cond        ConditionValue
 	FromZone    TimeZone
 	ToZone      TimeZone
@@ -37,12 +41,17 @@ type EditContext struct {
 
 func helpEdit() string {
 	return fmt.Sprintf(
-		"Time zone adjustments affect %s, %s, %s, and %s.\n",
+		"Time zone adjustments affect %s, %s, %s, and %s.\n\n",
 		spec.TimeOnField.Name,
 		spec.TimeOffField.Name,
 		spec.QsoDateField.Name,
 		spec.QsoDateOffField.Name,
-	)
+	) + `--rename and --copy keep the position of the original field, and apply
+before --set, --add, and --remove.  A field with a value will not be replaced
+by a rename or copy unless --overwrite is set.
+
+` + helpValueExpressions() + "\nConditions use the same syntax as find, see help find.\n" + helpWhere() +
+		"\n" + helpStreamOutput("edit --set my_gridsquare=FN31pr")
 }
 
 func runEdit(ctx *Context, args []string) error {
@@ -51,83 +60,303 @@ func runEdit(ctx *Context, args []string) error {
 	for _, n := range cctx.Remove {
 		remove[n] = true
 	}
-	set := make(map[string]adif.Field)
+	set := make(map[string]bool)
 	for _, f := range cctx.Set.values {
 		if remove[f.Name] {
 			return fmt.Errorf("%q in both -set and -remove", f.Name)
 		}
-		set[f.Name] = f
+		set[f.Name] = true
 	}
 	for _, f := range cctx.Add.values {
 		if remove[f.Name] {
 			return fmt.Errorf("%q in both -add and -remove, use -set to change values", f.Name)
 		}
-		if _, ok := set[f.Name]; ok {
+		if set[f.Name] {
 			return fmt.Errorf("%q in both -set and -add", f.Name)
 		}
 	}
+	mover, err := newFieldMover(cctx.Rename, cctx.Copy, cctx.Overwrite)
+	if err != nil {
+		return err
+	}
+	for _, p := range cctx.Rename {
+		if remove[p.From] || remove[p.To] {
+			return fmt.Errorf("%s in both -rename and -remove", p)
+		}
+	}
+	for _, p := range cctx.Copy {
+		if remove[p.To] {
+			return fmt.Errorf("%s in both -copy and -remove", p)
+		}
+	}
+	sets, err := compileAssignments(cctx.Set)
+	if err != nil {
+		return fmt.Errorf("invalid -set value: %w", err)
+	}
+	adds, err := compileAssignments(cctx.Add)
+	if err != nil {
+		return fmt.Errorf("invalid -add value: %w", err)
+	}
 	fromTz := cctx.FromZone.Get()
 	toTz := cctx.ToZone.Get()
 	adjustTz := fromTz.String() != toTz.String()
 	cond := cctx.Cond.Get()
-	out := adif.NewLogfile()
-	acc := accumulator{Out: out, Ctx: ctx}
-	for _, f := range filesOrStdin(args) {
-		l, err := acc.read(f)
+	s := recordStream{Ctx: ctx, Out: adif.NewLogfile()}
+	s.Header = func(out *adif.Logfile) error {
+		// unedited records keep the original field if there's a condition
+		keep := len(cond.Terms) > 0
+		out.FieldOrder = mover.fieldOrder(out.FieldOrder, keep)
+		return mover.userdefs(out, keep)
+	}
+	return s.run(args, func(r *adif.Record, _ *adif.Logfile, n int) (*adif.Record, error) {
+		eval := recordEvalContext{record: r, lang: ctx.Locale}
+		if !cond.Evaluate(eval) {
+			return r, nil // edit condition doesn't match, pass through
+		}
+		if mover.active() {
+			var err error
+			if r, err = mover.apply(r); err != nil {
+				return nil, fmt.Errorf("record %d: %w", n+1, err)
+			}
+			eval.record = r
+		}
+		setVals, err := sets.eval(eval)
 		if err != nil {
-			return err
+			return nil, err
+		}
+		addVals, err := adds.eval(eval)
+		if err != nil {
+			return nil, err
+		}
+		setNames := make(map[string]adif.Field)
+		for _, f := range setVals {
+			setNames[f.Name] = f
 		}
-		updateFieldOrder(out, l.FieldOrder)
-		// TODO merge headers
-		for _, r := range l.Records {
-			eval := recordEvalContext{record: r, lang: ctx.Locale}
-			if !cond.Evaluate(eval) {
-				out.AddRecord(r) // edit condition doesn't match, pass through
+		seen := make(map[string]bool)
+		old := r.Fields()
+		fields := make([]adif.Field, 0, len(old))
+		for _, f := range old {
+			if remove[f.Name] {
 				continue
 			}
-			seen := make(map[string]bool)
-			old := r.Fields()
-			fields := make([]adif.Field, 0, len(old))
-			for _, f := range old {
-				if remove[f.Name] {
-					continue
-				}
-				if cctx.RemoveBlank && f.Value == "" {
-					continue
-				}
-				seen[f.Name] = true
-				if v, ok := set[f.Name]; ok {
-					f = v
-				}
+			if cctx.RemoveBlank && f.Value == "" {
+				continue
+			}
+			seen[f.Name] = true
+			if v, ok := setNames[f.Name]; ok {
+				f = v
+			}
+			fields = append(fields, f)
+		}
+		for _, f := range setVals {
+			if !seen[f.Name] {
 				fields = append(fields, f)
 			}
-			for _, f := range cctx.Set.values {
-				if !seen[f.Name] {
-					fields = append(fields, f)
-				}
-				seen[f.Name] = true
+			seen[f.Name] = true
+		}
+		for _, f := range addVals {
+			if !seen[f.Name] {
+				fields = append(fields, f)
 			}
-			for _, f := range cctx.Add.values {
-				if !seen[f.Name] {
-					fields = append(fields, f)
-				}
-				seen[f.Name] = true
+			seen[f.Name] = true
+		}
+		if len(fields) == 0 {
+			return nil, nil
+		}
+		rec := adif.NewRecord(fields...)
+		if adjustTz {
+			if err := adjustTimeZone(rec, fromTz, toTz); err != nil {
+				return nil, fmt.Errorf("could not adjust time zone: %w", err)
+			}
+		}
+		return rec, nil
+	})
+}
+
+// fieldMover renames and copies fields.  A renamed field stays in the same
+// position and a copy is placed just after the source field, unless the
+// destination field is already present.
+type fieldMover struct {
+	rename    map[string]string   // source to destination
+	copies    map[string][]string // source to destinations
+	targets   map[string]string   // destination to source
+	overwrite bool
+}
+
+func newFieldMover(renames, copies FieldPairs, overwrite bool) (*fieldMover, error) {
+	m := &fieldMover{rename: make(map[string]string), copies: make(map[string][]string), targets: make(map[string]string), overwrite: overwrite}
+	for _, p := range renames {
+		if _, ok := m.rename[p.From]; ok {
+			return nil, fmt.Errorf("-rename %s: %s renamed more than once", p, p.From)
+		}
+		m.rename[p.From] = p.To
+	}
+	for _, p := range copies {
+		m.copies[p.From] = append(m.copies[p.From], p.To)
+	}
+	for _, ps := range []FieldPairs{renames, copies} {
+		for _, p := range ps {
+			if src, ok := m.targets[p.To]; ok {
+				return nil, fmt.Errorf("%s is the destination of both %s and %s", p.To, src, p.From)
+			}
+			m.targets[p.To] = p.From
+		}
+	}
+	return m, nil
+}
+
+func (m *fieldMover) active() bool { return len(m.targets) > 0 }
+
+// apply returns a copy of r with fields renamed and copied, or an error if a
+// field with a different value would be replaced without m.overwrite.
+func (m *fieldMover) apply(r *adif.Record) (*adif.Record, error) {
+	// existing returns true if dst is in r and will not be renamed
+	existing := func(dst string) bool {
+		_, renamed := m.rename[dst]
+		_, ok := r.Get(dst)
+		return ok && !renamed
+	}
+	old := r.Fields()
+	fields := make([]adif.Field, 0, len(old)+len(m.copies))
+	for _, f := range old {
+		if dst, ok := m.rename[f.Name]; ok {
+			// an empty field doesn't replace an existing one, and goes away
+			if f.Value != "" || !existing(dst) {
+				fields = append(fields, adif.Field{Name: dst, Value: f.Value, Type: f.Type})
 			}
-			if len(fields) > 0 {
-				rec := adif.NewRecord(fields...)
-				if adjustTz {
-					if err := adjustTimeZone(rec, fromTz, toTz); err != nil {
-						return fmt.Errorf("could not adjust time zone: %w", err)
-					}
+		} else if src, ok := m.targets[f.Name]; ok {
+			from, _ := r.Get(src)
+			if from.Value == "" {
+				fields = append(fields, f)
+			} else {
+				if f.Value != "" && f.Value != from.Value && !m.overwrite {
+					return nil, fmt.Errorf("%s=%s would replace %s value %q with %q, use -overwrite to allow", src, f.Name, f.Name, f.Value, from.Value)
+				}
+				if _, renamed := m.rename[src]; !renamed {
+					fields = append(fields, adif.Field{Name: f.Name, Value: from.Value, Type: from.Type})
 				}
-				out.AddRecord(rec)
 			}
+		} else {
+			fields = append(fields, f)
+		}
+		for _, dst := range m.copies[f.Name] {
+			if !existing(dst) {
+				fields = append(fields, adif.Field{Name: dst, Value: f.Value, Type: f.Type})
+			}
+		}
+	}
+	return adif.NewRecord(fields...), nil
+}
+
+// fieldOrder returns order with renamed fields replaced and copied fields
+// following their source.  If keepSource is true, renamed fields are kept and
+// followed by their new name.
+func (m *fieldMover) fieldOrder(order []string, keepSource bool) []string {
+	if !m.active() || len(order) == 0 {
+		return order
+	}
+	res := make([]string, 0, len(order)+len(m.targets))
+	seen := make(map[string]bool)
+	add := func(n string) {
+		if u := strings.ToUpper(n); !seen[u] {
+			res = append(res, n)
+			seen[u] = true
+		}
+	}
+	for _, n := range order {
+		u := strings.ToUpper(n)
+		if dst, ok := m.rename[u]; ok {
+			if keepSource {
+				add(n)
+			}
+			add(dst)
+		} else {
+			add(n)
+		}
+		for _, dst := range m.copies[u] {
+			add(dst)
 		}
 	}
-	if err := acc.prepare(); err != nil {
+	return res
+}
+
+// userdefs moves or copies user-defined field metadata in l to the new field
+// names, unless the new name is a standard ADIF field or an application
+// field.  If keepSource is true, renamed userdef fields are kept.
+func (m *fieldMover) userdefs(l *adif.Logfile, keepSource bool) error {
+	if !m.active() || len(l.Userdef) == 0 {
+		return nil
+	}
+	keep := make([]adif.UserdefField, 0, len(l.Userdef))
+	var added []adif.UserdefField
+	for _, u := range l.Userdef {
+		name := strings.ToUpper(u.Name)
+		dsts := m.copies[name]
+		if dst, ok := m.rename[name]; ok {
+			dsts = append([]string{dst}, dsts...)
+			if keepSource {
+				keep = append(keep, u)
+			}
+		} else {
+			keep = append(keep, u)
+		}
+		for _, dst := range dsts {
+			if _, ok := spec.Fields[dst]; !ok && !strings.HasPrefix(dst, "APP_") {
+				c := u
+				c.Name = dst
+				added = append(added, c)
+			}
+		}
+	}
+	l.Userdef = keep
+	for _, u := range added {
+		if err := l.AddUserdef(u); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ValidateAssignment checks that name is a field name and value is a valid
+// --set or --add value, so expression errors are reported when flags are
+// parsed.
+func ValidateAssignment(name, value string) error {
+	if err := ValidateAlphanumName(name, value); err != nil {
 		return err
 	}
-	return write(ctx, out)
+	_, err := compileValue(value)
+	return err
+}
+
+// compiledAssignments are --set or --add values which may refer to fields.
+type compiledAssignments []struct {
+	name string
+	expr valueExpr
+}
+
+func compileAssignments(a FieldAssignments) (compiledAssignments, error) {
+	res := make(compiledAssignments, len(a.values))
+	for i, f := range a.values {
+		x, err := compileValue(f.Value)
+		if err != nil {
+			return nil, fmt.Errorf("%s=%s: %w", f.Name, f.Value, err)
+		}
+		res[i].name, res[i].expr = f.Name, x
+	}
+	return res, nil
+}
+
+// eval returns the field for each assignment, computed from the record in e.
+func (c compiledAssignments) eval(e EvaluationContext) ([]adif.Field, error) {
+	res := make([]adif.Field, len(c))
+	for i, a := range c {
+		v, err := a.expr.eval(e)
+		if err != nil {
+			return nil, fmt.Errorf("could not compute %s: %w", a.name, err)
+		}
+		res[i] = adif.Field{Name: a.name, Value: v.Value}
+	}
+	return res, nil
 }
 
 func adjustTimeZone(r *adif.Record, from, to *time.Location) error {
@@ -156,6 +385,8 @@ func adjustTimeZone(r *adif.Record, from, to *time.Location) error {
 	}
 	ton, tonok := r.Get(spec.TimeOnField.Name)
 	toff, toffok := r.Get(spec.TimeOffField.Name)
+	// empty times, e.g. from a blank spreadsheet cell, are left alone
+	tonok, toffok = tonok && ton.Value != "", toffok && toff.Value != ""
 	don, donok := r.Get(spec.QsoDateField.Name)
 	doff, doffok := r.Get(spec.QsoDateOffField.Name)
 	if !donok && !doffok {
@@ -132,6 +132,259 @@ func TestEditIf(t *testing.T) {
 	}
 }
 
+func TestEditExpressions(t *testing.T) {
+	csv := adif.NewCSVIO()
+	out := &bytes.Buffer{}
+	file1 := `CALL,NAME,QTH,FREQ_KHZ,DATE,TX_PWR,MY_POTA_REF,MY_SIG_INFO
+w1aw,Hiram,Newington,14074,17/10/2023,5,K-0001,
+k2b,Bob,,7100.5,01/02/2023,100,,K-9999
+`
+	set, add := NewFieldAssignments(ValidateAlphanumName), NewFieldAssignments(ValidateAlphanumName)
+	for _, s := range []string{"call=upper({call})", "comment={name} in {qth}", "name=Anon (not {call})"} {
+		if err := set.Set(s); err != nil {
+			t.Fatalf("--set %q got error %v", s, err)
+		}
+	}
+	for _, s := range []string{"freq={freq_khz}/1000", `qso_date=parsedate({date}, "02/01/2006")`,
+		"my_sig_info={my_pota_ref}", "app_test_class=if({tx_pwr} <= 5, 'QRP', 'QRO')"} {
+		if err := add.Set(s); err != nil {
+			t.Fatalf("--add %q got error %v", s, err)
+		}
+	}
+	ctx := &Context{
+		OutputFormat: adif.FormatCSV,
+		Readers:      readers(csv),
+		Writers:      writers(csv),
+		Out:          out,
+		fs:           fakeFilesystem{map[string]string{"foo.csv": file1}},
+		CommandCtx:   &EditContext{Set: set, Add: add}}
+	if err := Edit.Run(ctx, []string{"foo.csv"}); err != nil {
+		t.Fatalf("Edit.Run(ctx, foo.csv) got error %v", err)
+	}
+	want := `CALL,NAME,QTH,FREQ_KHZ,DATE,TX_PWR,MY_POTA_REF,MY_SIG_INFO,COMMENT,FREQ,QSO_DATE,APP_TEST_CLASS
+W1AW,Anon (not w1aw),Newington,14074,17/10/2023,5,K-0001,,Hiram in Newington,14.074,20231017,QRP
+K2B,Anon (not k2b),,7100.5,01/02/2023,100,,K-9999,Bob in ,7.1005,20230201,QRO
+`
+	if diff := cmp.Diff(want, out.String()); diff != "" {
+		t.Errorf("Edit.Run(ctx, foo.csv) unexpected output, diff:\n%s", diff)
+	}
+
+	for _, tc := range []struct{ set, add string }{
+		{set: "call==nosuchfunc({call})"},
+		{add: "app_test_sum={call}+1"},
+		{add: "qso_date==parsedate({date}, '2006-01-02')"},
+	} {
+		set, add := NewFieldAssignments(ValidateAlphanumName), NewFieldAssignments(ValidateAlphanumName)
+		if tc.set != "" {
+			set.Set(tc.set)
+		}
+		if tc.add != "" {
+			add.Set(tc.add)
+		}
+		ctx.Out = &bytes.Buffer{}
+		ctx.CommandCtx = &EditContext{Set: set, Add: add}
+		if err := Edit.Run(ctx, []string{"foo.csv"}); err == nil {
+			t.Errorf("Edit.Run with --set %q --add %q want error, got\n%s", tc.set, tc.add, ctx.Out)
+		}
+	}
+}
+
+func TestValidateAssignment(t *testing.T) {
+	for _, s := range []string{"call=upper({call})", "call==upper({call})", "my_sig_info=POTA-{my_pota_ref}",
+		"comment={{hello}} world", "comment=upper(case)", `comment=="upper({call}"`} {
+		a := NewFieldAssignments(ValidateAssignment)
+		if err := a.Set(s); err != nil {
+			t.Errorf("Set(%q) got error %v", s, err)
+		}
+	}
+	for _, s := range []string{"call==nosuchfunc({call})", "call==upper({call}", "call=upper({call}",
+		"comment=concat({call}, {name}", "comment=={call} {name}", "my-call=W1AW"} {
+		a := NewFieldAssignments(ValidateAssignment)
+		if err := a.Set(s); err == nil {
+			t.Errorf("Set(%q) got no error, want error", s)
+		}
+	}
+}
+
+func TestEditRenameCopy(t *testing.T) {
+	file1 := `DATE,UTC,CALL,FREQUENCY_KHZ,FREQ
+17/10/2023,1405,W1AW,14074,
+18/10/2023,1506,K2B,7100,7.1
+19/10/2023,1607,N3C,,3.5
+`
+	pairs := func(s string) FieldPairs {
+		var p FieldPairs
+		if s != "" {
+			if err := p.Set(s); err != nil {
+				t.Fatalf("FieldPairs.Set(%q) got error %v", s, err)
+			}
+		}
+		return p
+	}
+	tests := []struct {
+		name         string
+		rename, copy string
+		overwrite    bool
+		cond         string
+		want         string
+		wantErr      bool
+	}{
+		{
+			name:   "rename and copy",
+			rename: "date=qso_date,utc=time_on", copy: "call=station_callsign",
+			want: `QSO_DATE,TIME_ON,CALL,STATION_CALLSIGN,FREQUENCY_KHZ,FREQ
+17/10/2023,1405,W1AW,W1AW,14074,
+18/10/2023,1506,K2B,K2B,7100,7.1
+19/10/2023,1607,N3C,N3C,,3.5
+`,
+		},
+		{
+			name:   "rename clobbers",
+			rename: "frequency_khz=freq", wantErr: true,
+		},
+		{
+			name: "copy clobbers",
+			copy: "frequency_khz=freq", wantErr: true,
+		},
+		{
+			name:   "rename overwrite",
+			rename: "frequency_khz=freq", overwrite: true,
+			want: `DATE,UTC,CALL,FREQ
+17/10/2023,1405,W1AW,14074
+18/10/2023,1506,K2B,7100
+19/10/2023,1607,N3C,3.5
+`,
+		},
+		{
+			name: "copy overwrite",
+			copy: "frequency_khz=freq", overwrite: true,
+			want: `DATE,UTC,CALL,FREQUENCY_KHZ,FREQ
+17/10/2023,1405,W1AW,14074,14074
+18/10/2023,1506,K2B,7100,7100
+19/10/2023,1607,N3C,,3.5
+`,
+		},
+		{
+			name:   "swap",
+			rename: "date=utc,utc=date",
+			want: `UTC,DATE,CALL,FREQUENCY_KHZ,FREQ
+17/10/2023,1405,W1AW,14074,
+18/10/2023,1506,K2B,7100,7.1
+19/10/2023,1607,N3C,,3.5
+`,
+		},
+		{
+			name:   "conditional rename keeps source column",
+			rename: "frequency_khz=freq", cond: "freq=",
+			want: `DATE,UTC,CALL,FREQUENCY_KHZ,FREQ
+17/10/2023,1405,W1AW,,14074
+18/10/2023,1506,K2B,7100,7.1
+19/10/2023,1607,N3C,,3.5
+`,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			csv := adif.NewCSVIO()
+			out := &bytes.Buffer{}
+			cond := ConditionValue{}
+			if tc.cond != "" {
+				cond.IfFlag().Set(tc.cond)
+			}
+			ctx := &Context{
+				OutputFormat: adif.FormatCSV,
+				Readers:      readers(csv),
+				Writers:      writers(csv),
+				Out:          out,
+				fs:           fakeFilesystem{map[string]string{"foo.csv": file1}},
+				CommandCtx:   &EditContext{Rename: pairs(tc.rename), Copy: pairs(tc.copy), Overwrite: tc.overwrite, Cond: cond}}
+			err := Edit.Run(ctx, []string{"foo.csv"})
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("Edit.Run(ctx, foo.csv) want error, got\n%s", out)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Edit.Run(ctx, foo.csv) got error %v", err)
+			}
+			if diff := cmp.Diff(tc.want, out.String()); diff != "" {
+				t.Errorf("Edit.Run(ctx, foo.csv) unexpected output, diff:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestEditRenameUserdef(t *testing.T) {
+	adi := adif.NewADIIO()
+	out := &bytes.Buffer{}
+	file1 := `<USERDEF1:9:N>EPC_SCORE<USERDEF2:5:S>MYTAG<USERDEF3:4:S>MISC<EOH>
+<CALL:4>W1AW <EPC_SCORE:2>12 <MYTAG:1>x <MISC:1>y <EOR>
+`
+	var rename, copy FieldPairs
+	rename.Set("epc_score=score,mytag=comment")
+	copy.Set("misc=misc2")
+	ctx := &Context{
+		OutputFormat: adif.FormatADI,
+		Readers:      readers(adi),
+		Writers:      writers(adi),
+		Out:          out,
+		Prepare:      testPrepare("My Comment", "3.1.4", "edit test", "1.2.3"),
+		fs:           fakeFilesystem{map[string]string{"foo.adi": file1}},
+		CommandCtx:   &EditContext{Rename: rename, Copy: copy}}
+	if err := Edit.Run(ctx, []string{"foo.adi"}); err != nil {
+		t.Fatalf("Edit.Run(ctx, foo.adi) got error %v", err)
+	}
+	want := `My Comment
+<ADIF_VER:5>3.1.4 <PROGRAMID:9>edit test <PROGRAMVERSION:5>1.2.3 <USERDEF1:4:S>MISC <USERDEF2:5:N>SCORE <USERDEF3:5:S>MISC2 <EOH>
+<CALL:4>W1AW <SCORE:2>12 <COMMENT:1>x <MISC:1>y <MISC2:1>y <EOR>
+`
+	if diff := cmp.Diff(want, out.String()); diff != "" {
+		t.Errorf("Edit.Run(ctx, foo.adi) unexpected output, diff:\n%s", diff)
+	}
+}
+
+func TestEditRenameErrors(t *testing.T) {
+	for _, s := range []string{"call", "call=", "=call", "call=call", "call=my call"} {
+		var p FieldPairs
+		if err := p.Set(s); err == nil {
+			t.Errorf("FieldPairs.Set(%q) want error, got %v", s, p)
+		}
+	}
+	for _, tc := range []struct {
+		rename, copy, remove string
+	}{
+		{rename: "a=b,a=c"},
+		{rename: "a=c,b=c"},
+		{rename: "a=c", copy: "b=c"},
+		{rename: "a=b", remove: "a"},
+		{rename: "a=b", remove: "b"},
+		{copy: "a=b", remove: "b"},
+	} {
+		var rename, copy FieldPairs
+		var remove FieldList
+		if tc.rename != "" {
+			rename.Set(tc.rename)
+		}
+		if tc.copy != "" {
+			copy.Set(tc.copy)
+		}
+		if tc.remove != "" {
+			remove.Set(tc.remove)
+		}
+		ctx := &Context{
+			OutputFormat: adif.FormatCSV,
+			Readers:      readers(adif.NewCSVIO()),
+			Writers:      writers(adif.NewCSVIO()),
+			Out:          &bytes.Buffer{},
+			fs:           fakeFilesystem{map[string]string{"foo.csv": "A,B,C\n1,2,3\n"}},
+			CommandCtx:   &EditContext{Rename: rename, Copy: copy, Remove: remove}}
+		if err := Edit.Run(ctx, []string{"foo.csv"}); err == nil {
+			t.Errorf("edit --rename %q --copy %q --remove %q want error", tc.rename, tc.copy, tc.remove)
+		}
+	}
+}
+
 func TestEditRemoveEmpty(t *testing.T) {
 	adi := adif.NewADIIO()
 	csv := adif.NewCSVIO()
@@ -215,6 +468,16 @@ func TestAdjustTimeZone(t *testing.T) {
 			want:  state{dateOn: "20210519", dateOff: "20210519", timeOn: "110456", timeOff: "1142"},
 			from:  zones["Asia/Bangkok"], to: zones["Asia/Kolkata"],
 		},
+		{
+			start: state{dateOn: "20201231", dateOff: "", timeOn: "231545", timeOff: ""},
+			want:  state{dateOn: "20210101", dateOff: "", timeOn: "041545", timeOff: ""},
+			from:  zones["America/New_York"], to: zones["UTC"],
+		},
+		{
+			start: state{dateOn: "20201231", dateOff: "20201231", timeOn: "", timeOff: "2320"},
+			want:  state{dateOn: "20201231", dateOff: "20210101", timeOn: "", timeOff: "0420"},
+			from:  zones["America/New_York"], to: zones["UTC"],
+		},
 	}
 	header := "QSO_DATE,TIME_ON,QSO_DATE_OFF,TIME_OFF"
 	for _, tc := range tests {
@@ -0,0 +1,971 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/flwyd/adif-multitool/adif"
+)
+
+// valueExpr computes a field value from a record, e.g. for edit --set.
+type valueExpr interface {
+	eval(e EvaluationContext) (adif.Field, error)
+	String() string
+}
+
+// compileValue parses an edit value.  A value starting with "=" is an
+// expression like "=upper({call})" or "={freq_khz}/1000" which is evaluated
+// for each record.  Without "=", a value which is a function call or
+// arithmetic using a {FIELD} reference, like "upper({call})" or
+// "{freq_khz}/1000", is also evaluated.  Otherwise {FIELD} references in s are
+// replaced with the field's value, like "{name} in {qth}" or
+// "POTA-{my_pota_ref}", and any other text is literal.
+func compileValue(s string) (valueExpr, error) {
+	if strings.HasPrefix(s, "=") {
+		p, err := newExprParser(s[1:], false)
+		if err != nil {
+			return nil, err
+		}
+		return p.parse()
+	}
+	p, err := newExprParser(s, false)
+	if err == nil {
+		var x valueExpr
+		if x, err = p.parse(); err == nil && isComputed(x) {
+			return x, nil
+		}
+	}
+	if m := exprCallPat.FindStringSubmatch(s); m != nil && err != nil {
+		if _, ok := exprFuncs[strings.ToLower(m[1])]; ok || strings.EqualFold(m[1], "if") {
+			return nil, fmt.Errorf("%w; start the value with = for an expression or use =%q for text", err, s)
+		}
+	}
+	return compileTemplate(s), nil
+}
+
+// exprCallPat matches a value which starts like a function call.
+var exprCallPat = regexp.MustCompile(`^\s*(\w+)\s*\(`)
+
+// isComputed returns true if x is a function call or arithmetic on numbers
+// which refers to at least one field, so a value without a leading "=" like
+// "upper({call})" is evaluated but "Smith-Jones" and "{call}/P" are text.
+func isComputed(x valueExpr) bool {
+	switch x.(type) {
+	case exprCall, exprIf:
+		return exprHasField(x)
+	case exprArithmetic:
+		return numericOperands(x) && exprHasField(x)
+	}
+	return false
+}
+
+// numericOperands returns false if arithmetic x has a literal operand which is
+// not a number.
+func numericOperands(x valueExpr) bool {
+	switch x := x.(type) {
+	case exprLiteral:
+		_, err := strconv.ParseFloat(string(x), 64)
+		return err == nil
+	case exprArithmetic:
+		return numericOperands(x.left) && numericOperands(x.right)
+	}
+	return true
+}
+
+// exprHasField returns true if x refers to a field in the record.
+func exprHasField(x valueExpr) bool {
+	switch x := x.(type) {
+	case exprField:
+		return true
+	case exprTemplate:
+		return len(x) > 1 || (len(x) == 1 && exprHasField(x[0]))
+	case exprArithmetic:
+		return exprHasField(x.left) || exprHasField(x.right)
+	case exprIf:
+		return exprHasField(x.cond) || exprHasField(x.then) || (x.els != nil && exprHasField(x.els))
+	case exprCall:
+		for _, a := range x.args {
+			if exprHasField(a) {
+				return true
+			}
+		}
+	case exprCondition:
+		return conditionHasField(x.Condition)
+	}
+	return false
+}
+
+func conditionHasField(c Condition) bool {
+	switch c := c.(type) {
+	case whereComparison:
+		if exprHasField(c.Left) {
+			return true
+		}
+		for _, r := range c.Right {
+			if exprHasField(r) {
+				return true
+			}
+		}
+	case negation:
+		return conditionHasField(c.Term)
+	case junction:
+		for _, t := range c.Terms {
+			if conditionHasField(t) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// compileTemplate replaces {FIELD} references in s with field values.  {{ and
+// }} are literal braces; other text is used as-is.
+func compileTemplate(s string) valueExpr {
+	var t exprTemplate
+	var lit strings.Builder
+	for i := 0; i < len(s); {
+		if strings.HasPrefix(s[i:], "{{") || strings.HasPrefix(s[i:], "}}") {
+			lit.WriteByte(s[i])
+			i += 2
+			continue
+		}
+		if s[i] == '{' {
+			if m := templateFieldPat.FindStringIndex(s[i:]); m != nil && m[0] == 0 {
+				if lit.Len() > 0 {
+					t = append(t, exprLiteral(lit.String()))
+					lit.Reset()
+				}
+				t = append(t, exprField(strings.ToUpper(s[i+1:i+m[1]-1])))
+				i += m[1]
+				continue
+			}
+		}
+		lit.WriteByte(s[i])
+		i++
+	}
+	if len(t) == 0 {
+		return exprLiteral(lit.String())
+	}
+	if lit.Len() > 0 {
+		t = append(t, exprLiteral(lit.String()))
+	}
+	return t
+}
+
+func helpValueExpressions() string {
+	names := make([]string, 0, len(exprFuncs))
+	for n := range exprFuncs {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	var funcs strings.Builder
+	for _, n := range names {
+		fmt.Fprintf(&funcs, "  %s : %s\n", exprFuncs[n].usage, exprFuncs[n].help)
+	}
+	return `--set and --add values can refer to fields in the input record by enclosing
+the field name in '{' and '}', e.g. 'comment={name} in {qth}'; other text is
+used as-is, and {{ and }} are literal braces.  A function call or arithmetic on
+fields, like 'call=upper({call})', is an expression which is computed, with
++ - * / arithmetic on numbers, comparisons, quoted 'strings', and functions.
+A value starting with = is always an expression.  Use a quoted string for
+text starting with = or which looks like an expression, e.g. 'comment=="=)"'.
+Functions:
+` + funcs.String() + `  if(condition, then, else) : then if condition is true (not empty or N), otherwise else
+Date and time layouts can use letters like "DD/MM/YYYY" or "hh:MM PM",
+strftime like "%d/%m/%Y" or "%H:%M", or Go layouts like "02/01/2006".
+Conditions can combine comparisons with AND, OR, and NOT, like --where, but
+quote text with spaces or + - * / characters, e.g. {call} = 'K1A/P'.
+Examples:
+  --set 'call=upper({call})'
+  --set 'freq={freq_khz}/1000'
+  --add 'my_sig_info=POTA-{my_pota_ref}'
+  --set 'qso_date=parsedate({date}, "DD/MM/YYYY")'
+  --set 'comment=if({tx_pwr} <= 5, "QRP", {comment})'
+  --set 'comment=={tx_pwr} <= 5'
+`
+}
+
+// exprLiteral is a constant string or number.
+type exprLiteral string
+
+func (l exprLiteral) eval(EvaluationContext) (adif.Field, error) {
+	return adif.Field{Value: string(l)}, nil
+}
+
+func (l exprLiteral) String() string { return strconv.Quote(string(l)) }
+
+// exprField is the value of a field in the record.
+type exprField string
+
+func (f exprField) eval(e EvaluationContext) (adif.Field, error) {
+	return e.Get(string(f)), nil
+}
+
+func (f exprField) String() string { return "{" + string(f) + "}" }
+
+// exprTemplate concatenates text and field values.
+type exprTemplate []valueExpr
+
+func (t exprTemplate) eval(e EvaluationContext) (adif.Field, error) {
+	var b strings.Builder
+	for _, x := range t {
+		v, err := x.eval(e)
+		if err != nil {
+			return adif.Field{}, err
+		}
+		b.WriteString(v.Value)
+	}
+	return adif.Field{Value: b.String()}, nil
+}
+
+func (t exprTemplate) String() string {
+	var b strings.Builder
+	for _, x := range t {
+		if l, ok := x.(exprLiteral); ok {
+			b.WriteString(templateBraces.Replace(string(l)))
+		} else {
+			b.WriteString(x.String())
+		}
+	}
+	return b.String()
+}
+
+var templateBraces = strings.NewReplacer("{", "{{", "}", "}}")
+
+// exprArithmetic applies +, -, *, or / to two numbers.  The result is empty
+// if either operand is empty.
+type exprArithmetic struct {
+	op          byte
+	left, right valueExpr
+}
+
+func (a exprArithmetic) eval(e EvaluationContext) (adif.Field, error) {
+	res := adif.Field{Type: adif.TypeNumber}
+	var nums [2]float64
+	for i, x := range []valueExpr{a.left, a.right} {
+		v, err := x.eval(e)
+		if err != nil {
+			return res, err
+		}
+		s := strings.TrimSpace(v.Value)
+		if s == "" {
+			return res, nil
+		}
+		if nums[i], err = strconv.ParseFloat(s, 64); err != nil {
+			return res, fmt.Errorf("%s is not a number: %q", x, v.Value)
+		}
+	}
+	var n float64
+	switch a.op {
+	case '+':
+		n = nums[0] + nums[1]
+	case '-':
+		n = nums[0] - nums[1]
+	case '*':
+		n = nums[0] * nums[1]
+	case '/':
+		if nums[1] == 0 {
+			return res, fmt.Errorf("division by zero in %s", a)
+		}
+		n = nums[0] / nums[1]
+	}
+	res.Value = formatNumber(n, exprDecimals)
+	return res, nil
+}
+
+func (a exprArithmetic) String() string {
+	return fmt.Sprintf("(%s %c %s)", a.left, a.op, a.right)
+}
+
+// exprDecimals limits arithmetic results so that 7.1*3 is 21.3, not
+// 21.299999999999997.
+const exprDecimals = 9
+
+// exprCondition is "Y" if a comparison, or conditions combined with AND, OR,
+// and NOT, is true, otherwise "N".
+type exprCondition struct{ Condition }
+
+func (c exprCondition) eval(e EvaluationContext) (adif.Field, error) {
+	res := adif.Field{Value: "N", Type: adif.TypeBoolean}
+	match, err := checkCondition(c.Condition, e)
+	if match {
+		res.Value = "Y"
+	}
+	return res, err
+}
+
+// checkCondition evaluates c like c.Evaluate, but returns an error if a value
+// in a comparison can't be computed, e.g. arithmetic on text.
+func checkCondition(c Condition, e EvaluationContext) (bool, error) {
+	switch c := c.(type) {
+	case whereComparison:
+		return c.check(e)
+	case negation:
+		match, err := checkCondition(c.Term, e)
+		return !match, err
+	case junction:
+		for _, t := range c.Terms {
+			match, err := checkCondition(t, e)
+			if err != nil || match == c.Any {
+				return match, err
+			}
+		}
+		return !c.Any || len(c.Terms) == 0, nil
+	}
+	return c.Evaluate(e), nil
+}
+
+// exprIf evaluates then if cond is true, otherwise els.
+type exprIf struct {
+	cond, then, els valueExpr
+}
+
+func (x exprIf) eval(e EvaluationContext) (adif.Field, error) {
+	c, err := x.cond.eval(e)
+	if err != nil {
+		return c, err
+	}
+	if v := strings.TrimSpace(c.Value); v != "" && !strings.EqualFold(v, "N") {
+		return x.then.eval(e)
+	}
+	if x.els == nil {
+		return adif.Field{}, nil
+	}
+	return x.els.eval(e)
+}
+
+func (x exprIf) String() string {
+	if x.els == nil {
+		return fmt.Sprintf("if(%s, %s)", x.cond, x.then)
+	}
+	return fmt.Sprintf("if(%s, %s, %s)", x.cond, x.then, x.els)
+}
+
+// exprCall applies a function to its evaluated arguments.
+type exprCall struct {
+	name string
+	fn   exprFunc
+	args []valueExpr
+}
+
+func (c exprCall) eval(e EvaluationContext) (adif.Field, error) {
+	args := make([]adif.Field, len(c.args))
+	for i, a := range c.args {
+		v, err := a.eval(e)
+		if err != nil {
+			return v, err
+		}
+		args[i] = v
+	}
+	res, err := c.fn.apply(args)
+	if err != nil {
+		return res, fmt.Errorf("%s: %w", c, err)
+	}
+	return res, nil
+}
+
+func (c exprCall) String() string {
+	args := make([]string, len(c.args))
+	for i, a := range c.args {
+		args[i] = a.String()
+	}
+	return fmt.Sprintf("%s(%s)", c.name, strings.Join(args, ", "))
+}
+
+type exprFunc struct {
+	usage   string
+	help    string
+	minArgs int
+	maxArgs int // -1 for any number
+	apply   func(args []adif.Field) (adif.Field, error)
+}
+
+// exprFuncs are functions for value expressions, including the single-argument
+// functions from whereFuncs.
+var exprFuncs = func() map[string]exprFunc {
+	res := map[string]exprFunc{
+		"trim": {usage: "trim(text)", help: "remove leading and trailing spaces", minArgs: 1, maxArgs: 1,
+			apply: func(args []adif.Field) (adif.Field, error) {
+				args[0].Value = strings.TrimSpace(args[0].Value)
+				return args[0], nil
+			}},
+		"substr": {usage: "substr(text, start, length)", help: "part of text, starting at character 1, length is optional", minArgs: 2, maxArgs: 3,
+			apply: exprSubstr},
+		"replace": {usage: "replace(text, old, new)", help: "replace all occurrences of old with new", minArgs: 3, maxArgs: 3,
+			apply: func(args []adif.Field) (adif.Field, error) {
+				return adif.Field{Value: strings.ReplaceAll(args[0].Value, args[1].Value, args[2].Value)}, nil
+			}},
+		"concat": {usage: "concat(a, b, ...)", help: "join values together", minArgs: 1, maxArgs: -1,
+			apply: func(args []adif.Field) (adif.Field, error) {
+				var b strings.Builder
+				for _, a := range args {
+					b.WriteString(a.Value)
+				}
+				return adif.Field{Value: b.String()}, nil
+			}},
+		"coalesce": {usage: "coalesce(a, b, ...)", help: "first value which is not empty", minArgs: 1, maxArgs: -1,
+			apply: func(args []adif.Field) (adif.Field, error) {
+				for _, a := range args {
+					if strings.TrimSpace(a.Value) != "" {
+						return a, nil
+					}
+				}
+				return adif.Field{}, nil
+			}},
+		"round": {usage: "round(number, digits)", help: "round to digits after the decimal point, default 0", minArgs: 1, maxArgs: 2,
+			apply: exprRound},
+		"parsedate": {usage: "parsedate(text, layout)", help: "convert a date in layout to YYYYMMDD", minArgs: 2, maxArgs: 2,
+			apply: exprTimeLayout(dateLayout, func(v, layout string) (string, error) { return convertTime(v, layout, "20060102") })},
+		"parsetime": {usage: "parsetime(text, layout)", help: "convert a time in layout to HHMMSS", minArgs: 2, maxArgs: 2,
+			apply: exprTimeLayout(timeLayout, func(v, layout string) (string, error) { return convertTime(v, layout, "150405") })},
+		"formatdate": {usage: "formatdate(date, layout)", help: "format a YYYYMMDD date with layout", minArgs: 2, maxArgs: 2,
+			apply: exprTimeLayout(dateFormatLayout, func(v, layout string) (string, error) { return convertTime(v, "20060102", layout) })},
+		"formattime": {usage: "formattime(time, layout)", help: "format an HHMM or HHMMSS time with layout", minArgs: 2, maxArgs: 2,
+			apply: exprTimeLayout(timeFormatLayout, func(v, layout string) (string, error) {
+				if len(v) == 4 {
+					return convertTime(v, "1504", layout)
+				}
+				return convertTime(v, "150405", layout)
+			})},
+	}
+	for n, f := range whereFuncs {
+		f := f
+		res[n] = exprFunc{usage: n + "(value)", help: f.help, minArgs: 1, maxArgs: 1,
+			apply: func(args []adif.Field) (adif.Field, error) { return f.apply(args[0]), nil }}
+	}
+	return res
+}()
+
+func exprSubstr(args []adif.Field) (adif.Field, error) {
+	runes := []rune(args[0].Value)
+	start, err := strconv.Atoi(strings.TrimSpace(args[1].Value))
+	if err != nil || start < 1 {
+		return adif.Field{}, fmt.Errorf("invalid start %q", args[1].Value)
+	}
+	end := len(runes)
+	if len(args) > 2 {
+		n, err := strconv.Atoi(strings.TrimSpace(args[2].Value))
+		if err != nil || n < 0 {
+			return adif.Field{}, fmt.Errorf("invalid length %q", args[2].Value)
+		}
+		if start-1+n < end {
+			end = start - 1 + n
+		}
+	}
+	if start > end {
+		return adif.Field{}, nil
+	}
+	return adif.Field{Value: string(runes[start-1 : end])}, nil
+}
+
+func exprRound(args []adif.Field) (adif.Field, error) {
+	res := adif.Field{Type: adif.TypeNumber}
+	s := strings.TrimSpace(args[0].Value)
+	if s == "" {
+		return res, nil
+	}
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return res, fmt.Errorf("not a number: %q", args[0].Value)
+	}
+	digits := 0
+	if len(args) > 1 {
+		if digits, err = strconv.Atoi(strings.TrimSpace(args[1].Value)); err != nil || digits < 0 {
+			return res, fmt.Errorf("invalid digits %q", args[1].Value)
+		}
+	}
+	res.Value = formatNumber(n, digits)
+	return res, nil
+}
+
+// exprTimeLayout returns a function which converts its first argument with the
+// format in its second argument, like DD/MM/YYYY, %d/%m/%Y, or the Go layout
+// 02/01/2006, see dateLayout and timeLayout.  Empty values are not converted.
+func exprTimeLayout(layout func(string) (string, error), conv func(v, layout string) (string, error)) func(args []adif.Field) (adif.Field, error) {
+	return func(args []adif.Field) (adif.Field, error) {
+		v := strings.TrimSpace(args[0].Value)
+		if v == "" {
+			return adif.Field{}, nil
+		}
+		l, err := layout(args[1].Value)
+		if err != nil {
+			return adif.Field{}, err
+		}
+		s, err := conv(v, l)
+		return adif.Field{Value: s}, err
+	}
+}
+
+func convertTime(v, from, to string) (string, error) {
+	t, err := time.Parse(from, v)
+	if err != nil {
+		return "", fmt.Errorf("%q does not match layout %q", v, from)
+	}
+	return t.Format(to), nil
+}
+
+type exprTokenKind int
+
+const (
+	exprEOF exprTokenKind = iota
+	exprWord
+	exprString
+	exprFieldRef
+	exprOp
+	exprOpen
+	exprClose
+	exprPipe
+	exprComma
+	exprArith // + - * / in value expressions
+)
+
+type exprToken struct {
+	kind exprTokenKind
+	text string
+	pos  int
+}
+
+func (t exprToken) String() string {
+	switch t.kind {
+	case exprEOF:
+		return "end of expression"
+	case exprFieldRef:
+		return strconv.Quote("{" + t.text + "}")
+	}
+	return strconv.Quote(t.text)
+}
+
+// isExprWordChar returns true for characters allowed in unquoted words like
+// field names, numbers, bands, and callsigns.  In --where expressions, words
+// can also have - + / and * for callsigns like K1A/P and globs; in value
+// expressions these are arithmetic.
+func isExprWordChar(r rune, arithmetic bool) bool {
+	if unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("_.:@#?", r) {
+		return true
+	}
+	return !arithmetic && strings.ContainsRune("-+/*", r)
+}
+
+// lexExpr splits a --where or value expression into tokens.  In quoted
+// strings, a backslash escapes the quote character or another backslash;
+// other backslashes are kept for regular expressions.
+func lexExpr(s string, arithmetic bool) ([]exprToken, error) {
+	var res []exprToken
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case unicode.IsSpace(r):
+			i += size
+		case r == '(':
+			res = append(res, exprToken{kind: exprOpen, text: "(", pos: i})
+			i++
+		case r == ')':
+			res = append(res, exprToken{kind: exprClose, text: ")", pos: i})
+			i++
+		case r == '|':
+			res = append(res, exprToken{kind: exprPipe, text: "|", pos: i})
+			i++
+		case r == ',':
+			res = append(res, exprToken{kind: exprComma, text: ",", pos: i})
+			i++
+		case r == '{':
+			end := strings.IndexRune(s[i:], '}')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated field reference at position %d in %q", i+1, s)
+			}
+			name := strings.TrimSpace(s[i+1 : i+end])
+			if !conditionFieldPat.MatchString(name) {
+				return nil, fmt.Errorf("invalid field reference %q at position %d in %q", s[i:i+end+1], i+1, s)
+			}
+			res = append(res, exprToken{kind: exprFieldRef, text: name, pos: i})
+			i += end + 1
+		case r == '\'' || r == '"':
+			var b strings.Builder
+			j := i + 1
+			for ; j < len(s) && rune(s[j]) != r; j++ {
+				if s[j] == '\\' && j+1 < len(s) && (rune(s[j+1]) == r || s[j+1] == '\\') {
+					j++
+				}
+				b.WriteByte(s[j])
+			}
+			if j >= len(s) {
+				return nil, fmt.Errorf("unterminated string at position %d in %q", i+1, s)
+			}
+			res = append(res, exprToken{kind: exprString, text: b.String(), pos: i})
+			i = j + 1
+		default:
+			var op operator
+			for _, o := range whereOps {
+				if strings.HasPrefix(s[i:], string(o)) {
+					op = o
+					break
+				}
+			}
+			if op != "" {
+				res = append(res, exprToken{kind: exprOp, text: string(op), pos: i})
+				i += len(op)
+				continue
+			}
+			if arithmetic && strings.ContainsRune("+-*/", r) {
+				res = append(res, exprToken{kind: exprArith, text: string(r), pos: i})
+				i++
+				continue
+			}
+			j := i
+			for j < len(s) {
+				r, size := utf8.DecodeRuneInString(s[j:])
+				// * in *= is an operator, otherwise a glob inside a word
+				if !isExprWordChar(r, arithmetic) || (r == '*' && strings.HasPrefix(s[j:], string(OpContains))) {
+					break
+				}
+				j += size
+			}
+			if j == i {
+				return nil, fmt.Errorf("unexpected %q at position %d in %q", r, i+1, s)
+			}
+			res = append(res, exprToken{kind: exprWord, text: s[i:j], pos: i})
+			i = j
+		}
+	}
+	return append(res, exprToken{kind: exprEOF, pos: len(s)}), nil
+}
+
+// exprParser is a recursive descent parser for --where conditions and edit
+// value expressions, with the grammar
+//
+//	condition  = and { "OR" and }
+//	and        = unary { "AND" unary }
+//	unary      = "NOT" unary | comparison
+//	comparison = sum [ op sum { "|" sum } ]
+//	sum        = product { ("+" | "-") product }
+//	product    = sign { ("*" | "/") sign }
+//	sign       = "-" sign | primary
+//	primary    = word | string | "{" field "}" | function "(" [ args ] ")" | "(" condition ")"
+//	args       = condition { "," condition }
+//
+// AND, OR, and NOT combine comparisons.  In --where expressions, arithmetic
+// characters are part of words, and a word is a field name unless it is on the
+// right side of a comparison, e.g. "upper(call) = W1AW".  In value expressions,
+// words are literal text, e.g. "{band} = 20m".
+type exprParser struct {
+	src    string
+	tokens []exprToken
+	pos    int
+	where  bool
+}
+
+func newExprParser(s string, where bool) (*exprParser, error) {
+	toks, err := lexExpr(s, !where)
+	if err != nil {
+		return nil, err
+	}
+	return &exprParser{src: s, tokens: toks, where: where}, nil
+}
+
+// parse parses the whole source as a condition.
+func (p *exprParser) parse() (valueExpr, error) {
+	x, err := p.condition()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != exprEOF {
+		return nil, p.errorf(t, "expected AND, OR, or end of expression")
+	}
+	return x, nil
+}
+
+func (p *exprParser) peek() exprToken { return p.tokens[p.pos] }
+
+func (p *exprParser) next() exprToken {
+	t := p.tokens[p.pos]
+	if t.kind != exprEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *exprParser) keyword(k string) bool {
+	t := p.peek()
+	if t.kind == exprWord && strings.EqualFold(t.text, k) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *exprParser) errorf(t exprToken, format string, args ...any) error {
+	return fmt.Errorf("%s, got %s at position %d in %q", fmt.Sprintf(format, args...), t, t.pos+1, p.src)
+}
+
+// asCondition returns the comparison or combined conditions in x, or an error
+// if x is a plain value like a field.
+func (p *exprParser) asCondition(x valueExpr) (Condition, error) {
+	if c, ok := x.(exprCondition); ok {
+		return c.Condition, nil
+	}
+	return nil, p.errorf(p.peek(), "expected comparison operator after %s", x)
+}
+
+func (p *exprParser) condition() (valueExpr, error) {
+	return p.junction("OR", true, p.and)
+}
+
+func (p *exprParser) and() (valueExpr, error) {
+	return p.junction("AND", false, p.unary)
+}
+
+func (p *exprParser) junction(keyword string, anyOf bool, term func() (valueExpr, error)) (valueExpr, error) {
+	x, err := term()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != exprWord || !strings.EqualFold(t.text, keyword) {
+		return x, nil
+	}
+	c, err := p.asCondition(x)
+	if err != nil {
+		return nil, err
+	}
+	terms := []Condition{c}
+	for p.keyword(keyword) {
+		x, err := term()
+		if err != nil {
+			return nil, err
+		}
+		c, err := p.asCondition(x)
+		if err != nil {
+			return nil, err
+		}
+		terms = append(terms, c)
+	}
+	return exprCondition{junction{Terms: terms, Any: anyOf}}, nil
+}
+
+func (p *exprParser) unary() (valueExpr, error) {
+	if p.keyword("NOT") {
+		x, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		c, err := p.asCondition(x)
+		if err != nil {
+			return nil, err
+		}
+		return exprCondition{negation{Term: c}}, nil
+	}
+	return p.comparison()
+}
+
+func (p *exprParser) comparison() (valueExpr, error) {
+	start := p.peek()
+	left, err := p.sum()
+	if err != nil {
+		return nil, err
+	}
+	t := p.peek()
+	if t.kind != exprOp {
+		return left, nil
+	}
+	if _, ok := left.(exprLiteral); ok && p.where {
+		return nil, p.errorf(start, "expected field name or function")
+	}
+	p.next()
+	c := whereComparison{Op: operator(t.text), Left: left}
+	for {
+		r, err := p.operand()
+		if err != nil {
+			return nil, err
+		}
+		var re *regexp.Regexp
+		if lit, ok := r.(exprLiteral); ok {
+			if lit == "" && c.Op != OpEqual && c.Op != OpNotEqual && c.Op != OpGreaterThan {
+				return nil, p.errorf(t, "cannot use %s with empty string", c.Op)
+			}
+			if re, err = compileOperand(c.Op, string(lit)); err != nil {
+				return nil, fmt.Errorf("invalid pattern %s in %q: %w", lit, p.src, err)
+			}
+		} else if c.Op == OpMatches {
+			return nil, p.errorf(t, "%s requires a pattern string", c.Op)
+		}
+		c.Right = append(c.Right, r)
+		c.patterns = append(c.patterns, re)
+		if p.peek().kind != exprPipe {
+			break
+		}
+		p.next()
+	}
+	return exprCondition{c}, nil
+}
+
+// operand parses the right side of a comparison.  In --where expressions, a
+// word is a value rather than a field name.  A missing value, e.g.
+// "operator=" at the end of the expression, is empty.
+func (p *exprParser) operand() (valueExpr, error) {
+	if p.emptyValue() {
+		return exprLiteral(""), nil
+	}
+	if t := p.peek(); p.where && t.kind == exprWord && p.tokens[p.pos+1].kind != exprOpen {
+		p.next()
+		return exprLiteral(t.text), nil
+	}
+	return p.sum()
+}
+
+// emptyValue returns true if the next token ends a comparison.  AND, OR, and
+// NOT are keywords and need to be quoted to be used as values.
+func (p *exprParser) emptyValue() bool {
+	switch t := p.peek(); t.kind {
+	case exprEOF, exprPipe, exprClose, exprComma:
+		return true
+	case exprWord:
+		for _, k := range []string{"AND", "OR", "NOT"} {
+			if strings.EqualFold(t.text, k) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (p *exprParser) sum() (valueExpr, error) {
+	return p.arithmetic(p.product, "+", "-")
+}
+
+func (p *exprParser) product() (valueExpr, error) {
+	return p.arithmetic(p.sign, "*", "/")
+}
+
+func (p *exprParser) arithmetic(term func() (valueExpr, error), ops ...string) (valueExpr, error) {
+	x, err := term()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		if t.kind != exprArith || (t.text != ops[0] && t.text != ops[1]) {
+			return x, nil
+		}
+		p.next()
+		y, err := term()
+		if err != nil {
+			return nil, err
+		}
+		x = exprArithmetic{op: t.text[0], left: x, right: y}
+	}
+}
+
+func (p *exprParser) sign() (valueExpr, error) {
+	if t := p.peek(); t.kind == exprArith && t.text == "-" {
+		p.next()
+		x, err := p.sign()
+		if err != nil {
+			return nil, err
+		}
+		if l, ok := x.(exprLiteral); ok {
+			if n, err := strconv.ParseFloat(string(l), 64); err == nil {
+				return exprLiteral(formatNumber(-n, exprDecimals)), nil
+			}
+		}
+		return exprArithmetic{op: '-', left: exprLiteral("0"), right: x}, nil
+	}
+	return p.primary()
+}
+
+func (p *exprParser) primary() (valueExpr, error) {
+	t := p.next()
+	switch t.kind {
+	case exprString:
+		return exprLiteral(t.text), nil
+	case exprFieldRef:
+		return exprField(strings.ToUpper(t.text)), nil
+	case exprOpen:
+		x, err := p.condition()
+		if err != nil {
+			return nil, err
+		}
+		if c := p.next(); c.kind != exprClose {
+			return nil, p.errorf(c, "expected )")
+		}
+		return x, nil
+	case exprWord:
+		if p.peek().kind == exprOpen {
+			return p.call(t)
+		}
+		if !p.where {
+			return exprLiteral(t.text), nil
+		}
+		if r, _ := utf8.DecodeRuneInString(t.text); unicode.IsDigit(r) {
+			return exprLiteral(t.text), nil // numbers like substr(call, 1, 2)
+		}
+		if conditionFieldPat.MatchString(t.text) {
+			return exprField(strings.ToUpper(t.text)), nil
+		}
+		return nil, p.errorf(t, "expected field name or function")
+	}
+	return nil, p.errorf(t, "expected value")
+}
+
+// call parses the arguments to function t and returns a function call
+// expression, or an error for unknown functions and the wrong number of
+// arguments.
+func (p *exprParser) call(t exprToken) (valueExpr, error) {
+	p.next() // (
+	var args []valueExpr
+	if p.peek().kind == exprClose {
+		p.next()
+	} else {
+		for {
+			a, err := p.condition()
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, a)
+			c := p.next()
+			if c.kind == exprClose {
+				break
+			}
+			if c.kind != exprComma {
+				return nil, p.errorf(c, "expected , or ) after %s argument", t.text)
+			}
+		}
+	}
+	name := strings.ToLower(t.text)
+	if name == "if" {
+		if len(args) < 2 || len(args) > 3 {
+			return nil, fmt.Errorf("if takes 2 or 3 arguments, got %d at position %d in %q", len(args), t.pos+1, p.src)
+		}
+		x := exprIf{cond: args[0], then: args[1]}
+		if len(args) > 2 {
+			x.els = args[2]
+		}
+		return x, nil
+	}
+	fn, ok := exprFuncs[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown function %q at position %d in %q", t.text, t.pos+1, p.src)
+	}
+	if len(args) < fn.minArgs || (fn.maxArgs >= 0 && len(args) > fn.maxArgs) {
+		return nil, fmt.Errorf("wrong number of arguments to %s, got %d at position %d in %q", fn.usage, len(args), t.pos+1, p.src)
+	}
+	return exprCall{name: name, fn: fn, args: args}, nil
+}
@@ -0,0 +1,200 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"testing"
+
+	"github.com/flwyd/adif-multitool/adif"
+)
+
+func TestCompileValue(t *testing.T) {
+	rec := adif.NewRecord(
+		adif.Field{Name: "CALL", Value: "k1a/p"},
+		adif.Field{Name: "NAME", Value: "Hiram"},
+		adif.Field{Name: "QTH", Value: "Newington"},
+		adif.Field{Name: "FREQ_KHZ", Value: "14074"},
+		adif.Field{Name: "TX_PWR", Value: "5"},
+		adif.Field{Name: "BAND", Value: "20m"},
+		adif.Field{Name: "QSO_DATE", Value: "20231017"},
+		adif.Field{Name: "TIME_ON", Value: "0930"},
+		adif.Field{Name: "DATE", Value: "3/4/2023"},
+		adif.Field{Name: "UTC", Value: "14:05:06"},
+		adif.Field{Name: "MY_POTA_REF", Value: "K-0001"},
+		adif.Field{Name: "COMMENT", Value: "  hi  "},
+		adif.Field{Name: "EMPTY", Value: ""},
+	)
+	eval := recordEvalContext{record: rec}
+	tests := []struct{ value, want string }{
+		{value: "", want: ""},
+		{value: "plain text", want: "plain text"},
+		{value: "Nice park (K-0001)", want: "Nice park (K-0001)"},
+		{value: "1/2", want: "1/2"},
+		{value: "-5", want: "-5"},
+		{value: "'quoted'", want: "'quoted'"},
+		{value: "{missing} and {", want: " and {"},
+		{value: "{my_pota_ref}", want: "K-0001"},
+		{value: "={ name }", want: "Hiram"},
+		{value: "{ name }", want: "{ name }"},
+		{value: "{name} {qth}", want: "Hiram Newington"},
+		{value: "{name} in {qth}, 73", want: "Hiram in Newington, 73"},
+		{value: "POTA-{my_pota_ref}", want: "POTA-K-0001"},
+		{value: "{call}/P", want: "k1a/p/P"},
+		{value: "{{hello}} world", want: "{hello} world"},
+		{value: "{{{name}}}", want: "{Hiram}"},
+		{value: "{{ {name} }}", want: "{ Hiram }"},
+		{value: "upper(case)", want: "upper(case)"},
+		{value: "round(3.14)", want: "round(3.14)"},
+		{value: "Smith-Jones", want: "Smith-Jones"},
+		{value: "{freq_khz}/1000", want: "14.074"},
+		{value: "{tx_pwr}-1", want: "4"},
+		{value: "upper({call})", want: "K1A/P"},
+		{value: "concat({name}, ' in ', {qth})", want: "Hiram in Newington"},
+		{value: "if({tx_pwr} <= 5, 'QRP', 'QRO')", want: "QRP"},
+		{value: "a=b", want: "a=b"},
+		{value: "=5", want: "5"},
+		{value: `="=)"`, want: "=)"},
+		{value: "=Nice", want: "Nice"},
+		{value: "=upper({call})", want: "K1A/P"},
+		{value: "=lower(upper({call}))", want: "k1a/p"},
+		{value: "=trim({comment})", want: "hi"},
+		{value: "=len({call})", want: "5"},
+		{value: "={freq_khz}/1000", want: "14.074"},
+		{value: "={freq_khz} / 1000 + 0.001", want: "14.075"},
+		{value: "=({tx_pwr} + 1) * 2", want: "12"},
+		{value: "={tx_pwr} + 1 * 2", want: "7"},
+		{value: "=-{tx_pwr}", want: "-5"},
+		{value: "={tx_pwr}*7.1/5*3", want: "21.3"},
+		{value: "={empty}*3", want: ""},
+		{value: "=round({freq_khz}/3, 2)", want: "4691.33"},
+		{value: "=round({freq_khz}/1000)", want: "14"},
+		{value: "=concat({call}, '-', {tx_pwr})", want: "k1a/p-5"},
+		{value: `=concat("it's ", 'a \'test\'')`, want: "it's a 'test'"},
+		{value: "=coalesce({empty}, {missing}, {name})", want: "Hiram"},
+		{value: "=substr({my_pota_ref}, 3)", want: "0001"},
+		{value: "=substr({qth}, 1, 3)", want: "New"},
+		{value: "=substr({qth}, 8, 10)", want: "on"},
+		{value: "=substr({qth}, 20, 1)", want: ""},
+		{value: "=replace({my_pota_ref}, 'K-', 'US-')", want: "US-0001"},
+		{value: "=year({qso_date})", want: "2023"},
+		{value: "=month({qso_date})*100+day({qso_date})", want: "1017"},
+		{value: `=parsedate({date}, "1/2/2006")`, want: "20230304"},
+		{value: `=parsedate({date}, "2/1/2006")`, want: "20230403"},
+		{value: `=parsetime({utc}, "15:04:05")`, want: "140506"},
+		{value: `=parsedate({empty}, "2006")`, want: ""},
+		{value: `=formatdate({qso_date}, "Jan 2, 2006")`, want: "Oct 17, 2023"},
+		{value: `=formattime({time_on}, "3:04 PM")`, want: "9:30 AM"},
+		{value: `=parsedate({date}, "D/M/YYYY")`, want: "20230403"},
+		{value: `=parsedate({date}, "%m/%d/%Y")`, want: "20230304"},
+		{value: `=parsetime({utc}, "HH:MM:SS")`, want: "140506"},
+		{value: `=formatdate({qso_date}, "DD/MM/YYYY")`, want: "17/10/2023"},
+		{value: `=formatdate({qso_date}, "%d.%m.%y")`, want: "17.10.23"},
+		{value: `=formattime({time_on}, "hh:MM am")`, want: "09:30 am"},
+		{value: "=if({tx_pwr} <= 5, 'QRP', 'QRO')", want: "QRP"},
+		{value: "=if({tx_pwr} > 5, 'QRP', 'QRO')", want: "QRO"},
+		{value: "=if({tx_pwr} > 5, 'QRO')", want: ""},
+		{value: "=if({band} >= 40m, 'higher', 'lower')", want: "higher"},
+		{value: "=if({band} = 20M, 'twenty', 'other')", want: "twenty"},
+		{value: "=if({band} != 20M, 'other', 'twenty')", want: "twenty"},
+		{value: "=if({mode}=SSB, 'phone', 'other')", want: "other"},
+		{value: "=if({name}, {name}, {call})", want: "Hiram"},
+		{value: "=if({empty}, {name}, {call})", want: "k1a/p"},
+		{value: "=if({tx_pwr}=5, {freq_khz}/0, 'safe')", want: ""},
+		{value: "={tx_pwr} < 10", want: "Y"},
+		{value: "={band} = 40m|20m", want: "Y"},
+		{value: "={call} ~ '/P$'", want: "Y"},
+		{value: "={call} ^= 'W'", want: "N"},
+		{value: "=({tx_pwr} > 1) AND ({tx_pwr} < 10)", want: "Y"},
+		{value: "=if({mode} = SSB OR {band} = 20m, 'yes', 'no')", want: "yes"},
+		{value: "=if(NOT {tx_pwr} < 10, 'QRO', 'QRP')", want: "QRP"},
+		{value: `=concat('a\b', "\"", '\\')`, want: `a\b"\`},
+	}
+	for _, tc := range tests {
+		x, err := compileValue(tc.value)
+		if err != nil {
+			t.Errorf("compileValue(%q) got error %v", tc.value, err)
+			continue
+		}
+		got, err := x.eval(eval)
+		if tc.value == "=if({tx_pwr}=5, {freq_khz}/0, 'safe')" {
+			if err == nil {
+				t.Errorf("compileValue(%q) = %s want division by zero error, got %q", tc.value, x, got.Value)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("compileValue(%q) = %s got error %v", tc.value, x, err)
+		} else if got.Value != tc.want {
+			t.Errorf("compileValue(%q) = %s got %q, want %q", tc.value, x, got.Value, tc.want)
+		}
+	}
+}
+
+func TestCompileValueErrors(t *testing.T) {
+	for _, s := range []string{
+		"=",
+		"=foo({call})",
+		"upper({call}",
+		"upper({call}, {name})",
+		"if({tx_pwr} <= 5, 'QRP'",
+		"=upper({call}, {name})",
+		"=upper()",
+		"=upper({call}",
+		"=upper({call}))",
+		"=if({call})",
+		"=substr({call})",
+		"=concat()",
+		"=concat({call} {name})",
+		"={call",
+		"={my-call}",
+		"='unterminated",
+		"={name} in {qth}",
+		"=({tx_pwr} + 1",
+		"={tx_pwr} +",
+		"=Nice park (K-0001)",
+		"={call};",
+		"={call} AND {name}",
+		"=NOT {call}",
+		"=if({qth} AND {tx_pwr} = 5, 'yes', 'no')",
+		"={call} ~ {name}",
+		"={call} *=",
+	} {
+		if x, err := compileValue(s); err == nil {
+			t.Errorf("compileValue(%q) got %s, want error", s, x)
+		}
+	}
+	eval := recordEvalContext{record: adif.NewRecord(adif.Field{Name: "CALL", Value: "W1AW"}, adif.Field{Name: "DATE", Value: "2023"})}
+	for _, s := range []string{
+		"={call}-1",
+		"{call}-1",
+		"={call}*2",
+		"=round({call})",
+		"=substr({call}, 0)",
+		"=substr({call}, 1, x)",
+		"=parsedate({date}, '01/02/2006')",
+		"=formatdate({call}, '2006')",
+		"=parsedate({date}, 'QQ/MM')",
+		"=if({call} * 2 > 3, 'big')",
+	} {
+		x, err := compileValue(s)
+		if err != nil {
+			t.Errorf("compileValue(%q) got error %v", s, err)
+			continue
+		}
+		if got, err := x.eval(eval); err == nil {
+			t.Errorf("compileValue(%q) = %s got %q, want error", s, x, got.Value)
+		}
+	}
+}
@@ -17,6 +17,8 @@ package cmd
 import (
 	"fmt"
 	"io"
+	"path"
+	"strconv"
 	"strings"
 )
 
@@ -70,3 +72,31 @@ func (fs fakeFilesystem) MkdirAll(dir string) error {
 	// currently not worying about enforcing directories
 	return nil
 }
+
+func (fs fakeFilesystem) CreateTemp(dir, pattern string) (io.WriteCloser, string, error) {
+	for i := 1; ; i++ {
+		name := path.Join(dir, strings.Replace(pattern, "*", strconv.Itoa(i), 1))
+		if !fs.Exists(name) {
+			w, err := fs.Create(name)
+			return w, name, err
+		}
+	}
+}
+
+func (fs fakeFilesystem) Rename(oldpath, newpath string) error {
+	f, ok := fs.files[oldpath]
+	if !ok {
+		return fmt.Errorf("%s does not exist", oldpath)
+	}
+	fs.files[newpath] = f
+	delete(fs.files, oldpath)
+	return nil
+}
+
+func (fs fakeFilesystem) Remove(name string) error {
+	if _, ok := fs.files[name]; !ok {
+		return fmt.Errorf("%s does not exist", name)
+	}
+	delete(fs.files, name)
+	return nil
+}
@@ -32,6 +32,19 @@ func helpFind() string {
   field <= value : Less than or equal, band<=10m
   field > value : Greater than, tx_pwr>100
   field >= value : Greater than or equal, qso_date>=20200101
+  field != value : Not equal to any value, mode!=CW|SSB
+  field ~ regex : Case-insensitive regular expression match, call~^[AKNW][0-9]
+  field *= value : Contains, comment*=park
+  field ^= value : Starts with, call^=VE
+  field $= value : Ends with, call$=/P|/MM
+
+Equality conditions can use glob wildcards, * for any number of characters
+and ? for a single character:
+  call=*/P|*/MM : portable or maritime mobile
+  gridsquare=FN3? : any FN3x grid square
+
+Text matching ignores case.  '|' separates values, except with ~ where it is
+regular expression alternation.
 
 Fields can be compared to other fields by enclosing in '{' and '}':
   gridsquare={my_gridsquare} : contact in the same maidenhead grid
@@ -47,32 +60,23 @@ Conditions match list fields if any value matches:
 Empty or absent fields can be matched by omitting value:
   operator= : OPERATOR field not set
   my_sig_info> : MY_SIG_INFO field is set ("greater than empty")
+  my_sig_info!= : MY_SIG_INFO field is set
 
 Use quotes so operators are not treated as special shell characters:
   find --if 'freq>=7' --if-not 'mode=CW' --or-if 'tx_pwr<=5'
-`
+
+` + helpWhere() + "\n" + helpStreamOutput("find --if band=20m")
 }
 
 func runFind(ctx *Context, args []string) error {
 	cctx := ctx.CommandCtx.(*FindContext)
 	cond := cctx.Cond.Get()
-	out := adif.NewLogfile()
-	acc := accumulator{Out: out, Ctx: ctx}
-	for _, f := range filesOrStdin(args) {
-		l, err := acc.read(f)
-		if err != nil {
-			return err
-		}
-		updateFieldOrder(out, l.FieldOrder)
-		for _, r := range l.Records {
-			eval := recordEvalContext{record: r, lang: ctx.Locale}
-			if cond.Evaluate(eval) {
-				out.AddRecord(r)
-			}
+	s := recordStream{Ctx: ctx, Out: adif.NewLogfile(), KnownFields: true}
+	return s.run(args, func(r *adif.Record, _ *adif.Logfile, _ int) (*adif.Record, error) {
+		eval := recordEvalContext{record: r, lang: ctx.Locale}
+		if cond.Evaluate(eval) {
+			return r, nil
 		}
-	}
-	if err := acc.prepare(); err != nil {
-		return err
-	}
-	return write(ctx, out)
+		return nil, nil
+	})
 }
@@ -289,3 +289,83 @@ func TestFindCondition(t *testing.T) {
 		})
 	}
 }
+
+func TestFindPatterns(t *testing.T) {
+	adi := adif.NewADIIO()
+	csv := adif.NewCSVIO()
+	file1 := `CALL,MODE,POTA_REF,COMMENT
+K1A/P,SSB,K-0001,Nice park
+W2B/MM,CW,,On a ship
+VE3C,SSB,"K-0034,K-4556",
+KH6/W4D,FT8,K-4556,thanks for the park
+N5E,SSB,,
+`
+	tests := []struct {
+		cond string
+		neg  bool
+		want []string
+	}{
+		{cond: "call=*/P|*/MM", want: []string{"K1A/P", "W2B/MM"}},
+		{cond: "call=k?a/p", want: []string{"K1A/P"}},
+		{cond: "call=K*", want: []string{"K1A/P", "KH6/W4D"}},
+		{cond: "call=K*", neg: true, want: []string{"W2B/MM", "VE3C", "N5E"}},
+		{cond: "pota_ref=K-45*", want: []string{"VE3C", "KH6/W4D"}},
+		{cond: "mode!=SSB", want: []string{"W2B/MM", "KH6/W4D"}},
+		{cond: "mode!=SSB|CW", want: []string{"KH6/W4D"}},
+		{cond: "pota_ref!=", want: []string{"K1A/P", "VE3C", "KH6/W4D"}},
+		{cond: "call~/(P|MM)$", want: []string{"K1A/P", "W2B/MM"}},
+		{cond: "call~^[a-z]{2}[0-9]", want: []string{"VE3C", "KH6/W4D"}},
+		{cond: "call~^[a-z]{2}[0-9]", neg: true, want: []string{"K1A/P", "W2B/MM", "N5E"}},
+		{cond: "comment*=PARK", want: []string{"K1A/P", "KH6/W4D"}},
+		{cond: "comment*=ship|thanks", want: []string{"W2B/MM", "KH6/W4D"}},
+		{cond: "call^=k", want: []string{"K1A/P", "KH6/W4D"}},
+		{cond: "pota_ref^=K-4", want: []string{"VE3C", "KH6/W4D"}},
+		{cond: "call$=/p|/mm", want: []string{"K1A/P", "W2B/MM"}},
+		{cond: "call$={mode}", want: []string{}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.cond, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			cond := ConditionValue{}
+			f := cond.IfFlag()
+			if tc.neg {
+				f = cond.IfNotFlag()
+			}
+			if err := f.Set(tc.cond); err != nil {
+				t.Fatalf("Error parsing condition %q: %v", tc.cond, err)
+			}
+			ctx := &Context{
+				OutputFormat: adif.FormatADI,
+				Readers:      readers(adi, csv),
+				Writers:      writers(adi, csv),
+				Out:          out,
+				fs:           fakeFilesystem{map[string]string{"foo.csv": file1}},
+				CommandCtx:   &FindContext{Cond: cond},
+			}
+			if err := Find.Run(ctx, []string{"foo.csv"}); err != nil {
+				t.Fatalf("Find.Run(ctx, foo.csv) got error %v", err)
+			}
+			gotlog, err := adi.Read(out)
+			if err != nil {
+				t.Fatalf("Find output could not be parsed: %v", err)
+			}
+			got := make([]string, len(gotlog.Records))
+			for i, r := range gotlog.Records {
+				call, _ := r.Get("CALL")
+				got[i] = call.Value
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("%s %v got diff\n%s", tc.cond, cond.Get(), diff)
+			}
+		})
+	}
+}
+
+func TestConditionParseErrors(t *testing.T) {
+	for _, s := range []string{"call~(K|W", "call~[a-", "call~", "comment*=", "call^=", "call$=", "call<", "call", "=foo"} {
+		cond := ConditionValue{}
+		if err := cond.IfFlag().Set(s); err == nil {
+			t.Errorf("IfFlag().Set(%q) got no error, condition %v", s, cond.Get())
+		}
+	}
+}
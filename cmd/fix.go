@@ -16,7 +16,9 @@ package cmd
 
 import (
 	"fmt"
+	"io"
 	"math"
+	"os"
 	"regexp"
 	"strconv"
 	"strings"
@@ -29,65 +31,298 @@ import (
 var Fix = Command{Name: "fix", Run: runFix, Help: helpFix,
 	Description: "Correct field formats to match the ADIF specification"}
 
+type FixContext struct {
+	// Fixes lists the categories of fixes to apply, or the default categories
+	// (date, time, location, and country) if empty.
+	Fixes      FieldList
+	CommentLog bool
+	// DateFormat is a field order (DMY, MDY, or YMD) or a date layout.
+	DateFormat string
+	TimeFormat string
+	// YearPivot is the first two-digit year in the 1900s, or 0 for the default.
+	YearPivot int
+	report    io.Writer // unparseable values are written here, os.Stderr if nil
+}
+
+const (
+	FixDate      = "DATE"
+	FixTime      = "TIME"
+	FixLocation  = "LOCATION"
+	FixCountry   = "COUNTRY"
+	FixBoolean   = "BOOLEAN"
+	FixCase      = "CASE"
+	FixFrequency = "FREQ"
+	FixBand      = "BAND"
+	FixMode      = "MODE"
+	FixContestID = "CONTEST_ID"
+)
+
+type fixCategory struct {
+	Name, Description string
+	Default           bool // applied if --fixes is not set
+}
+
+var fixCategories = []fixCategory{
+	{Name: FixDate, Default: true, Description: "Date fields in year-month-day or --date-format order"},
+	{Name: FixTime, Default: true, Description: "Time fields with colons, AM/PM, or --time-format"},
+	{Name: FixLocation, Default: true, Description: "Location fields in decimal degrees (GPS coordinates)"},
+	{Name: FixCountry, Default: true, Description: "COUNTRY and MY_COUNTRY ISO 3166-1 alpha-2 and alpha-3 codes"},
+	{Name: FixBoolean, Description: "Boolean fields like yes, true, 1, no, false, 0 to Y or N"},
+	{Name: FixCase, Description: "Upper case callsigns and POTA, SOTA, WWFF, IOTA references; grid squares like FN31pr"},
+	{Name: FixFrequency, Description: "FREQ and FREQ_RX in kHz or Hz to MHz"},
+	{Name: FixBand, Description: "BAND and BAND_RX like 20 or 70CM to 20m or 70cm"},
+	{Name: FixMode, Description: "MODE and SUBMODE like USB to SSB and USB, ft-8 to FT8"},
+	{Name: FixContestID, Description: "CONTEST_ID case, Cabrillo names, and descriptions like ARRL Field Day"},
+}
+
+// defaultYearPivot puts two-digit years 00 to 49 in the 2000s and 50 to 99 in
+// the 1900s.
+const defaultYearPivot = 50
+
 var allNumeric = regexp.MustCompile("^[0-9]+$")
 
 func helpFix() string {
-	return `Fixable data formats:
+	var cats strings.Builder
+	for _, c := range fixCategories {
+		def := ""
+		if c.Default {
+			def = " (default)"
+		}
+		fmt.Fprintf(&cats, "  %-12s %s%s\n", c.Name, c.Description, def)
+	}
+	return "Fix categories (select with --fixes, e.g. --fixes=date,time,band,mode):\n" + cats.String() + `
+Fixable data formats:
   Date fields: 2006-01-02, 2006/01/02, 2006.01.02 (or without zero padding)
+    2 Jan 2006, Jan 2, 2006, 2-Jan-2006, January 2 2006 (month names)
+    --date-format=DMY 02/01/2006, 2.1.2006, 02-01-06, 2-Jan-06
+    --date-format=MDY 01/02/2006, 1.2.2006, 01-02-06
+    --date-format=YMD 06-01-02, 06/1/2 (two-digit years)
+    --date-format=FORMAT e.g. DD/MM/YYYY, %d.%m.%y, 02.01.2006 (Go layout)
   Time fields (seconds): 15:04:05, 3:04:05 PM, 3:04:05pm
   Time fields (no seconds): 15:04, 3:04 PM, 3:04pm
+    --time-format=FORMAT e.g. HH.MM, hh:mm:ss AM, %H.%M, 15h04 (Go layout)
   Location fields: decimal degrees (GPS coordinates)
   Country fields: ISO 3166-1 alpha-2 and alpha-3 codes
-`
+
+Two-digit years below --year-pivot are in the 2000s, others in the 1900s.
+Date and time values which could not be fixed are listed on standard error,
+but are still written to the output and do not change the exit status; use
+adifmt validate to fail on invalid values.
+
+` + helpStreamOutput("fix")
 }
 
 func runFix(ctx *Context, args []string) error {
-	// TODO add any needed flags
+	cctx, _ := ctx.CommandCtx.(*FixContext)
+	if cctx == nil {
+		cctx = &FixContext{}
+	}
+	fix, err := newFixer(cctx)
+	if err != nil {
+		return err
+	}
+	report := cctx.report
+	if report == nil {
+		report = os.Stderr
+	}
+	unfixed := 0
 	out := adif.NewLogfile()
-	acc := accumulator{Out: out, Ctx: ctx}
-	for _, f := range filesOrStdin(args) {
-		l, err := acc.read(f)
-		if err != nil {
-			return err
+	s := recordStream{Ctx: ctx, Out: out}
+	// out has userdef fields from all input files and the command line by the
+	// time the first record is processed
+	err = s.run(args, func(r *adif.Record, l *adif.Logfile, i int) (*adif.Record, error) {
+		rec, did, bad := fix.record(r, out)
+		if cctx.CommentLog && len(did) > 0 {
+			c := "adif-multitool fixed value for " + strings.Join(did, ", ")
+			if rec.GetComment() == "" {
+				rec.SetComment(c)
+			} else {
+				rec.SetComment(rec.GetComment() + "\n" + c)
+			}
 		}
-		updateFieldOrder(out, l.FieldOrder)
-		for _, rec := range l.Records {
-			out.AddRecord(fixRecord(rec, l))
+		for _, f := range bad {
+			unfixed++
+			fmt.Fprintf(report, "could not fix %s %q on %s record %d\n", f.Name, f.Value, l, i+1)
 		}
-	}
-	if err := acc.prepare(); err != nil {
+		return rec, nil
+	})
+	if err != nil {
 		return err
 	}
-	// fix again in case userdef fields were added
-	for _, r := range out.Records {
-		for _, f := range r.Fields() {
-			ff := fixField(f, out)
-			if f != ff {
-				r.Set(ff)
+	if unfixed > 0 {
+		fmt.Fprintf(report, "fix could not parse %d date or time values\n", unfixed)
+	}
+	return nil
+}
+
+var (
+	ymdDateFormats       = []string{"2006-1-2", "2006/1/2", "2006.1.2"}
+	monthNameDateFormats = []string{
+		"2 Jan 2006", "2-Jan-2006", "2 January 2006", "2-January-2006",
+		"Jan 2 2006", "Jan 2, 2006", "January 2 2006", "January 2, 2006",
+		"2006-Jan-2", "2006 Jan 2", "2006 January 2",
+	}
+	// dateFormatOrders are tried before ymdDateFormats and monthNameDateFormats
+	// if --date-format names a field order
+	dateFormatOrders = map[string][]string{
+		"YMD": {"06-1-2", "06/1/2", "06.1.2"},
+		"DMY": {"2-1-2006", "2/1/2006", "2.1.2006", "2-1-06", "2/1/06", "2.1.06", "2 Jan 06", "2-Jan-06"},
+		"MDY": {"1-2-2006", "1/2/2006", "1.2.2006", "1-2-06", "1/2/06", "1.2.06", "Jan 2 06", "Jan 2, 06"},
+	}
+)
+
+var (
+	timeWithSecs    = []string{"15:04:05", "3:04:05 PM", "3:04:05 pm", "3:04:05PM", "3:04:05pm"}
+	timeWithoutSecs = []string{"15:04", "3:04 PM", "3:04 pm", "3:04PM", "3:04pm"}
+)
+
+// fixer corrects field values according to fix options.
+type fixer struct {
+	enabled     map[string]bool
+	dateLayout  string // custom layout, tried first
+	dateLayouts []string
+	timeLayout  string // custom layout, tried first
+	yearPivot   int
+}
+
+func newFixer(cctx *FixContext) (*fixer, error) {
+	f := &fixer{yearPivot: cctx.YearPivot, enabled: make(map[string]bool)}
+	for _, c := range fixCategories {
+		f.enabled[c.Name] = len(cctx.Fixes) == 0 && c.Default
+	}
+	for _, n := range cctx.Fixes {
+		if _, ok := f.enabled[n]; !ok {
+			names := make([]string, len(fixCategories))
+			for i, c := range fixCategories {
+				names[i] = c.Name
+			}
+			return nil, fmt.Errorf("unknown fix %q, options: %s", n, strings.Join(names, ", "))
+		}
+		f.enabled[n] = true
+	}
+	if f.yearPivot == 0 {
+		f.yearPivot = defaultYearPivot
+	} else if f.yearPivot < 0 || f.yearPivot > 100 {
+		return nil, fmt.Errorf("year pivot %d must be between 0 and 100", cctx.YearPivot)
+	}
+	if df := strings.TrimSpace(cctx.DateFormat); df != "" {
+		if order, ok := dateFormatOrders[strings.ToUpper(df)]; ok {
+			f.dateLayouts = append(f.dateLayouts, order...)
+		} else {
+			l, err := dateLayout(df)
+			if err != nil {
+				return nil, fmt.Errorf("invalid date format: %w", err)
 			}
+			f.dateLayout = l
 		}
 	}
-	return write(ctx, out)
+	f.dateLayouts = append(f.dateLayouts, ymdDateFormats...)
+	f.dateLayouts = append(f.dateLayouts, monthNameDateFormats...)
+	if tf := strings.TrimSpace(cctx.TimeFormat); tf != "" {
+		l, err := timeLayout(tf)
+		if err != nil {
+			return nil, fmt.Errorf("invalid time format: %w", err)
+		}
+		f.timeLayout = l
+	}
+	return f, nil
 }
 
-func fixRecord(r *adif.Record, l *adif.Logfile) *adif.Record {
+// record returns a copy of r with fixed fields, the names of fields which
+// were changed, and a list of date and time fields which are still not valid.
+func (x *fixer) record(r *adif.Record, l *adif.Logfile) (*adif.Record, []string, []adif.Field) {
 	fields := r.Fields()
+	var did []string
+	var bad []adif.Field
 	for i, f := range fields {
-		fields[i] = fixField(f, l)
+		fields[i] = x.field(f, r, l)
+		if fields[i].Value != f.Value {
+			did = append(did, f.Name)
+		}
+		if v := fields[i].Value; v != "" {
+			switch fieldType(f, l) {
+			case spec.DateDataType:
+				if x.enabled[FixDate] && !isADIFDate(v) {
+					bad = append(bad, f)
+				}
+			case spec.TimeDataType:
+				if x.enabled[FixTime] && !isADIFTime(v) {
+					bad = append(bad, f)
+				}
+			}
+		}
+	}
+	res := adif.NewRecord(fields...)
+	res.SetComment(r.GetComment())
+	if x.enabled[FixMode] {
+		did = append(did, fixModeSubmode(res)...)
 	}
-	return adif.NewRecord(fields...)
+	return res, did, bad
 }
 
-func fixField(f adif.Field, l *adif.Logfile) adif.Field {
-	t := fieldType(f, l)
-	if t == spec.DateDataType {
-		f.Value = fixDate(f.Value)
-	} else if t == spec.TimeDataType {
-		f.Value = fixTime(f.Value)
-	} else if t == spec.LocationDataType {
-		f.Value = fixLocation(f.Value, f.Name)
-	} else if f.Name == spec.CountryField.Name || f.Name == spec.MyCountryField.Name {
-		f.Value = fixCountry(f.Value)
+func (x *fixer) field(f adif.Field, r *adif.Record, l *adif.Logfile) adif.Field {
+	switch f.Name {
+	case spec.CountryField.Name, spec.MyCountryField.Name:
+		if x.enabled[FixCountry] {
+			f.Value = fixCountry(f.Value)
+		}
+		return f
+	case spec.FreqField.Name:
+		if x.enabled[FixFrequency] {
+			f.Value = fixFrequency(f.Value, fieldValue(r, spec.BandField.Name))
+		}
+		return f
+	case spec.FreqRxField.Name:
+		if x.enabled[FixFrequency] {
+			f.Value = fixFrequency(f.Value, fieldValue(r, spec.BandRxField.Name))
+		}
+		return f
+	case spec.BandField.Name, spec.BandRxField.Name:
+		if x.enabled[FixBand] {
+			f.Value = fixBand(f.Value)
+		}
+		return f
+	case spec.ContestIdField.Name:
+		if x.enabled[FixContestID] {
+			f.Value = fixContestID(f.Value)
+		}
+		return f
+	}
+	if callsignFields[f.Name] {
+		if x.enabled[FixCase] {
+			f.Value = strings.ToUpper(strings.TrimSpace(f.Value))
+		}
+		return f
+	}
+	switch t := fieldType(f, l); t {
+	case spec.DateDataType:
+		if x.enabled[FixDate] {
+			f.Value = x.date(f.Value)
+		}
+	case spec.TimeDataType:
+		if x.enabled[FixTime] {
+			f.Value = x.time(f.Value)
+		}
+	case spec.LocationDataType:
+		if x.enabled[FixLocation] {
+			f.Value = fixLocation(f.Value, f.Name)
+		}
+	case spec.BooleanDataType:
+		if x.enabled[FixBoolean] {
+			f.Value = fixBoolean(f.Value)
+		}
+	case spec.GridSquareDataType, spec.GridSquareListDataType:
+		if x.enabled[FixCase] {
+			f.Value = fixGridsquare(f.Value, 0)
+		}
+	case spec.GridSquareExtDataType:
+		if x.enabled[FixCase] {
+			f.Value = fixGridsquare(f.Value, 8)
+		}
+	case spec.POTARefDataType, spec.POTARefListDataType, spec.SOTARefDataType, spec.WWFFRefDataType, spec.IOTARefNoDataType:
+		if x.enabled[FixCase] {
+			f.Value = strings.ToUpper(strings.TrimSpace(f.Value))
+		}
 	}
 	return f
 }
@@ -110,30 +345,54 @@ func fieldType(f adif.Field, l *adif.Logfile) spec.DataType {
 	return spec.StringDataType // reasonable default
 }
 
-var dateFormats = []string{"2006-1-2", "2006/1/2", "2006.1.2"}
-
-func fixDate(d string) string {
+func (x *fixer) date(d string) string {
 	d = strings.TrimSpace(d)
-	// TODO take date format as flag
+	if x.dateLayout != "" {
+		if v, ok := x.parseDate(x.dateLayout, d); ok {
+			return v
+		}
+	}
 	if allNumeric.MatchString(d) {
 		return d
 	}
-	for _, pat := range dateFormats {
-		if p, err := time.Parse(pat, d); err == nil {
-			return p.Format("20060102")
+	for _, pat := range x.dateLayouts {
+		if v, ok := x.parseDate(pat, d); ok {
+			return v
 		}
 	}
 	return d
 }
 
-var (
-	timeWithSecs    = []string{"15:04:05", "3:04:05 PM", "3:04:05 pm", "3:04:05PM", "3:04:05pm"}
-	timeWithoutSecs = []string{"15:04", "3:04 PM", "3:04 pm", "3:04PM", "3:04pm"}
-)
+// parseDate parses d with layout and returns an ADIF date, putting two-digit
+// years in the century given by yearPivot.
+func (x *fixer) parseDate(layout, d string) (string, bool) {
+	p, err := time.Parse(layout, d)
+	if err != nil {
+		return "", false
+	}
+	if strings.Contains(strings.ReplaceAll(layout, "2006", ""), "06") {
+		y := p.Year() % 100
+		if y < x.yearPivot {
+			y += 2000
+		} else {
+			y += 1900
+		}
+		q := time.Date(y, p.Month(), p.Day(), 0, 0, 0, 0, time.UTC)
+		if q.Day() != p.Day() { // February 29 in a non-leap year
+			return "", false
+		}
+		p = q
+	}
+	return p.Format("20060102"), true
+}
 
-func fixTime(t string) string {
+func (x *fixer) time(t string) string {
 	t = strings.TrimSpace(t)
-	// TODO take date format as flag
+	if x.timeLayout != "" {
+		if v, err := formatADIFTime(x.timeLayout, t); err == nil {
+			return v
+		}
+	}
 	if allNumeric.MatchString(t) {
 		switch len(t) {
 		case 6, 4:
@@ -155,6 +414,27 @@ func fixTime(t string) string {
 	return t
 }
 
+func isADIFDate(d string) bool {
+	if len(d) != 8 || !allNumeric.MatchString(d) {
+		return false
+	}
+	_, err := time.Parse("20060102", d)
+	return err == nil
+}
+
+func isADIFTime(t string) bool {
+	var err error
+	switch len(t) {
+	case 4:
+		_, err = time.Parse("1504", t)
+	case 6:
+		_, err = time.Parse("150405", t)
+	default:
+		return false
+	}
+	return err == nil
+}
+
 func fixCountry(c string) string {
 	if e := spec.CountryEnumeration.Value(c); len(e) > 0 {
 		return c
@@ -17,6 +17,7 @@ package cmd
 import (
 	"bytes"
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/flwyd/adif-multitool/adif"
@@ -253,3 +254,274 @@ func TestFixCountry(t *testing.T) {
 		}
 	}
 }
+
+func runFixCSV(t *testing.T, cctx *FixContext, field, value string) (string, string) {
+	t.Helper()
+	csv := adif.NewCSVIO()
+	out, report := &bytes.Buffer{}, &bytes.Buffer{}
+	cctx.report = report
+	ctx := &Context{
+		OutputFormat: adif.FormatCSV,
+		Readers:      readers(csv),
+		Writers:      writers(csv),
+		Out:          out,
+		Prepare:      testPrepare("My Comment", "3.1.4", "fix test", "1.2.3"),
+		fs:           fakeFilesystem{map[string]string{"foo.csv": fmt.Sprintf("CALL,%s\nW1AW,%q\n", field, value)}},
+		CommandCtx:   cctx,
+	}
+	if err := Fix.Run(ctx, []string{"foo.csv"}); err != nil {
+		t.Fatalf("Fix.Run(ctx, foo.csv) with %s=%q got error %v", field, value, err)
+	}
+	want := fmt.Sprintf("CALL,%s\nW1AW,", field)
+	got := out.String()
+	if !strings.HasPrefix(got, want) {
+		t.Fatalf("Fix.Run(ctx, foo.csv) with %s=%q got %q, want prefix %q", field, value, got, want)
+	}
+	return strings.Trim(strings.TrimPrefix(got, want), "\"\n"), report.String()
+}
+
+func TestFixDateFormat(t *testing.T) {
+	tests := []struct{ format, source, want string }{
+		{format: "", source: "3 Mar 2023", want: "20230303"},
+		{format: "", source: "Mar 3, 2023", want: "20230303"},
+		{format: "", source: "december 25 1999", want: "19991225"},
+		{format: "", source: "25-DEC-1999", want: "19991225"},
+		{format: "", source: "2023-Oct-17", want: "20231017"},
+		{format: "", source: "1/2/2003", want: "1/2/2003"},
+		{format: "DMY", source: "1/2/2003", want: "20030201"},
+		{format: "dmy", source: "17.10.2023", want: "20231017"},
+		{format: "DMY", source: "17-10-23", want: "20231017"},
+		{format: "DMY", source: "17-10-73", want: "19731017"},
+		{format: "DMY", source: "3 Mar 23", want: "20230303"},
+		{format: "DMY", source: "2023-10-17", want: "20231017"},
+		{format: "DMY", source: "20231017", want: "20231017"},
+		{format: "DMY", source: "10/17/2023", want: "10/17/2023"},
+		{format: "MDY", source: "1/2/2003", want: "20030102"},
+		{format: "MDY", source: "10/17/23", want: "20231017"},
+		{format: "MDY", source: "Oct 17, 49", want: "20491017"},
+		{format: "MDY", source: "Oct 17, 50", want: "19501017"},
+		{format: "YMD", source: "23/10/17", want: "20231017"},
+		{format: "DD/MM/YYYY", source: "07/10/2023", want: "20231007"},
+		{format: "DDMMYYYY", source: "07102023", want: "20231007"},
+		{format: "DDMMYYYY", source: "20231007", want: "20231007"},
+		{format: "%d.%m.%y", source: "7.10.99", want: "19991007"},
+		{format: "02 Jan 06", source: "29 Feb 00", want: "20000229"},
+		{format: "YY-MM-DD", source: "97-02-29", want: "97-02-29"},
+	}
+	for _, tc := range tests {
+		got, _ := runFixCSV(t, &FixContext{DateFormat: tc.format}, "QSO_DATE", tc.source)
+		if got != tc.want {
+			t.Errorf("fix --date-format=%q QSO_DATE=%q got %q, want %q", tc.format, tc.source, got, tc.want)
+		}
+	}
+}
+
+func TestFixYearPivot(t *testing.T) {
+	tests := []struct {
+		pivot        int
+		source, want string
+	}{
+		{pivot: 0, source: "1/2/49", want: "20490201"},
+		{pivot: 30, source: "1/2/29", want: "20290201"},
+		{pivot: 30, source: "1/2/30", want: "19300201"},
+		{pivot: 100, source: "1/2/99", want: "20990201"},
+	}
+	for _, tc := range tests {
+		got, _ := runFixCSV(t, &FixContext{DateFormat: "DMY", YearPivot: tc.pivot}, "QSO_DATE", tc.source)
+		if got != tc.want {
+			t.Errorf("fix --year-pivot=%d QSO_DATE=%q got %q, want %q", tc.pivot, tc.source, got, tc.want)
+		}
+	}
+}
+
+func TestFixTimeFormat(t *testing.T) {
+	tests := []struct{ format, source, want string }{
+		{format: "HH.MM", source: "14.05", want: "1405"},
+		{format: "HH.MM", source: "14:05", want: "1405"},
+		{format: "HH.MM", source: "1405", want: "1405"},
+		{format: "hh:mm:ss AM", source: "02:05:09 PM", want: "140509"},
+		{format: "%Hh%M", source: "9h05", want: "0905"},
+		{format: "15h04", source: "21h30", want: "2130"},
+		{format: "HH.MM", source: "14-05", want: "14-05"},
+	}
+	for _, tc := range tests {
+		got, _ := runFixCSV(t, &FixContext{TimeFormat: tc.format}, "TIME_ON", tc.source)
+		if got != tc.want {
+			t.Errorf("fix --time-format=%q TIME_ON=%q got %q, want %q", tc.format, tc.source, got, tc.want)
+		}
+	}
+}
+
+func TestFixReport(t *testing.T) {
+	tests := []struct{ field, source, report string }{
+		{field: "QSO_DATE", source: "1/2/2003", report: "could not fix QSO_DATE \"1/2/2003\" on foo.csv record 1\nfix could not parse 1 date or time values\n"},
+		{field: "QSO_DATE", source: "20231301", report: "could not fix QSO_DATE \"20231301\" on foo.csv record 1\nfix could not parse 1 date or time values\n"},
+		{field: "TIME_OFF", source: "12/34", report: "could not fix TIME_OFF \"12/34\" on foo.csv record 1\nfix could not parse 1 date or time values\n"},
+		{field: "TIME_OFF", source: "975", report: "could not fix TIME_OFF \"975\" on foo.csv record 1\nfix could not parse 1 date or time values\n"},
+		{field: "QSO_DATE", source: "2023-10-17", report: ""},
+		{field: "TIME_ON", source: "", report: ""},
+		{field: "NAME", source: "1/2/2003", report: ""},
+	}
+	for _, tc := range tests {
+		_, got := runFixCSV(t, &FixContext{}, tc.field, tc.source)
+		if diff := cmp.Diff(tc.report, got); diff != "" {
+			t.Errorf("fix %s=%q report diff:\n%s", tc.field, tc.source, diff)
+		}
+	}
+}
+
+func TestFixFormatErrors(t *testing.T) {
+	for _, cctx := range []*FixContext{
+		{DateFormat: "QQ/MM/YYYY"},
+		{DateFormat: "%d/%m/%Q"},
+		{TimeFormat: "HH:XX"},
+		{YearPivot: 101},
+		{YearPivot: -1},
+	} {
+		ctx := &Context{CommandCtx: cctx, fs: fakeFilesystem{map[string]string{}}}
+		if err := Fix.Run(ctx, []string{"foo.csv"}); err == nil {
+			t.Errorf("Fix.Run with %+v got no error", cctx)
+		}
+	}
+}
+
+var allFixes = func() FieldList {
+	var res FieldList
+	for _, c := range fixCategories {
+		res = append(res, c.Name)
+	}
+	return res
+}()
+
+func TestFixValues(t *testing.T) {
+	tests := []struct{ field, source, want string }{
+		{field: "QSL_RCVD_VIA", source: "e", want: "e"}, // not a Boolean
+		{field: "FORCE_INIT", source: "yes", want: "Y"},
+		{field: "FORCE_INIT", source: "True", want: "Y"},
+		{field: "SWL", source: "1", want: "Y"},
+		{field: "QSO_RANDOM", source: "no", want: "N"},
+		{field: "QSO_RANDOM", source: "FALSE", want: "N"},
+		{field: "SILENT_KEY", source: "0", want: "N"},
+		{field: "SILENT_KEY", source: "maybe", want: "maybe"},
+		{field: "STATION_CALLSIGN", source: "k0a/p", want: "K0A/P"},
+		{field: "OPERATOR", source: " n0call ", want: "N0CALL"},
+		{field: "NAME", source: "hiram", want: "hiram"},
+		{field: "GRIDSQUARE", source: "fn31PR", want: "FN31pr"},
+		{field: "MY_GRIDSQUARE", source: "dm79", want: "DM79"},
+		{field: "GRIDSQUARE", source: "FN31PR21RJ", want: "FN31pr21rj"},
+		{field: "GRIDSQUARE_EXT", source: "RJ", want: "rj"},
+		{field: "VUCC_GRIDS", source: "en98,fm08,eM97,FM07", want: "EN98,FM08,EM97,FM07"},
+		{field: "GRIDSQUARE", source: "ZZ99", want: "ZZ99"},
+		{field: "POTA_REF", source: "k-4556", want: "K-4556"},
+		{field: "SOTA_REF", source: "w7w/kg-001", want: "W7W/KG-001"},
+		{field: "IOTA", source: "na-001", want: "NA-001"},
+		{field: "FREQ", source: "14074", want: "14.074"},
+		{field: "FREQ", source: "7030500", want: "7.0305"},
+		{field: "FREQ", source: "14.074", want: "14.074"},
+		{field: "FREQ", source: "146.52", want: "146.52"},
+		{field: "FREQ", source: "3573 kHz", want: "3.573"},
+		{field: "FREQ", source: "14074123Hz", want: "14.074123"},
+		{field: "FREQ", source: "10.368 GHz", want: "10368"},
+		{field: "FREQ_RX", source: "28.074 MHz", want: "28.074"},
+		{field: "FREQ", source: "27.185", want: "27.185"}, // not a ham band
+		{field: "FREQ", source: "fourteen", want: "fourteen"},
+		{field: "BAND", source: "20", want: "20m"},
+		{field: "BAND", source: "20M", want: "20m"},
+		{field: "BAND", source: "2 meters", want: "2m"},
+		{field: "BAND", source: "70", want: "70cm"},
+		{field: "BAND_RX", source: "1.25", want: "1.25m"},
+		{field: "BAND_RX", source: "23CM", want: "23cm"},
+		{field: "BAND", source: "21", want: "21"},
+		{field: "CONTEST_ID", source: "arrl-field-day", want: "ARRL-FIELD-DAY"},
+		{field: "CONTEST_ID", source: "ARRL Field Day", want: "ARRL-FIELD-DAY"},
+		{field: "CONTEST_ID", source: "ARRL-FD", want: "ARRL-FIELD-DAY"},
+		{field: "CONTEST_ID", source: "cq_ww_ssb", want: "CQ-WW-SSB"},
+		{field: "CONTEST_ID", source: "My Club Sprint", want: "My Club Sprint"},
+	}
+	for _, tc := range tests {
+		got, _ := runFixCSV(t, &FixContext{Fixes: allFixes}, tc.field, tc.source)
+		if got != tc.want {
+			t.Errorf("fix %s=%q got %q, want %q", tc.field, tc.source, got, tc.want)
+		}
+	}
+}
+
+func runFixRecords(t *testing.T, cctx *FixContext, input string) string {
+	t.Helper()
+	adi := adif.NewADIIO()
+	csv := adif.NewCSVIO()
+	out := &bytes.Buffer{}
+	cctx.report = &bytes.Buffer{}
+	ctx := &Context{
+		OutputFormat: adif.FormatADI,
+		Readers:      readers(adi, csv),
+		Writers:      writers(adi, csv),
+		Out:          out,
+		Prepare:      testPrepare("My Comment", "3.1.4", "fix test", "1.2.3"),
+		fs:           fakeFilesystem{map[string]string{"foo.csv": input}},
+		CommandCtx:   cctx,
+	}
+	if err := Fix.Run(ctx, []string{"foo.csv"}); err != nil {
+		t.Fatalf("Fix.Run(ctx, foo.csv) with %q got error %v", input, err)
+	}
+	return strings.TrimPrefix(out.String(), "My Comment\n<ADIF_VER:5>3.1.4 <PROGRAMID:8>fix test <PROGRAMVERSION:5>1.2.3 <EOH>\n")
+}
+
+func TestFixModeSubmode(t *testing.T) {
+	tests := []struct{ mode, submode, want string }{
+		{mode: "SSB", submode: "", want: "<MODE:3>SSB <SUBMODE:0> <EOR>\n"},
+		{mode: "ssb", submode: "usb", want: "<MODE:3>SSB <SUBMODE:3>USB <EOR>\n"},
+		{mode: "USB", submode: "", want: "<MODE:3>SSB <SUBMODE:3>USB <EOR>\n"},
+		{mode: "lsb", submode: "LSB", want: "<MODE:3>SSB <SUBMODE:3>LSB <EOR>\n"},
+		{mode: "USB", submode: "LSB", want: "<MODE:3>USB <SUBMODE:3>LSB <EOR>\n"},
+		{mode: "ft8", submode: "", want: "<MODE:3>FT8 <SUBMODE:0> <EOR>\n"},
+		{mode: "FT-8", submode: "", want: "<MODE:3>FT8 <SUBMODE:0> <EOR>\n"},
+		{mode: "FT 4", submode: "", want: "<MODE:4>MFSK <SUBMODE:3>FT4 <EOR>\n"},
+		{mode: "PSK31", submode: "", want: "<MODE:3>PSK <SUBMODE:5>PSK31 <EOR>\n"},
+		{mode: "MFSK", submode: "ft4", want: "<MODE:4>MFSK <SUBMODE:3>FT4 <EOR>\n"},
+		{mode: "SSB", submode: "ft4", want: "<MODE:3>SSB <SUBMODE:3>ft4 <EOR>\n"},
+		{mode: "VOICE", submode: "", want: "<MODE:5>VOICE <SUBMODE:0> <EOR>\n"},
+	}
+	for _, tc := range tests {
+		input := fmt.Sprintf("MODE,SUBMODE\n%s,%s\n", tc.mode, tc.submode)
+		if diff := cmp.Diff(tc.want, runFixRecords(t, &FixContext{Fixes: FieldList{FixMode}}, input)); diff != "" {
+			t.Errorf("fix MODE=%q SUBMODE=%q diff:\n%s", tc.mode, tc.submode, diff)
+		}
+	}
+}
+
+func TestFixCategories(t *testing.T) {
+	input := "CALL,QSO_DATE,BAND,MODE\nw1aw,2023-10-17,20,usb\n"
+	tests := []struct {
+		fixes FieldList
+		want  string
+	}{
+		{fixes: nil, want: "<CALL:4>w1aw <QSO_DATE:8>20231017 <BAND:2>20 <MODE:3>usb <EOR>\n"},
+		{fixes: FieldList{FixDate, FixCase, FixBand, FixMode}, want: "<CALL:4>W1AW <QSO_DATE:8>20231017 <BAND:3>20m <MODE:3>SSB <SUBMODE:3>USB <EOR>\n"},
+		{fixes: FieldList{FixDate}, want: "<CALL:4>w1aw <QSO_DATE:8>20231017 <BAND:2>20 <MODE:3>usb <EOR>\n"},
+		{fixes: FieldList{FixCase, FixBand}, want: "<CALL:4>W1AW <QSO_DATE:10>2023-10-17 <BAND:3>20m <MODE:3>usb <EOR>\n"},
+		{fixes: FieldList{FixMode}, want: "<CALL:4>w1aw <QSO_DATE:10>2023-10-17 <BAND:2>20 <MODE:3>SSB <SUBMODE:3>USB <EOR>\n"},
+	}
+	for _, tc := range tests {
+		if diff := cmp.Diff(tc.want, runFixRecords(t, &FixContext{Fixes: tc.fixes}, input)); diff != "" {
+			t.Errorf("fix --fixes=%s diff:\n%s", tc.fixes.String(), diff)
+		}
+	}
+	ctx := &Context{CommandCtx: &FixContext{Fixes: FieldList{"SPELLING"}}, fs: fakeFilesystem{map[string]string{}}}
+	if err := Fix.Run(ctx, []string{"foo.csv"}); err == nil {
+		t.Errorf("fix --fixes=SPELLING got no error")
+	}
+}
+
+func TestFixCommentLog(t *testing.T) {
+	input := "CALL,QSO_DATE,BAND,MODE,NAME\nW1AW,20231017,20,USB,Hiram\nK0A,20231017,20m,SSB,Santa\n"
+	want := "adif-multitool fixed value for BAND, MODE, SUBMODE <CALL:4>W1AW <QSO_DATE:8>20231017 <BAND:3>20m <MODE:3>SSB <NAME:5>Hiram <SUBMODE:3>USB <EOR>\n" +
+		"<CALL:3>K0A <QSO_DATE:8>20231017 <BAND:3>20m <MODE:3>SSB <NAME:5>Santa <EOR>\n"
+	if diff := cmp.Diff(want, runFixRecords(t, &FixContext{CommentLog: true, Fixes: FieldList{FixBand, FixMode}}, input)); diff != "" {
+		t.Errorf("fix --comment-log diff:\n%s", diff)
+	}
+}
+
+func BenchmarkFix(b *testing.B) {
+	benchmarkCommand(b, Fix, nil, benchmarkLog(10000, true))
+}
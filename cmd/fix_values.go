@@ -0,0 +1,259 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/flwyd/adif-multitool/adif"
+	"github.com/flwyd/adif-multitool/adif/spec"
+)
+
+// callsignFields are String fields which hold a callsign, conventionally
+// written in upper case.
+var callsignFields = map[string]bool{
+	spec.CallField.Name:            true,
+	spec.ContactedOpField.Name:     true,
+	spec.EqCallField.Name:          true,
+	spec.GuestOpField.Name:         true,
+	spec.OperatorField.Name:        true,
+	spec.OwnerCallsignField.Name:   true,
+	spec.StationCallsignField.Name: true,
+}
+
+func fixBoolean(b string) string {
+	switch strings.ToLower(strings.TrimSpace(b)) {
+	case "y", "yes", "t", "true", "1", "on":
+		return "Y"
+	case "n", "no", "f", "false", "0", "off":
+		return "N"
+	}
+	return b
+}
+
+// fixGridsquare changes a Maidenhead locator, or a comma-separated list of
+// them, to the conventional case: upper case field, lower case subsquare and
+// extended subsquare, e.g. FN31pr21rj.  offset is the position of the first
+// character, 8 for GRIDSQUARE_EXT.  Values with invalid characters are
+// returned unchanged.
+func fixGridsquare(g string, offset int) string {
+	grids := strings.Split(g, ",")
+	for i, s := range grids {
+		s = strings.TrimSpace(s)
+		var res strings.Builder
+		for j, c := range s {
+			pair := (j + offset) / 2
+			switch {
+			case pair == 0:
+				c = unicode.ToUpper(c)
+				if c < 'A' || c > 'R' {
+					return g
+				}
+			case pair%2 == 1:
+				if c < '0' || c > '9' {
+					return g
+				}
+			default:
+				c = unicode.ToLower(c)
+				if c < 'a' || c > 'x' {
+					return g
+				}
+			}
+			res.WriteRune(c)
+		}
+		grids[i] = res.String()
+	}
+	return strings.Join(grids, ",")
+}
+
+var freqUnitPat = regexp.MustCompile(`(?i)^([0-9]*\.?[0-9]+)\s*(hz|khz|mhz|ghz)?$`)
+
+// fixFrequency converts a frequency with a unit like "14074 kHz" or without a
+// unit, but which is in kHz or Hz (e.g. 14074 or 14074000), to MHz.  Values
+// without a unit are only converted if they are within an amateur band and
+// are not in a band as MHz.  If band is set, the frequency must be in that
+// band.
+func fixFrequency(f, band string) string {
+	m := freqUnitPat.FindStringSubmatch(strings.TrimSpace(f))
+	if m == nil {
+		return f
+	}
+	n, err := strconv.ParseFloat(m[1], 64)
+	if err != nil {
+		return f
+	}
+	band = fixBand(band)
+	switch strings.ToLower(m[2]) {
+	case "hz":
+		return formatNumber(n/1000000, 6)
+	case "khz":
+		return formatNumber(n/1000, 6)
+	case "mhz":
+		return formatNumber(n, 6)
+	case "ghz":
+		return formatNumber(n*1000, 6)
+	}
+	// bare numbers in the submillimeter band are more likely in Hz or kHz
+	if inBand(n, band) && (band != "" || n < 300000) {
+		return f
+	}
+	for _, div := range []float64{1000, 1000000} {
+		if inBand(n/div, band) {
+			return formatNumber(n/div, 6)
+		}
+	}
+	return f
+}
+
+// inBand returns true if mhz is within band, or within any band if band is
+// empty.
+func inBand(mhz float64, band string) bool {
+	vals := spec.BandEnumeration.Values
+	if band != "" {
+		vals = spec.BandEnumeration.Value(band)
+	}
+	for _, v := range vals {
+		b := v.(spec.BandEnum)
+		min, err := strconv.ParseFloat(b.LowerFreqMhz, 64)
+		if err != nil {
+			continue
+		}
+		max, err := strconv.ParseFloat(b.UpperFreqMhz, 64)
+		if err != nil {
+			continue
+		}
+		if min <= mhz && mhz <= max {
+			return true
+		}
+	}
+	return false
+}
+
+var bandMetersPat = regexp.MustCompile(`(?i)\s*(meters?|metres?)$`)
+
+// fixBand changes band names to the case in the ADIF specification and adds a
+// unit to bare numbers, trying meters before centimeters and millimeters, so
+// 20 is 20m and 70 is 70cm.
+func fixBand(b string) string {
+	s := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(b), " ", ""))
+	if s == "" {
+		return b
+	}
+	s = bandMetersPat.ReplaceAllString(s, "m")
+	cands := []string{s}
+	if _, err := strconv.ParseFloat(s, 64); err == nil {
+		cands = []string{s + "m", s + "cm", s + "mm"}
+	}
+	for _, c := range cands {
+		if v := spec.BandEnumeration.Value(c); len(v) > 0 {
+			return v[0].(spec.BandEnum).Band
+		}
+	}
+	return b
+}
+
+var contestIDSeparators = regexp.MustCompile(`[\s_]+`)
+
+// fixContestID changes a contest ID to the case in the ADIF specification,
+// replacing spaces and underscores with hyphens.  Cabrillo contest names like
+// ARRL-FD and contest descriptions like "ARRL Field Day" are also changed to
+// the ADIF contest ID.
+func fixContestID(c string) string {
+	s := strings.TrimSpace(c)
+	if s == "" {
+		return c
+	}
+	for _, k := range []string{s, contestIDSeparators.ReplaceAllString(s, "-")} {
+		if v := spec.ContestIdEnumeration.Value(k); len(v) > 0 {
+			return v[0].(spec.ContestIdEnum).ContestId
+		}
+	}
+	for _, t := range defaultCabrilloTemplates {
+		if strings.EqualFold(s, t.CabrilloName) {
+			return t.ContestID
+		}
+	}
+	for _, v := range spec.ContestIdEnumeration.Values {
+		if e := v.(spec.ContestIdEnum); strings.EqualFold(s, e.Description) {
+			return e.ContestId
+		}
+	}
+	return c
+}
+
+// modeKey ignores case and punctuation in modes like FT-8 and Olivia 8/250.
+func modeKey(m string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '-', '_':
+			return -1
+		}
+		return r
+	}, strings.ToUpper(strings.TrimSpace(m)))
+}
+
+func findMode(m string) (spec.ModeEnum, bool) {
+	k := modeKey(m)
+	for _, v := range spec.ModeEnumeration.Values {
+		if e := v.(spec.ModeEnum); e.ImportOnly == "" && modeKey(e.Mode) == k {
+			return e, true
+		}
+	}
+	return spec.ModeEnum{}, false
+}
+
+func findSubmode(m string) (spec.SubmodeEnum, bool) {
+	k := modeKey(m)
+	for _, v := range spec.SubmodeEnumeration.Values {
+		if e := v.(spec.SubmodeEnum); e.ImportOnly == "" && modeKey(e.Submode) == k {
+			return e, true
+		}
+	}
+	return spec.SubmodeEnum{}, false
+}
+
+// fixModeSubmode changes MODE and SUBMODE to values in the ADIF specification.
+// A MODE which is a submode, like USB or an import-only mode like PSK31, is
+// changed to the mode and submode (SSB and USB) unless SUBMODE is different.
+// Returns the names of fields which were changed.
+func fixModeSubmode(r *adif.Record) []string {
+	mode, sub := fieldValue(r, spec.ModeField.Name), fieldValue(r, spec.SubmodeField.Name)
+	newMode, newSub := mode, sub
+	if mode != "" {
+		if m, ok := findMode(mode); ok {
+			newMode = m.Mode
+		} else if s, ok := findSubmode(mode); ok && (sub == "" || modeKey(sub) == modeKey(s.Submode)) {
+			newMode, newSub = s.Mode, s.Submode
+		}
+	}
+	if sub != "" && newSub == sub {
+		if s, ok := findSubmode(sub); ok && (newMode == "" || newMode == s.Mode) {
+			newSub = s.Submode
+		}
+	}
+	var did []string
+	if newMode != mode {
+		r.Set(adif.Field{Name: spec.ModeField.Name, Value: newMode})
+		did = append(did, spec.ModeField.Name)
+	}
+	if newSub != sub {
+		r.Set(adif.Field{Name: spec.SubmodeField.Name, Value: newSub})
+		did = append(did, spec.SubmodeField.Name)
+	}
+	return did
+}
@@ -43,6 +43,45 @@ func (f *FieldList) Set(s string) error {
 	return nil
 }
 
+// FieldPair is a mapping from one field name to another, e.g. for renaming.
+type FieldPair struct{ From, To string }
+
+func (p FieldPair) String() string { return p.From + "=" + p.To }
+
+// FieldPairs is a flag with comma-separated or multiple instance old=new field
+// name pairs.
+type FieldPairs []FieldPair
+
+func (f *FieldPairs) String() string {
+	s := make([]string, len(*f))
+	for i, p := range *f {
+		s[i] = p.String()
+	}
+	return strings.Join(s, ",")
+}
+
+func (f *FieldPairs) Get() FieldPairs { return *f }
+
+func (f *FieldPairs) Set(s string) error {
+	for _, x := range strings.Split(s, ",") {
+		from, to, found := strings.Cut(x, "=")
+		if !found {
+			return fmt.Errorf(`expected "old=new", got %q`, x)
+		}
+		p := FieldPair{From: strings.ToUpper(strings.TrimSpace(from)), To: strings.ToUpper(strings.TrimSpace(to))}
+		for _, n := range []string{p.From, p.To} {
+			if !adifNamePat.MatchString(n) {
+				return fmt.Errorf("invalid ADIF field name %q in %q", n, x)
+			}
+		}
+		if p.From == p.To {
+			return fmt.Errorf("field %s mapped to itself", p.From)
+		}
+		*f = append(*f, p)
+	}
+	return nil
+}
+
 type UserdefFieldList []adif.UserdefField
 
 func (f *UserdefFieldList) String() string {
@@ -138,7 +177,11 @@ func (a *FieldAssignments) Get() FieldAssignments { return *a }
 
 func (f *FieldAssignments) Set(s string) error {
 	chunks := strings.Split(s, ";;")
-	a := NewFieldAssignments(ValidateAlphanumName)
+	validate := f.validate
+	if validate == nil {
+		validate = ValidateAlphanumName
+	}
+	a := NewFieldAssignments(validate)
 	for _, c := range chunks {
 		c = strings.TrimSpace(c)
 		key, val, found := strings.Cut(c, "=")
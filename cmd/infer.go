@@ -31,9 +31,33 @@ var Infer = Command{Name: "infer", Run: runInfer, Help: helpInfer,
 type InferContext struct {
 	Fields     FieldList
 	CommentLog bool
+	// CtyFile is a country prefix file in cty.dat or cty.csv format.
+	CtyFile string
+	// LongPath computes DISTANCE and ANT_AZ along the long great-circle path
+	// unless ANT_PATH is set.
+	LongPath bool
+	// BoundaryFile is a GeoJSON file with secondary subdivision boundaries.
+	BoundaryFile string
+	cty          *ctyDatabase
+	boundaries   *boundaryDatabase
 }
 
-type inferrer func(*adif.Record, string) bool
+type inferrer func(r *adif.Record, name string, cctx *InferContext) bool
+
+// ctyInferred is the set of fields which can only be inferred with a country
+// prefix file.
+var ctyInferred = map[string]bool{
+	spec.CqzField.Name:  true,
+	spec.ItuzField.Name: true,
+	spec.ContField.Name: true,
+}
+
+// boundaryInferred is the set of fields which can only be inferred with a
+// subdivision boundary file.
+var boundaryInferred = map[string]bool{
+	spec.CntyField.Name:   true,
+	spec.MyCntyField.Name: true,
+}
 
 var inferrers = map[string]inferrer{
 	spec.BandField.Name:            inferBand,
@@ -51,6 +75,13 @@ var inferrers = map[string]inferrer{
 	spec.MyLatField.Name:           inferLatLon,
 	spec.LonField.Name:             inferLatLon,
 	spec.MyLonField.Name:           inferLatLon,
+	spec.CqzField.Name:             inferFromCall,
+	spec.ItuzField.Name:            inferFromCall,
+	spec.ContField.Name:            inferFromCall,
+	spec.CntyField.Name:            inferCounty,
+	spec.MyCntyField.Name:          inferCounty,
+	spec.DistanceField.Name:        inferPath,
+	spec.AntAzField.Name:           inferPath,
 	spec.OperatorField.Name:        inferStation,
 	spec.StationCallsignField.Name: inferStation,
 	spec.OwnerCallsignField.Name:   inferStation,
@@ -77,6 +108,12 @@ func helpInfer() string {
 	fmt.Fprintf(res, fromfmt, spec.MyCountryField.Name, spec.MyDxccField.Name)
 	fmt.Fprintf(res, fromfmt, spec.DxccField.Name, spec.CountryField.Name)
 	fmt.Fprintf(res, fromfmt, spec.MyDxccField.Name, spec.MyCountryField.Name)
+	ctyfmt := "  %s from %s with --cty-file\n"
+	for _, f := range []spec.Field{spec.DxccField, spec.CountryField, spec.CqzField, spec.ItuzField, spec.ContField} {
+		fmt.Fprintf(res, ctyfmt, f.Name, spec.CallField.Name)
+	}
+	fmt.Fprintf(res, ctyfmt, spec.MyDxccField.Name, spec.StationCallsignField.Name)
+	fmt.Fprintf(res, ctyfmt, spec.MyCountryField.Name, spec.StationCallsignField.Name)
 
 	gsfmt := "  %s and %s from %s/%s\n"
 	fmt.Fprintf(res, gsfmt, spec.GridsquareField.Name, spec.GridsquareExtField.Name, spec.LatField.Name, spec.LonField.Name)
@@ -85,6 +122,17 @@ func helpInfer() string {
 	fmt.Fprintf(res, llfmt, spec.LatField.Name, spec.LonField.Name, spec.GridsquareField.Name, spec.GridsquareExtField.Name)
 	fmt.Fprintf(res, llfmt, spec.MyLatField.Name, spec.MyLonField.Name, spec.MyGridsquareField.Name, spec.MyGridsquareExtField.Name)
 
+	cntyfmt := "  %s from %s/%s with --boundary-file\n"
+	fmt.Fprintf(res, cntyfmt, spec.CntyField.Name, spec.LatField.Name, spec.LonField.Name)
+	fmt.Fprintf(res, cntyfmt, spec.MyCntyField.Name, spec.MyLatField.Name, spec.MyLonField.Name)
+	fmt.Fprintf(res, "    (only areas matching %s and %s if set)\n", spec.DxccField.Name, spec.StateField.Name)
+
+	pathfmt := "  %s from %s/%s or %s and %s/%s or %s\n"
+	for _, f := range []spec.Field{spec.DistanceField, spec.AntAzField} {
+		fmt.Fprintf(res, pathfmt, f.Name, spec.LatField.Name, spec.LonField.Name, spec.GridsquareField.Name, spec.MyLatField.Name, spec.MyLonField.Name, spec.MyGridsquareField.Name)
+	}
+	fmt.Fprintf(res, "    (long path if %s is %q or --long-path is set)\n", spec.AntPathField.Name, "L")
+
 	fmt.Fprintf(res, fromfmt, spec.OperatorField.Name, spec.GuestOpField.Name)
 	fmt.Fprintf(res, "  %s from %s or %s\n", spec.StationCallsignField.Name, spec.OperatorField.Name, spec.GuestOpField.Name)
 	fmt.Fprintf(res, "  %s from %s, %s, or %s\n", spec.OwnerCallsignField.Name, spec.StationCallsignField.Name, spec.OperatorField.Name, spec.GuestOpField.Name)
@@ -109,6 +157,7 @@ func helpInfer() string {
 		fmt.Fprintf(res, progfmt, p.field.Name, spec.SigInfoField.Name, spec.SigField.Name, p.prog)
 		fmt.Fprintf(res, progfmt, "MY_"+p.field.Name, spec.MySigInfoField.Name, spec.MySigField.Name, p.prog)
 	}
+	res.WriteString("\n" + helpStreamOutput("infer"))
 	return res.String()
 }
 
@@ -120,44 +169,52 @@ func runInfer(ctx *Context, args []string) error {
 		if inferrers[todo[i]] == nil {
 			return fmt.Errorf("don't know how to infer field %s\n%s", todo[i], helpInfer())
 		}
+		if ctyInferred[todo[i]] && cctx.CtyFile == "" {
+			return fmt.Errorf("inferring %s requires a country file, see --cty-file", todo[i])
+		}
+		if boundaryInferred[todo[i]] && cctx.BoundaryFile == "" {
+			return fmt.Errorf("inferring %s requires a boundary file, see --boundary-file", todo[i])
+		}
+	}
+	if cctx.CtyFile != "" {
+		db, err := readCtyFile(ctx, cctx.CtyFile)
+		if err != nil {
+			return err
+		}
+		cctx.cty = db
 	}
-	out := adif.NewLogfile()
-	acc := accumulator{Out: out, Ctx: ctx}
-	for _, f := range filesOrStdin(args) {
-		l, err := acc.read(f)
+	if cctx.BoundaryFile != "" {
+		db, err := readBoundaryFile(ctx, cctx.BoundaryFile)
 		if err != nil {
 			return err
 		}
-		updateFieldOrder(out, l.FieldOrder)
-		for _, r := range l.Records {
-			did := make([]string, 0, len(todo))
-			for _, t := range todo {
-				if inferrers[t] != nil {
-					if f, ok := r.Get(t); !ok || f.Value == "" {
-						if inferrers[t](r, t) {
-							did = append(did, t)
-						}
+		cctx.boundaries = db
+	}
+	s := recordStream{Ctx: ctx, Out: adif.NewLogfile()}
+	return s.run(args, func(r *adif.Record, _ *adif.Logfile, _ int) (*adif.Record, error) {
+		did := make([]string, 0, len(todo))
+		for _, t := range todo {
+			if inferrers[t] != nil {
+				if f, ok := r.Get(t); !ok || f.Value == "" {
+					if inferrers[t](r, t, cctx) {
+						did = append(did, t)
 					}
 				}
 			}
-			if cctx.CommentLog && len(did) > 0 {
-				c := "adif-multitool infered value for " + strings.Join(did, ", ")
-				if r.GetComment() == "" {
-					r.SetComment(c)
-				} else {
-					r.SetComment(r.GetComment() + "\n" + c)
-				}
+		}
+		if cctx.CommentLog && len(did) > 0 {
+			c := "adif-multitool infered value for " + strings.Join(did, ", ")
+			if r.GetComment() == "" {
+				r.SetComment(c)
+			} else {
+				r.SetComment(r.GetComment() + "\n" + c)
 			}
-			out.AddRecord(r)
 		}
-	}
-	if err := acc.prepare(); err != nil {
-		return err
-	}
-	return write(ctx, out)
+		return r, nil
+	})
 }
 
-func inferBand(r *adif.Record, name string) bool {
+func inferBand(r *adif.Record, name string, _ *InferContext) bool {
 	freqname := spec.FreqField.Name
 	if name == spec.BandRxField.Name {
 		freqname = spec.FreqRxField.Name
@@ -188,15 +245,22 @@ func inferBand(r *adif.Record, name string) bool {
 	return false
 }
 
-func inferCountry(r *adif.Record, name string) bool {
+func inferCountry(r *adif.Record, name string, cctx *InferContext) bool {
 	my := func(s string) string { return s }
 	if strings.HasPrefix(name, "MY_") {
 		my = func(s string) string { return "MY_" + s }
 	}
 	code, ok := r.Get(my(spec.DxccField.Name))
-	if !ok || code.Value == "" || code.Value == "0" {
+	if code.Value == "0" {
 		return false
 	}
+	if !ok || code.Value == "" {
+		e, ok := lookupCall(r, name, cctx)
+		if !ok || e.DXCC == "" {
+			return false
+		}
+		code = adif.Field{Name: my(spec.DxccField.Name), Value: e.DXCC}
+	}
 	for _, e := range spec.DxccEntityCodeEnumeration.Value(code.Value) {
 		ee := e.(spec.DxccEntityCodeEnum)
 		if ee.Deleted == "true" {
@@ -208,27 +272,94 @@ func inferCountry(r *adif.Record, name string) bool {
 	return false
 }
 
-func inferDxcc(r *adif.Record, name string) bool {
+func inferDxcc(r *adif.Record, name string, cctx *InferContext) bool {
 	my := func(s string) string { return s }
 	if strings.HasPrefix(name, "MY_") {
 		my = func(s string) string { return "MY_" + s }
 	}
-	c, ok := r.Get(my(spec.CountryField.Name))
-	if !ok || c.Value == "" {
-		return false
-	}
-	for _, e := range spec.CountryEnumeration.Value(c.Value) {
-		ee := e.(spec.CountryEnum)
-		if ee.Deleted == "true" {
-			continue
+	if c, ok := r.Get(my(spec.CountryField.Name)); ok && c.Value != "" {
+		for _, e := range spec.CountryEnumeration.Value(c.Value) {
+			ee := e.(spec.CountryEnum)
+			if ee.Deleted == "true" {
+				continue
+			}
+			r.Set(adif.Field{Name: name, Value: ee.EntityCode})
+			return true
 		}
-		r.Set(adif.Field{Name: name, Value: ee.EntityCode})
+	}
+	if e, ok := lookupCall(r, name, cctx); ok && e.DXCC != "" {
+		r.Set(adif.Field{Name: name, Value: e.DXCC})
 		return true
 	}
 	return false
 }
 
-func inferMode(r *adif.Record, name string) bool {
+func inferFromCall(r *adif.Record, name string, cctx *InferContext) bool {
+	e, ok := lookupCall(r, name, cctx)
+	if !ok {
+		return false
+	}
+	var v string
+	switch name {
+	case spec.CqzField.Name:
+		v = strconv.Itoa(e.CQZone)
+	case spec.ItuzField.Name:
+		v = strconv.Itoa(e.ITUZone)
+	case spec.ContField.Name:
+		v = e.Continent
+	}
+	if v == "" || v == "0" {
+		return false
+	}
+	r.Set(adif.Field{Name: name, Value: v})
+	return true
+}
+
+// lookupCall finds the country file entity for CALL or, if name starts with
+// MY_, STATION_CALLSIGN.
+func lookupCall(r *adif.Record, name string, cctx *InferContext) (ctyEntity, bool) {
+	if cctx == nil || cctx.cty == nil {
+		return ctyEntity{}, false
+	}
+	call := spec.CallField.Name
+	if strings.HasPrefix(name, "MY_") {
+		call = spec.StationCallsignField.Name
+	}
+	c, ok := r.Get(call)
+	if !ok || c.Value == "" {
+		return ctyEntity{}, false
+	}
+	return cctx.cty.lookup(c.Value)
+}
+
+func inferCounty(r *adif.Record, name string, cctx *InferContext) bool {
+	if cctx == nil || cctx.boundaries == nil {
+		return false
+	}
+	my := func(s string) string { return s }
+	if strings.HasPrefix(name, "MY_") {
+		my = func(s string) string { return "MY_" + s }
+	}
+	lat, _ := r.Get(my(spec.LatField.Name))
+	lon, _ := r.Get(my(spec.LonField.Name))
+	if lat.Value == "" || lon.Value == "" {
+		return false
+	}
+	la, lo, err := parseADIFCoordinates(lat.Value, lon.Value)
+	if err != nil {
+		return false
+	}
+	dxcc, _ := r.Get(my(spec.DxccField.Name))
+	state, _ := r.Get(my(spec.StateField.Name))
+	a, ok := cctx.boundaries.lookup(la, lo, strings.TrimSpace(dxcc.Value), strings.TrimSpace(state.Value))
+	if !ok {
+		return false
+	}
+	r.Set(adif.Field{Name: name, Value: a.cnty})
+	return true
+}
+
+func inferMode(r *adif.Record, name string, _ *InferContext) bool {
 	s, ok := r.Get(spec.SubmodeField.Name)
 	if !ok || s.Value == "" {
 		return false
@@ -241,7 +372,7 @@ func inferMode(r *adif.Record, name string) bool {
 	return false
 }
 
-func inferSigInfo(r *adif.Record, name string) bool {
+func inferSigInfo(r *adif.Record, name string, _ *InferContext) bool {
 	my := func(s string) string { return s }
 	if strings.HasPrefix(name, "MY_") {
 		my = func(s string) string { return "MY_" + s }
@@ -304,7 +435,7 @@ func inferSigInfo(r *adif.Record, name string) bool {
 }
 
 func inferProgramRef(wantSig string) inferrer {
-	return func(r *adif.Record, name string) bool {
+	return func(r *adif.Record, name string, _ *InferContext) bool {
 		my := func(s string) string { return s }
 		if strings.HasPrefix(name, "MY_") {
 			my = func(s string) string { return "MY_" + s }
@@ -329,7 +460,7 @@ func inferProgramRef(wantSig string) inferrer {
 	}
 }
 
-func inferStation(r *adif.Record, name string) bool {
+func inferStation(r *adif.Record, name string, _ *InferContext) bool {
 	var order []spec.Field
 	if name == spec.OperatorField.Name {
 		order = []spec.Field{spec.GuestOpField}
@@ -347,7 +478,7 @@ func inferStation(r *adif.Record, name string) bool {
 	return false
 }
 
-func inferLatLon(r *adif.Record, name string) bool {
+func inferLatLon(r *adif.Record, name string, _ *InferContext) bool {
 	my := func(s string) string { return s }
 	if strings.HasPrefix(name, "MY_") {
 		my = func(s string) string { return "MY_" + s }
@@ -383,7 +514,105 @@ func inferLatLon(r *adif.Record, name string) bool {
 	return false
 }
 
-func inferGridsquare(r *adif.Record, name string) bool {
+// earthRadiusKm is the mean radius of the Earth.
+const earthRadiusKm = 6371.0
+
+func inferPath(r *adif.Record, name string, cctx *InferContext) bool {
+	lat1, lon1, ok := recordLocation(r, "MY_")
+	if !ok {
+		return false
+	}
+	lat2, lon2, ok := recordLocation(r, "")
+	if !ok {
+		return false
+	}
+	long := cctx != nil && cctx.LongPath
+	if p, ok := r.Get(spec.AntPathField.Name); ok && p.Value != "" {
+		long = strings.EqualFold(p.Value, "L")
+	}
+	dist, az := greatCircle(lat1, lon1, lat2, lon2)
+	if dist == 0 {
+		// same location, no meaningful azimuth or long path
+		if name != spec.DistanceField.Name {
+			return false
+		}
+		long = false
+	}
+	if long {
+		dist = 2*math.Pi*earthRadiusKm - dist
+		az = math.Mod(az+180, 360)
+	}
+	var v string
+	switch name {
+	case spec.DistanceField.Name:
+		v = formatNumber(dist, 1)
+	case spec.AntAzField.Name:
+		v = formatNumber(math.Mod(math.Round(az), 360), 0)
+	default:
+		return false
+	}
+	f := spec.Fields[name]
+	if spec.ValidateNumber(v, f, spec.ValidationContext{}).Validity != spec.Valid {
+		return false
+	}
+	r.Set(adif.Field{Name: name, Value: v})
+	return true
+}
+
+// recordLocation returns the coordinates of a station from LAT/LON if present
+// or GRIDSQUARE and GRIDSQUARE_EXT otherwise, with prefix "MY_" for the
+// logging station.
+func recordLocation(r *adif.Record, prefix string) (lat, lon float64, ok bool) {
+	la, laok := r.Get(prefix + spec.LatField.Name)
+	lo, look := r.Get(prefix + spec.LonField.Name)
+	if laok && look && la.Value != "" && lo.Value != "" {
+		if lat, lon, err := parseADIFCoordinates(la.Value, lo.Value); err == nil {
+			return lat, lon, true
+		}
+	}
+	gs, ok := r.Get(prefix + spec.GridsquareField.Name)
+	if !ok || gs.Value == "" {
+		return 0, 0, false
+	}
+	g := gs.Value
+	if ext, ok := r.Get(prefix + spec.GridsquareExtField.Name); ok {
+		g += ext.Value
+	}
+	lat, lon, err := parseMaidenhead(g)
+	if err != nil {
+		return 0, 0, false
+	}
+	return lat, lon, true
+}
+
+// greatCircle returns the short path distance in kilometers and initial
+// bearing in degrees from the first point to the second.
+func greatCircle(lat1, lon1, lat2, lon2 float64) (dist, azimuth float64) {
+	rad := math.Pi / 180
+	phi1, phi2 := lat1*rad, lat2*rad
+	dphi, dlambda := (lat2-lat1)*rad, (lon2-lon1)*rad
+	a := math.Pow(math.Sin(dphi/2), 2) + math.Cos(phi1)*math.Cos(phi2)*math.Pow(math.Sin(dlambda/2), 2)
+	dist = 2 * earthRadiusKm * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+	y := math.Sin(dlambda) * math.Cos(phi2)
+	x := math.Cos(phi1)*math.Sin(phi2) - math.Sin(phi1)*math.Cos(phi2)*math.Cos(dlambda)
+	azimuth = math.Mod(math.Atan2(y, x)/rad+360, 360)
+	return
+}
+
+// formatNumber formats v with at most decimals digits after the decimal point
+// and no trailing zeros, as the ADIF Number type does not allow exponents.
+func formatNumber(v float64, decimals int) string {
+	s := strconv.FormatFloat(v, 'f', decimals, 64)
+	if strings.Contains(s, ".") {
+		s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	}
+	if s == "-0" {
+		s = "0"
+	}
+	return s
+}
+
+func inferGridsquare(r *adif.Record, name string, _ *InferContext) bool {
 	my := func(s string) string { return s }
 	if strings.HasPrefix(name, "MY_") {
 		my = func(s string) string { return "MY_" + s }
@@ -404,6 +633,17 @@ func inferGridsquare(r *adif.Record, name string) bool {
 		fmt.Println(err)
 		return false
 	}
+	gs := maidenheadLocator(lat, lon)
+	if strings.HasSuffix(name, "_EXT") {
+		r.Set(adif.Field{Name: name, Value: gs[8:]})
+	} else {
+		r.Set(adif.Field{Name: name, Value: gs[0:8]})
+	}
+	return true
+}
+
+// maidenheadLocator returns the 12-character Maidenhead locator for lat, lon.
+func maidenheadLocator(lat, lon float64) string {
 	// Maidenhead locator uses positive values from south pole and antiprime meridian
 	lat += 90
 	lon += 180
@@ -428,12 +668,7 @@ func inferGridsquare(r *adif.Record, name string) bool {
 	// fifth pair is divided into 10 digits, 0.12" longitude (≈3.6m), 0.0625" latitude (≈1.9m)
 	gs.WriteRune('0' + rune(lons.split(10)))
 	gs.WriteRune('0' + rune(lats.split(10)))
-	if strings.HasSuffix(name, "_EXT") {
-		r.Set(adif.Field{Name: name, Value: gs.String()[8:]})
-	} else {
-		r.Set(adif.Field{Name: name, Value: gs.String()[0:8]})
-	}
-	return true
+	return gs.String()
 }
 
 type maidenheadSlice struct{ rem, scale float64 }
@@ -16,6 +16,7 @@ package cmd
 
 import (
 	"bytes"
+	"strings"
 	"testing"
 
 	"github.com/flwyd/adif-multitool/adif"
@@ -306,7 +307,7 @@ func TestInfer(t *testing.T) {
 				"BAND", "BAND_RX", "MODE",
 				"DXCC", "MY_DXCC", "COUNTRY", "MY_COUNTRY",
 				"GRIDSQUARE", "GRIDSQUARE_EXT", "MY_GRIDSQUARE", "MY_GRIDSQUARE_EXT",
-				"LAT", "LON", "MY_LAT", "MY_LON",
+				"LAT", "LON", "MY_LAT", "MY_LON", "DISTANCE", "ANT_AZ",
 				"OPERATOR", "STATION_CALLSIGN", "OWNER_CALLSIGN",
 				"SIG_INFO", "IOTA", "POTA_REF", "SOTA_REF", "WWFF_REF",
 				"MY_SIG_INFO", "MY_IOTA", "MY_POTA_REF", "MY_SOTA_REF", "MY_WWFF_REF",
@@ -352,3 +353,359 @@ func TestInfer(t *testing.T) {
 		})
 	}
 }
+
+func TestInferFromCty(t *testing.T) {
+	adi := adif.NewADIIO()
+	tests := []struct {
+		name  string
+		infer FieldList
+		start []adif.Field
+		want  []adif.Field
+	}{
+		{
+			name:  "dxcc and zones from call",
+			infer: FieldList{"DXCC", "CQZ", "ITUZ", "CONT"},
+			start: []adif.Field{{Name: "CALL", Value: "KH6/W1AW"}},
+			want: []adif.Field{{Name: "CALL", Value: "KH6/W1AW"},
+				{Name: "DXCC", Value: "110"}, {Name: "CQZ", Value: "31"}, {Name: "ITUZ", Value: "61"}, {Name: "CONT", Value: "OC"}},
+		},
+		{
+			name:  "country from call",
+			infer: FieldList{"COUNTRY"},
+			start: []adif.Field{{Name: "CALL", Value: "DL1ABC/P"}},
+			want:  []adif.Field{{Name: "CALL", Value: "DL1ABC/P"}, {Name: "COUNTRY", Value: "FEDERAL REPUBLIC OF GERMANY"}},
+		},
+		{
+			name:  "WAE entity uses parent DXCC",
+			infer: FieldList{"DXCC", "COUNTRY"},
+			start: []adif.Field{{Name: "CALL", Value: "IT9XYZ"}},
+			want:  []adif.Field{{Name: "CALL", Value: "IT9XYZ"}, {Name: "DXCC", Value: "248"}, {Name: "COUNTRY", Value: "ITALY"}},
+		},
+		{
+			name:  "exact call override",
+			infer: FieldList{"CQZ", "ITUZ"},
+			start: []adif.Field{{Name: "CALL", Value: "K1ABC"}},
+			want:  []adif.Field{{Name: "CALL", Value: "K1ABC"}, {Name: "CQZ", Value: "4"}, {Name: "ITUZ", Value: "7"}},
+		},
+		{
+			name:  "my_dxcc from station_callsign",
+			infer: FieldList{"MY_DXCC", "MY_COUNTRY"},
+			start: []adif.Field{{Name: "CALL", Value: "KH6ABC"}, {Name: "STATION_CALLSIGN", Value: "W1AW"}},
+			want: []adif.Field{{Name: "CALL", Value: "KH6ABC"}, {Name: "STATION_CALLSIGN", Value: "W1AW"},
+				{Name: "MY_DXCC", Value: "291"}, {Name: "MY_COUNTRY", Value: "UNITED STATES OF AMERICA"}},
+		},
+		{
+			name:  "dxcc from country takes precedence",
+			infer: FieldList{"DXCC"},
+			start: []adif.Field{{Name: "CALL", Value: "W1AW"}, {Name: "COUNTRY", Value: "Alaska"}},
+			want:  []adif.Field{{Name: "CALL", Value: "W1AW"}, {Name: "COUNTRY", Value: "Alaska"}, {Name: "DXCC", Value: "6"}},
+		},
+		{
+			name:  "country from dxcc takes precedence",
+			infer: FieldList{"COUNTRY"},
+			start: []adif.Field{{Name: "CALL", Value: "W1AW"}, {Name: "DXCC", Value: "110"}},
+			want:  []adif.Field{{Name: "CALL", Value: "W1AW"}, {Name: "DXCC", Value: "110"}, {Name: "COUNTRY", Value: "HAWAII"}},
+		},
+		{
+			name:  "maritime mobile",
+			infer: FieldList{"DXCC", "COUNTRY", "CQZ", "ITUZ", "CONT"},
+			start: []adif.Field{{Name: "CALL", Value: "W1AW/MM"}},
+			want:  []adif.Field{{Name: "CALL", Value: "W1AW/MM"}},
+		},
+		{
+			name:  "unknown entity has zones but no DXCC",
+			infer: FieldList{"DXCC", "COUNTRY", "CQZ", "CONT"},
+			start: []adif.Field{{Name: "CALL", Value: "ZZ1Z"}},
+			want:  []adif.Field{{Name: "CALL", Value: "ZZ1Z"}, {Name: "CQZ", Value: "99"}, {Name: "CONT", Value: "AF"}},
+		},
+	}
+
+	for _, ctyFile := range []string{"cty.dat", "cty.csv"} {
+		for _, tc := range tests {
+			if ctyFile == "cty.csv" && strings.HasPrefix(tc.name, "unknown entity") {
+				continue // CSV has explicit DXCC codes
+			}
+			t.Run(ctyFile+" "+tc.name, func(t *testing.T) {
+				in := &bytes.Buffer{}
+				lin := adif.NewLogfile()
+				lin.AddRecord(adif.NewRecord(tc.start...))
+				if err := adi.Write(lin, in); err != nil {
+					t.Fatalf("Error writing fields %v: %v", tc.start, err)
+				}
+				out := &bytes.Buffer{}
+				ctx := &Context{
+					InputFormat:  adif.FormatADI,
+					OutputFormat: adif.FormatADI,
+					Readers:      readers(adi),
+					Writers:      writers(adi),
+					Out:          out,
+					fs: fakeFilesystem{map[string]string{"foo.adi": in.String(),
+						"cty.dat": testCtyDat, "cty.csv": testCtyCSV + "DL,Fed. Rep. of Germany,230,EU,14,28,51.00,-10.00,-1.0,DL;\n"}},
+					CommandCtx: &InferContext{Fields: tc.infer, CtyFile: ctyFile}}
+				if err := Infer.Run(ctx, []string{"foo.adi"}); err != nil {
+					t.Fatalf("Infer(%s) got error %v", in.String(), err)
+				}
+				l, err := adi.Read(out)
+				if err != nil {
+					t.Fatalf("Read(%s) got error: %v", out.String(), err)
+				}
+				if len(l.Records) != 1 {
+					t.Fatalf("Read(%s) got %d records, want 1", out.String(), len(l.Records))
+				}
+				if want := adif.NewRecord(tc.want...); !want.Equal(l.Records[0]) {
+					t.Errorf("infer %v from %v got %v, want %v", tc.infer, tc.start, l.Records[0], want)
+				}
+			})
+		}
+	}
+}
+
+func TestInferCtyRequired(t *testing.T) {
+	adi := adif.NewADIIO()
+	ctx := &Context{
+		InputFormat:  adif.FormatADI,
+		OutputFormat: adif.FormatADI,
+		Readers:      readers(adi),
+		Writers:      writers(adi),
+		Out:          &bytes.Buffer{},
+		fs:           fakeFilesystem{map[string]string{"foo.adi": "<EOH>\n<CALL:4>W1AW<EOR>\n"}},
+		CommandCtx:   &InferContext{Fields: FieldList{"CQZ"}}}
+	if err := Infer.Run(ctx, []string{"foo.adi"}); err == nil {
+		t.Errorf("Infer CQZ without a country file got no error")
+	}
+	ctx.CommandCtx = &InferContext{Fields: FieldList{"DXCC"}, CtyFile: "missing.dat"}
+	if err := Infer.Run(ctx, []string{"foo.adi"}); err == nil {
+		t.Errorf("Infer with missing country file got no error")
+	}
+}
+
+func TestInferPath(t *testing.T) {
+	adi := adif.NewADIIO()
+	grids := []adif.Field{{Name: "MY_GRIDSQUARE", Value: "FN31pr"}, {Name: "GRIDSQUARE", Value: "IO91wm"}}
+	coords := []adif.Field{
+		{Name: "MY_LAT", Value: "N041 42.852"}, {Name: "MY_LON", Value: "W072 43.668"},
+		{Name: "LAT", Value: "S033 52.000"}, {Name: "LON", Value: "E151 12.500"},
+		{Name: "MY_GRIDSQUARE", Value: "FN31pr"}, {Name: "GRIDSQUARE", Value: "IO91wm"}}
+	with := func(base []adif.Field, fs ...adif.Field) []adif.Field {
+		return append(append([]adif.Field{}, base...), fs...)
+	}
+	tests := []struct {
+		name     string
+		longPath bool
+		start    []adif.Field
+		want     []adif.Field
+	}{
+		{
+			name:  "from gridsquares",
+			start: grids,
+			want:  with(grids, adif.Field{Name: "DISTANCE", Value: "5414.7"}, adif.Field{Name: "ANT_AZ", Value: "52"}),
+		},
+		{
+			name:  "long path from ANT_PATH",
+			start: with(grids, adif.Field{Name: "ANT_PATH", Value: "L"}),
+			want: with(grids, adif.Field{Name: "ANT_PATH", Value: "L"},
+				adif.Field{Name: "DISTANCE", Value: "34615.5"}, adif.Field{Name: "ANT_AZ", Value: "232"}),
+		},
+		{
+			name:  "lat/lon preferred to gridsquare",
+			start: coords,
+			want:  with(coords, adif.Field{Name: "DISTANCE", Value: "16101.1"}, adif.Field{Name: "ANT_AZ", Value: "268"}),
+		},
+		{
+			name:     "long path option",
+			longPath: true,
+			start:    coords,
+			want:     with(coords, adif.Field{Name: "DISTANCE", Value: "23929"}, adif.Field{Name: "ANT_AZ", Value: "88"}),
+		},
+		{
+			name:     "ANT_PATH overrides long path option",
+			longPath: true,
+			start:    with(grids, adif.Field{Name: "ANT_PATH", Value: "S"}),
+			want: with(grids, adif.Field{Name: "ANT_PATH", Value: "S"},
+				adif.Field{Name: "DISTANCE", Value: "5414.7"}, adif.Field{Name: "ANT_AZ", Value: "52"}),
+		},
+		{
+			name:  "same location",
+			start: []adif.Field{{Name: "MY_GRIDSQUARE", Value: "FN31"}, {Name: "GRIDSQUARE", Value: "FN31"}},
+			want:  []adif.Field{{Name: "MY_GRIDSQUARE", Value: "FN31"}, {Name: "GRIDSQUARE", Value: "FN31"}, {Name: "DISTANCE", Value: "0"}},
+		},
+		{
+			name:  "missing logging station location",
+			start: []adif.Field{{Name: "GRIDSQUARE", Value: "IO91wm"}},
+			want:  []adif.Field{{Name: "GRIDSQUARE", Value: "IO91wm"}},
+		},
+		{
+			name:  "invalid gridsquare",
+			start: []adif.Field{{Name: "MY_GRIDSQUARE", Value: "FN3"}, {Name: "GRIDSQUARE", Value: "IO91wm"}},
+			want:  []adif.Field{{Name: "MY_GRIDSQUARE", Value: "FN3"}, {Name: "GRIDSQUARE", Value: "IO91wm"}},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			in := &bytes.Buffer{}
+			lin := adif.NewLogfile()
+			lin.AddRecord(adif.NewRecord(tc.start...))
+			if err := adi.Write(lin, in); err != nil {
+				t.Fatalf("Error writing fields %v: %v", tc.start, err)
+			}
+			out := &bytes.Buffer{}
+			ctx := &Context{
+				InputFormat:  adif.FormatADI,
+				OutputFormat: adif.FormatADI,
+				Readers:      readers(adi),
+				Writers:      writers(adi),
+				Out:          out,
+				fs:           fakeFilesystem{map[string]string{"foo.adi": in.String()}},
+				CommandCtx:   &InferContext{Fields: FieldList{"DISTANCE", "ANT_AZ"}, LongPath: tc.longPath}}
+			if err := Infer.Run(ctx, []string{"foo.adi"}); err != nil {
+				t.Fatalf("Infer(%s) got error %v", in.String(), err)
+			}
+			l, err := adi.Read(out)
+			if err != nil {
+				t.Fatalf("Read(%s) got error: %v", out.String(), err)
+			}
+			if len(l.Records) != 1 {
+				t.Fatalf("Read(%s) got %d records, want 1", out.String(), len(l.Records))
+			}
+			if want := adif.NewRecord(tc.want...); !want.Equal(l.Records[0]) {
+				t.Errorf("infer from %v got %v, want %v", tc.start, l.Records[0], want)
+			}
+		})
+	}
+}
+
+// testBoundaries has two adjacent squares in the US, the west one with a hole
+// which is a separate county, and a square in Japan.
+const testBoundaries = `{"type": "FeatureCollection", "features": [
+{"type": "Feature", "properties": {"CNTY": "CO,West", "STATE": "CO", "DXCC": 291},
+ "geometry": {"type": "Polygon", "coordinates": [
+  [[-106, 39], [-105, 39], [-105, 40], [-106, 40], [-106, 39]],
+  [[-105.8, 39.2], [-105.6, 39.2], [-105.6, 39.4], [-105.8, 39.4], [-105.8, 39.2]]]}},
+{"type": "Feature", "properties": {"CNTY": "CO,Hole", "STATE": "CO", "DXCC": 291},
+ "geometry": {"type": "Polygon", "coordinates": [
+  [[-105.8, 39.2], [-105.6, 39.2], [-105.6, 39.4], [-105.8, 39.4], [-105.8, 39.2]]]}},
+{"type": "Feature", "properties": {"cnty": "CO,East", "state": "CO"},
+ "geometry": {"type": "MultiPolygon", "coordinates": [
+  [[[-105, 39], [-104, 39], [-104, 40], [-105, 40], [-105, 39]]],
+  [[[-103, 39], [-102, 39], [-102, 40], [-103, 40], [-103, 39]]]]}},
+{"type": "Feature", "properties": {"CNTY": "100101", "STATE": "10", "DXCC": "339"},
+ "geometry": {"type": "Polygon", "coordinates": [
+  [[139, 35], [140, 35], [140, 36], [139, 36], [139, 35]]]}}
+]}`
+
+func TestInferCounty(t *testing.T) {
+	adi := adif.NewADIIO()
+	tests := []struct {
+		name  string
+		infer FieldList
+		start []adif.Field
+		want  []adif.Field
+	}{
+		{
+			name:  "west county",
+			infer: FieldList{"CNTY"},
+			start: []adif.Field{{Name: "LAT", Value: "N039 30.000"}, {Name: "LON", Value: "W105 30.000"}},
+			want:  []adif.Field{{Name: "LAT", Value: "N039 30.000"}, {Name: "LON", Value: "W105 30.000"}, {Name: "CNTY", Value: "CO,West"}},
+		},
+		{
+			name:  "county in hole",
+			infer: FieldList{"MY_CNTY"},
+			start: []adif.Field{{Name: "MY_LAT", Value: "N039 18.000"}, {Name: "MY_LON", Value: "W105 42.000"}},
+			want:  []adif.Field{{Name: "MY_LAT", Value: "N039 18.000"}, {Name: "MY_LON", Value: "W105 42.000"}, {Name: "MY_CNTY", Value: "CO,Hole"}},
+		},
+		{
+			name:  "second polygon of multipolygon",
+			infer: FieldList{"CNTY"},
+			start: []adif.Field{{Name: "DXCC", Value: "291"}, {Name: "LAT", Value: "N039 30.000"}, {Name: "LON", Value: "W102 30.000"}},
+			want:  []adif.Field{{Name: "DXCC", Value: "291"}, {Name: "LAT", Value: "N039 30.000"}, {Name: "LON", Value: "W102 30.000"}, {Name: "CNTY", Value: "CO,East"}},
+		},
+		{
+			name:  "japan",
+			infer: FieldList{"CNTY"},
+			start: []adif.Field{{Name: "LAT", Value: "N035 30.000"}, {Name: "LON", Value: "E139 30.000"}},
+			want:  []adif.Field{{Name: "LAT", Value: "N035 30.000"}, {Name: "LON", Value: "E139 30.000"}, {Name: "CNTY", Value: "100101"}},
+		},
+		{
+			name:  "between polygons",
+			infer: FieldList{"CNTY"},
+			start: []adif.Field{{Name: "LAT", Value: "N039 30.000"}, {Name: "LON", Value: "W103 30.000"}},
+			want:  []adif.Field{{Name: "LAT", Value: "N039 30.000"}, {Name: "LON", Value: "W103 30.000"}},
+		},
+		{
+			name:  "state mismatch",
+			infer: FieldList{"CNTY"},
+			start: []adif.Field{{Name: "STATE", Value: "UT"}, {Name: "LAT", Value: "N039 30.000"}, {Name: "LON", Value: "W105 30.000"}},
+			want:  []adif.Field{{Name: "STATE", Value: "UT"}, {Name: "LAT", Value: "N039 30.000"}, {Name: "LON", Value: "W105 30.000"}},
+		},
+		{
+			name:  "no location",
+			infer: FieldList{"CNTY"},
+			start: []adif.Field{{Name: "GRIDSQUARE", Value: "DM79"}},
+			want:  []adif.Field{{Name: "GRIDSQUARE", Value: "DM79"}},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			in := &bytes.Buffer{}
+			lin := adif.NewLogfile()
+			lin.AddRecord(adif.NewRecord(tc.start...))
+			if err := adi.Write(lin, in); err != nil {
+				t.Fatalf("Error writing fields %v: %v", tc.start, err)
+			}
+			out := &bytes.Buffer{}
+			ctx := &Context{
+				InputFormat:  adif.FormatADI,
+				OutputFormat: adif.FormatADI,
+				Readers:      readers(adi),
+				Writers:      writers(adi),
+				Out:          out,
+				fs:           fakeFilesystem{map[string]string{"foo.adi": in.String(), "counties.geojson": testBoundaries}},
+				CommandCtx:   &InferContext{Fields: tc.infer, BoundaryFile: "counties.geojson"}}
+			if err := Infer.Run(ctx, []string{"foo.adi"}); err != nil {
+				t.Fatalf("Infer(%s) got error %v", in.String(), err)
+			}
+			l, err := adi.Read(out)
+			if err != nil {
+				t.Fatalf("Read(%s) got error: %v", out.String(), err)
+			}
+			if len(l.Records) != 1 {
+				t.Fatalf("Read(%s) got %d records, want 1", out.String(), len(l.Records))
+			}
+			if want := adif.NewRecord(tc.want...); !want.Equal(l.Records[0]) {
+				t.Errorf("infer %v from %v got %v, want %v", tc.infer, tc.start, l.Records[0], want)
+			}
+		})
+	}
+}
+
+func TestInferBoundaryErrors(t *testing.T) {
+	adi := adif.NewADIIO()
+	for _, tc := range []struct {
+		name, file string
+	}{
+		{name: "no boundary file"},
+		{name: "missing boundary file", file: "missing.geojson"},
+		{name: "not a feature collection", file: "point.geojson"},
+		{name: "feature without CNTY", file: "nocnty.geojson"},
+	} {
+		ctx := &Context{
+			InputFormat:  adif.FormatADI,
+			OutputFormat: adif.FormatADI,
+			Readers:      readers(adi),
+			Writers:      writers(adi),
+			Out:          &bytes.Buffer{},
+			fs: fakeFilesystem{map[string]string{"foo.adi": "<EOH>\n<CALL:4>W1AW<EOR>\n",
+				"point.geojson":  `{"type": "Point", "coordinates": [1, 2]}`,
+				"nocnty.geojson": `{"type": "FeatureCollection", "features": [{"type": "Feature", "properties": {"STATE": "CO"}, "geometry": {"type": "Polygon", "coordinates": []}}]}`}},
+			CommandCtx: &InferContext{Fields: FieldList{"CNTY"}, BoundaryFile: tc.file}}
+		if err := Infer.Run(ctx, []string{"foo.adi"}); err == nil {
+			t.Errorf("Infer CNTY with %s got no error", tc.name)
+		}
+	}
+}
+
+func BenchmarkInfer(b *testing.B) {
+	log := benchmarkLog(10000, false)
+	cctx := &InferContext{Fields: FieldList{"COUNTRY", "GRIDSQUARE", "MY_LAT", "MY_LON", "DISTANCE", "ANT_AZ", "POTA_REF", "OPERATOR"}}
+	benchmarkCommand(b, Infer, cctx, log)
+}
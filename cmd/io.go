@@ -16,28 +16,43 @@ package cmd
 
 import (
 	"bufio"
+	"crypto/rand"
+	"encoding/binary"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 
 	"github.com/flwyd/adif-multitool/adif"
 )
 
 func write(ctx *Context, l *adif.Logfile) error {
+	if ctx.pipe != nil && ctx.pipe.capture {
+		ctx.pipe.out = l
+		return nil
+	}
 	if ctx.Prepare != nil {
 		ctx.Prepare(l)
 	}
+	w, err := outputWriter(ctx)
+	if err != nil {
+		return err
+	}
+	return w.Write(l, ctx.Out)
+}
+
+func outputWriter(ctx *Context) (adif.Writer, error) {
 	format := ctx.OutputFormat
 	if !format.IsValid() {
 		format = adif.FormatADI
 	}
 	w, ok := ctx.Writers[format]
 	if !ok {
-		return fmt.Errorf("unknown output format %q", format)
+		return nil, fmt.Errorf("unknown output format %q", format)
 	}
-	return w.Write(l, ctx.Out)
+	return w, nil
 }
 
 func filesOrStdin(args []string) []string {
@@ -47,16 +62,39 @@ func filesOrStdin(args []string) []string {
 	return args
 }
 
+func isStdin(filename string) bool {
+	return filename == "-" || filename == os.Stdin.Name()
+}
+
 func readFile(ctx *Context, filename string) (*adif.Logfile, error) {
+	if ctx.pipe != nil && ctx.pipe.in != nil && isStdin(filename) {
+		return ctx.pipe.input()
+	}
+	f, ior, format, err := openFile(ctx, filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r := ctx.Readers[format]
+	l, err := r.Read(ior)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", f.Name(), err)
+	}
+	l.Filename = f.Name()
+	return l, nil
+}
+
+// openFile opens filename and determines its format from the file name or,
+// failing that, its content.
+func openFile(ctx *Context, filename string) (NamedReader, *bufio.Reader, adif.Format, error) {
 	fs := ctx.fs
 	if fs == nil {
 		fs = osFilesystem{}
 	}
 	f, err := fs.Open(filename)
 	if err != nil {
-		return nil, err
+		return nil, nil, "", err
 	}
-	defer f.Close()
 	ior := bufio.NewReader(f)
 	format := ctx.InputFormat
 	if !format.IsValid() {
@@ -64,17 +102,12 @@ func readFile(ctx *Context, filename string) (*adif.Logfile, error) {
 		if err != nil {
 			format, err = adif.GuessFormatFromContent(ior)
 			if err != nil {
-				return nil, fmt.Errorf("could not determine type of %s: %w", f.Name(), err)
+				f.Close()
+				return nil, nil, "", fmt.Errorf("could not determine type of %s: %w", f.Name(), err)
 			}
 		}
 	}
-	r := ctx.Readers[format]
-	l, err := r.Read(ior)
-	if err != nil {
-		return nil, fmt.Errorf("error reading %s: %w", f.Name(), err)
-	}
-	l.Filename = f.Name()
-	return l, nil
+	return f, ior, format, nil
 }
 
 // NamedReader is an io.Reader with a name.  os.File implements this interface
@@ -94,6 +127,14 @@ type filesystem interface {
 	Create(name string) (io.WriteCloser, error)
 	// MkdirAll creates a directory for path and any needed parents
 	MkdirAll(dir string) error
+	// CreateTemp creates a new file in dir with a unique name based on pattern
+	// and opens it for writing.  See os.CreateTemp for more details.
+	CreateTemp(dir, pattern string) (w io.WriteCloser, name string, err error)
+	// Rename moves a file, replacing newpath if it exists and keeping the
+	// replaced file's permissions.
+	Rename(oldpath, newpath string) error
+	// Remove deletes the named file.
+	Remove(name string) error
 }
 
 type osFilesystem struct{}
@@ -104,7 +145,7 @@ func (_ osFilesystem) Exists(name string) bool {
 }
 
 func (_ osFilesystem) Open(name string) (NamedReader, error) {
-	if name == "-" || name == os.Stdin.Name() {
+	if isStdin(name) {
 		return os.Stdin, nil
 	}
 	return os.Open(name)
@@ -114,6 +155,44 @@ func (_ osFilesystem) Create(name string) (io.WriteCloser, error) { return os.Cr
 
 func (_ osFilesystem) MkdirAll(dir string) error { return os.MkdirAll(dir, 0777) }
 
+func (_ osFilesystem) CreateTemp(dir, pattern string) (io.WriteCloser, string, error) {
+	// os.CreateTemp uses mode 0600, but saved logs are not private, so create
+	// with 0666 and let the umask apply, like os.Create.  Rename applies the
+	// mode of an existing file.  Names use crypto/rand since math/rand is not
+	// seeded before Go 1.20, so concurrent runs would try the same names.
+	prefix, suffix := pattern, ""
+	if i := strings.LastIndex(pattern, "*"); i >= 0 {
+		prefix, suffix = pattern[:i], pattern[i+1:]
+	}
+	var b [4]byte
+	for try := 0; try < 10000; try++ {
+		if _, err := rand.Read(b[:]); err != nil {
+			return nil, "", err
+		}
+		name := filepath.Join(dir, prefix+strconv.FormatUint(uint64(binary.BigEndian.Uint32(b[:])), 10)+suffix)
+		f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0666)
+		if os.IsExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, "", err
+		}
+		return f, name, nil
+	}
+	return nil, "", &os.PathError{Op: "createtemp", Path: filepath.Join(dir, pattern), Err: os.ErrExist}
+}
+
+func (_ osFilesystem) Rename(oldpath, newpath string) error {
+	if st, err := os.Stat(newpath); err == nil {
+		if err := os.Chmod(oldpath, st.Mode().Perm()); err != nil {
+			return err
+		}
+	}
+	return os.Rename(oldpath, newpath)
+}
+
+func (_ osFilesystem) Remove(name string) error { return os.Remove(name) }
+
 func updateFieldOrder(l *adif.Logfile, fields []string) {
 	seen := make(map[string]bool)
 	for _, f := range l.FieldOrder {
@@ -139,20 +218,42 @@ func (a *accumulator) read(filename string) (*adif.Logfile, error) {
 	if err != nil {
 		return l, err
 	}
+	a.merge(l)
+	a.addComment(l)
+	return l, err
+}
+
+// merge adds userdef fields and Cabrillo header fields from l to the output
+// log.
+func (a *accumulator) merge(l *adif.Logfile) {
 	for _, u := range l.Userdef {
 		a.Out.AddUserdef(u)
 	}
+	// Cabrillo tags are passed through so they can be written back out; other
+	// application-defined header fields describe the input file, and standard
+	// header fields are set by Context.Prepare
+	for _, f := range l.Header.Fields() {
+		if strings.HasPrefix(strings.ToUpper(f.Name), adif.CabrilloAppPrefix) {
+			if _, ok := a.Out.Header.Get(f.Name); !ok {
+				a.Out.Header.Set(f)
+			}
+		}
+	}
+}
+
+// addComment saves l's comment, if any, to add to the output log's comment.
+func (a *accumulator) addComment(l *adif.Logfile) {
 	if c := l.Comment; c != "" {
 		prefix := "adif-multitool: original comment"
 		if !strings.HasPrefix(c, prefix) {
-			if filename != "" && filename != "-" && filename != os.Stdin.Name() {
+			filename := l.Filename
+			if filename != "" && !isStdin(filename) {
 				prefix = fmt.Sprintf("%s (%s)", prefix, filepath.Base(filename))
 			}
 			c = prefix + "\n" + c
 		}
 		a.comments = append(a.comments, c)
 	}
-	return l, err
 }
 
 func (a *accumulator) prepare() error {
@@ -0,0 +1,78 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/flwyd/adif-multitool/adif"
+)
+
+// PipelineStage is one command in a pipeline run by RunPipeline.  Ctx should
+// be configured for Command, e.g. with CommandCtx set from command-line flags.
+type PipelineStage struct {
+	Command Command
+	Ctx     *Context
+	Args    []string
+}
+
+// pipe connects pipeline stages.  A stage reads in as standard input and, if
+// capture is true, sets out rather than writing to Context.Out.
+type pipe struct {
+	in      *adif.Logfile
+	inRead  bool
+	capture bool
+	out     *adif.Logfile
+}
+
+// input returns the previous stage's output, which can only be read once.
+func (p *pipe) input() (*adif.Logfile, error) {
+	if p.inRead {
+		return nil, errors.New("standard input was already read")
+	}
+	p.inRead = true
+	return p.in, nil
+}
+
+// RunPipeline runs several commands in one process.  The output of each stage
+// is passed directly to the next stage as standard input ("-" or no file
+// arguments) without converting to and from a file format, so field types are
+// kept.  Only the last stage writes to its Context's Out.
+func RunPipeline(stages []PipelineStage) error {
+	if len(stages) == 0 {
+		return errors.New("no commands in pipeline")
+	}
+	var prev *adif.Logfile
+	for i, s := range stages {
+		last := i == len(stages)-1
+		if !last && s.Command.Name == Save.Name {
+			return fmt.Errorf("%s must be the last command in a pipeline", Save.Name)
+		}
+		p := &pipe{in: prev, capture: !last}
+		s.Ctx.pipe = p
+		if err := s.Command.Run(s.Ctx, s.Args); err != nil {
+			return fmt.Errorf("%s: %w", s.Command.Name, err)
+		}
+		if i > 0 && !p.inRead {
+			return fmt.Errorf("%s did not read output from %s, use - as a file name", s.Command.Name, stages[i-1].Command.Name)
+		}
+		if !last && p.out == nil {
+			return fmt.Errorf("%s did not produce output for %s", s.Command.Name, stages[i+1].Command.Name)
+		}
+		prev = p.out
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/flwyd/adif-multitool/adif"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRunPipeline(t *testing.T) {
+	adi := adif.NewADIIO()
+	csv := adif.NewCSVIO()
+	file1 := `QSO_DATE,CALL,FREQ
+2022-12-31,K1A,14.070
+2023-01-02,K2B,7.074
+2023-01-03,K3C,
+`
+	fs := fakeFilesystem{map[string]string{"foo.csv": file1}}
+	out := &bytes.Buffer{}
+	newCtx := func(cctx any) *Context {
+		return &Context{
+			OutputFormat: adif.FormatADI,
+			Readers:      readers(adi, csv),
+			Writers:      writers(adi, csv),
+			Out:          out,
+			Prepare:      testPrepare("My Comment", "3.1.4", "pipeline test", "1.2.3"),
+			fs:           fs,
+			CommandCtx:   cctx}
+	}
+	find := &FindContext{}
+	if err := find.Cond.IfFlag().Set("qso_date>=20230101"); err != nil {
+		t.Fatal(err)
+	}
+	stages := []PipelineStage{
+		{Command: Fix, Ctx: newCtx(nil), Args: []string{"foo.csv"}},
+		{Command: Infer, Ctx: newCtx(&InferContext{Fields: FieldList{"BAND"}})},
+		{Command: Find, Ctx: newCtx(find), Args: []string{"-"}},
+		{Command: Select, Ctx: newCtx(&SelectContext{Fields: FieldList{"CALL", "BAND"}})},
+	}
+	if err := RunPipeline(stages); err != nil {
+		t.Fatalf("RunPipeline got error %v", err)
+	}
+	want := `My Comment
+<ADIF_VER:5>3.1.4 <PROGRAMID:13>pipeline test <PROGRAMVERSION:5>1.2.3 <EOH>
+<CALL:3>K2B <BAND:3>40m <EOR>
+<CALL:3>K3C <EOR>
+`
+	if diff := cmp.Diff(want, out.String()); diff != "" {
+		t.Errorf("RunPipeline unexpected output, diff:\n%s", diff)
+	}
+}
+
+func TestRunPipelineErrors(t *testing.T) {
+	csv := adif.NewCSVIO()
+	fs := fakeFilesystem{map[string]string{"foo.csv": "CALL\nK1A\n"}}
+	newCtx := func(cctx any) *Context {
+		return &Context{
+			OutputFormat: adif.FormatCSV,
+			Readers:      readers(csv),
+			Writers:      writers(csv),
+			Out:          &bytes.Buffer{},
+			fs:           fs,
+			CommandCtx:   cctx}
+	}
+	tests := []struct {
+		name   string
+		stages []PipelineStage
+	}{
+		{name: "empty"},
+		{name: "save before end", stages: []PipelineStage{
+			{Command: Cat, Ctx: newCtx(nil), Args: []string{"foo.csv"}},
+			{Command: Save, Ctx: newCtx(&SaveContext{}), Args: []string{"bar.csv"}},
+			{Command: Cat, Ctx: newCtx(nil)},
+		}},
+		{name: "input not read", stages: []PipelineStage{
+			{Command: Cat, Ctx: newCtx(nil), Args: []string{"foo.csv"}},
+			{Command: Cat, Ctx: newCtx(nil), Args: []string{"foo.csv"}},
+		}},
+		{name: "input read twice", stages: []PipelineStage{
+			{Command: Cat, Ctx: newCtx(nil), Args: []string{"foo.csv"}},
+			{Command: Cat, Ctx: newCtx(nil), Args: []string{"-", "-"}},
+		}},
+	}
+	for _, tc := range tests {
+		if err := RunPipeline(tc.stages); err == nil {
+			t.Errorf("RunPipeline %s got no error", tc.name)
+		}
+	}
+}
@@ -0,0 +1,260 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/flwyd/adif-multitool/adif"
+	"github.com/flwyd/adif-multitool/adif/spec"
+)
+
+var Pota = Command{Name: "pota", Run: runPota, Help: helpPota,
+	Description: "Check Parks on the Air activations and write upload files"}
+
+const (
+	potaSig              = "POTA"
+	potaActivationQSOs   = 10
+	potaParkField        = "PARK"
+	potaQSOsField        = "QSOS"
+	potaP2PField         = "P2P"
+	potaActivatedField   = "ACTIVATED"
+	potaFileField        = "FILE"
+	potaUploadFileSuffix = ".adi"
+)
+
+type PotaContext struct {
+	UploadDir       string
+	CreateDirectory bool
+	Quiet           bool
+}
+
+// potaRequiredFields must be set in every record of an upload file.
+var potaRequiredFields = []spec.Field{
+	spec.StationCallsignField, spec.CallField, spec.QsoDateField, spec.TimeOnField,
+	spec.BandField, spec.ModeField, spec.MySigInfoField,
+}
+
+func helpPota() string {
+	return fmt.Sprintf(`Groups records by %s, park, and UTC %s and outputs a record
+for each activation with %s, %s, number of %s (distinct %s, %s, and %s),
+number of park-to-park contacts (%s), and whether the park was %s (at least
+%d QSOs).  Parks come from %s, with each park in a two-fer list counted
+separately, or %s if %s is %s.  Park-to-park contacts have %s set.
+
+With --upload-dir, an ADIF file is written to that directory for each
+activation, named STATION_CALLSIGN@PARK-YYYYMMDD%s as expected by
+https://pota.app, with %s and %s set to the single park.  No files are
+written if any record is missing a required field:
+  %s
+`,
+		spec.StationCallsignField.Name, spec.QsoDateField.Name, potaParkField, spec.QsoDateField.Name,
+		potaQSOsField, spec.CallField.Name, spec.BandField.Name, spec.ModeField.Name,
+		potaP2PField, potaActivatedField, potaActivationQSOs,
+		spec.MyPotaRefField.Name, spec.MySigInfoField.Name, spec.MySigField.Name, potaSig,
+		spec.PotaRefField.Name, potaUploadFileSuffix, spec.MySigField.Name, spec.MySigInfoField.Name,
+		strings.Join(fieldNames(potaRequiredFields), ", "))
+}
+
+type potaKey struct{ call, park, date string }
+
+type potaActivation struct {
+	key      potaKey
+	records  []*adif.Record
+	contacts map[string]bool
+	p2p      int
+}
+
+func (a *potaActivation) filename() string {
+	name := fmt.Sprintf("%s@%s-%s%s", a.key.call, a.key.park, a.key.date, potaUploadFileSuffix)
+	return strings.Map(func(c rune) rune {
+		if c == '/' || c == '\\' {
+			return '-'
+		}
+		return c
+	}, name)
+}
+
+func runPota(ctx *Context, args []string) error {
+	cctx := ctx.CommandCtx.(*PotaContext)
+	acts := make(map[potaKey]*potaActivation)
+	err := forEachRecord(ctx, args, func(r *adif.Record, _ *adif.Logfile) error {
+		parks := potaParks(r)
+		if len(parks) == 0 {
+			return nil
+		}
+		call, _ := r.Get(spec.StationCallsignField.Name)
+		date, _ := r.Get(spec.QsoDateField.Name)
+		other, _ := r.Get(spec.CallField.Name)
+		band, _ := r.Get(spec.BandField.Name)
+		mode, _ := r.Get(spec.ModeField.Name)
+		contact := strings.ToUpper(strings.Join([]string{other.Value, band.Value, mode.Value}, " "))
+		p2p := false
+		if f, ok := r.Get(spec.PotaRefField.Name); ok && f.Value != "" {
+			p2p = true
+		}
+		for _, p := range parks {
+			k := potaKey{call: strings.ToUpper(call.Value), park: p, date: date.Value}
+			a := acts[k]
+			if a == nil {
+				a = &potaActivation{key: k, contacts: make(map[string]bool)}
+				acts[k] = a
+			}
+			a.records = append(a.records, r)
+			a.contacts[contact] = true
+			if p2p {
+				a.p2p++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	sorted := make([]*potaActivation, 0, len(acts))
+	for _, a := range acts {
+		sorted = append(sorted, a)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		a, b := sorted[i].key, sorted[j].key
+		if a.date != b.date {
+			return a.date < b.date
+		}
+		if a.call != b.call {
+			return a.call < b.call
+		}
+		return a.park < b.park
+	})
+	out := adif.NewLogfile()
+	out.FieldOrder = []string{spec.StationCallsignField.Name, potaParkField, spec.QsoDateField.Name, potaQSOsField, potaP2PField, potaActivatedField}
+	if cctx.UploadDir != "" {
+		out.FieldOrder = append(out.FieldOrder, potaFileField)
+		if err := writePotaUploads(ctx, cctx, sorted); err != nil {
+			return err
+		}
+	}
+	for _, a := range sorted {
+		activated := "N"
+		if len(a.contacts) >= potaActivationQSOs {
+			activated = "Y"
+		}
+		r := adif.NewRecord(
+			adif.Field{Name: spec.StationCallsignField.Name, Value: a.key.call},
+			adif.Field{Name: potaParkField, Value: a.key.park},
+			adif.Field{Name: spec.QsoDateField.Name, Value: a.key.date},
+			adif.Field{Name: potaQSOsField, Value: strconv.Itoa(len(a.contacts))},
+			adif.Field{Name: potaP2PField, Value: strconv.Itoa(a.p2p)},
+			adif.Field{Name: potaActivatedField, Value: activated},
+		)
+		if cctx.UploadDir != "" {
+			r.Set(adif.Field{Name: potaFileField, Value: path.Join(cctx.UploadDir, a.filename())})
+		}
+		out.AddRecord(r)
+	}
+	return write(ctx, out)
+}
+
+// writePotaUploads checks that all records have required fields and that no
+// upload file exists, and then writes one file per activation in the order of
+// sorted, so a failure partway through always leaves the same files.
+func writePotaUploads(ctx *Context, cctx *PotaContext, sorted []*potaActivation) error {
+	var errs []error
+	for _, a := range sorted {
+		for _, r := range a.records {
+			var missing []string
+			for _, f := range potaRequiredFields {
+				if f.Name == spec.MySigInfoField.Name {
+					continue // set from park
+				}
+				if v, ok := r.Get(f.Name); !ok || v.Value == "" {
+					missing = append(missing, f.Name)
+				}
+			}
+			if len(missing) > 0 {
+				errs = append(errs, fmt.Errorf("%s: record %s missing %s", a.filename(), r, strings.Join(missing, ", ")))
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("not writing POTA upload files: %w", errorsJoin(errs...))
+	}
+	fs := ctx.fs
+	if fs == nil {
+		fs = osFilesystem{}
+	}
+	if cctx.CreateDirectory {
+		if err := fs.MkdirAll(cctx.UploadDir); err != nil && !errors.Is(err, os.ErrExist) {
+			return err
+		}
+	}
+	for _, a := range sorted {
+		file := path.Join(cctx.UploadDir, a.filename())
+		if fs.Exists(file) {
+			return fmt.Errorf("output file %s already exists", file)
+		}
+	}
+	for _, a := range sorted {
+		l := adif.NewLogfile()
+		for _, r := range a.records {
+			n := adif.NewRecord(r.Fields()...)
+			n.Set(adif.Field{Name: spec.MySigField.Name, Value: potaSig})
+			n.Set(adif.Field{Name: spec.MySigInfoField.Name, Value: a.key.park})
+			n.Set(adif.Field{Name: spec.MyPotaRefField.Name, Value: a.key.park})
+			l.AddRecord(n)
+		}
+		file := path.Join(cctx.UploadDir, a.filename())
+		if err := saveFile(ctx, fs, file, adif.FormatADI, l); err != nil {
+			return err
+		}
+		if !cctx.Quiet {
+			fmt.Fprintf(os.Stderr, "Wrote %d records to %s\n", len(l.Records), file)
+		}
+	}
+	return nil
+}
+
+// potaParks returns the parks activated in r from MY_POTA_REF, or MY_SIG_INFO
+// if MY_SIG is POTA.  Location suffixes like @US-CA are removed.
+func potaParks(r *adif.Record) []string {
+	var refs string
+	if f, ok := r.Get(spec.MyPotaRefField.Name); ok && f.Value != "" {
+		refs = f.Value
+	} else if sig, ok := r.Get(spec.MySigField.Name); ok && strings.EqualFold(sig.Value, potaSig) {
+		f, _ := r.Get(spec.MySigInfoField.Name)
+		refs = f.Value
+	}
+	var res []string
+	for _, p := range strings.Split(refs, ",") {
+		p, _, _ = strings.Cut(strings.ToUpper(strings.TrimSpace(p)), "@")
+		if p != "" {
+			res = append(res, p)
+		}
+	}
+	return res
+}
+
+func fieldNames(fs []spec.Field) []string {
+	res := make([]string, len(fs))
+	for i, f := range fs {
+		res[i] = f.Name
+	}
+	return res
+}
@@ -0,0 +1,169 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/flwyd/adif-multitool/adif"
+	"github.com/google/go-cmp/cmp"
+)
+
+func potaTestLog() string {
+	var b strings.Builder
+	b.WriteString("STATION_CALLSIGN,CALL,QSO_DATE,TIME_ON,BAND,MODE,MY_POTA_REF,MY_SIG,MY_SIG_INFO,POTA_REF\n")
+	// two-fer with 10 distinct contacts, one duplicate, and a park-to-park
+	for i := 0; i < 10; i++ {
+		fmt.Fprintf(&b, "K1ABC,W%dXYZ,20230501,%02d00,20m,SSB,\"K-0001,K-0002@US-CT\",,,\n", i, i+10)
+	}
+	b.WriteString("K1ABC,W0XYZ,20230501,2100,20m,SSB,\"K-0001,K-0002@US-CT\",,,\n")
+	b.WriteString("K1ABC,N1P,20230501,2200,40m,CW,\"K-0001,K-0002@US-CT\",,,K-1234\n")
+	// UTC date change, park from MY_SIG_INFO
+	b.WriteString("K1ABC/P,W1AW,20230502,0010,40m,CW,,POTA,K-0001,\n")
+	// not an activation
+	b.WriteString("K1ABC,W2AW,20230502,0100,40m,CW,,WWFF,KFF-0001,\n")
+	return b.String()
+}
+
+func TestPota(t *testing.T) {
+	csv := adif.NewCSVIO()
+	out := &bytes.Buffer{}
+	ctx := &Context{
+		OutputFormat: adif.FormatCSV,
+		Readers:      readers(csv),
+		Writers:      writers(csv),
+		Out:          out,
+		fs:           fakeFilesystem{map[string]string{"log.csv": potaTestLog()}},
+		CommandCtx:   &PotaContext{}}
+	if err := Pota.Run(ctx, []string{"log.csv"}); err != nil {
+		t.Fatalf("Pota.Run(log.csv) got error %v", err)
+	}
+	want := `STATION_CALLSIGN,PARK,QSO_DATE,QSOS,P2P,ACTIVATED
+K1ABC,K-0001,20230501,11,1,Y
+K1ABC,K-0002,20230501,11,1,Y
+K1ABC/P,K-0001,20230502,1,0,N
+`
+	if diff := cmp.Diff(want, out.String()); diff != "" {
+		t.Errorf("Pota.Run(log.csv) unexpected output, diff:\n%s", diff)
+	}
+}
+
+func TestPotaUpload(t *testing.T) {
+	adi := adif.NewADIIO()
+	csv := adif.NewCSVIO()
+	out := &bytes.Buffer{}
+	fs := fakeFilesystem{map[string]string{"log.csv": potaTestLog()}}
+	ctx := &Context{
+		OutputFormat: adif.FormatCSV,
+		Readers:      readers(adi, csv),
+		Writers:      writers(adi, csv),
+		Out:          out,
+		fs:           fs,
+		CommandCtx:   &PotaContext{UploadDir: "up", Quiet: true}}
+	if err := Pota.Run(ctx, []string{"log.csv"}); err != nil {
+		t.Fatalf("Pota.Run(log.csv) got error %v", err)
+	}
+	want := `STATION_CALLSIGN,PARK,QSO_DATE,QSOS,P2P,ACTIVATED,FILE
+K1ABC,K-0001,20230501,11,1,Y,up/K1ABC@K-0001-20230501.adi
+K1ABC,K-0002,20230501,11,1,Y,up/K1ABC@K-0002-20230501.adi
+K1ABC/P,K-0001,20230502,1,0,N,up/K1ABC-P@K-0001-20230502.adi
+`
+	if diff := cmp.Diff(want, out.String()); diff != "" {
+		t.Errorf("Pota.Run(log.csv) unexpected output, diff:\n%s", diff)
+	}
+	counts := map[string]int{
+		"up/K1ABC@K-0001-20230501.adi":   12,
+		"up/K1ABC@K-0002-20230501.adi":   12,
+		"up/K1ABC-P@K-0001-20230502.adi": 1,
+	}
+	for file, count := range counts {
+		if !fs.Exists(file) {
+			t.Errorf("%s not written, files: %v", file, fs.files)
+			continue
+		}
+		l, err := adi.Read(strings.NewReader(fs.files[file]))
+		if err != nil {
+			t.Errorf("error reading %s: %v", file, err)
+			continue
+		}
+		if len(l.Records) != count {
+			t.Errorf("%s got %d records, want %d", file, len(l.Records), count)
+		}
+		park := file[strings.Index(file, "@")+1 : strings.Index(file, "@")+7]
+		for _, r := range l.Records {
+			for _, f := range []adif.Field{{Name: "MY_SIG", Value: "POTA"}, {Name: "MY_SIG_INFO", Value: park}, {Name: "MY_POTA_REF", Value: park}} {
+				if got, _ := r.Get(f.Name); got != f {
+					t.Errorf("%s record %v got %v, want %v", file, r, got, f)
+				}
+			}
+		}
+	}
+	if len(fs.files) != len(counts)+1 {
+		t.Errorf("unexpected files written: %v", fs.files)
+	}
+}
+
+func TestPotaUploadMissingFields(t *testing.T) {
+	csv := adif.NewCSVIO()
+	adi := adif.NewADIIO()
+	fs := fakeFilesystem{map[string]string{"log.csv": `STATION_CALLSIGN,CALL,QSO_DATE,TIME_ON,BAND,MODE,MY_POTA_REF
+K1ABC,W1AW,20230501,1200,20m,SSB,K-0001
+K1ABC,W2AW,20230501,1201,,SSB,K-0001
+K1ABC,W3AW,20230501,1202,20m,SSB,K-0003
+`}}
+	ctx := &Context{
+		OutputFormat: adif.FormatCSV,
+		Readers:      readers(adi, csv),
+		Writers:      writers(adi, csv),
+		Out:          &bytes.Buffer{},
+		fs:           fs,
+		CommandCtx:   &PotaContext{UploadDir: "up", Quiet: true}}
+	err := Pota.Run(ctx, []string{"log.csv"})
+	if err == nil {
+		t.Fatalf("Pota.Run(log.csv) got no error, want missing BAND")
+	}
+	if !strings.Contains(err.Error(), "BAND") {
+		t.Errorf("Pota.Run(log.csv) error %q does not mention BAND", err)
+	}
+	if len(fs.files) != 1 {
+		t.Errorf("files written despite error: %v", fs.files)
+	}
+}
+
+func TestPotaUploadFileExists(t *testing.T) {
+	csv := adif.NewCSVIO()
+	adi := adif.NewADIIO()
+	fs := fakeFilesystem{map[string]string{"log.csv": `STATION_CALLSIGN,CALL,QSO_DATE,TIME_ON,BAND,MODE,MY_POTA_REF
+K1ABC,W1AW,20230501,1200,20m,SSB,K-0001
+K1ABC,W3AW,20230502,1202,20m,SSB,K-0003
+`, "up/K1ABC@K-0003-20230502.adi": "existing"}}
+	ctx := &Context{
+		OutputFormat: adif.FormatCSV,
+		Readers:      readers(adi, csv),
+		Writers:      writers(adi, csv),
+		Out:          &bytes.Buffer{},
+		fs:           fs,
+		CommandCtx:   &PotaContext{UploadDir: "up", Quiet: true}}
+	err := Pota.Run(ctx, []string{"log.csv"})
+	if err == nil || !strings.Contains(err.Error(), "K1ABC@K-0003-20230502.adi") {
+		t.Fatalf("Pota.Run(log.csv) got error %v, want K1ABC@K-0003-20230502.adi already exists", err)
+	}
+	if len(fs.files) != 2 {
+		t.Errorf("files written despite existing file: %v", fs.files)
+	}
+}
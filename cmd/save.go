@@ -17,6 +17,7 @@ package cmd
 import (
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path"
 	"regexp"
@@ -33,6 +34,8 @@ var Save = Command{Name: "save", Run: runSave, Help: helpSave,
 
 type SaveContext struct {
 	OverwriteExisting bool
+	Append            bool
+	SkipDuplicates    bool
 	WriteIfEmpty      bool
 	CreateDirectory   bool
 	Quiet             bool
@@ -45,6 +48,13 @@ logfiles withoutout any records will not be saved (useful if validate failed).
 File name may be a template with {FIELD} placeholders replaced by field values.
 For example, '{QSO_DATE}_{BAND}.adi' will create a separate file for each
 contact date + band combination.  Quote the name to avoid shell expansion.
+
+With --append, records are added to the end of existing files, which may be in
+any format.  The existing file's comment and header are kept, except for
+ADIF_VER, CREATED_TIMESTAMP, PROGRAMID, and PROGRAMVERSION, which are updated.
+--skip-duplicates will not append records which exactly match an existing
+record.  Files are written to a temporary file and then renamed, so
+an existing file is left unchanged if there is an error.
 `
 }
 
@@ -79,10 +89,39 @@ func runSave(ctx *Context, args []string) error {
 		l.AddUserdef(u)
 	}
 
-	saveLog := func(l *adif.Logfile, file string) error {
-		if !cctx.OverwriteExisting && fs.Exists(file) {
+	if cctx.Append && cctx.OverwriteExisting {
+		return fmt.Errorf("cannot both append to and overwrite existing files")
+	}
+	canWrite := func(file string) error {
+		if !cctx.OverwriteExisting && !cctx.Append && fs.Exists(file) {
 			return fmt.Errorf("output file %s already exists", file)
 		}
+		return nil
+	}
+
+	saveLog := func(l *adif.Logfile, file string) error {
+		if err := canWrite(file); err != nil {
+			return err
+		}
+		appending, added := cctx.Append && fs.Exists(file), len(l.Records)
+		wctx := ctx
+		if appending {
+			var err error
+			if l, added, err = appendToExisting(ctx, file, l, cctx.SkipDuplicates); err != nil {
+				return err
+			}
+			if c := strings.TrimSpace(l.Header.GetComment()); c != "" {
+				// keep the existing file's comment after Prepare sets the header
+				actx := *ctx
+				actx.Prepare = func(l *adif.Logfile) {
+					if ctx.Prepare != nil {
+						ctx.Prepare(l)
+					}
+					l.Header.SetComment(c)
+				}
+				wctx = &actx
+			}
+		}
 		if len(l.Records) == 0 {
 			if !cctx.WriteIfEmpty {
 				return fmt.Errorf("no records in input, not saving to %s", file)
@@ -97,18 +136,17 @@ func runSave(ctx *Context, args []string) error {
 				return err
 			}
 		}
-		out, err := fs.Create(file)
-		if err != nil {
+		if err := saveFile(wctx, fs, file, format, l); err != nil {
 			return err
 		}
-		defer out.Close()
-		ctx.Out = out
-		ctx.OutputFormat = format
-		err = write(ctx, l)
-		if err == nil && !cctx.Quiet {
-			fmt.Fprintf(os.Stderr, "Wrote %d records to %s\n", len(l.Records), file)
+		if !cctx.Quiet {
+			if appending {
+				fmt.Fprintf(os.Stderr, "Appended %d records to %s, %d total\n", added, file, len(l.Records))
+			} else {
+				fmt.Fprintf(os.Stderr, "Wrote %d records to %s\n", len(l.Records), file)
+			}
 		}
-		return err
+		return nil
 	}
 
 	if len(l.Records) == 0 {
@@ -121,8 +159,8 @@ func runSave(ctx *Context, args []string) error {
 	for _, r := range l.Records {
 		file := st.format(r)
 		if logs[file] == nil {
-			if !cctx.OverwriteExisting && fs.Exists(file) {
-				return fmt.Errorf("output file %s already exists", file)
+			if err := canWrite(file); err != nil {
+				return err
 			}
 			if cctx.CreateDirectory {
 				dir := path.Dir(file)
@@ -150,6 +188,121 @@ func runSave(ctx *Context, args []string) error {
 	return errorsJoin(errs...)
 }
 
+// saveFile writes l to file in the given format with writeFileAtomic.
+func saveFile(ctx *Context, fs filesystem, file string, format adif.Format, l *adif.Logfile) error {
+	return writeFileAtomic(fs, file, func(out io.Writer) error {
+		wctx := *ctx
+		wctx.Out = out
+		wctx.OutputFormat = format
+		wctx.pipe = nil
+		return write(&wctx, l)
+	})
+}
+
+// writeFileAtomic calls fn with a temporary file in the same directory as file
+// and then renames the temporary file, so an existing file is left unchanged if
+// there is an error.
+func writeFileAtomic(fs filesystem, file string, fn func(io.Writer) error) error {
+	out, tmp, err := fs.CreateTemp(path.Dir(file), "."+path.Base(file)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	err = fn(out)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = fs.Rename(tmp, file)
+	}
+	if err != nil {
+		fs.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// appendToExisting reads file and returns a Logfile with its header, userdefs,
+// comment, and records followed by records from l, and the number of records
+// added.  If skipDupes is true, records in l which are equal to a record in
+// file are not added.
+func appendToExisting(ctx *Context, file string, l *adif.Logfile, skipDupes bool) (*adif.Logfile, int, error) {
+	rctx := *ctx
+	rctx.InputFormat = adif.Format("") // detect existing file's format
+	rctx.Readers = withoutColumnMapping(ctx.Readers)
+	existing, err := readFile(&rctx, file)
+	if err != nil {
+		return nil, 0, fmt.Errorf("could not append to %s: %w", file, err)
+	}
+	for _, u := range l.Userdef {
+		if err := existing.AddUserdef(u); err != nil {
+			return nil, 0, fmt.Errorf("could not append to %s: %w", file, err)
+		}
+	}
+	for _, f := range l.Header.Fields() {
+		if _, ok := existing.Header.Get(f.Name); !ok {
+			existing.Header.Set(f)
+		}
+	}
+	updateFieldOrder(existing, l.FieldOrder)
+	seen := make(map[string]bool)
+	if skipDupes {
+		for _, e := range existing.Records {
+			seen[recordKey(e)] = true
+		}
+	}
+	added := 0
+	for _, r := range l.Records {
+		if skipDupes {
+			k := recordKey(r)
+			if seen[k] {
+				continue
+			}
+			seen[k] = true
+		}
+		existing.AddRecord(r)
+		added++
+	}
+	return existing, added, nil
+}
+
+// recordKey returns a string which is the same for records which are Equal:
+// non-empty fields in name order, ignoring comments.
+func recordKey(r *adif.Record) string {
+	fields := r.Fields()
+	sort.Slice(fields, func(i, j int) bool {
+		return strings.ToUpper(fields[i].Name) < strings.ToUpper(fields[j].Name)
+	})
+	var k strings.Builder
+	for _, f := range fields {
+		if f.Value != "" {
+			fmt.Fprintf(&k, "<%s:%d:%s>%s", strings.ToUpper(f.Name), len(f.Value), f.Type.Indicator(), f.Value)
+		}
+	}
+	return k.String()
+}
+
+// withoutColumnMapping returns readers with CSV and TSV column mappings
+// removed, since --csv-mapping and --columns describe command input, not an
+// existing file written by a previous save.
+func withoutColumnMapping(rs map[adif.Format]adif.Reader) map[adif.Format]adif.Reader {
+	res := make(map[adif.Format]adif.Reader, len(rs))
+	for f, r := range rs {
+		switch r := r.(type) {
+		case *adif.CSVIO:
+			c := *r
+			c.Mapping = nil
+			res[f] = &c
+		case *adif.TSVIO:
+			t := *r
+			t.Mapping = nil
+			res[f] = &t
+		default:
+			res[f] = r
+		}
+	}
+	return res
+}
+
 type saveTemplate struct {
 	pieces []func(r *adif.Record) string
 	static bool
@@ -17,6 +17,8 @@ package cmd
 import (
 	"encoding/xml"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/flwyd/adif-multitool/adif"
@@ -176,3 +178,214 @@ func TestSaveFileTemplate(t *testing.T) {
 		})
 	}
 }
+
+func TestSaveAppend(t *testing.T) {
+	adiio := adif.NewADIIO()
+	adiio.FieldSep = adif.SeparatorSpace
+	adiio.RecordSep = adif.SeparatorNewline
+	csvio := adif.NewCSVIO()
+	input := `QSO_DATE,CALL,BAND
+19870605,W1AW,40m
+20221224,N0P,2m
+`
+	existing := `Existing log
+<ADIF_VER:5>3.1.4 <PROGRAMID:7>monolog <USERDEF1:3:N>AGE <APP_MONOLOG_LOCATION:4>home <EOH>
+<CALL:4>W1AW <QSO_DATE:8>19870605 <BAND:3>40m <EOR>
+<CALL:4>K1MU <QSO_DATE:8>19870605 <BAND:3>40m <AGE:2>42 <EOR>
+`
+	tests := []struct {
+		name     string
+		ctx      SaveContext
+		files    map[string]string
+		filename string
+		want     string
+		wantErr  bool
+	}{
+		{
+			name:     "append to existing ADI",
+			ctx:      SaveContext{Append: true},
+			files:    map[string]string{"log.adi": existing},
+			filename: "log.adi",
+			want: `Existing log
+<ADIF_VER:5>3.1.4 <PROGRAMID:9>test save <APP_MONOLOG_LOCATION:4>home <PROGRAMVERSION:5>5.6.7 <USERDEF1:3:N>AGE <EOH>
+<CALL:4>W1AW <QSO_DATE:8>19870605 <BAND:3>40m <EOR>
+<CALL:4>K1MU <QSO_DATE:8>19870605 <BAND:3>40m <AGE:2>42 <EOR>
+<QSO_DATE:8>19870605 <CALL:4>W1AW <BAND:3>40m <EOR>
+<QSO_DATE:8>20221224 <CALL:3>N0P <BAND:2>2m <EOR>
+`,
+		},
+		{
+			name:     "skip duplicates",
+			ctx:      SaveContext{Append: true, SkipDuplicates: true},
+			files:    map[string]string{"log.adi": existing},
+			filename: "log.adi",
+			want: `Existing log
+<ADIF_VER:5>3.1.4 <PROGRAMID:9>test save <APP_MONOLOG_LOCATION:4>home <PROGRAMVERSION:5>5.6.7 <USERDEF1:3:N>AGE <EOH>
+<CALL:4>W1AW <QSO_DATE:8>19870605 <BAND:3>40m <EOR>
+<CALL:4>K1MU <QSO_DATE:8>19870605 <BAND:3>40m <AGE:2>42 <EOR>
+<QSO_DATE:8>20221224 <CALL:3>N0P <BAND:2>2m <EOR>
+`,
+		},
+		{
+			name:     "append CSV",
+			ctx:      SaveContext{Append: true},
+			files:    map[string]string{"log.csv": "CALL,BAND,QSO_DATE,MODE\nK1MU,20m,19990101,CW\n"},
+			filename: "log.csv",
+			want: `CALL,BAND,QSO_DATE,MODE
+K1MU,20m,19990101,CW
+W1AW,40m,19870605,
+N0P,2m,20221224,
+`,
+		},
+		{
+			name:     "append new file",
+			ctx:      SaveContext{Append: true},
+			files:    map[string]string{},
+			filename: "new.csv",
+			want:     input,
+		},
+		{
+			name:     "append and overwrite",
+			ctx:      SaveContext{Append: true, OverwriteExisting: true},
+			files:    map[string]string{"log.csv": "CALL\nK1MU\n"},
+			filename: "log.csv",
+			want:     "CALL\nK1MU\n",
+			wantErr:  true,
+		},
+		{
+			name:     "existing not parseable",
+			ctx:      SaveContext{Append: true},
+			files:    map[string]string{"log.adi": "<CALL:4>W1AW <EOR> <MODE:9>CW"},
+			filename: "log.adi",
+			want:     "<CALL:4>W1AW <EOR> <MODE:9>CW",
+			wantErr:  true,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			files := map[string]string{os.Stdin.Name(): input}
+			for k, v := range tc.files {
+				files[k] = v
+			}
+			fs := fakeFilesystem{files: files}
+			cctx := tc.ctx
+			cctx.Quiet = true
+			ctx := &Context{
+				InputFormat: adif.FormatCSV,
+				Readers:     readers(adiio, csvio),
+				Writers:     writers(adiio, csvio),
+				Out:         os.Stdout,
+				CommandCtx:  &cctx,
+				Prepare:     testPrepare("Test comment", "3.1.4", "test save", "5.6.7"),
+				fs:          fs,
+			}
+			err := runSave(ctx, []string{tc.filename})
+			if err != nil && !tc.wantErr {
+				t.Errorf("runSave(%q) got error: %v", tc.filename, err)
+			} else if err == nil && tc.wantErr {
+				t.Errorf("runSave(%q) got no error", tc.filename)
+			}
+			if got, ok := fs.files[tc.filename]; tc.want != "" && !ok {
+				t.Errorf("runSave(%q) didn't write to file", tc.filename)
+			} else if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("runSave(%q) got diff\n%s", tc.filename, diff)
+			}
+			for name := range fs.files {
+				if name != os.Stdin.Name() && name != tc.filename {
+					t.Errorf("runSave(%q) left extra file %q", tc.filename, name)
+				}
+			}
+		})
+	}
+}
+
+func TestSaveAppendColumns(t *testing.T) {
+	csvio := adif.NewCSVIO()
+	csvio.Mapping = &adif.ColumnMapping{Columns: []string{"CALL", "BAND"}}
+	fs := fakeFilesystem{files: map[string]string{
+		os.Stdin.Name(): "W1AW,40m\nN0P,2m\n",
+		"log.csv":       "CALL,BAND\nK1MU,20m\n",
+	}}
+	ctx := &Context{
+		InputFormat: adif.FormatCSV,
+		Readers:     readers(csvio),
+		Writers:     writers(csvio),
+		Out:         os.Stdout,
+		CommandCtx:  &SaveContext{Append: true, Quiet: true},
+		Prepare:     testPrepare("Test comment", "3.1.4", "test save", "5.6.7"),
+		fs:          fs,
+	}
+	if err := runSave(ctx, []string{"log.csv"}); err != nil {
+		t.Fatalf("runSave(log.csv) got error: %v", err)
+	}
+	want := "CALL,BAND\nK1MU,20m\nW1AW,40m\nN0P,2m\n"
+	if diff := cmp.Diff(want, fs.files["log.csv"]); diff != "" {
+		t.Errorf("runSave(log.csv) with columns %v got diff\n%s", csvio.Mapping.Columns, diff)
+	}
+}
+
+func TestOSFilesystemTempMode(t *testing.T) {
+	dir := t.TempDir()
+	// mode 0666 with the current umask applied
+	ref, err := os.OpenFile(filepath.Join(dir, "ref"), os.O_RDWR|os.O_CREATE|os.O_EXCL, 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref.Close()
+	refInfo, err := os.Stat(ref.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	fs := osFilesystem{}
+	w, tmp, err := fs.CreateTemp(dir, ".log.adi.*.tmp")
+	if err != nil {
+		t.Fatalf("CreateTemp got error %v", err)
+	}
+	w.Close()
+	if !strings.HasPrefix(filepath.Base(tmp), ".log.adi.") || !strings.HasSuffix(tmp, ".tmp") {
+		t.Errorf("CreateTemp got name %q, want .log.adi.*.tmp", tmp)
+	}
+	if st, err := os.Stat(tmp); err != nil {
+		t.Fatal(err)
+	} else if got, want := st.Mode().Perm(), refInfo.Mode().Perm(); got != want {
+		t.Errorf("CreateTemp got mode %v, want %v", got, want)
+	}
+	existing := filepath.Join(dir, "log.adi")
+	if err := os.WriteFile(existing, []byte("<EOH>\n"), 0640); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(existing, 0640); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Rename(tmp, existing); err != nil {
+		t.Fatalf("Rename got error %v", err)
+	}
+	if st, err := os.Stat(existing); err != nil {
+		t.Fatal(err)
+	} else if got := st.Mode().Perm(); got != 0640 {
+		t.Errorf("Rename over a 0640 file got mode %v", got)
+	}
+}
+
+func TestRecordKey(t *testing.T) {
+	w1aw := adif.NewRecord(adif.Field{Name: "CALL", Value: "W1AW"}, adif.Field{Name: "BAND", Value: "40m"})
+	tests := []struct {
+		r    *adif.Record
+		want bool
+	}{
+		{r: adif.NewRecord(adif.Field{Name: "BAND", Value: "40m"}, adif.Field{Name: "CALL", Value: "W1AW"}), want: true},
+		{r: adif.NewRecord(adif.Field{Name: "CALL", Value: "W1AW"}, adif.Field{Name: "BAND", Value: "40m"}, adif.Field{Name: "MODE", Value: ""}), want: true},
+		{r: adif.NewRecord(adif.Field{Name: "CALL", Value: "W1AW"}, adif.Field{Name: "BAND", Value: "20m"}), want: false},
+		{r: adif.NewRecord(adif.Field{Name: "CALL", Value: "W1AW"}), want: false},
+		{r: adif.NewRecord(adif.Field{Name: "CALL", Value: "W1AW"}, adif.Field{Name: "BAND", Value: "40m"}, adif.Field{Name: "MODE", Value: "CW"}), want: false},
+		{r: adif.NewRecord(adif.Field{Name: "CALL", Value: "W1AWB"}, adif.Field{Name: "AND", Value: "40m"}), want: false},
+	}
+	for _, tc := range tests {
+		if got := recordKey(tc.r) == recordKey(w1aw); got != tc.want {
+			t.Errorf("recordKey(%v) == recordKey(%v) got %v, want %v", tc.r, w1aw, got, tc.want)
+		}
+		if got := tc.r.Equal(w1aw); got != tc.want {
+			t.Errorf("%v.Equal(%v) got %v, want %v", tc.r, w1aw, got, tc.want)
+		}
+	}
+}
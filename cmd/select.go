@@ -30,7 +30,7 @@ type SelectContext struct {
 }
 
 func helpSelect() string {
-	return "Records with no matching fields will be skipped in the output.\n"
+	return "Records with no matching fields will be skipped in the output.\n\n" + helpStreamOutput("select --fields call,band")
 }
 
 func runSelect(ctx *Context, args []string) error {
@@ -40,27 +40,17 @@ func runSelect(ctx *Context, args []string) error {
 	}
 	out := adif.NewLogfile()
 	out.FieldOrder = con.Fields
-	acc := accumulator{Out: out, Ctx: ctx}
-	for _, f := range filesOrStdin(args) {
-		l, err := acc.read(f)
-		if err != nil {
-			return err
-		}
-		// TODO merge headers and comments
-		for _, r := range l.Records {
-			fields := make([]adif.Field, 0, len(con.Fields))
-			for _, name := range con.Fields {
-				if f, ok := r.Get(name); ok {
-					fields = append(fields, f)
-				}
-			}
-			if len(fields) > 0 {
-				out.AddRecord(adif.NewRecord(fields...))
+	s := recordStream{Ctx: ctx, Out: out, FixedFieldOrder: true, KnownFields: true}
+	return s.run(args, func(r *adif.Record, _ *adif.Logfile, _ int) (*adif.Record, error) {
+		fields := make([]adif.Field, 0, len(con.Fields))
+		for _, name := range con.Fields {
+			if f, ok := r.Get(name); ok {
+				fields = append(fields, f)
 			}
 		}
-	}
-	if err := acc.prepare(); err != nil {
-		return err
-	}
-	return write(ctx, out)
+		if len(fields) == 0 {
+			return nil, nil
+		}
+		return adif.NewRecord(fields...), nil
+	})
 }
@@ -21,6 +21,7 @@ import (
 
 	"github.com/flwyd/adif-multitool/adif"
 	"github.com/flwyd/adif-multitool/adif/spec"
+	"golang.org/x/text/language"
 )
 
 var Sort = Command{Name: "sort", Run: runSort, Help: helpSort,
@@ -36,7 +37,6 @@ func helpSort() string {
 
 func runSort(ctx *Context, args []string) error {
 	cctx := ctx.CommandCtx.(*SortContext)
-	comps := make([]spec.FieldComparator, len(cctx.Fields))
 	mults := make([]int, len(cctx.Fields))
 	fields := make([]string, len(cctx.Fields))
 	for i, n := range cctx.Fields {
@@ -50,10 +50,6 @@ func runSort(ctx *Context, args []string) error {
 			mults[i] = 1
 		}
 		fields[i] = n
-		n = strings.ToUpper(n)
-		if f, ok := spec.Fields[n]; ok {
-			comps[i] = spec.ComparatorForField(f, ctx.Locale)
-		} // else resolve dynamically
 	}
 	out := adif.NewLogfile()
 	acc := accumulator{Out: out, Ctx: ctx}
@@ -69,50 +65,93 @@ func runSort(ctx *Context, args []string) error {
 	if err := acc.prepare(); err != nil {
 		return err
 	}
+	// userdef fields from all input files are known after prepare
+	comps := make([]func(a, b *adif.Record) (int, error), len(fields))
+	for i, f := range fields {
+		comps[i] = fieldComparator(f, out, ctx.Locale)
+	}
 	sort.SliceStable(out.Records, func(i, j int) bool {
 		a := out.Records[i]
 		b := out.Records[j]
 		for k, comp := range comps {
-			if comp == nil {
-				if uf, _ := out.GetUserdef(fields[k]); uf.Type.Indicator() != "" {
-					f := spec.Field{Name: fields[k], Type: spec.DataTypes[uf.Type.Indicator()]}
-					comp = spec.ComparatorForField(f, ctx.Locale)
-				} else {
-					var t spec.DataType
-					fa, _ := a.Get(fields[k])
-					fb, _ := b.Get(fields[k])
-					if fa.Type == fb.Type {
-						if fa.Type == adif.TypeUnspecified {
-							t = spec.StringDataType
-						} else {
-							t = spec.DataTypes[fa.Type.Indicator()]
-						}
-					} else if fa.Type == adif.TypeUnspecified {
-						t = spec.DataTypes[fb.Type.Indicator()]
-					} else if fb.Type == adif.TypeUnspecified {
-						t = spec.DataTypes[fa.Type.Indicator()]
-					} else {
-						t = spec.StringDataType // type confusion, sort as strings
-					}
-					f := spec.Field{Name: fields[k], Type: t}
-					comp = spec.ComparatorForField(f, ctx.Locale)
-				}
-			}
-			af, _ := a.Get(fields[k])
-			bf, _ := b.Get(fields[k])
-			c, err := comp(af.Value, bf.Value)
+			c, err := comp(a, b)
 			if err != nil {
 				return false // if can't compare, treat as equal
 			}
-			c *= mults[k]
-			if c < 0 {
-				return true
-			}
-			if c > 0 {
-				return false
+			if c *= mults[k]; c != 0 {
+				return c < 0
 			}
 		}
 		return false
 	})
 	return write(ctx, out)
 }
+
+// recordComparator returns a function which compares records by the values of
+// each field in turn, returning the first non-zero comparison.  Values which
+// cannot be compared according to the field's type, e.g. invalid numbers, are
+// compared as case-insensitive strings.
+func recordComparator(fields []string, l *adif.Logfile, locale language.Tag) func(a, b *adif.Record) int {
+	comps := make([]func(a, b *adif.Record) (int, error), len(fields))
+	for i, k := range fields {
+		comps[i] = fieldComparator(k, l, locale)
+	}
+	return func(a, b *adif.Record) int {
+		for i, k := range fields {
+			c, err := comps[i](a, b)
+			if err != nil {
+				af, _ := a.Get(k)
+				bf, _ := b.Get(k)
+				c = strings.Compare(strings.ToUpper(af.Value), strings.ToUpper(bf.Value))
+			}
+			if c != 0 {
+				return c
+			}
+		}
+		return 0
+	}
+}
+
+// fieldComparator returns a function which compares the value of field k in
+// two records.  Fields which are not in the ADIF specification or a userdef
+// in l are compared by the type of their values, e.g.
+// <APP_MONOLOG_AGE:2:N>42, or as strings.  An error is returned if values
+// cannot be compared according to the field's type, e.g. invalid numbers.
+func fieldComparator(k string, l *adif.Logfile, locale language.Tag) func(a, b *adif.Record) (int, error) {
+	var comp spec.FieldComparator
+	dynamic := make(map[adif.DataType]spec.FieldComparator)
+	if f, ok := spec.Fields[strings.ToUpper(k)]; ok {
+		comp = spec.ComparatorForField(f, locale)
+	} else if u, ok := l.GetUserdef(k); ok && u.Type.Indicator() != "" {
+		comp = spec.ComparatorForField(spec.Field{Name: k, Type: spec.DataTypes[u.Type.Indicator()]}, locale)
+	}
+	return func(a, b *adif.Record) (int, error) {
+		af, _ := a.Get(k)
+		bf, _ := b.Get(k)
+		c := comp
+		if c == nil {
+			t := commonType(af, bf)
+			if c = dynamic[t]; c == nil {
+				dt := spec.StringDataType
+				if t != adif.TypeUnspecified {
+					dt = spec.DataTypes[t.Indicator()]
+				}
+				c = spec.ComparatorForField(spec.Field{Name: k, Type: dt}, locale)
+				dynamic[t] = c
+			}
+		}
+		return c(af.Value, bf.Value)
+	}
+}
+
+// commonType returns the type of a and b if they have the same type or only one
+// has a type, or TypeUnspecified if their types differ.
+func commonType(a, b adif.Field) adif.DataType {
+	switch {
+	case a.Type == b.Type, b.Type == adif.TypeUnspecified:
+		return a.Type
+	case a.Type == adif.TypeUnspecified:
+		return b.Type
+	}
+	return adif.TypeUnspecified // type confusion, compare as strings
+}
@@ -205,6 +205,20 @@ func TestSortCondition(t *testing.T) {
 20020202,0202,KL7K,7.123,AK
 20040404,0404,N5M,7,NM
 20010101,0101,K1K,1.888,CT
+`,
+		},
+		{
+			name:   "invalid number compares equal",
+			fields: FieldList{"freq"},
+			file: `CALL,FREQ
+W1W,abc
+K1K,14
+N5N,7
+`,
+			want: `CALL,FREQ
+W1W,abc
+N5N,7
+K1K,14
 `,
 		},
 		{
@@ -0,0 +1,352 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/flwyd/adif-multitool/adif"
+	"github.com/flwyd/adif-multitool/adif/spec"
+)
+
+var Sota = Command{Name: "sota", Run: runSota, Help: helpSota,
+	Description: "Summarize Summits on the Air activations and chases, export SOTA CSV"}
+
+const (
+	sotaActivationQSOs = 4
+	sotaRoleActivator  = "ACTIVATOR"
+	sotaRoleChaser     = "CHASER"
+	sotaRoleField      = "ROLE"
+	sotaSummitField    = "SUMMIT"
+	sotaQSOsField      = "QSOS"
+	sotaS2SField       = "S2S"
+	sotaActivatedField = "ACTIVATED"
+	sotaPointsField    = "POINTS"
+)
+
+type SotaContext struct {
+	SummitsFile string
+	ExportFile  string
+	Quiet       bool
+	report      io.Writer // invalid references are written here, os.Stderr if nil
+}
+
+func helpSota() string {
+	return fmt.Sprintf(`Outputs a record for each SOTA activation (%s) and chase (%s)
+grouped by %s, summit, and UTC %s, with the number of %s (distinct %s),
+summit-to-summit contacts (%s), and, for activations, whether it was %s
+with at least %d QSOs.
+
+--summits reads a summits list in the summitslist.csv format from
+https://www.sotadata.org.uk/ and adds %s: summit points for the first
+activation of each summit per calendar year and for each summit chased per
+UTC day.  Seasonal bonus points are not included.
+
+--export writes records with %s or %s to a file in the SOTA database CSV
+"V2" format for upload.
+
+Records with an invalid SOTA reference are listed on standard error and are
+not included in the summary or export.
+`,
+		spec.MySotaRefField.Name, spec.SotaRefField.Name,
+		spec.StationCallsignField.Name, spec.QsoDateField.Name, sotaQSOsField, spec.CallField.Name,
+		sotaS2SField, sotaActivatedField, sotaActivationQSOs,
+		sotaPointsField, spec.MySotaRefField.Name, spec.SotaRefField.Name)
+}
+
+type sotaKey struct{ call, role, summit, date string }
+
+type sotaGroup struct {
+	key      sotaKey
+	contacts map[string]bool
+	s2s      int
+}
+
+func runSota(ctx *Context, args []string) error {
+	cctx := ctx.CommandCtx.(*SotaContext)
+	var points map[string]int
+	if cctx.SummitsFile != "" {
+		var err error
+		if points, err = readSotaSummits(ctx, cctx.SummitsFile); err != nil {
+			return err
+		}
+	}
+	groups := make(map[sotaKey]*sotaGroup)
+	var export []*adif.Record
+	report := cctx.report
+	if report == nil {
+		report = os.Stderr
+	}
+	skipped := 0
+	recNum := make(map[*adif.Logfile]int)
+	validate := spec.TypeValidators[spec.SOTARefDataType.Name]
+	err := forEachRecord(ctx, args, func(r *adif.Record, l *adif.Logfile) error {
+		recNum[l]++
+		mine, _ := r.Get(spec.MySotaRefField.Name)
+		theirs, _ := r.Get(spec.SotaRefField.Name)
+		if mine.Value == "" && theirs.Value == "" {
+			return nil
+		}
+		for _, f := range []adif.Field{mine, theirs} {
+			if f.Value == "" {
+				continue
+			}
+			if v := validate(f.Value, spec.Fields[f.Name], spec.ValidationContext{}); v.Validity != spec.Valid {
+				skipped++
+				fmt.Fprintf(report, "skipping %s record %d: %s\n", l, recNum[l], v.Message)
+				return nil
+			}
+		}
+		if cctx.ExportFile != "" {
+			export = append(export, r)
+		}
+		call, _ := r.Get(spec.StationCallsignField.Name)
+		date, _ := r.Get(spec.QsoDateField.Name)
+		other, _ := r.Get(spec.CallField.Name)
+		s2s := mine.Value != "" && theirs.Value != ""
+		add := func(role, summit string) {
+			k := sotaKey{call: strings.ToUpper(call.Value), role: role, summit: strings.ToUpper(summit), date: date.Value}
+			g := groups[k]
+			if g == nil {
+				g = &sotaGroup{key: k, contacts: make(map[string]bool)}
+				groups[k] = g
+			}
+			g.contacts[strings.ToUpper(other.Value)] = true
+			if s2s {
+				g.s2s++
+			}
+		}
+		if mine.Value != "" {
+			add(sotaRoleActivator, mine.Value)
+		}
+		if theirs.Value != "" {
+			add(sotaRoleChaser, theirs.Value)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if skipped > 0 {
+		fmt.Fprintf(report, "sota skipped %d records with invalid SOTA references\n", skipped)
+	}
+	sorted := make([]*sotaGroup, 0, len(groups))
+	for _, g := range groups {
+		sorted = append(sorted, g)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		a, b := sorted[i].key, sorted[j].key
+		if a.date != b.date {
+			return a.date < b.date
+		}
+		if a.call != b.call {
+			return a.call < b.call
+		}
+		if a.role != b.role {
+			return a.role < b.role
+		}
+		return a.summit < b.summit
+	})
+	if cctx.ExportFile != "" {
+		if err := writeSotaExport(ctx, cctx, export); err != nil {
+			return err
+		}
+	}
+	out := adif.NewLogfile()
+	out.FieldOrder = []string{spec.StationCallsignField.Name, sotaRoleField, sotaSummitField, spec.QsoDateField.Name, sotaQSOsField, sotaS2SField, sotaActivatedField}
+	if points != nil {
+		out.FieldOrder = append(out.FieldOrder, sotaPointsField)
+	}
+	// activator points are only awarded once per summit per year
+	claimed := make(map[string]bool)
+	for _, g := range sorted {
+		r := adif.NewRecord(
+			adif.Field{Name: spec.StationCallsignField.Name, Value: g.key.call},
+			adif.Field{Name: sotaRoleField, Value: g.key.role},
+			adif.Field{Name: sotaSummitField, Value: g.key.summit},
+			adif.Field{Name: spec.QsoDateField.Name, Value: g.key.date},
+			adif.Field{Name: sotaQSOsField, Value: strconv.Itoa(len(g.contacts))},
+			adif.Field{Name: sotaS2SField, Value: strconv.Itoa(g.s2s)},
+		)
+		activated := ""
+		pts := points[g.key.summit]
+		if g.key.role == sotaRoleActivator {
+			activated = "N"
+			if len(g.contacts) >= sotaActivationQSOs {
+				activated = "Y"
+			}
+			year := g.key.date
+			if len(year) > 4 {
+				year = year[:4]
+			}
+			claim := strings.Join([]string{g.key.call, g.key.summit, year}, " ")
+			if activated == "N" || claimed[claim] {
+				pts = 0
+			}
+			claimed[claim] = claimed[claim] || activated == "Y"
+		}
+		r.Set(adif.Field{Name: sotaActivatedField, Value: activated})
+		if points != nil {
+			r.Set(adif.Field{Name: sotaPointsField, Value: strconv.Itoa(pts)})
+		}
+		out.AddRecord(r)
+	}
+	return write(ctx, out)
+}
+
+// readSotaSummits reads summit codes and points from a SOTA summits list CSV
+// file, which starts with a title line followed by a header row.
+func readSotaSummits(ctx *Context, filename string) (map[string]int, error) {
+	fs := ctx.fs
+	if fs == nil {
+		fs = osFilesystem{}
+	}
+	f, err := fs.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	c := csv.NewReader(f)
+	c.FieldsPerRecord = -1
+	codeCol, pointsCol := -1, -1
+	res := make(map[string]int)
+	for {
+		row, err := c.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading summits file %s: %w", filename, err)
+		}
+		if codeCol < 0 {
+			for i, col := range row {
+				switch strings.TrimSpace(col) {
+				case "SummitCode":
+					codeCol = i
+				case "Points":
+					pointsCol = i
+				}
+			}
+			if codeCol >= 0 && pointsCol < 0 {
+				return nil, fmt.Errorf("summits file %s has no Points column", filename)
+			}
+			continue
+		}
+		if len(row) <= codeCol || len(row) <= pointsCol {
+			continue
+		}
+		p, err := strconv.Atoi(strings.TrimSpace(row[pointsCol]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid points for %s in %s: %w", row[codeCol], filename, err)
+		}
+		res[strings.ToUpper(strings.TrimSpace(row[codeCol]))] = p
+	}
+	if codeCol < 0 {
+		return nil, fmt.Errorf("summits file %s has no SummitCode header", filename)
+	}
+	return res, nil
+}
+
+// writeSotaExport writes records in the SOTA database "V2" CSV format:
+// V2, my callsign, my summit, date (dd/mm/yy), time (hhmm), frequency or band,
+// mode, other callsign, other summit, notes.
+func writeSotaExport(ctx *Context, cctx *SotaContext, recs []*adif.Record) error {
+	fs := ctx.fs
+	if fs == nil {
+		fs = osFilesystem{}
+	}
+	if fs.Exists(cctx.ExportFile) {
+		return fmt.Errorf("output file %s already exists", cctx.ExportFile)
+	}
+	rows := make([][]string, len(recs))
+	for i, r := range recs {
+		get := func(f spec.Field) string {
+			v, _ := r.Get(f.Name)
+			return strings.TrimSpace(v.Value)
+		}
+		call := get(spec.StationCallsignField)
+		if call == "" {
+			call = get(spec.OperatorField)
+		}
+		date := get(spec.QsoDateField)
+		if len(date) != 8 {
+			return fmt.Errorf("invalid %s %q in record %s", spec.QsoDateField.Name, date, r)
+		}
+		time := get(spec.TimeOnField)
+		if len(time) < 4 {
+			return fmt.Errorf("invalid %s %q in record %s", spec.TimeOnField.Name, time, r)
+		}
+		if call == "" || get(spec.CallField) == "" {
+			return fmt.Errorf("missing %s or %s in record %s", spec.StationCallsignField.Name, spec.CallField.Name, r)
+		}
+		rows[i] = []string{"V2",
+			strings.ToUpper(call),
+			strings.ToUpper(get(spec.MySotaRefField)),
+			date[6:8] + "/" + date[4:6] + "/" + date[2:4],
+			time[0:4],
+			sotaFrequency(get(spec.FreqField), get(spec.BandField)),
+			sotaMode(get(spec.ModeField)),
+			strings.ToUpper(get(spec.CallField)),
+			strings.ToUpper(get(spec.SotaRefField)),
+			get(spec.CommentField),
+		}
+	}
+	err := writeFileAtomic(fs, cctx.ExportFile, func(out io.Writer) error {
+		w := csv.NewWriter(out)
+		w.WriteAll(rows)
+		return w.Error()
+	})
+	if err != nil {
+		return err
+	}
+	if !cctx.Quiet {
+		fmt.Fprintf(os.Stderr, "Wrote %d records to %s\n", len(rows), cctx.ExportFile)
+	}
+	return nil
+}
+
+// sotaFrequency returns freq in MHz or the lower edge of band, e.g. 14MHz.
+func sotaFrequency(freq, band string) string {
+	if f, err := strconv.ParseFloat(freq, 64); err == nil {
+		return formatNumber(f, 6) + "MHz"
+	}
+	for _, b := range spec.BandEnumeration.Value(band) {
+		if f, err := strconv.ParseFloat(b.(spec.BandEnum).LowerFreqMhz, 64); err == nil {
+			return formatNumber(f, 6) + "MHz"
+		}
+	}
+	return band
+}
+
+// sotaMode converts an ADIF mode to one of the SOTA database modes.
+func sotaMode(mode string) string {
+	switch m := strings.ToUpper(mode); m {
+	case "AM", "CW", "FM", "SSB":
+		return m
+	case "DIGITALVOICE":
+		return "DV"
+	case "":
+		return "Other"
+	default:
+		return "Data"
+	}
+}
@@ -0,0 +1,189 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/flwyd/adif-multitool/adif"
+	"github.com/google/go-cmp/cmp"
+)
+
+const sotaTestLog = `STATION_CALLSIGN,CALL,QSO_DATE,TIME_ON,BAND,FREQ,MODE,MY_SOTA_REF,SOTA_REF,COMMENT
+W7ABC/P,K1A,20230601,1500,20m,14.062,CW,W7W/KG-001,,
+W7ABC/P,K2B,20230601,1501,20m,,CW,W7W/KG-001,,
+W7ABC/P,K2B,20230601,1502,40m,,SSB,W7W/KG-001,,
+W7ABC/P,K3C,20230601,1503,2m,,FM,W7W/KG-001,W7W/LC-002,"s2s, nice"
+W7ABC/P,K4D,20230601,1504,20m,,FT8,W7W/KG-001,,
+W7ABC/P,K5E,20230701,1600,20m,,CW,W7W/KG-001,,
+W7ABC/P,K6F,20230701,1601,20m,,CW,W7W/KG-001,,
+W7ABC/P,K7G,20230701,1602,20m,,CW,W7W/KG-001,,
+W7ABC/P,K8H,20230701,1603,20m,,CW,W7W/KG-001,,
+W7ABC,G4X/P,20230702,0900,20m,,SSB,,G/LD-003,
+W7ABC,G5Y/P,20230702,0930,20m,,SSB,,G/LD-003,
+W7ABC,K9Z,20230702,0930,20m,,SSB,,,
+`
+
+const sotaTestSummits = `SOTA Summits List (Date=01/06/2023)
+SummitCode,AssociationName,RegionName,SummitName,AltM,AltFt,GridRef1,GridRef2,Longitude,Latitude,Points,BonusPoints,ValidFrom,ValidTo,ActivationCount,ActivationDate,ActivationCall
+W7W/KG-001,USA - Washington,King,Mount Example,2000,6562,-121.5,47.5,-121.5,47.5,8,3,01/01/2010,31/12/2099,3,01/05/2023,W7XYZ
+W7W/LC-002,USA - Washington,Lewis,Other Peak,1500,4921,-122.0,46.5,-122.0,46.5,6,3,01/01/2010,31/12/2099,0,,
+G/LD-003,England,Lake District,Helvellyn,950,3117,NY342151,,-3.0,54.5,10,3,01/01/2002,31/12/2099,900,01/06/2023,G4X
+`
+
+func TestSota(t *testing.T) {
+	csv := adif.NewCSVIO()
+	tests := []struct {
+		name, want string
+		ctx        SotaContext
+	}{
+		{
+			name: "no points",
+			want: `STATION_CALLSIGN,ROLE,SUMMIT,QSO_DATE,QSOS,S2S,ACTIVATED
+W7ABC/P,ACTIVATOR,W7W/KG-001,20230601,4,1,Y
+W7ABC/P,CHASER,W7W/LC-002,20230601,1,1,
+W7ABC/P,ACTIVATOR,W7W/KG-001,20230701,4,0,Y
+W7ABC,CHASER,G/LD-003,20230702,2,0,
+`,
+		},
+		{
+			name: "with points",
+			ctx:  SotaContext{SummitsFile: "summits.csv"},
+			want: `STATION_CALLSIGN,ROLE,SUMMIT,QSO_DATE,QSOS,S2S,ACTIVATED,POINTS
+W7ABC/P,ACTIVATOR,W7W/KG-001,20230601,4,1,Y,8
+W7ABC/P,CHASER,W7W/LC-002,20230601,1,1,,6
+W7ABC/P,ACTIVATOR,W7W/KG-001,20230701,4,0,Y,0
+W7ABC,CHASER,G/LD-003,20230702,2,0,,10
+`,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			cctx := tc.ctx
+			ctx := &Context{
+				OutputFormat: adif.FormatCSV,
+				Readers:      readers(csv),
+				Writers:      writers(csv),
+				Out:          out,
+				fs:           fakeFilesystem{map[string]string{"log.csv": sotaTestLog, "summits.csv": sotaTestSummits}},
+				CommandCtx:   &cctx}
+			if err := Sota.Run(ctx, []string{"log.csv"}); err != nil {
+				t.Fatalf("Sota.Run(%+v, log.csv) got error %v", tc.ctx, err)
+			}
+			if diff := cmp.Diff(tc.want, out.String()); diff != "" {
+				t.Errorf("Sota.Run(%+v, log.csv) unexpected output, diff:\n%s", tc.ctx, diff)
+			}
+		})
+	}
+}
+
+func TestSotaExport(t *testing.T) {
+	csv := adif.NewCSVIO()
+	fs := fakeFilesystem{map[string]string{"log.csv": sotaTestLog}}
+	ctx := &Context{
+		OutputFormat: adif.FormatCSV,
+		Readers:      readers(csv),
+		Writers:      writers(csv),
+		Out:          &bytes.Buffer{},
+		fs:           fs,
+		CommandCtx:   &SotaContext{ExportFile: "sota.csv", Quiet: true}}
+	if err := Sota.Run(ctx, []string{"log.csv"}); err != nil {
+		t.Fatalf("Sota.Run(log.csv) got error %v", err)
+	}
+	want := `V2,W7ABC/P,W7W/KG-001,01/06/23,1500,14.062MHz,CW,K1A,,
+V2,W7ABC/P,W7W/KG-001,01/06/23,1501,14MHz,CW,K2B,,
+V2,W7ABC/P,W7W/KG-001,01/06/23,1502,7MHz,SSB,K2B,,
+V2,W7ABC/P,W7W/KG-001,01/06/23,1503,144MHz,FM,K3C,W7W/LC-002,"s2s, nice"
+V2,W7ABC/P,W7W/KG-001,01/06/23,1504,14MHz,Data,K4D,,
+V2,W7ABC/P,W7W/KG-001,01/07/23,1600,14MHz,CW,K5E,,
+V2,W7ABC/P,W7W/KG-001,01/07/23,1601,14MHz,CW,K6F,,
+V2,W7ABC/P,W7W/KG-001,01/07/23,1602,14MHz,CW,K7G,,
+V2,W7ABC/P,W7W/KG-001,01/07/23,1603,14MHz,CW,K8H,,
+V2,W7ABC,,02/07/23,0900,14MHz,SSB,G4X/P,G/LD-003,
+V2,W7ABC,,02/07/23,0930,14MHz,SSB,G5Y/P,G/LD-003,
+`
+	if diff := cmp.Diff(want, fs.files["sota.csv"]); diff != "" {
+		t.Errorf("Sota.Run(log.csv) unexpected export, diff:\n%s", diff)
+	}
+	if err := Sota.Run(ctx, []string{"log.csv"}); err == nil {
+		t.Errorf("Sota.Run(log.csv) with existing export file got no error")
+	}
+}
+
+func TestSotaInvalidRef(t *testing.T) {
+	csv := adif.NewCSVIO()
+	log := `STATION_CALLSIGN,CALL,QSO_DATE,TIME_ON,BAND,MODE,MY_SOTA_REF,SOTA_REF
+W7ABC/P,K1A,20230601,1500,20m,CW,W7W/KG-001,
+W7ABC/P,K2B,20230601,1501,20m,CW,W7W-KG-001,
+W7ABC/P,K3C,20230601,1502,20m,CW,W7W/KG-001,LC-002
+W7ABC/P,K4D,20230601,1503,20m,CW,W7W/KG-001,
+`
+	out, report := &bytes.Buffer{}, &bytes.Buffer{}
+	fs := fakeFilesystem{map[string]string{"log.csv": log}}
+	ctx := &Context{
+		OutputFormat: adif.FormatCSV,
+		Readers:      readers(csv),
+		Writers:      writers(csv),
+		Out:          out,
+		fs:           fs,
+		CommandCtx:   &SotaContext{ExportFile: "sota.csv", Quiet: true, report: report}}
+	if err := Sota.Run(ctx, []string{"log.csv"}); err != nil {
+		t.Fatalf("Sota.Run(log.csv) got error %v", err)
+	}
+	want := `STATION_CALLSIGN,ROLE,SUMMIT,QSO_DATE,QSOS,S2S,ACTIVATED
+W7ABC/P,ACTIVATOR,W7W/KG-001,20230601,2,0,N
+`
+	if diff := cmp.Diff(want, out.String()); diff != "" {
+		t.Errorf("Sota.Run(log.csv) unexpected output, diff:\n%s", diff)
+	}
+	if got := strings.Count(fs.files["sota.csv"], "\n"); got != 2 {
+		t.Errorf("Sota.Run(log.csv) exported %d records, want 2:\n%s", got, fs.files["sota.csv"])
+	}
+	for _, w := range []string{"log.csv record 2", "log.csv record 3", "skipped 2 records"} {
+		if !strings.Contains(report.String(), w) {
+			t.Errorf("Sota.Run(log.csv) report missing %q:\n%s", w, report.String())
+		}
+	}
+}
+
+func TestSotaErrors(t *testing.T) {
+	csv := adif.NewCSVIO()
+	for _, tc := range []struct {
+		name, log, summits string
+	}{
+		{name: "no summits header", log: sotaTestLog, summits: "W7W/KG-001,8\n"},
+		{name: "bad points", log: sotaTestLog, summits: "SummitCode,Points\nW7W/KG-001,eight\n"},
+	} {
+		files := map[string]string{"log.csv": tc.log}
+		cctx := &SotaContext{}
+		if tc.summits != "" {
+			files["summits.csv"] = tc.summits
+			cctx.SummitsFile = "summits.csv"
+		}
+		ctx := &Context{
+			OutputFormat: adif.FormatCSV,
+			Readers:      readers(csv),
+			Writers:      writers(csv),
+			Out:          &bytes.Buffer{},
+			fs:           fakeFilesystem{files},
+			CommandCtx:   cctx}
+		if err := Sota.Run(ctx, []string{"log.csv"}); err == nil {
+			t.Errorf("%s: Sota.Run got no error", tc.name)
+		}
+	}
+}
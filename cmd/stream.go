@@ -0,0 +1,279 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/flwyd/adif-multitool/adif"
+)
+
+// recordFunc processes record r from input log in, where n is the position of
+// r in that log, starting at 0.  It returns the record to output, or nil to
+// skip the record.  in has header, userdef, and field order data but no
+// Records.
+type recordFunc func(r *adif.Record, in *adif.Logfile, n int) (*adif.Record, error)
+
+// recordStream reads input files one record at a time and, if the output
+// format supports it, writes each record as soon as it has been processed, so
+// large logs don't need to fit in memory.  Commands which need to see all
+// records before producing output, like sort, use accumulator instead.
+type recordStream struct {
+	Ctx *Context
+	Out *adif.Logfile
+	// FixedFieldOrder prevents input field order from being added to Out.
+	FixedFieldOrder bool
+	// Buffer holds all output records until finish is called, e.g. so that
+	// nothing is written if there was an error in any record.
+	Buffer bool
+	// KnownFields means fn only returns fields in Out.FieldOrder or, unless
+	// FixedFieldOrder is set, in the field order of the record's input.
+	// Formats with a header row, like CSV, only stream if fields are known
+	// and every input file lists its fields, e.g. CSV input.
+	KnownFields bool
+	// Header, if set, is called after input headers are merged into Out and
+	// before any records are processed, e.g. to change field order.
+	Header func(out *adif.Logfile) error
+
+	acc accumulator
+	w   adif.RecordWriter
+	cur *streamInput
+}
+
+// streamInput is an open file whose records have not been read yet.  file is
+// nil if input comes from a previous pipeline stage.
+type streamInput struct {
+	file    NamedReader
+	log     *adif.Logfile
+	records adif.RecordIterator
+}
+
+// sliceRecords iterates over records from a format without streaming support.
+type sliceRecords struct{ recs []*adif.Record }
+
+func (it *sliceRecords) Next() (*adif.Record, error) {
+	if len(it.recs) == 0 {
+		return nil, io.EOF
+	}
+	r := it.recs[0]
+	it.recs = it.recs[1:]
+	return r, nil
+}
+
+func openStream(ctx *Context, filename string) (*streamInput, error) {
+	if ctx.pipe != nil && ctx.pipe.in != nil && isStdin(filename) {
+		l, err := ctx.pipe.input()
+		if err != nil {
+			return nil, err
+		}
+		header := *l
+		header.Records = nil
+		return &streamInput{log: &header, records: &sliceRecords{recs: l.Records}}, nil
+	}
+	f, ior, format, err := openFile(ctx, filename)
+	if err != nil {
+		return nil, err
+	}
+	in := &streamInput{file: f, log: adif.NewLogfile()}
+	r := ctx.Readers[format]
+	if sr, ok := r.(adif.StreamReader); ok {
+		in.records, err = sr.ReadRecords(ior, adif.LogfileHandler(in.log))
+	} else {
+		var l *adif.Logfile
+		if l, err = r.Read(ior); err == nil {
+			in.records = &sliceRecords{recs: l.Records}
+			l.Records = nil
+			in.log = l
+		}
+	}
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("error reading %s: %w", f.Name(), err)
+	}
+	in.log.Filename = f.Name()
+	return in, nil
+}
+
+// process opens all files in args, merging their headers into s.Out, then
+// calls fn on each record in turn.  Records are written or, if s.Buffer is set
+// or the output format doesn't support streaming, added to s.Out.
+func (s *recordStream) process(args []string, fn recordFunc) error {
+	s.acc = accumulator{Out: s.Out, Ctx: s.Ctx}
+	var inputs []*streamInput
+	defer func() {
+		for _, in := range inputs {
+			if in.file != nil {
+				in.file.Close()
+			}
+		}
+	}()
+	for _, f := range filesOrStdin(args) {
+		in, err := openStream(s.Ctx, f)
+		if err != nil {
+			return err
+		}
+		inputs = append(inputs, in)
+		s.acc.merge(in.log)
+		if !s.FixedFieldOrder {
+			updateFieldOrder(s.Out, in.log.FieldOrder)
+		}
+	}
+	for _, u := range s.Ctx.UserdefFields {
+		if err := s.Out.AddUserdef(u); err != nil {
+			return err
+		}
+	}
+	if s.Header != nil {
+		if err := s.Header(s.Out); err != nil {
+			return err
+		}
+	}
+	w, err := outputWriter(s.Ctx)
+	if err != nil {
+		return err
+	}
+	sw, streaming := w.(adif.StreamWriter)
+	streaming = streaming && !s.Buffer && (s.Ctx.pipe == nil || !s.Ctx.pipe.capture)
+	if streaming && hasHeaderRow(w) {
+		streaming = s.KnownFields
+		for _, in := range inputs {
+			streaming = streaming && (s.FixedFieldOrder || (in.file != nil && len(in.log.FieldOrder) > 0))
+		}
+	}
+	for _, in := range inputs {
+		s.cur = in
+		for n := 0; ; n++ {
+			r, err := in.records.Next()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return fmt.Errorf("error reading %s: %w", in.log, err)
+			}
+			if r, err = fn(r, in.log, n); err != nil {
+				return err
+			}
+			if r == nil {
+				continue
+			}
+			if !streaming {
+				s.Out.AddRecord(r)
+				continue
+			}
+			if s.w == nil {
+				if s.Ctx.Prepare != nil {
+					s.Ctx.Prepare(s.Out)
+				}
+				if s.w, err = sw.WriteHeader(s.Out, s.Ctx.Out); err != nil {
+					return err
+				}
+			}
+			if err := s.w.WriteRecord(r); err != nil {
+				return err
+			}
+		}
+		s.acc.addComment(in.log)
+	}
+	return nil
+}
+
+// helpStreamOutput explains what happens to streamed output when a command
+// fails partway through its input.  example is the command and its options.
+func helpStreamOutput(example string) string {
+	return fmt.Sprintf(`Records are written as they are processed, so if a later record or input
+file has an error, output before the error has already been written and the
+last record may be incomplete.  The exit status is non-zero in that case; to
+avoid keeping partial output, write to a temporary file and rename it on
+success, e.g.
+  adifmt %s log.adi > out.tmp && mv out.tmp out.adi
+`, example)
+}
+
+// hasHeaderRow returns true if w writes a row of field names before any
+// records, so every field needs to be known before the first record.
+func hasHeaderRow(w adif.Writer) bool {
+	switch w.(type) {
+	case *adif.CSVIO, *adif.TSVIO:
+		return true
+	}
+	return false
+}
+
+// position returns where the record being processed starts in its input, if
+// the input format keeps track of positions.
+func (s *recordStream) position() (adif.Position, bool) {
+	if s.cur == nil {
+		return adif.Position{}, false
+	}
+	p, ok := s.cur.records.(adif.PositionedIterator)
+	if !ok {
+		return adif.Position{}, false
+	}
+	return p.Position(), true
+}
+
+// finish writes any records which have not been written yet, followed by the
+// combined comments of all input files.
+func (s *recordStream) finish() error {
+	if err := s.acc.prepare(); err != nil {
+		return err
+	}
+	if s.w == nil {
+		return write(s.Ctx, s.Out)
+	}
+	return s.w.Close()
+}
+
+// run processes all records in args with fn and writes the output.
+func (s *recordStream) run(args []string, fn recordFunc) error {
+	if err := s.process(args, fn); err != nil {
+		return err
+	}
+	return s.finish()
+}
+
+// forEachRecord calls fn on each record in the files named by args, one at a
+// time, for commands which summarize input rather than writing it.
+func forEachRecord(ctx *Context, args []string, fn func(r *adif.Record, in *adif.Logfile) error) error {
+	for _, f := range filesOrStdin(args) {
+		in, err := openStream(ctx, f)
+		if err != nil {
+			return err
+		}
+		err = func() error {
+			if in.file != nil {
+				defer in.file.Close()
+			}
+			for {
+				r, err := in.records.Next()
+				if errors.Is(err, io.EOF) {
+					return nil
+				}
+				if err != nil {
+					return fmt.Errorf("error reading %s: %w", in.log, err)
+				}
+				if err := fn(r, in.log); err != nil {
+					return err
+				}
+			}
+		}()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,188 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/flwyd/adif-multitool/adif"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRecordStream(t *testing.T) {
+	adi := adif.NewADIIO()
+	csv := adif.NewCSVIO()
+	file1 := `CALL,BAND
+K1A,20m
+K2B,40m
+`
+	file2 := `<USERDEF1:5:S>MY_ID <EOH>
+<CALL:3>K3C <MY_ID:2>xy <EOR>
+Second file comment
+`
+	tests := []struct {
+		name     string
+		format   adif.Format
+		buffer   bool
+		buffered int
+		want     string
+	}{
+		{
+			name:     "streaming ADI",
+			format:   adif.FormatADI,
+			buffered: 0,
+			want: `My Comment
+<ADIF_VER:5>3.1.4 <PROGRAMID:11>stream test <PROGRAMVERSION:5>1.2.3 <USERDEF1:5:S>MY_ID <EOH>
+<CALL:3>K1A <EOR>
+<CALL:3>K3C <MY_ID:2>xy <EOR>
+adif-multitool: original comment (bar.adi)
+Second file comment
+`,
+		},
+		{
+			name:     "buffered ADI",
+			format:   adif.FormatADI,
+			buffer:   true,
+			buffered: 2,
+			want: `My Comment
+<ADIF_VER:5>3.1.4 <PROGRAMID:11>stream test <PROGRAMVERSION:5>1.2.3 <USERDEF1:5:S>MY_ID <EOH>
+<CALL:3>K1A <EOR>
+<CALL:3>K3C <MY_ID:2>xy <EOR>
+adif-multitool: original comment (bar.adi)
+Second file comment
+`,
+		},
+		{
+			name:     "CSV does not stream",
+			format:   adif.FormatCSV,
+			buffered: 2,
+			want: `CALL,MY_ID
+K1A,
+K3C,xy
+`,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			ctx := &Context{
+				OutputFormat: tc.format,
+				Readers:      readers(adi, csv),
+				Writers:      writers(adi, csv),
+				Out:          out,
+				Prepare:      testPrepare("My Comment", "3.1.4", "stream test", "1.2.3"),
+				fs:           fakeFilesystem{map[string]string{"foo.csv": file1, "bar.adi": file2}}}
+			l := adif.NewLogfile()
+			l.FieldOrder = []string{"CALL"}
+			s := recordStream{Ctx: ctx, Out: l, FixedFieldOrder: true, Buffer: tc.buffer}
+			err := s.run([]string{"foo.csv", "bar.adi"}, func(r *adif.Record, in *adif.Logfile, n int) (*adif.Record, error) {
+				if n == 1 && in.Filename == "foo.csv" {
+					return nil, nil
+				}
+				res := adif.NewRecord()
+				for _, name := range []string{"CALL", "MY_ID"} {
+					if f, ok := r.Get(name); ok {
+						res.Set(f)
+					}
+				}
+				return res, nil
+			})
+			if err != nil {
+				t.Fatalf("recordStream.run got error %v", err)
+			}
+			if len(l.Records) != tc.buffered {
+				t.Errorf("recordStream.run buffered %d records, want %d", len(l.Records), tc.buffered)
+			}
+			if diff := cmp.Diff(tc.want, out.String()); diff != "" {
+				t.Errorf("recordStream.run unexpected output, diff:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRecordStreamKnownFields(t *testing.T) {
+	adi := adif.NewADIIO()
+	csv := adif.NewCSVIO()
+	file1 := `CALL,BAND
+K1A,20m
+K2B,40m
+`
+	file2 := `MODE,CALL
+CW,K3C
+`
+	file3 := `<CALL:3>K4D <BAND:3>80m <EOR>
+`
+	tests := []struct {
+		name     string
+		files    []string
+		known    bool
+		buffered int
+		want     string
+	}{
+		{
+			name: "CSV input streams", files: []string{"foo.csv", "bar.csv"}, known: true,
+			buffered: 0,
+			want: `CALL,BAND,MODE
+K1A,20m,
+K2B,40m,
+K3C,,CW
+`,
+		},
+		{
+			name: "fields not known", files: []string{"foo.csv", "bar.csv"},
+			buffered: 3,
+			want: `CALL,BAND,MODE
+K1A,20m,
+K2B,40m,
+K3C,,CW
+`,
+		},
+		{
+			name: "ADI input does not list fields", files: []string{"foo.csv", "baz.adi"}, known: true,
+			buffered: 3,
+			want: `CALL,BAND
+K1A,20m
+K2B,40m
+K4D,80m
+`,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			ctx := &Context{
+				OutputFormat: adif.FormatCSV,
+				Readers:      readers(adi, csv),
+				Writers:      writers(adi, csv),
+				Out:          out,
+				fs:           fakeFilesystem{map[string]string{"foo.csv": file1, "bar.csv": file2, "baz.adi": file3}}}
+			l := adif.NewLogfile()
+			s := recordStream{Ctx: ctx, Out: l, KnownFields: tc.known}
+			err := s.run(tc.files, func(r *adif.Record, _ *adif.Logfile, _ int) (*adif.Record, error) {
+				return r, nil
+			})
+			if err != nil {
+				t.Fatalf("recordStream.run got error %v", err)
+			}
+			if len(l.Records) != tc.buffered {
+				t.Errorf("recordStream.run buffered %d records, want %d", len(l.Records), tc.buffered)
+			}
+			if diff := cmp.Diff(tc.want, out.String()); diff != "" {
+				t.Errorf("recordStream.run unexpected output, diff:\n%s", diff)
+			}
+		})
+	}
+}
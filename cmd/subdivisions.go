@@ -0,0 +1,269 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/flwyd/adif-multitool/adif/spec"
+)
+
+// subdivisionsDefaultDxcc is used if a subdivisions file has no DXCC column,
+// e.g. a list of US counties for USA-CA.
+const subdivisionsDefaultDxcc = 291
+
+// readSubdivisionsFile reads a CSV file of secondary administrative
+// subdivisions.  The header row names a CNTY column with values in ADIF
+// format, e.g. "MA,Middlesex" or a JCC/JCG number, and optional DXCC and STATE
+// columns.  DXCC defaults to 291 (United States) and STATE defaults to the
+// part of CNTY before a comma.  Other columns are ignored.
+func readSubdivisionsFile(ctx *Context, filename string) (*spec.Subdivisions, error) {
+	fs := ctx.fs
+	if fs == nil {
+		fs = osFilesystem{}
+	}
+	f, err := fs.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	c := csv.NewReader(f)
+	c.FieldsPerRecord = -1
+	c.TrimLeadingSpace = true
+	header, err := c.Read()
+	if err != nil {
+		return nil, fmt.Errorf("error reading subdivisions file %s: %w", filename, err)
+	}
+	dxccCol, stateCol, cntyCol := -1, -1, -1
+	for i, h := range header {
+		switch strings.ToUpper(strings.TrimSpace(strings.TrimPrefix(h, "\ufeff"))) {
+		case spec.DxccField.Name:
+			dxccCol = i
+		case spec.StateField.Name:
+			stateCol = i
+		case spec.CntyField.Name:
+			cntyCol = i
+		}
+	}
+	if cntyCol < 0 {
+		return nil, fmt.Errorf("subdivisions file %s has no %s column in header %q", filename, spec.CntyField.Name, strings.Join(header, ","))
+	}
+	res := spec.NewSubdivisions()
+	get := func(row []string, col int) string {
+		if col < 0 || col >= len(row) {
+			return ""
+		}
+		return strings.TrimSpace(row[col])
+	}
+	for {
+		row, err := c.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading subdivisions file %s: %w", filename, err)
+		}
+		cnty := get(row, cntyCol)
+		if cnty == "" {
+			continue
+		}
+		dxcc := subdivisionsDefaultDxcc
+		if d := get(row, dxccCol); d != "" {
+			if dxcc, err = strconv.Atoi(d); err != nil {
+				return nil, fmt.Errorf("invalid %s %q for %s in subdivisions file %s", spec.DxccField.Name, d, cnty, filename)
+			}
+		}
+		state := get(row, stateCol)
+		if stateCol < 0 {
+			state, _, _ = strings.Cut(cnty, ",")
+		}
+		res.Add(dxcc, state, cnty)
+	}
+	return res, nil
+}
+
+// boundaryDatabase has the areas of secondary administrative subdivisions for
+// finding the county which contains a location.
+type boundaryDatabase struct {
+	areas []boundaryArea
+}
+
+// boundaryArea is a subdivision made of one or more polygons, each of which
+// is a list of rings; the first ring is the outer boundary and the others are
+// holes.  Points are longitude, latitude pairs.
+type boundaryArea struct {
+	dxcc, state, cnty              string
+	minLat, maxLat, minLon, maxLon float64
+	polygons                       [][][][2]float64
+}
+
+// readBoundaryFile reads a GeoJSON FeatureCollection with a Polygon or
+// MultiPolygon feature for each subdivision.  Each feature has a CNTY property
+// with the ADIF value for the subdivision and optional STATE and DXCC
+// properties.
+func readBoundaryFile(ctx *Context, filename string) (*boundaryDatabase, error) {
+	fs := ctx.fs
+	if fs == nil {
+		fs = osFilesystem{}
+	}
+	f, err := fs.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	db, err := parseGeoJSONBoundaries(f)
+	if err != nil {
+		return nil, fmt.Errorf("error reading boundary file %s: %w", filename, err)
+	}
+	return db, nil
+}
+
+func parseGeoJSONBoundaries(r io.Reader) (*boundaryDatabase, error) {
+	var fc struct {
+		Type     string `json:"type"`
+		Features []struct {
+			Properties map[string]interface{} `json:"properties"`
+			Geometry   struct {
+				Type        string          `json:"type"`
+				Coordinates json.RawMessage `json:"coordinates"`
+			} `json:"geometry"`
+		} `json:"features"`
+	}
+	if err := json.NewDecoder(r).Decode(&fc); err != nil {
+		return nil, err
+	}
+	if fc.Type != "FeatureCollection" {
+		return nil, fmt.Errorf("expected GeoJSON FeatureCollection, got type %q", fc.Type)
+	}
+	db := &boundaryDatabase{}
+	for i, feat := range fc.Features {
+		a := boundaryArea{minLat: math.Inf(1), maxLat: math.Inf(-1), minLon: math.Inf(1), maxLon: math.Inf(-1)}
+		for k, v := range feat.Properties {
+			var s string
+			switch v := v.(type) {
+			case string:
+				s = strings.TrimSpace(v)
+			case float64:
+				s = strconv.FormatFloat(v, 'f', -1, 64)
+			default:
+				continue
+			}
+			switch strings.ToUpper(k) {
+			case spec.CntyField.Name:
+				a.cnty = s
+			case spec.StateField.Name:
+				a.state = s
+			case spec.DxccField.Name:
+				a.dxcc = s
+			}
+		}
+		if a.cnty == "" {
+			return nil, fmt.Errorf("feature %d has no %s property", i+1, spec.CntyField.Name)
+		}
+		var polys [][][][]float64
+		switch feat.Geometry.Type {
+		case "Polygon":
+			var p [][][]float64
+			if err := json.Unmarshal(feat.Geometry.Coordinates, &p); err != nil {
+				return nil, fmt.Errorf("feature %d %s: %w", i+1, a.cnty, err)
+			}
+			polys = append(polys, p)
+		case "MultiPolygon":
+			if err := json.Unmarshal(feat.Geometry.Coordinates, &polys); err != nil {
+				return nil, fmt.Errorf("feature %d %s: %w", i+1, a.cnty, err)
+			}
+		default:
+			return nil, fmt.Errorf("feature %d %s: unsupported geometry type %q", i+1, a.cnty, feat.Geometry.Type)
+		}
+		for _, poly := range polys {
+			rings := make([][][2]float64, 0, len(poly))
+			for _, ring := range poly {
+				pts := make([][2]float64, 0, len(ring))
+				for _, pt := range ring {
+					if len(pt) < 2 {
+						return nil, fmt.Errorf("feature %d %s: invalid position %v", i+1, a.cnty, pt)
+					}
+					lon, lat := pt[0], pt[1]
+					a.minLon, a.maxLon = math.Min(a.minLon, lon), math.Max(a.maxLon, lon)
+					a.minLat, a.maxLat = math.Min(a.minLat, lat), math.Max(a.maxLat, lat)
+					pts = append(pts, [2]float64{lon, lat})
+				}
+				rings = append(rings, pts)
+			}
+			a.polygons = append(a.polygons, rings)
+		}
+		db.areas = append(db.areas, a)
+	}
+	return db, nil
+}
+
+// lookup returns the first area containing lat/lon, skipping areas with a
+// DXCC or STATE which doesn't match the non-empty dxcc or state.
+func (db *boundaryDatabase) lookup(lat, lon float64, dxcc, state string) (boundaryArea, bool) {
+	for _, a := range db.areas {
+		if dxcc != "" && a.dxcc != "" && strings.TrimLeft(dxcc, "0") != strings.TrimLeft(a.dxcc, "0") {
+			continue
+		}
+		if state != "" && a.state != "" && !strings.EqualFold(state, a.state) {
+			continue
+		}
+		if a.contains(lat, lon) {
+			return a, true
+		}
+	}
+	return boundaryArea{}, false
+}
+
+func (a boundaryArea) contains(lat, lon float64) bool {
+	if lat < a.minLat || lat > a.maxLat || lon < a.minLon || lon > a.maxLon {
+		return false
+	}
+	for _, poly := range a.polygons {
+		if len(poly) == 0 || !ringContains(poly[0], lat, lon) {
+			continue
+		}
+		hole := false
+		for _, ring := range poly[1:] {
+			if ringContains(ring, lat, lon) {
+				hole = true
+				break
+			}
+		}
+		if !hole {
+			return true
+		}
+	}
+	return false
+}
+
+// ringContains uses the even-odd rule to determine whether lat/lon is inside
+// ring, treating coordinates as planar.
+func ringContains(ring [][2]float64, lat, lon float64) bool {
+	in := false
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		xi, yi, xj, yj := ring[i][0], ring[i][1], ring[j][0], ring[j][1]
+		if (yi > lat) != (yj > lat) && lon < (xj-xi)*(lat-yi)/(yj-yi)+xi {
+			in = !in
+		}
+	}
+	return in
+}
@@ -0,0 +1,164 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// layoutToken converts pattern to a Go layout for parsing.  format is the
+// layout for formatting, if different, e.g. zero-padded "02" for DD.
+type layoutToken struct{ pattern, layout, format string }
+
+// Letter patterns like DD/MM/YYYY for dates, longest first so YYYY is matched
+// before YY.  Date patterns are case-insensitive.  When parsing, two-letter
+// patterns also accept a single digit, e.g. 7/10/2023.
+var dateLayoutTokens = []layoutToken{
+	{"MONTH", "January", ""}, {"YYYY", "2006", ""}, {"MMM", "Jan", ""}, {"MON", "Jan", ""},
+	{"YY", "06", ""}, {"MM", "1", "01"}, {"DD", "2", "02"}, {"M", "1", ""}, {"D", "2", ""},
+}
+
+// Letter patterns like HH:MM for times.  Time patterns are case-sensitive so
+// that hh is a 12-hour clock; minutes can be MM or mm since there is no month.
+var timeLayoutTokens = []layoutToken{
+	{"HH", "15", ""}, {"hh", "3", "03"}, {"MM", "4", "04"}, {"mm", "4", "04"}, {"SS", "5", "05"}, {"ss", "5", "05"},
+	{"AM", "PM", ""}, {"PM", "PM", ""}, {"am", "pm", ""}, {"pm", "pm", ""},
+	{"H", "15", ""}, {"h", "3", ""}, {"M", "4", ""}, {"m", "4", ""}, {"S", "5", ""}, {"s", "5", ""},
+}
+
+var strftimeLayouts = map[byte]string{
+	'Y': "2006", 'y': "06", 'm': "1", 'd': "2", 'e': "_2", 'b': "Jan", 'h': "Jan", 'B': "January",
+	'H': "15", 'I': "3", 'M': "4", 'S': "5", 'p': "PM",
+	'F': "2006-1-2", 'T': "15:4:5", 'R': "15:4", '%': "%",
+}
+
+// strftimeFormats are zero-padded like strftime, for formatting.
+var strftimeFormats = map[byte]string{
+	'm': "01", 'd': "02", 'I': "03", 'M': "04", 'S': "05",
+	'F': "2006-01-02", 'T': "15:04:05", 'R': "15:04",
+}
+
+// dateLayout converts a date format like DD/MM/YYYY, %d/%m/%Y, or the Go
+// layout 02/01/2006 to a Go time layout.
+func dateLayout(s string) (string, error) {
+	return convertLayout(s, dateLayoutTokens, true, false)
+}
+
+// dateFormatLayout is like dateLayout, but for formatting, so DD/MM/YYYY
+// is 04/03/2023 rather than 4/3/2023.
+func dateFormatLayout(s string) (string, error) {
+	return convertLayout(s, dateLayoutTokens, true, true)
+}
+
+// timeLayout converts a time format like HH:MM, %H:%M, or the Go layout 15:04
+// to a Go time layout.
+func timeLayout(s string) (string, error) {
+	return convertLayout(s, timeLayoutTokens, false, false)
+}
+
+// timeFormatLayout is like timeLayout, but for formatting, so hh:MM is
+// 09:05 rather than 9:05.
+func timeFormatLayout(s string) (string, error) {
+	return convertLayout(s, timeLayoutTokens, false, true)
+}
+
+func convertLayout(s string, tokens []layoutToken, ignoreCase, format bool) (string, error) {
+	if strings.TrimSpace(s) == "" {
+		return "", fmt.Errorf("empty date/time format")
+	}
+	if strings.ContainsRune(s, '%') {
+		return strftimeLayout(s, format)
+	}
+	if strings.IndexFunc(s, unicode.IsDigit) >= 0 {
+		return s, nil // already a Go layout
+	}
+	var res strings.Builder
+	for rest := s; rest != ""; {
+		if r := rune(rest[0]); !unicode.IsLetter(r) {
+			res.WriteByte(rest[0])
+			rest = rest[1:]
+			continue
+		}
+		found := false
+		for _, t := range tokens {
+			if len(rest) < len(t.pattern) {
+				continue
+			}
+			p := rest[:len(t.pattern)]
+			if p == t.pattern || (ignoreCase && strings.EqualFold(p, t.pattern)) {
+				if format && t.format != "" {
+					res.WriteString(t.format)
+				} else {
+					res.WriteString(t.layout)
+				}
+				rest = rest[len(t.pattern):]
+				found = true
+				break
+			}
+		}
+		if !found {
+			return "", fmt.Errorf("unknown pattern %q in date/time format %q", rest, s)
+		}
+	}
+	return res.String(), nil
+}
+
+func strftimeLayout(s string, format bool) (string, error) {
+	var res strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '%' {
+			res.WriteByte(s[i])
+			continue
+		}
+		if i+1 >= len(s) {
+			return "", fmt.Errorf("trailing %% in date/time format %q", s)
+		}
+		i++
+		l, ok := strftimeLayouts[s[i]]
+		if !ok {
+			return "", fmt.Errorf("unsupported %%%c in date/time format %q", s[i], s)
+		}
+		if f, ok := strftimeFormats[s[i]]; ok && format {
+			l = f
+		}
+		res.WriteString(l)
+	}
+	return res.String(), nil
+}
+
+// formatADIFDate parses v with Go layout and returns it as an ADIF Date.
+func formatADIFDate(layout, v string) (string, error) {
+	t, err := time.Parse(layout, strings.TrimSpace(v))
+	if err != nil {
+		return "", err
+	}
+	return t.Format("20060102"), nil
+}
+
+// formatADIFTime parses v with Go layout and returns it as an ADIF Time, with
+// seconds if the layout has seconds.
+func formatADIFTime(layout, v string) (string, error) {
+	t, err := time.Parse(layout, strings.TrimSpace(v))
+	if err != nil {
+		return "", err
+	}
+	if strings.ContainsRune(strings.NewReplacer("2006", "", "15", "").Replace(layout), '5') {
+		return t.Format("150405"), nil
+	}
+	return t.Format("1504"), nil
+}
@@ -15,8 +15,12 @@
 package cmd
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"sort"
 	"strings"
 
 	"github.com/flwyd/adif-multitool/adif"
@@ -26,88 +30,316 @@ import (
 var Validate = Command{Name: "validate", Run: runValidate, Help: helpValidate,
 	Description: "Validate field values; non-zero exit and no stdout if invalid"}
 
+const (
+	ValidateReportText = "text"
+	ValidateReportJSON = "json"
+)
+
+type ValidateContext struct {
+	DisableRules FieldList
+	ErrorRules   FieldList
+	WarningRules FieldList
+	Profiles     FieldList
+	ProfileFile  string
+	// SubdivisionsFile lists secondary administrative subdivisions like US
+	// counties for validating CNTY and MY_CNTY.
+	SubdivisionsFile string
+	subdivisions     *spec.Subdivisions
+	ReportFormat     string
+	ReportFile       string
+	MaxErrors        int
+	ErrorExitCode    int
+	WarningExitCode  int
+}
+
 func helpValidate() string {
-	return "Non-failure warnings are added as comments in ADI and ADX output.\n"
+	var rules strings.Builder
+	for _, r := range recordRules {
+		sev := "error"
+		if r.Warning {
+			sev = "warning"
+		}
+		fmt.Fprintf(&rules, "  %-20s %s (%s)\n", r.Name, r.Description, sev)
+	}
+	var profiles strings.Builder
+	names := make([]string, 0, len(validationProfiles))
+	for n := range validationProfiles {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	for _, n := range names {
+		fmt.Fprintf(&profiles, "  %-20s %s\n", n, validationProfiles[n].Description)
+	}
+	return `Non-failure warnings are added as comments in ADI and ADX output.
+
+In addition to checking each field, records are checked for consistency
+between fields with the following rules.  Problems are reported as an error or
+warning as shown; use --error-rules and --warning-rules to change this and
+--disable-rules to skip a rule.
+` + rules.String() + `
+--profile checks that records meet the requirements of a service which
+accepts uploads, reporting problems as errors.  Built-in profiles:
+` + profiles.String() + `
+--profile-file defines profiles (or overrides built-in ones) in a file with a
+[name] line for each profile followed by key: value lines:
+  description: text shown in help
+  require: FIELD or FIELD1|FIELD2 if any of several fields will do
+  forbid: FIELD which must not be set
+  if: condition every record must match, using --if syntax, e.g. mode=CW|SSB
+  if-not: condition no record may match, using --if-not syntax
+
+The ADIF specification only enumerates Alaska's ` + spec.CntyField.Name + ` values.
+--subdivisions-file checks ` + spec.CntyField.Name + ` and ` + spec.MyCntyField.Name + ` for other places against
+a CSV file with a header row naming a ` + spec.CntyField.Name + ` column and optional ` + spec.DxccField.Name + ` (default
+291, United States) and ` + spec.StateField.Name + ` (default is the part of ` + spec.CntyField.Name + ` before a comma)
+columns.  Values are checked if the record's DXCC entity is in the file.
+`
 }
 
 func runValidate(ctx *Context, args []string) error {
-	// TODO add any needed flags
-	log := os.Stderr
-	var errors, warnings int
+	cctx := ctx.CommandCtx.(*ValidateContext)
+	rules, err := enabledRules(cctx)
+	if err != nil {
+		return err
+	}
+	profiles, err := loadProfiles(ctx, cctx.Profiles, cctx.ProfileFile)
+	if err != nil {
+		return err
+	}
+	if cctx.SubdivisionsFile != "" {
+		if cctx.subdivisions, err = readSubdivisionsFile(ctx, cctx.SubdivisionsFile); err != nil {
+			return err
+		}
+	}
+	rep := &validateReport{format: cctx.ReportFormat, out: os.Stderr}
+	switch cctx.ReportFormat {
+	case "", ValidateReportText, ValidateReportJSON:
+	default:
+		return fmt.Errorf("unknown report format %q", cctx.ReportFormat)
+	}
+	if cctx.ReportFile != "" && !isStdin(cctx.ReportFile) {
+		fs := ctx.fs
+		if fs == nil {
+			fs = osFilesystem{}
+		}
+		f, err := fs.Create(cctx.ReportFile)
+		if err != nil {
+			return fmt.Errorf("could not create report file: %w", err)
+		}
+		defer f.Close()
+		rep.out = f
+	}
 	appFields := make(map[string]adif.DataType)
 	out := adif.NewLogfile()
-	acc := accumulator{Out: out, Ctx: ctx}
-	for _, f := range filesOrStdin(args) {
-		l, err := acc.read(f)
-		if err != nil {
-			return err
+	// output is buffered so nothing is written if any record is invalid
+	st := recordStream{Ctx: ctx, Out: out, Buffer: true}
+	err = st.process(args, func(r *adif.Record, l *adif.Logfile, i int) (*adif.Record, error) {
+		// EnumScope consistency is checked by record rules
+		vctx := spec.ValidationContext{Subdivisions: cctx.subdivisions}
+		var msgs []string
+		loc := validationMessage{File: l.String(), Record: i + 1}
+		if p, ok := st.position(); ok {
+			loc.Line = p.Line
+			if p.Offset >= 0 {
+				off := p.Offset
+				loc.Offset = &off
+			}
 		}
-		updateFieldOrder(out, l.FieldOrder)
-		for i, r := range l.Records {
-			vctx := spec.ValidationContext{FieldValue: func(name string) string {
-				f, _ := r.Get(name)
-				return f.Value
-			}}
-			var msgs []string
-			for _, f := range r.Fields() {
-				name := strings.ToUpper(f.Name)
-				if f.IsAppDefined() {
-					if adt := appFields[name]; adt == adif.TypeUnspecified {
-						appFields[name] = f.Type
-					} else if f.Type != adif.TypeUnspecified && f.Type != adt {
-						warnings++
-						fmt.Fprintf(log, "WARNING on %s record %d: inconsistent types for %s\n", l, i+1, f.Name)
-					}
+		report := func(v spec.Validity, m validationMessage) {
+			m.File, m.Record, m.Line, m.Offset = loc.File, loc.Record, loc.Line, loc.Offset
+			m.Severity = v.String()
+			rep.add(m)
+			if v == spec.InvalidWarning {
+				name := m.Rule
+				if name == "" {
+					name = m.Field
 				}
-				if f.Value == "" {
-					continue
+				msgs = append(msgs, fmt.Sprintf("%s: %s", name, m.Message))
+			}
+		}
+		for _, f := range r.Fields() {
+			name := strings.ToUpper(f.Name)
+			if f.IsAppDefined() {
+				if adt := appFields[name]; adt == adif.TypeUnspecified {
+					appFields[name] = f.Type
+				} else if f.Type != adif.TypeUnspecified && f.Type != adt {
+					report(spec.InvalidWarning, validationMessage{Field: f.Name, Value: f.Value, Message: fmt.Sprintf("inconsistent types for %s", f.Name)})
 				}
-				validateSpec := func(fv spec.FieldValidator, fs spec.Field) {
-					if fv != nil {
-						switch v := fv(f.Value, fs, vctx); v.Validity {
-						case spec.InvalidError:
-							errors++
-							fmt.Fprintf(log, "ERROR on %s record %d: %s\n", l, i+1, v)
-						case spec.InvalidWarning:
-							warnings++
-							fmt.Fprintf(log, "WARNING on %s record %d: %s\n", l, i+1, v)
-							msgs = append(msgs, fmt.Sprintf("%s: %s", f.Name, v.Message))
-						}
+			}
+			if f.Value == "" {
+				continue
+			}
+			validateSpec := func(fv spec.FieldValidator, fs spec.Field) {
+				if fv != nil {
+					if v := fv(f.Value, fs, vctx); v.Validity != spec.Valid {
+						report(v.Validity, validationMessage{Field: f.Name, Value: f.Value, Message: v.Message})
 					}
 				}
-				if fs, ok := spec.Fields[f.Name]; ok {
-					validateSpec(spec.TypeValidators[fs.Type.Name], fs)
-				} else if u, ok := acc.Out.GetUserdef(f.Name); ok {
-					if len(u.EnumValues) > 0 || u.Min != 0.0 || u.Max != 0.0 {
-						if err := u.Validate(f); err != nil {
-							errors++
-							fmt.Fprintf(log, "ERROR on %s record %d: %s\n", l, i+1, err)
-						}
-					} else { // spec enum validator can't handle userdef enums
-						dt := spec.DataTypes[u.Type.Indicator()]
-						fs := spec.Field{Name: u.Name, Type: dt}
-						validateSpec(spec.TypeValidators[dt.Name], fs)
+			}
+			if fs, ok := spec.Fields[f.Name]; ok {
+				validateSpec(spec.TypeValidators[fs.Type.Name], fs)
+			} else if u, ok := out.GetUserdef(f.Name); ok {
+				if len(u.EnumValues) > 0 || u.Min != 0.0 || u.Max != 0.0 {
+					if err := u.Validate(f); err != nil {
+						report(spec.InvalidError, validationMessage{Field: f.Name, Value: f.Value, Message: err.Error()})
 					}
-				} else if f.IsAppDefined() {
-					fs := spec.Field{Name: f.Name, Type: spec.DataTypes[appFields[name].Indicator()]}
-					validateSpec(spec.TypeValidators[fs.Type.Name], fs)
-				}
-				if len(msgs) > 0 {
-					r.SetComment("adif-multitool: validate warnings: " + strings.Join(msgs, "; "))
+				} else { // spec enum validator can't handle userdef enums
+					dt := spec.DataTypes[u.Type.Indicator()]
+					fs := spec.Field{Name: u.Name, Type: dt}
+					validateSpec(spec.TypeValidators[dt.Name], fs)
 				}
+			} else if f.IsAppDefined() {
+				fs := spec.Field{Name: f.Name, Type: spec.DataTypes[appFields[name].Indicator()]}
+				validateSpec(spec.TypeValidators[fs.Type.Name], fs)
 			}
-			out.AddRecord(r)
 		}
+		for _, rule := range rules {
+			v := spec.InvalidError
+			if rule.Warning {
+				v = spec.InvalidWarning
+			}
+			for _, p := range rule.Check(r, cctx) {
+				report(v, validationMessage{Field: p.field, Value: p.value, Rule: rule.Name, Message: p.message})
+			}
+		}
+		eval := recordEvalContext{record: r, lang: ctx.Locale}
+		for _, prof := range profiles {
+			for _, p := range prof.check(r, eval) {
+				report(spec.InvalidError, validationMessage{Field: p.field, Value: p.value, Profile: prof.Name, Message: p.message})
+			}
+		}
+		if len(msgs) > 0 {
+			r.SetComment("adif-multitool: validate warnings: " + strings.Join(msgs, "; "))
+		}
+		if cctx.MaxErrors > 0 && rep.errors >= cctx.MaxErrors {
+			return nil, errMaxErrors
+		}
+		return r, nil
+	})
+	if err != nil && !errors.Is(err, errMaxErrors) {
+		return err
 	}
-	if errors > 0 {
-		return fmt.Errorf("validate got %d errors and %d warnings", errors, warnings)
+	if err := rep.finish(); err != nil {
+		return err
 	}
-	if err := acc.prepare(); err != nil {
+	// a trailing text line would make a JSON report on stderr unparseable
+	reported := rep.format == ValidateReportJSON && rep.out == os.Stderr
+	if rep.errors > 0 {
+		err = fmt.Errorf("validate got %d errors and %d warnings", rep.errors, rep.warnings)
+		if cctx.MaxErrors > 0 && rep.errors >= cctx.MaxErrors {
+			err = fmt.Errorf("validate stopped after %d errors and %d warnings", rep.errors, rep.warnings)
+		}
+		return ExitCodeError{Code: cctx.ErrorExitCode, Err: err, Reported: reported}
+	}
+	if err := st.finish(); err != nil {
 		return err
 	}
-	err := write(ctx, out)
-	if warnings > 0 {
-		fmt.Fprintf(log, "validate got %d warnings\n", warnings)
+	if rep.warnings > 0 {
+		msg := fmt.Sprintf("validate got %d warnings", rep.warnings)
+		if cctx.WarningExitCode != 0 {
+			return ExitCodeError{Code: cctx.WarningExitCode, Err: errors.New(msg), Reported: reported}
+		}
+		if rep.format != ValidateReportJSON {
+			fmt.Fprintln(rep.out, msg)
+		}
+	}
+	return nil
+}
+
+var errMaxErrors = errors.New("too many validation errors")
+
+// validationMessage is a validation problem in a record, with JSON tags for
+// machine-readable reports.
+type validationMessage struct {
+	File     string `json:"file"`
+	Record   int    `json:"record"`
+	Line     int    `json:"line,omitempty"`
+	Offset   *int64 `json:"offset,omitempty"`
+	Field    string `json:"field,omitempty"`
+	Value    string `json:"value,omitempty"`
+	Rule     string `json:"rule,omitempty"`
+	Profile  string `json:"profile,omitempty"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+}
+
+// validateReport writes text messages as they are added or a JSON document
+// when finished.
+type validateReport struct {
+	format           string
+	out              io.Writer
+	errors, warnings int
+	messages         []validationMessage
+}
+
+func (r *validateReport) add(m validationMessage) {
+	sev := "ERROR"
+	if m.Severity == spec.InvalidWarning.String() {
+		r.warnings++
+		sev = "WARNING"
+	} else {
+		r.errors++
+	}
+	if r.format == ValidateReportJSON {
+		r.messages = append(r.messages, m)
+	} else {
+		fmt.Fprintf(r.out, "%s on %s record %d: %s\n", sev, m.File, m.Record, m.Message)
+	}
+}
+
+func (r *validateReport) finish() error {
+	if r.format != ValidateReportJSON {
+		return nil
+	}
+	if r.messages == nil {
+		r.messages = make([]validationMessage, 0)
+	}
+	enc := json.NewEncoder(r.out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(struct {
+		Errors   int                 `json:"errors"`
+		Warnings int                 `json:"warnings"`
+		Messages []validationMessage `json:"messages"`
+	}{Errors: r.errors, Warnings: r.warnings, Messages: r.messages})
+}
+
+// enabledRules returns recordRules without disabled rules and with severity
+// changed by ErrorRules and WarningRules.
+func enabledRules(cctx *ValidateContext) ([]recordRule, error) {
+	known := make(map[string]bool)
+	for _, r := range recordRules {
+		known[r.Name] = true
+	}
+	sev := make(map[string]bool)
+	for _, n := range cctx.ErrorRules {
+		sev[n] = false
+	}
+	for _, n := range cctx.WarningRules {
+		if w, ok := sev[n]; ok && !w {
+			return nil, fmt.Errorf("validation rule %s cannot be both an error and a warning", n)
+		}
+		sev[n] = true
+	}
+	disabled := make(map[string]bool)
+	for _, n := range cctx.DisableRules {
+		disabled[n] = true
+	}
+	for _, l := range []FieldList{cctx.DisableRules, cctx.ErrorRules, cctx.WarningRules} {
+		for _, n := range l {
+			if !known[n] {
+				return nil, fmt.Errorf("unknown validation rule %q", n)
+			}
+		}
+	}
+	res := make([]recordRule, 0, len(recordRules))
+	for _, r := range recordRules {
+		if disabled[r.Name] {
+			continue
+		}
+		if w, ok := sev[r.Name]; ok {
+			r.Warning = w
+		}
+		res = append(res, r)
 	}
-	return err
+	return res, nil
 }
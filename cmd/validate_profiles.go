@@ -0,0 +1,245 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/flwyd/adif-multitool/adif"
+)
+
+// validationProfile lists the requirements of a service which accepts logs.
+type validationProfile struct {
+	Name        string
+	Description string
+	// Required has a list of alternatives, at least one of which must be set
+	Required [][]string
+	// Forbidden fields must not be set
+	Forbidden []string
+	// Conditions must be true for every record
+	Conditions []comparison
+}
+
+// builtinProfiles is in the same format as a --profile-file.
+const builtinProfiles = `
+[clublog]
+description: Club Log upload
+require: CALL
+require: QSO_DATE
+require: TIME_ON
+require: BAND|FREQ
+require: MODE
+
+[eqsl]
+description: eQSL.cc upload
+require: CALL
+require: QSO_DATE
+require: TIME_ON
+require: BAND
+require: MODE
+
+[lotw]
+description: ARRL Logbook of the World, signed with TQSL
+require: STATION_CALLSIGN
+require: CALL
+require: QSO_DATE
+require: TIME_ON
+require: BAND|FREQ
+require: MODE
+if: QSO_DATE>=19451101
+
+[pota]
+description: Parks on the Air activation upload
+require: STATION_CALLSIGN|OPERATOR
+require: CALL
+require: QSO_DATE
+require: TIME_ON
+require: BAND
+require: MODE
+require: MY_POTA_REF|MY_SIG_INFO
+
+[sota]
+description: Summits on the Air activator or chaser upload
+require: STATION_CALLSIGN|OPERATOR
+require: CALL
+require: QSO_DATE
+require: TIME_ON
+require: BAND|FREQ
+require: MODE
+require: MY_SOTA_REF|SOTA_REF
+`
+
+var validationProfiles = mustParseProfiles(builtinProfiles)
+
+func mustParseProfiles(s string) map[string]validationProfile {
+	p, err := parseProfiles(strings.NewReader(s), "built-in profiles")
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// parseProfiles reads validation profiles from a file with a "[name]" line
+// for each profile followed by "key: value" lines.  Keys are "description",
+// "require" with a |-separated list of alternative fields, "forbid" with a
+// field name, and "if" or "if-not" with a condition like --if in find.  Blank
+// lines and lines starting with # are ignored.
+func parseProfiles(r io.Reader, filename string) (map[string]validationProfile, error) {
+	res := make(map[string]validationProfile)
+	var cur *validationProfile
+	done := func() {
+		if cur != nil {
+			res[cur.Name] = *cur
+		}
+	}
+	s := bufio.NewScanner(r)
+	for line := 1; s.Scan(); line++ {
+		text := strings.TrimSpace(s.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		if strings.HasPrefix(text, "[") && strings.HasSuffix(text, "]") {
+			done()
+			name := strings.ToLower(strings.TrimSpace(text[1 : len(text)-1]))
+			if name == "" {
+				return nil, fmt.Errorf("%s line %d: empty profile name", filename, line)
+			}
+			if _, ok := res[name]; ok {
+				return nil, fmt.Errorf("%s line %d: duplicate profile %q", filename, line, name)
+			}
+			cur = &validationProfile{Name: name}
+			continue
+		}
+		if cur == nil {
+			return nil, fmt.Errorf("%s line %d: expected [profile name], got %q", filename, line, text)
+		}
+		key, val, ok := strings.Cut(text, ":")
+		if !ok {
+			return nil, fmt.Errorf("%s line %d: expected key: value, got %q", filename, line, text)
+		}
+		key, val = strings.ToLower(strings.TrimSpace(key)), strings.TrimSpace(val)
+		switch key {
+		case "description":
+			cur.Description = val
+		case "require":
+			var alts []string
+			for _, f := range strings.Split(val, "|") {
+				if f = strings.ToUpper(strings.TrimSpace(f)); f != "" {
+					alts = append(alts, f)
+				}
+			}
+			if len(alts) == 0 {
+				return nil, fmt.Errorf("%s line %d: empty require list", filename, line)
+			}
+			cur.Required = append(cur.Required, alts)
+		case "forbid":
+			if val == "" {
+				return nil, fmt.Errorf("%s line %d: empty forbid field", filename, line)
+			}
+			cur.Forbidden = append(cur.Forbidden, strings.ToUpper(val))
+		case "if", "if-not":
+			cv := &ConditionValue{}
+			if err := (&ifValue{cv: cv, negate: key == "if-not"}).Set(val); err != nil {
+				return nil, fmt.Errorf("%s line %d: %w", filename, line, err)
+			}
+			cur.Conditions = append(cur.Conditions, cv.cur.Terms[0].(comparison))
+		default:
+			return nil, fmt.Errorf("%s line %d: unknown key %q", filename, line, key)
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", filename, err)
+	}
+	done()
+	return res, nil
+}
+
+// loadProfiles returns the named profiles from built-ins or from file, which
+// can override a built-in profile with the same name.
+func loadProfiles(ctx *Context, names []string, file string) ([]validationProfile, error) {
+	avail := validationProfiles
+	if file != "" {
+		fs := ctx.fs
+		if fs == nil {
+			fs = osFilesystem{}
+		}
+		f, err := fs.Open(file)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		custom, err := parseProfiles(f, file)
+		if err != nil {
+			return nil, err
+		}
+		avail = make(map[string]validationProfile)
+		for k, v := range validationProfiles {
+			avail[k] = v
+		}
+		for k, v := range custom {
+			avail[k] = v
+		}
+	}
+	res := make([]validationProfile, 0, len(names))
+	for _, n := range names {
+		p, ok := avail[strings.ToLower(n)]
+		if !ok {
+			known := make([]string, 0, len(avail))
+			for k := range avail {
+				known = append(known, k)
+			}
+			sort.Strings(known)
+			return nil, fmt.Errorf("unknown validation profile %q, options: %s", n, strings.Join(known, ", "))
+		}
+		res = append(res, p)
+	}
+	return res, nil
+}
+
+// check returns a problem for each requirement of p which r does not meet.
+func (p validationProfile) check(r *adif.Record, eval EvaluationContext) []ruleProblem {
+	var res []ruleProblem
+	for _, alts := range p.Required {
+		var found bool
+		for _, f := range alts {
+			if fieldValue(r, f) != "" {
+				found = true
+				break
+			}
+		}
+		if !found {
+			res = append(res, ruleProblem{field: alts[0],
+				message: fmt.Sprintf("%s requires %s", p.Name, strings.Join(alts, " or "))})
+		}
+	}
+	for _, f := range p.Forbidden {
+		if v := fieldValue(r, f); v != "" {
+			res = append(res, ruleProblem{field: f, value: v,
+				message: fmt.Sprintf("%s does not allow %s", p.Name, f)})
+		}
+	}
+	for _, c := range p.Conditions {
+		if !c.Evaluate(eval) {
+			field := strings.ToUpper(c.FieldName)
+			res = append(res, ruleProblem{field: field, value: fieldValue(r, field),
+				message: fmt.Sprintf("%s requires %s", p.Name, c)})
+		}
+	}
+	return res
+}
@@ -0,0 +1,257 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/flwyd/adif-multitool/adif"
+	"github.com/flwyd/adif-multitool/adif/spec"
+)
+
+// recordRule checks consistency between fields in a record, returning each
+// problem found.
+type recordRule struct {
+	Name        string
+	Description string
+	Warning     bool // if true, problems are warnings by default, otherwise errors
+	Check       func(r *adif.Record, cctx *ValidateContext) []ruleProblem
+}
+
+// ruleProblem is an inconsistency found by a recordRule in the value of field.
+type ruleProblem struct{ field, value, message string }
+
+const (
+	RuleBandFreq     = "BAND_FREQ"
+	RuleBandRxFreqRx = "BAND_RX_FREQ_RX"
+	RuleSubmodeMode  = "SUBMODE_MODE"
+	RuleTimeOff      = "TIME_OFF"
+	RuleGridLocation = "GRIDSQUARE_LOCATION"
+	RuleDxccCountry  = "DXCC_COUNTRY"
+	RuleStateDxcc    = "STATE_DXCC"
+	RuleSigInfoRef   = "SIG_INFO_REF"
+	RuleCntyState    = "CNTY_STATE"
+)
+
+var recordRules = []recordRule{
+	{Name: RuleBandFreq, Description: "FREQ is within the range of BAND", Warning: true,
+		Check: checkBandFreq(spec.BandField, spec.FreqField)},
+	{Name: RuleBandRxFreqRx, Description: "FREQ_RX is within the range of BAND_RX", Warning: true,
+		Check: checkBandFreq(spec.BandRxField, spec.FreqRxField)},
+	{Name: RuleSubmodeMode, Description: "SUBMODE is a submode of MODE", Warning: true,
+		Check: checkEnumScope(spec.SubmodeField)},
+	{Name: RuleTimeOff, Description: "QSO_DATE_OFF and TIME_OFF are not before QSO_DATE and TIME_ON", Warning: true,
+		Check: checkTimeOff},
+	{Name: RuleGridLocation, Description: "GRIDSQUARE contains LAT and LON, also MY_ fields", Warning: true,
+		Check: checkGridLocation},
+	{Name: RuleDxccCountry, Description: "COUNTRY is the name of DXCC entity, also MY_ fields", Warning: true,
+		Check: checkDxccCountry},
+	{Name: RuleStateDxcc, Description: "STATE and CNTY are subdivisions of DXCC entity, also MY_ fields", Warning: true,
+		Check: checkEnumScope(spec.StateField, spec.CntyField, spec.MyStateField, spec.MyCntyField)},
+	{Name: RuleSigInfoRef, Description: "SIG_INFO matches POTA_REF, SOTA_REF, WWFF_REF, or IOTA given by SIG, also MY_ fields", Warning: true,
+		Check: checkSigInfoRef},
+	{Name: RuleCntyState, Description: "CNTY is listed for DXCC and STATE in --subdivisions-file, also MY_ fields",
+		Check: checkCntyState},
+}
+
+// sigRefFields maps special interest group names to the field holding the
+// same reference as SIG_INFO.
+var sigRefFields = map[string]spec.Field{
+	"IOTA": spec.IotaField,
+	"POTA": spec.PotaRefField,
+	"SOTA": spec.SotaRefField,
+	"WWFF": spec.WwffRefField,
+}
+
+func fieldValue(r *adif.Record, name string) string {
+	f, _ := r.Get(name)
+	return strings.TrimSpace(f.Value)
+}
+
+func checkBandFreq(band, freq spec.Field) func(r *adif.Record, _ *ValidateContext) []ruleProblem {
+	return func(r *adif.Record, _ *ValidateContext) []ruleProblem {
+		b, fv := fieldValue(r, band.Name), fieldValue(r, freq.Name)
+		if b == "" || fv == "" {
+			return nil
+		}
+		f, err := strconv.ParseFloat(fv, 64)
+		if err != nil {
+			return nil
+		}
+		for _, v := range spec.BandEnumeration.Value(b) {
+			e := v.(spec.BandEnum)
+			min, err := strconv.ParseFloat(e.LowerFreqMhz, 64)
+			if err != nil {
+				return nil
+			}
+			max, err := strconv.ParseFloat(e.UpperFreqMhz, 64)
+			if err != nil {
+				return nil
+			}
+			if f < min || f > max {
+				return []ruleProblem{{field: freq.Name, value: fv,
+					message: fmt.Sprintf("%s %s is not in %s %s (%s to %s MHz)", freq.Name, fv, band.Name, b, e.LowerFreqMhz, e.UpperFreqMhz)}}
+			}
+		}
+		return nil
+	}
+}
+
+// checkEnumScope checks that each field's value is valid for the value of
+// the field's EnumScope, e.g. SUBMODE is valid for MODE.  Values which aren't
+// in the enumeration are left to field validation.
+func checkEnumScope(fields ...spec.Field) func(r *adif.Record, _ *ValidateContext) []ruleProblem {
+	return func(r *adif.Record, _ *ValidateContext) []ruleProblem {
+		var res []ruleProblem
+		for _, f := range fields {
+			val, scope := fieldValue(r, f.Name), fieldValue(r, f.EnumScope)
+			if val == "" || scope == "" {
+				continue
+			}
+			e := f.Enum()
+			if e.ScopeProperty() == "" || len(e.Value(val)) == 0 {
+				continue
+			}
+			if len(e.ScopedValue(val, scope)) == 0 {
+				res = append(res, ruleProblem{field: f.Name, value: val,
+					message: fmt.Sprintf("%s %q is not valid for %s %q", f.Name, val, f.EnumScope, scope)})
+			}
+		}
+		return res
+	}
+}
+
+func checkTimeOff(r *adif.Record, _ *ValidateContext) []ruleProblem {
+	ondate, offdate := fieldValue(r, spec.QsoDateField.Name), fieldValue(r, spec.QsoDateOffField.Name)
+	if len(ondate) != 8 || len(offdate) != 8 {
+		return nil
+	}
+	if offdate < ondate {
+		return []ruleProblem{{field: spec.QsoDateOffField.Name, value: offdate,
+			message: fmt.Sprintf("%s %s is before %s %s", spec.QsoDateOffField.Name, offdate, spec.QsoDateField.Name, ondate)}}
+	}
+	if offdate > ondate {
+		return nil
+	}
+	seconds := func(t string) string {
+		if len(t) == 4 {
+			return t + "00"
+		}
+		return t
+	}
+	ontime, offtime := fieldValue(r, spec.TimeOnField.Name), fieldValue(r, spec.TimeOffField.Name)
+	if (len(ontime) != 4 && len(ontime) != 6) || (len(offtime) != 4 && len(offtime) != 6) {
+		return nil
+	}
+	if seconds(offtime) < seconds(ontime) {
+		return []ruleProblem{{field: spec.TimeOffField.Name, value: offtime,
+			message: fmt.Sprintf("%s %s is before %s %s on %s", spec.TimeOffField.Name, offtime, spec.TimeOnField.Name, ontime, ondate)}}
+	}
+	return nil
+}
+
+func checkGridLocation(r *adif.Record, _ *ValidateContext) []ruleProblem {
+	var res []ruleProblem
+	for _, my := range []string{"", "MY_"} {
+		grid := fieldValue(r, my+spec.GridsquareField.Name)
+		if len(grid) == 8 {
+			grid += fieldValue(r, my+spec.GridsquareExtField.Name)
+		}
+		latf, lonf := fieldValue(r, my+spec.LatField.Name), fieldValue(r, my+spec.LonField.Name)
+		if grid == "" || latf == "" || lonf == "" {
+			continue
+		}
+		if _, _, err := parseMaidenhead(grid); err != nil || len(grid) > 12 {
+			continue
+		}
+		lat, lon, err := parseADIFCoordinates(latf, lonf)
+		if err != nil {
+			continue
+		}
+		if loc := maidenheadLocator(lat, lon); !strings.EqualFold(grid, loc[:len(grid)]) {
+			res = append(res, ruleProblem{field: my + spec.GridsquareField.Name, value: grid,
+				message: fmt.Sprintf("%s %s does not contain %s %s %s %s (%s)", my+spec.GridsquareField.Name, grid, my+spec.LatField.Name, latf, my+spec.LonField.Name, lonf, loc[:len(grid)])})
+		}
+	}
+	return res
+}
+
+func checkDxccCountry(r *adif.Record, _ *ValidateContext) []ruleProblem {
+	var res []ruleProblem
+	for _, my := range []string{"", "MY_"} {
+		dxcc, country := fieldValue(r, my+spec.DxccField.Name), fieldValue(r, my+spec.CountryField.Name)
+		if dxcc == "" || country == "" {
+			continue
+		}
+		vals := spec.DxccEntityCodeEnumeration.Value(dxcc)
+		if len(vals) == 0 {
+			continue
+		}
+		var match bool
+		for _, v := range vals {
+			if strings.EqualFold(v.(spec.DxccEntityCodeEnum).EntityName, country) {
+				match = true
+				break
+			}
+		}
+		if !match {
+			res = append(res, ruleProblem{field: my + spec.CountryField.Name, value: country,
+				message: fmt.Sprintf("%s %q is not %s %s %s", my+spec.CountryField.Name, country, my+spec.DxccField.Name, dxcc, vals[0].(spec.DxccEntityCodeEnum).EntityName)})
+		}
+	}
+	return res
+}
+
+func checkSigInfoRef(r *adif.Record, _ *ValidateContext) []ruleProblem {
+	var res []ruleProblem
+	normalize := func(s string) string { return strings.ToUpper(strings.ReplaceAll(s, " ", "")) }
+	for _, my := range []string{"", "MY_"} {
+		ref, ok := sigRefFields[strings.ToUpper(fieldValue(r, my+spec.SigField.Name))]
+		if !ok {
+			continue
+		}
+		info, refval := fieldValue(r, my+spec.SigInfoField.Name), fieldValue(r, my+ref.Name)
+		if info == "" || refval == "" {
+			continue
+		}
+		if normalize(info) != normalize(refval) {
+			res = append(res, ruleProblem{field: my + spec.SigInfoField.Name, value: info,
+				message: fmt.Sprintf("%s %q does not match %s %q", my+spec.SigInfoField.Name, info, my+ref.Name, refval)})
+		}
+	}
+	return res
+}
+
+// checkCntyState checks CNTY and MY_CNTY values which aren't in the ADIF
+// enumeration against the --subdivisions-file list, if any.
+func checkCntyState(r *adif.Record, cctx *ValidateContext) []ruleProblem {
+	if cctx == nil || cctx.subdivisions == nil {
+		return nil
+	}
+	var res []ruleProblem
+	vctx := spec.ValidationContext{Subdivisions: cctx.subdivisions, FieldValue: func(name string) string { return fieldValue(r, name) }}
+	for _, f := range []spec.Field{spec.CntyField, spec.MyCntyField} {
+		val := fieldValue(r, f.Name)
+		if val == "" || len(f.Enum().Value(val)) > 0 {
+			continue
+		}
+		if v := spec.ValidateSubdivision(val, f, vctx); v.Validity != spec.Valid {
+			res = append(res, ruleProblem{field: f.Name, value: val, message: v.Message})
+		}
+	}
+	return res
+}
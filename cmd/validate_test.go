@@ -16,9 +16,16 @@ package cmd
 
 import (
 	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
 	"testing"
 
 	"github.com/flwyd/adif-multitool/adif"
+	"github.com/flwyd/adif-multitool/adif/spec"
 	"github.com/google/go-cmp/cmp"
 )
 
@@ -32,6 +39,7 @@ func TestValidateEmpty(t *testing.T) {
 		Writers:      writers(io),
 		Out:          out,
 		Prepare:      testPrepare("My Comment", "3.1.4", "validate test", "1.2.3"),
+		CommandCtx:   &ValidateContext{},
 		fs:           fakeFilesystem{map[string]string{"-": ""}}}
 	if err := Validate.Run(ctx, []string{}); err != nil {
 		t.Errorf("Validate.Run(ctx) got error %v", err)
@@ -60,6 +68,7 @@ func TestValidateNoErrors(t *testing.T) {
 		Writers:      writers(adi),
 		Out:          out,
 		Prepare:      testPrepare("My Comment", "3.1.4", "validate test", "1.2.3"),
+		CommandCtx:   &ValidateContext{},
 		fs:           fakeFilesystem{map[string]string{"foo.adi": file1}}}
 	if err := Validate.Run(ctx, []string{"foo.adi"}); err != nil {
 		t.Errorf("Validate.Run(ctx) got error on file without problems: %v", err)
@@ -144,6 +153,7 @@ func TestValidateErrors(t *testing.T) {
 				Writers:      writers(adi),
 				Out:          out,
 				Prepare:      testPrepare("My Comment", "3.1.4", "validate test", "1.2.3"),
+				CommandCtx:   &ValidateContext{},
 				fs:           fakeFilesystem{map[string]string{"foo.adi": infile.String()}}}
 			if err := Validate.Run(ctx, []string{"foo.adi"}); err == nil {
 				t.Errorf("Validate.Run(ctx) want error, got output:\n%s", out)
@@ -154,4 +164,545 @@ func TestValidateErrors(t *testing.T) {
 	}
 }
 
+func TestValidateRules(t *testing.T) {
+	tests := []struct {
+		name   string
+		record []adif.Field
+		ctx    ValidateContext
+		// wantRule is the rule which should produce a warning comment; if empty,
+		// the record should be an error if wantErr, otherwise have no comment
+		wantRule string
+		wantErr  bool
+	}{
+		{
+			name:   "band and freq match",
+			record: []adif.Field{{Name: "BAND", Value: "20m"}, {Name: "FREQ", Value: "14.074"}, {Name: "BAND_RX", Value: "2m"}, {Name: "FREQ_RX", Value: "145.9"}},
+		},
+		{
+			name:     "freq outside band",
+			record:   []adif.Field{{Name: "BAND", Value: "20m"}, {Name: "FREQ", Value: "7.074"}},
+			wantRule: RuleBandFreq,
+		},
+		{
+			name:     "freq_rx outside band_rx",
+			record:   []adif.Field{{Name: "BAND_RX", Value: "70cm"}, {Name: "FREQ_RX", Value: "145.9"}},
+			wantRule: RuleBandRxFreqRx,
+		},
+		{
+			name:    "freq outside band as error",
+			record:  []adif.Field{{Name: "BAND", Value: "20m"}, {Name: "FREQ", Value: "7.074"}},
+			ctx:     ValidateContext{ErrorRules: FieldList{RuleBandFreq}},
+			wantErr: true,
+		},
+		{
+			name:   "freq outside band disabled",
+			record: []adif.Field{{Name: "BAND", Value: "20m"}, {Name: "FREQ", Value: "7.074"}},
+			ctx:    ValidateContext{DisableRules: FieldList{RuleBandFreq}},
+		},
+		{
+			name:     "submode not in mode",
+			record:   []adif.Field{{Name: "MODE", Value: "SSB"}, {Name: "SUBMODE", Value: "FT4"}},
+			wantRule: RuleSubmodeMode,
+		},
+		{
+			name:    "submode not in mode as error",
+			record:  []adif.Field{{Name: "MODE", Value: "SSB"}, {Name: "SUBMODE", Value: "FT4"}},
+			ctx:     ValidateContext{ErrorRules: FieldList{RuleSubmodeMode}},
+			wantErr: true,
+		},
+		{
+			name:   "submode in mode",
+			record: []adif.Field{{Name: "MODE", Value: "MFSK"}, {Name: "SUBMODE", Value: "FT4"}},
+		},
+		{
+			name:   "time off after time on",
+			record: []adif.Field{{Name: "QSO_DATE", Value: "20230102"}, {Name: "TIME_ON", Value: "2359"}, {Name: "QSO_DATE_OFF", Value: "20230103"}, {Name: "TIME_OFF", Value: "000130"}},
+		},
+		{
+			name:   "time off past midnight without date off",
+			record: []adif.Field{{Name: "QSO_DATE", Value: "20230102"}, {Name: "TIME_ON", Value: "2359"}, {Name: "TIME_OFF", Value: "0001"}},
+		},
+		{
+			name:     "time off before time on",
+			record:   []adif.Field{{Name: "QSO_DATE", Value: "20230102"}, {Name: "TIME_ON", Value: "1200"}, {Name: "QSO_DATE_OFF", Value: "20230102"}, {Name: "TIME_OFF", Value: "115959"}},
+			wantRule: RuleTimeOff,
+		},
+		{
+			name:     "date off before date on",
+			record:   []adif.Field{{Name: "QSO_DATE", Value: "20230102"}, {Name: "QSO_DATE_OFF", Value: "20230101"}},
+			wantRule: RuleTimeOff,
+		},
+		{
+			name:    "date off before date on as error",
+			record:  []adif.Field{{Name: "QSO_DATE", Value: "20230102"}, {Name: "QSO_DATE_OFF", Value: "20230101"}},
+			ctx:     ValidateContext{ErrorRules: FieldList{RuleTimeOff}},
+			wantErr: true,
+		},
+		{
+			name:   "gridsquare contains location",
+			record: []adif.Field{{Name: "GRIDSQUARE", Value: "FN31pr"}, {Name: "LAT", Value: "N041 42.852"}, {Name: "LON", Value: "W072 43.640"}},
+		},
+		{
+			name:     "gridsquare does not contain location",
+			record:   []adif.Field{{Name: "GRIDSQUARE", Value: "FN31"}, {Name: "LAT", Value: "N040 00.000"}, {Name: "LON", Value: "W105 00.000"}},
+			wantRule: RuleGridLocation,
+		},
+		{
+			name:     "my gridsquare ext does not contain location",
+			record:   []adif.Field{{Name: "MY_GRIDSQUARE", Value: "FN31pr00"}, {Name: "MY_GRIDSQUARE_EXT", Value: "aa"}, {Name: "MY_LAT", Value: "N041 42.852"}, {Name: "MY_LON", Value: "W072 43.640"}},
+			wantRule: RuleGridLocation,
+		},
+		{
+			name:   "dxcc and country match",
+			record: []adif.Field{{Name: "DXCC", Value: "291"}, {Name: "COUNTRY", Value: "United States of America"}},
+		},
+		{
+			name:     "dxcc and country mismatch",
+			record:   []adif.Field{{Name: "MY_DXCC", Value: "1"}, {Name: "MY_COUNTRY", Value: "UNITED STATES OF AMERICA"}},
+			wantRule: RuleDxccCountry,
+		},
+		{
+			name:   "state in dxcc",
+			record: []adif.Field{{Name: "DXCC", Value: "1"}, {Name: "STATE", Value: "ON"}},
+		},
+		{
+			name:     "state not in dxcc",
+			record:   []adif.Field{{Name: "DXCC", Value: "291"}, {Name: "STATE", Value: "ON"}},
+			wantRule: RuleStateDxcc,
+		},
+		{
+			name:   "sig info matches ref",
+			record: []adif.Field{{Name: "MY_SIG", Value: "pota"}, {Name: "MY_SIG_INFO", Value: "K-0001, K-0002"}, {Name: "MY_POTA_REF", Value: "K-0001,K-0002"}},
+		},
+		{
+			name:     "sig info does not match ref",
+			record:   []adif.Field{{Name: "SIG", Value: "SOTA"}, {Name: "SIG_INFO", Value: "W7W/KG-001"}, {Name: "SOTA_REF", Value: "W7W/KG-002"}},
+			wantRule: RuleSigInfoRef,
+		},
+		{
+			name:   "sig info other program",
+			record: []adif.Field{{Name: "SIG", Value: "WAB"}, {Name: "SIG_INFO", Value: "SU00"}, {Name: "SOTA_REF", Value: "W7W/KG-002"}},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			inlog := adif.NewLogfile()
+			inlog.AddRecord(adif.NewRecord(tc.record...))
+			adi := adif.NewADIIO()
+			infile := &bytes.Buffer{}
+			if err := adi.Write(inlog, infile); err != nil {
+				t.Fatalf("could not create input logfile: %v", err)
+			}
+			out := &bytes.Buffer{}
+			cctx := tc.ctx
+			ctx := &Context{
+				OutputFormat: adif.FormatADI,
+				Readers:      readers(adi),
+				Writers:      writers(adi),
+				Out:          out,
+				CommandCtx:   &cctx,
+				fs:           fakeFilesystem{map[string]string{"foo.adi": infile.String()}}}
+			err := Validate.Run(ctx, []string{"foo.adi"})
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("Validate.Run(ctx) want error, got output:\n%s", out)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Validate.Run(ctx) got error %v", err)
+			}
+			comment := "validate warnings: " + tc.wantRule
+			if got := out.String(); tc.wantRule != "" && !strings.Contains(got, comment) {
+				t.Errorf("Validate.Run(ctx) want %q in output, got\n%s", comment, got)
+			} else if tc.wantRule == "" && strings.Contains(got, "validate warnings") {
+				t.Errorf("Validate.Run(ctx) want no warnings, got\n%s", got)
+			}
+		})
+	}
+}
+
+func TestValidateRuleFlags(t *testing.T) {
+	for _, c := range []ValidateContext{
+		{DisableRules: FieldList{"NO_SUCH_RULE"}},
+		{ErrorRules: FieldList{RuleBandFreq}, WarningRules: FieldList{RuleBandFreq}},
+	} {
+		ctx := &Context{
+			OutputFormat: adif.FormatADI,
+			Readers:      readers(adif.NewADIIO()),
+			Writers:      writers(adif.NewADIIO()),
+			Out:          &bytes.Buffer{},
+			CommandCtx:   &c,
+			fs:           fakeFilesystem{map[string]string{"foo.adi": "<EOH>\n"}}}
+		if err := Validate.Run(ctx, []string{"foo.adi"}); err == nil {
+			t.Errorf("Validate.Run with %+v got no error", c)
+		}
+	}
+}
+
+func TestValidateReport(t *testing.T) {
+	adi := adif.NewADIIO()
+	input := "Header <EOH>\n<BAND:3>20m <FREQ:5>7.074 <EOR>\n<MODE:3>SSB <SUBMODE:3>FT4 <EOR>\n<QSO_DATE:8>20231301 <EOR>\n"
+	fs := fakeFilesystem{map[string]string{"foo.adi": input}}
+	off := func(o int64) *int64 { return &o }
+	tests := []struct {
+		name         string
+		ctx          ValidateContext
+		wantErrors   int
+		wantWarnings int
+		wantMessages []validationMessage
+		wantCode     int
+	}{
+		{
+			name:         "all errors",
+			ctx:          ValidateContext{ErrorRules: FieldList{RuleBandFreq}, ErrorExitCode: 3},
+			wantErrors:   2,
+			wantWarnings: 1,
+			wantCode:     3,
+			wantMessages: []validationMessage{
+				{File: "foo.adi", Record: 1, Line: 2, Offset: off(13), Field: "FREQ", Value: "7.074", Rule: RuleBandFreq, Severity: "InvalidError", Message: "FREQ 7.074 is not in BAND 20m (14.0 to 14.35 MHz)"},
+				{File: "foo.adi", Record: 2, Line: 3, Offset: off(45), Field: "SUBMODE", Value: "FT4", Rule: RuleSubmodeMode, Severity: "InvalidWarning", Message: `SUBMODE "FT4" is not valid for MODE "SSB"`},
+				{File: "foo.adi", Record: 3, Line: 4, Offset: off(78), Field: "QSO_DATE", Value: "20231301", Severity: "InvalidError", Message: `QSO_DATE invalid date "20231301"`},
+			},
+		},
+		{
+			name:         "max errors",
+			ctx:          ValidateContext{ErrorRules: FieldList{RuleBandFreq}, MaxErrors: 1},
+			wantErrors:   1,
+			wantWarnings: 0,
+			wantMessages: []validationMessage{
+				{File: "foo.adi", Record: 1, Line: 2, Offset: off(13), Field: "FREQ", Value: "7.074", Rule: RuleBandFreq, Severity: "InvalidError", Message: "FREQ 7.074 is not in BAND 20m (14.0 to 14.35 MHz)"},
+			},
+		},
+		{
+			name:         "changed rule severity",
+			ctx:          ValidateContext{DisableRules: FieldList{RuleBandFreq}, WarningRules: FieldList{RuleSubmodeMode}, WarningExitCode: 2},
+			wantErrors:   1,
+			wantWarnings: 1,
+			wantCode:     0,
+			wantMessages: []validationMessage{
+				{File: "foo.adi", Record: 2, Line: 3, Offset: off(45), Field: "SUBMODE", Value: "FT4", Rule: RuleSubmodeMode, Severity: "InvalidWarning", Message: `SUBMODE "FT4" is not valid for MODE "SSB"`},
+				{File: "foo.adi", Record: 3, Line: 4, Offset: off(78), Field: "QSO_DATE", Value: "20231301", Severity: "InvalidError", Message: `QSO_DATE invalid date "20231301"`},
+			},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cctx := tc.ctx
+			cctx.ReportFormat = ValidateReportJSON
+			cctx.ReportFile = "report.json"
+			ctx := &Context{
+				OutputFormat: adif.FormatADI,
+				Readers:      readers(adi),
+				Writers:      writers(adi),
+				Out:          &bytes.Buffer{},
+				CommandCtx:   &cctx,
+				fs:           fs}
+			err := Validate.Run(ctx, []string{"foo.adi"})
+			var ec ExitCodeError
+			if !errors.As(err, &ec) {
+				t.Fatalf("Validate.Run got error %v, want ExitCodeError", err)
+			}
+			if ec.Code != tc.wantCode {
+				t.Errorf("Validate.Run got exit code %d, want %d", ec.Code, tc.wantCode)
+			}
+			if ec.Reported {
+				t.Errorf("Validate.Run with --report-file got Reported error %v", ec)
+			}
+			var got struct {
+				Errors, Warnings int
+				Messages         []validationMessage
+			}
+			if err := json.Unmarshal([]byte(fs.files["report.json"]), &got); err != nil {
+				t.Fatalf("could not parse report %q: %v", fs.files["report.json"], err)
+			}
+			if got.Errors != tc.wantErrors || got.Warnings != tc.wantWarnings {
+				t.Errorf("report got %d errors and %d warnings, want %d and %d", got.Errors, got.Warnings, tc.wantErrors, tc.wantWarnings)
+			}
+			if diff := cmp.Diff(tc.wantMessages, got.Messages); diff != "" {
+				t.Errorf("report messages diff (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestValidateJSONStderr(t *testing.T) {
+	adi := adif.NewADIIO()
+	stderr, err := os.CreateTemp(t.TempDir(), "stderr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stderr.Close()
+	orig := os.Stderr
+	os.Stderr = stderr
+	defer func() { os.Stderr = orig }()
+	ctx := &Context{
+		OutputFormat: adif.FormatADI,
+		Readers:      readers(adi),
+		Writers:      writers(adi),
+		Out:          &bytes.Buffer{},
+		CommandCtx:   &ValidateContext{ReportFormat: ValidateReportJSON, ErrorExitCode: 1},
+		fs:           fakeFilesystem{map[string]string{"foo.adi": "<QSO_DATE:8>20231301 <EOR>\n"}}}
+	err = Validate.Run(ctx, []string{"foo.adi"})
+	os.Stderr = orig
+	var ec ExitCodeError
+	if !errors.As(err, &ec) || ec.Code != 1 {
+		t.Fatalf("Validate.Run got error %v, want exit code 1", err)
+	}
+	if !ec.Reported {
+		t.Errorf("Validate.Run with JSON report on standard error got Reported=false")
+	}
+	report, err := os.ReadFile(stderr.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got struct{ Errors int }
+	if err := json.Unmarshal(report, &got); err != nil || got.Errors != 1 {
+		t.Errorf("standard error is not a JSON report with 1 error, got %v %q", err, report)
+	}
+}
+
+func TestValidateWarningExitCode(t *testing.T) {
+	adi := adif.NewADIIO()
+	input := "<MODE:3>SSB <SUBMODE:3>FT4 <EOR>\n"
+	out := &bytes.Buffer{}
+	ctx := &Context{
+		OutputFormat: adif.FormatADI,
+		Readers:      readers(adi),
+		Writers:      writers(adi),
+		Out:          out,
+		CommandCtx:   &ValidateContext{WarningExitCode: 2, ReportFile: "report.txt"},
+		fs:           fakeFilesystem{map[string]string{"foo.adi": input}}}
+	err := Validate.Run(ctx, []string{"foo.adi"})
+	var ec ExitCodeError
+	if !errors.As(err, &ec) || ec.Code != 2 {
+		t.Errorf("Validate.Run got error %v, want exit code 2", err)
+	}
+	if !strings.Contains(out.String(), "<SUBMODE:3>FT4") {
+		t.Errorf("Validate.Run with only warnings want output, got %q", out.String())
+	}
+}
+
+func TestValidateProfiles(t *testing.T) {
+	adi := adif.NewADIIO()
+	input := `<EOH>
+<STATION_CALLSIGN:4>W1AW <CALL:4>K1AB <QSO_DATE:8>20230102 <TIME_ON:4>1234 <FREQ:6>14.074 <MODE:3>FT8 <EOR>
+<CALL:4>K1AB <QSO_DATE:8>19400102 <TIME_ON:4>1234 <BAND:3>20m <MODE:2>CW <APP_TEST_X:1>Y <EOR>
+`
+	custom := `# custom profiles
+[lotw]
+description: stricter LoTW
+require: STATION_CALLSIGN
+require: BAND
+
+[contest]
+require: CALL
+forbid: APP_TEST_X
+if: mode=CW|SSB
+if-not: qso_date<20000101
+`
+	tests := []struct {
+		name     string
+		profiles FieldList
+		file     string
+		want     []validationMessage
+	}{
+		{
+			name:     "built-in lotw",
+			profiles: FieldList{"LOTW"},
+			want: []validationMessage{
+				{Record: 2, Field: "STATION_CALLSIGN", Profile: "lotw", Message: "lotw requires STATION_CALLSIGN"},
+				{Record: 2, Field: "QSO_DATE", Value: "19400102", Profile: "lotw", Message: "lotw requires QSO_DATE>=19451101"},
+			},
+		},
+		{
+			name:     "custom file",
+			profiles: FieldList{"lotw", "contest", "eqsl"},
+			file:     "profiles.txt",
+			want: []validationMessage{
+				{Record: 1, Field: "BAND", Profile: "lotw", Message: "lotw requires BAND"},
+				{Record: 1, Field: "MODE", Value: "FT8", Profile: "contest", Message: "contest requires mode=CW|SSB"},
+				{Record: 1, Field: "BAND", Profile: "eqsl", Message: "eqsl requires BAND"},
+				{Record: 2, Field: "STATION_CALLSIGN", Profile: "lotw", Message: "lotw requires STATION_CALLSIGN"},
+				{Record: 2, Field: "APP_TEST_X", Value: "Y", Profile: "contest", Message: "contest does not allow APP_TEST_X"},
+				{Record: 2, Field: "QSO_DATE", Value: "19400102", Profile: "contest", Message: "contest requires NOT qso_date<20000101"},
+			},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			fs := fakeFilesystem{map[string]string{"foo.adi": input, "profiles.txt": custom}}
+			ctx := &Context{
+				OutputFormat: adif.FormatADI,
+				Readers:      readers(adi),
+				Writers:      writers(adi),
+				Out:          &bytes.Buffer{},
+				CommandCtx: &ValidateContext{Profiles: tc.profiles, ProfileFile: tc.file,
+					DisableRules: FieldList{RuleBandFreq}, ReportFormat: ValidateReportJSON, ReportFile: "report.json"},
+				fs: fs}
+			if err := Validate.Run(ctx, []string{"foo.adi"}); err == nil {
+				t.Errorf("Validate.Run with profiles %v got no error", tc.profiles)
+			}
+			var got struct{ Messages []validationMessage }
+			if err := json.Unmarshal([]byte(fs.files["report.json"]), &got); err != nil {
+				t.Fatalf("could not parse report %q: %v", fs.files["report.json"], err)
+			}
+			for i := range got.Messages {
+				m := &got.Messages[i]
+				m.File, m.Line, m.Offset, m.Severity = "", 0, nil, ""
+			}
+			if diff := cmp.Diff(tc.want, got.Messages); diff != "" {
+				t.Errorf("report messages diff (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestValidateProfileErrors(t *testing.T) {
+	for _, tc := range []struct{ name, file string }{
+		{name: "no section", file: "require: CALL\n"},
+		{name: "unknown key", file: "[x]\nrequires: CALL\n"},
+		{name: "bad condition", file: "[x]\nif: mode\n"},
+		{name: "duplicate", file: "[x]\n[x]\n"},
+		{name: "empty require", file: "[x]\nrequire: |\n"},
+	} {
+		if p, err := parseProfiles(strings.NewReader(tc.file), tc.name); err == nil {
+			t.Errorf("%s: parseProfiles(%q) got %v, want error", tc.name, tc.file, p)
+		}
+	}
+	ctx := &Context{fs: fakeFilesystem{map[string]string{}}}
+	if _, err := loadProfiles(ctx, []string{"nope"}, ""); err == nil {
+		t.Errorf("loadProfiles(nope) got no error")
+	}
+}
+
 // TODO test warnings (which are printed to stderr)
+
+func TestValidateSubdivisionsFile(t *testing.T) {
+	subs := `DXCC,STATE,CNTY
+291,MA,"MA,Franklin"
+291,MA,"MA,Hampshire"
+339,10,1001
+`
+	usOnly := "CNTY\n\"MA,Franklin\"\n\"NY,Kings\"\n"
+	tests := []struct {
+		name    string
+		file    string
+		record  string
+		wantErr bool
+	}{
+		{name: "county in file", file: subs, record: "<DXCC:3>291<STATE:2>MA<CNTY:11>MA,Franklin<EOR>"},
+		{name: "county not in file", file: subs, record: "<DXCC:3>291<STATE:2>MA<CNTY:11>MA,Frankln<EOR>", wantErr: true},
+		{name: "county in other state", file: subs, record: "<MY_DXCC:3>291<MY_STATE:2>NY<MY_CNTY:11>MA,Franklin<EOR>", wantErr: true},
+		{name: "jcc in file", file: subs, record: "<DXCC:3>339<STATE:2>10<CNTY:4>1001<EOR>"},
+		{name: "jcc not in file", file: subs, record: "<DXCC:3>339<CNTY:4>1002<EOR>", wantErr: true},
+		{name: "entity not in file", file: subs, record: "<DXCC:1>1<CNTY:5>Other<EOR>"},
+		{name: "default dxcc and state", file: usOnly, record: "<DXCC:3>291<STATE:2>NY<CNTY:8>NY,Kings<EOR>"},
+		{name: "default state mismatch", file: usOnly, record: "<DXCC:3>291<STATE:2>MA<CNTY:8>NY,Kings<EOR>", wantErr: true},
+		{name: "usaca counties", file: usOnly, record: "<USACA_COUNTIES:20>MA,Franklin:NY,Kings<EOR>"},
+		{name: "usaca counties not in file", file: usOnly, record: "<USACA_COUNTIES:22>MA,Franklin:NY,Bronx<EOR>", wantErr: true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			ctx := &Context{
+				OutputFormat: adif.FormatADI,
+				Readers:      readers(adif.NewADIIO()),
+				Writers:      writers(adif.NewADIIO()),
+				Out:          out,
+				CommandCtx:   &ValidateContext{SubdivisionsFile: "subs.csv"},
+				fs:           fakeFilesystem{map[string]string{"foo.adi": "<EOH>\n" + tc.record + "\n", "subs.csv": tc.file}}}
+			err := Validate.Run(ctx, []string{"foo.adi"})
+			if tc.wantErr && err == nil {
+				t.Errorf("Validate.Run(%s) want error, got output:\n%s", tc.record, out)
+			} else if !tc.wantErr && err != nil {
+				t.Errorf("Validate.Run(%s) got error %v", tc.record, err)
+			}
+		})
+	}
+	for _, bad := range []string{"DXCC,STATE\n291,MA\n", "DXCC,CNTY\nUSA,\"MA,Franklin\"\n"} {
+		ctx := &Context{
+			OutputFormat: adif.FormatADI,
+			Readers:      readers(adif.NewADIIO()),
+			Writers:      writers(adif.NewADIIO()),
+			Out:          &bytes.Buffer{},
+			CommandCtx:   &ValidateContext{SubdivisionsFile: "subs.csv"},
+			fs:           fakeFilesystem{map[string]string{"foo.adi": "<EOH>\n", "subs.csv": bad}}}
+		if err := Validate.Run(ctx, []string{"foo.adi"}); err == nil {
+			t.Errorf("Validate.Run with subdivisions file %q got no error", bad)
+		}
+	}
+}
+
+// benchmarkLog returns an ADI log with n synthetic records using a variety of
+// enumeration values.  If messy, dates, times, and locations are in formats
+// which fix corrects.
+func benchmarkLog(n int, messy bool) string {
+	subdivs := spec.PrimaryAdministrativeSubdivisionEnumeration.Values
+	submodes := spec.SubmodeEnumeration.Values
+	bands := []struct{ band, freq string }{{"160m", "1.84"}, {"40m", "7.074"}, {"20m", "14.074"}, {"15m", "21.3"}, {"2m", "146.52"}}
+	l := adif.NewLogfile()
+	for i := 0; i < n; i++ {
+		s := subdivs[i%len(subdivs)].(spec.PrimaryAdministrativeSubdivisionEnum)
+		m := submodes[i%len(submodes)].(spec.SubmodeEnum)
+		b := bands[i%len(bands)]
+		date := fmt.Sprintf("2023%02d%02d", i%12+1, i%28+1)
+		time := fmt.Sprintf("%02d%02d", i%24, i%60)
+		lat, lon := fmt.Sprintf("N%03d %02d.000", i%90, i%60), fmt.Sprintf("W%03d %02d.000", i%180, i%60)
+		if messy {
+			date = date[0:4] + "-" + date[4:6] + "-" + date[6:8]
+			time = time[0:2] + ":" + time[2:4]
+			lat, lon = fmt.Sprintf("%d.5", i%90), fmt.Sprintf("-%d.25", i%180)
+		}
+		l.AddRecord(adif.NewRecord(
+			adif.Field{Name: "CALL", Value: fmt.Sprintf("K%dABC", i%10)},
+			adif.Field{Name: "STATION_CALLSIGN", Value: "W1AW"},
+			adif.Field{Name: "QSO_DATE", Value: date},
+			adif.Field{Name: "TIME_ON", Value: time},
+			adif.Field{Name: "BAND", Value: b.band},
+			adif.Field{Name: "FREQ", Value: b.freq},
+			adif.Field{Name: "MODE", Value: m.Mode},
+			adif.Field{Name: "SUBMODE", Value: m.Submode},
+			adif.Field{Name: "DXCC", Value: s.DxccEntityCode},
+			adif.Field{Name: "STATE", Value: s.Code},
+			adif.Field{Name: "LAT", Value: lat},
+			adif.Field{Name: "LON", Value: lon},
+			adif.Field{Name: "MY_GRIDSQUARE", Value: "FN31pr"},
+			adif.Field{Name: "SIG", Value: "POTA"},
+			adif.Field{Name: "SIG_INFO", Value: fmt.Sprintf("K-%04d", i%10000)},
+		))
+	}
+	buf := &bytes.Buffer{}
+	if err := adif.NewADIIO().Write(l, buf); err != nil {
+		panic(err)
+	}
+	return buf.String()
+}
+
+func benchmarkCommand(b *testing.B, c Command, cctx any, log string) {
+	b.Helper()
+	adi := adif.NewADIIO()
+	fs := fakeFilesystem{map[string]string{"bench.adi": log}}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ctx := &Context{
+			OutputFormat: adif.FormatADI,
+			Readers:      readers(adi),
+			Writers:      writers(adi),
+			Out:          io.Discard,
+			CommandCtx:   cctx,
+			fs:           fs}
+		if err := c.Run(ctx, []string{"bench.adi"}); err != nil {
+			var ec ExitCodeError
+			if !errors.As(err, &ec) {
+				b.Fatalf("%s.Run got error %v", c.Name, err)
+			}
+		}
+	}
+}
+
+func BenchmarkValidate(b *testing.B) {
+	log := benchmarkLog(10000, false)
+	benchmarkCommand(b, Validate, &ValidateContext{ReportFile: os.DevNull}, log)
+}
@@ -0,0 +1,194 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/flwyd/adif-multitool/adif"
+)
+
+// WhereFlag returns a flag which parses a boolean expression like
+// "(mode=CW OR mode=SSB) AND NOT year(qso_date)<2020".  Records must match
+// every --where expression in addition to the --if and --or-if groups.
+func (cv *ConditionValue) WhereFlag() flag.Value {
+	return &whereFlag{cv: cv}
+}
+
+type whereFlag struct{ cv *ConditionValue }
+
+func (w *whereFlag) String() string { return "" }
+
+func (w *whereFlag) Set(s string) error {
+	c, err := parseWhere(s)
+	if err != nil {
+		return err
+	}
+	w.cv.where = append(w.cv.where, c)
+	return nil
+}
+
+func helpWhere() string {
+	names := make([]string, 0, len(whereFuncs))
+	for n := range whereFuncs {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	var funcs strings.Builder
+	for _, n := range names {
+		fmt.Fprintf(&funcs, "  %s(field) : %s\n", n, whereFuncs[n].help)
+	}
+	return `--where takes an expression combining conditions with AND, OR, NOT, and
+parentheses.  Values containing spaces or characters other than letters,
+digits, and _-./+:@#*? must be quoted with ' or ".  Functions:
+` + funcs.String() + `Value expression functions from edit, like trim and substr, can also be used.
+Examples:
+  --where '(mode=CW OR mode=SSB) AND NOT band=20m'
+  --where 'year(qso_date)=2023 AND call ~ "/(P|MM)$"'
+  --where 'len(call)<=4 OR upper(comment) *= "QRP"'
+--where may be combined with --if flags; records must match every --where and
+at least one --if/--or-if group.  A comparison which can't be computed, like
+round(call), is false, and so is its NOT.
+`
+}
+
+// negation is a Condition which is true if its term is false.  Like the term,
+// it is false if a value can't be computed.
+type negation struct{ Term Condition }
+
+func (n negation) Evaluate(e EvaluationContext) bool {
+	match, err := checkCondition(n, e)
+	return match && err == nil
+}
+
+func (n negation) String() string { return "NOT (" + n.Term.String() + ")" }
+
+type whereFunc struct {
+	help  string
+	apply func(adif.Field) adif.Field
+}
+
+// datePartFunc returns a function which extracts a number from the part of a
+// date or time field between start and end, e.g. year from QSO_DATE.
+func datePartFunc(help string, start, end int) whereFunc {
+	return whereFunc{help: help, apply: func(f adif.Field) adif.Field {
+		res := adif.Field{Type: adif.TypeNumber}
+		if len(f.Value) >= end {
+			if n, err := strconv.Atoi(f.Value[start:end]); err == nil {
+				res.Value = strconv.Itoa(n)
+			}
+		}
+		return res
+	}}
+}
+
+var whereFuncs = map[string]whereFunc{
+	"len": {help: "number of characters", apply: func(f adif.Field) adif.Field {
+		return adif.Field{Value: strconv.Itoa(utf8.RuneCountInString(f.Value)), Type: adif.TypeNumber}
+	}},
+	"lower": {help: "lower case text", apply: func(f adif.Field) adif.Field {
+		f.Value = strings.ToLower(f.Value)
+		return f
+	}},
+	"upper": {help: "upper case text", apply: func(f adif.Field) adif.Field {
+		f.Value = strings.ToUpper(f.Value)
+		return f
+	}},
+	"year":   datePartFunc("year of a date", 0, 4),
+	"month":  datePartFunc("month (1 to 12) of a date", 4, 6),
+	"day":    datePartFunc("day of month of a date", 6, 8),
+	"hour":   datePartFunc("hour of a time", 0, 2),
+	"minute": datePartFunc("minute of a time", 2, 4),
+}
+
+// whereComparison compares a left value to one or more right values, like
+// comparison but with functions, fields, and literals on either side.
+// Literals on the right are cast to the type of the field on the left.
+type whereComparison struct {
+	Op       operator
+	Left     valueExpr
+	Right    []valueExpr
+	patterns []*regexp.Regexp
+}
+
+func (c whereComparison) String() string {
+	s := make([]string, len(c.Right))
+	for i, r := range c.Right {
+		s[i] = r.String()
+	}
+	return fmt.Sprintf("%s %s %s", c.Left, c.Op, strings.Join(s, "|"))
+}
+
+// Evaluate returns false if a value can't be computed.
+func (c whereComparison) Evaluate(e EvaluationContext) bool {
+	match, err := c.check(e)
+	return match && err == nil
+}
+
+func (c whereComparison) check(e EvaluationContext) (bool, error) {
+	f, err := c.Left.eval(e)
+	if err != nil {
+		return false, err
+	}
+	if f.Name == "" && f.Type == adif.TypeUnspecified {
+		f.Type = adif.TypeString // computed text like concat(call, mode)
+	}
+	var match bool
+	for i, r := range c.Right {
+		if re := c.patterns[i]; re != nil {
+			match = anyListValue(f, re.MatchString)
+		} else {
+			var v adif.Field
+			if lit, ok := r.(exprLiteral); ok {
+				v = e.Cast(f.Name, string(lit))
+			} else if v, err = r.eval(e); err != nil {
+				return false, err
+			}
+			match = compareFields(e, c.Op, f, v)
+		}
+		if match {
+			break
+		}
+	}
+	if c.Op == OpNotEqual {
+		return !match, nil
+	}
+	return match, nil
+}
+
+// whereOps are comparison operators, longest first so "<=" isn't read as "<".
+var whereOps = []operator{OpNotEqual, OpLessThanEqual, OpGreaterThanEqual, OpContains, OpStartsWith, OpEndsWith,
+	OpEqual, OpLessThan, OpGreaterThan, OpMatches}
+
+// parseWhere parses a boolean --where expression, see exprParser.
+func parseWhere(s string) (Condition, error) {
+	p, err := newExprParser(s, true)
+	if err != nil {
+		return nil, err
+	}
+	x, err := p.parse()
+	if err != nil {
+		return nil, err
+	}
+	return p.asCondition(x)
+}
+
+var conditionFieldPat = regexp.MustCompile(`^\w+$`)
@@ -0,0 +1,191 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/flwyd/adif-multitool/adif"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestWhere(t *testing.T) {
+	adi := adif.NewADIIO()
+	csv := adif.NewCSVIO()
+	file1 := `QSO_DATE,TIME_ON,CALL,BAND,FREQ,MODE,TX_PWR,POTA_REF,COMMENT
+20190101,1301,K1A/P,160m,1.810,SSB,10,K-0001,Nice park
+20200202,1402,W2B/MM,80m,3.502,CW,100,,On a ship
+20210303,0503,VE3CDE,40m,7.203,SSB,5,"K-0034,K-4556",qrp fun
+20220404,1604,KH6/W4D,20m,14.074,FT8,50,K-4556,
+20230505,2305,N5E,20m,14.250,SSB,1500,,it's loud
+`
+	tests := []struct {
+		expr string
+		want []string
+	}{
+		{expr: "mode=SSB", want: []string{"K1A/P", "VE3CDE", "N5E"}},
+		{expr: "mode = cw OR mode = ft8", want: []string{"W2B/MM", "KH6/W4D"}},
+		{expr: "(mode=CW OR mode=SSB) AND NOT band=20m", want: []string{"K1A/P", "W2B/MM", "VE3CDE"}},
+		{expr: "mode=SSB and (tx_pwr<=5 or tx_pwr>1000)", want: []string{"VE3CDE", "N5E"}},
+		{expr: "not (mode=SSB or mode=CW)", want: []string{"KH6/W4D"}},
+		{expr: "NOT NOT mode=CW", want: []string{"W2B/MM"}},
+		{expr: "mode=CW|FT8", want: []string{"W2B/MM", "KH6/W4D"}},
+		{expr: "mode!=SSB|CW", want: []string{"KH6/W4D"}},
+		{expr: "freq < 10 AND freq >= 3.5", want: []string{"W2B/MM", "VE3CDE"}},
+		{expr: "year(qso_date) = 2021", want: []string{"VE3CDE"}},
+		{expr: "year(qso_date) >= 2021 AND month(qso_date) < 5", want: []string{"VE3CDE", "KH6/W4D"}},
+		{expr: "day(qso_date)=5", want: []string{"N5E"}},
+		{expr: "hour(time_on) < 6 OR hour(time_on) >= 23", want: []string{"VE3CDE", "N5E"}},
+		{expr: "minute(time_on) = 2", want: []string{"W2B/MM"}},
+		{expr: "len(call) <= 6", want: []string{"K1A/P", "W2B/MM", "VE3CDE", "N5E"}},
+		{expr: "len(call) > len(mode)", want: []string{"K1A/P", "W2B/MM", "VE3CDE", "KH6/W4D"}},
+		{expr: "len(pota_ref) = 0", want: []string{"W2B/MM", "N5E"}},
+		{expr: "upper(comment) *= 'QRP'", want: []string{"VE3CDE"}},
+		{expr: "lower(call) = 'n5e'", want: []string{"N5E"}},
+		{expr: `comment = "it's loud"`, want: []string{"N5E"}},
+		{expr: `comment = 'it\'s loud'`, want: []string{"N5E"}},
+		{expr: "call=*/P OR call=*/MM", want: []string{"K1A/P", "W2B/MM"}},
+		{expr: `call ~ "/(P|MM)$"`, want: []string{"K1A/P", "W2B/MM"}},
+		{expr: `call ~ '^[a-z]{2}\d'`, want: []string{"VE3CDE", "KH6/W4D"}},
+		{expr: "call ^= K AND call $= /W4D", want: []string{"KH6/W4D"}},
+		{expr: "pota_ref *= k-4556", want: []string{"VE3CDE", "KH6/W4D"}},
+		{expr: "pota_ref =", want: []string{"W2B/MM", "N5E"}},
+		{expr: "comment= AND mode=FT8", want: []string{"KH6/W4D"}},
+		{expr: "pota_ref != ''", want: []string{"K1A/P", "VE3CDE", "KH6/W4D"}},
+		{expr: "tx_pwr > {freq}", want: []string{"K1A/P", "W2B/MM", "KH6/W4D", "N5E"}},
+		{expr: "{tx_pwr} < 20", want: []string{"K1A/P", "VE3CDE"}},
+		{expr: "substr(call, 1, 2) = KH OR trim(comment) = 'Nice park'", want: []string{"K1A/P", "KH6/W4D"}},
+		{expr: "concat(call, '-', mode) = N5E-SSB", want: []string{"N5E"}},
+		{expr: "upper(call) = upper(lower(call)) AND len(call) < len(comment)", want: []string{"K1A/P", "W2B/MM", "VE3CDE", "N5E"}},
+		{expr: "round(call) > 2 OR mode=CW", want: []string{"W2B/MM"}},
+		{expr: "NOT round(call) > 2", want: []string{}},
+		{expr: "NOT (round(call) > 2 AND mode=CW) AND band=20m", want: []string{}},
+		{expr: "NOT NOT round(call) > 2", want: []string{}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.expr, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			cond := ConditionValue{}
+			if err := cond.WhereFlag().Set(tc.expr); err != nil {
+				t.Fatalf("WhereFlag().Set(%q) got error %v", tc.expr, err)
+			}
+			ctx := &Context{
+				OutputFormat: adif.FormatADI,
+				Readers:      readers(adi, csv),
+				Writers:      writers(adi, csv),
+				Out:          out,
+				fs:           fakeFilesystem{map[string]string{"foo.csv": file1}},
+				CommandCtx:   &FindContext{Cond: cond},
+			}
+			if err := Find.Run(ctx, []string{"foo.csv"}); err != nil {
+				t.Fatalf("Find.Run(ctx, foo.csv) got error %v", err)
+			}
+			gotlog, err := adi.Read(out)
+			if err != nil {
+				t.Fatalf("Find output could not be parsed: %v", err)
+			}
+			got := make([]string, len(gotlog.Records))
+			for i, r := range gotlog.Records {
+				call, _ := r.Get("CALL")
+				got[i] = call.Value
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("--where %q parsed as %v got diff\n%s", tc.expr, cond.Get(), diff)
+			}
+		})
+	}
+}
+
+func TestWhereWithIf(t *testing.T) {
+	cond := ConditionValue{}
+	// --where applies to all --or-if groups: (band=20m OR call=W1AW) AND (mode=SSB OR mode=CW)
+	if err := errorsJoin(cond.IfFlag().Set("band=20m"), cond.WhereFlag().Set("mode=SSB OR mode=CW"), cond.OrIfFlag().Set("call=W1AW")); err != nil {
+		t.Fatalf("error setting conditions: %v", err)
+	}
+	c := cond.Get()
+	for _, tc := range []struct {
+		fields []adif.Field
+		want   bool
+	}{
+		{fields: []adif.Field{{Name: "BAND", Value: "20m"}, {Name: "MODE", Value: "SSB"}}, want: true},
+		{fields: []adif.Field{{Name: "BAND", Value: "20m"}, {Name: "MODE", Value: "FT8"}}, want: false},
+		{fields: []adif.Field{{Name: "BAND", Value: "40m"}, {Name: "MODE", Value: "CW"}}, want: false},
+		{fields: []adif.Field{{Name: "BAND", Value: "40m"}, {Name: "CALL", Value: "W1AW"}}, want: false},
+		{fields: []adif.Field{{Name: "BAND", Value: "40m"}, {Name: "CALL", Value: "W1AW"}, {Name: "MODE", Value: "CW"}}, want: true},
+		{fields: []adif.Field{{Name: "BAND", Value: "20m"}, {Name: "CALL", Value: "W1AW"}, {Name: "MODE", Value: "FT8"}}, want: false},
+	} {
+		r := adif.NewRecord(tc.fields...)
+		if got := c.Evaluate(recordEvalContext{record: r}); got != tc.want {
+			t.Errorf("%v Evaluate(%v) got %v, want %v", c, r, got, tc.want)
+		}
+	}
+}
+
+func TestWhereOnly(t *testing.T) {
+	cond := ConditionValue{}
+	if err := errorsJoin(cond.WhereFlag().Set("mode=CW"), cond.WhereFlag().Set("band=20m")); err != nil {
+		t.Fatalf("error setting conditions: %v", err)
+	}
+	c := cond.Get()
+	for _, tc := range []struct {
+		fields []adif.Field
+		want   bool
+	}{
+		{fields: []adif.Field{{Name: "BAND", Value: "20m"}, {Name: "MODE", Value: "CW"}}, want: true},
+		{fields: []adif.Field{{Name: "BAND", Value: "20m"}, {Name: "MODE", Value: "SSB"}}, want: false},
+		{fields: []adif.Field{{Name: "BAND", Value: "40m"}, {Name: "MODE", Value: "CW"}}, want: false},
+	} {
+		r := adif.NewRecord(tc.fields...)
+		if got := c.Evaluate(recordEvalContext{record: r}); got != tc.want {
+			t.Errorf("%v Evaluate(%v) got %v, want %v", c, r, got, tc.want)
+		}
+	}
+}
+
+func TestWhereErrors(t *testing.T) {
+	for _, s := range []string{
+		"",
+		"mode",
+		"mode=CW AND",
+		"mode=CW OR OR band=20m",
+		"(mode=CW",
+		"mode=CW)",
+		"mode=CW band=20m",
+		"'mode'=CW",
+		"foo(mode)=CW",
+		"len(mode=CW",
+		"len()=3",
+		"mode=CW AND NOT",
+		"call~'(K|W'",
+		"call~{mode}",
+		"call*=",
+		"call<''",
+		"{call=W1AW",
+		"{}=W1AW",
+		"comment='unterminated",
+		"mode=CW; band=20m",
+		"mode=CW, band=20m",
+		"len(call) > 3 AND len(call)",
+		"NOT call",
+		"K-1=mode",
+		"substr(call)=K",
+	} {
+		cond := ConditionValue{}
+		if err := cond.WhereFlag().Set(s); err == nil {
+			t.Errorf("WhereFlag().Set(%q) got no error, parsed as %v", s, cond.Get())
+		}
+	}
+}